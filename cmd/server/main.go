@@ -5,12 +5,21 @@ import (
 	"github.com/supermario64bit/whatsapp_connect/config"
 	"github.com/supermario64bit/whatsapp_connect/migrations"
 	"github.com/supermario64bit/whatsapp_connect/server/routes"
+	"github.com/supermario64bit/whatsapp_connect/server/service"
 )
 
 func main() {
 	config.LoadEnvFile()
 	migrations.Run()
 
+	go service.NewMessageJobService().Run()
+	go service.NewCampaignService().Run()
+	go service.NewWebhookDeliveryService().Run()
+	go service.NewOutboxService().Run()
+	go service.NewContactImportService().Run()
+	go service.NewInboundReplyJobService().Run()
+	go service.NewInboundMediaJobService().Run()
+
 	r := gin.Default()
 	routes.MountHTTPRoutes(r)
 	r.Run()
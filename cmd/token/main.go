@@ -0,0 +1,45 @@
+// Issues a bearer token for a user, signed with AUTH_TOKEN_SECRET from .env. Meant for operators
+// and local development; clients get theirs from the identity provider.
+//
+//	go run ./cmd/token -user 1 -ttl 1h
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/supermario64bit/whatsapp_connect/config"
+	"github.com/supermario64bit/whatsapp_connect/pkg/authtoken"
+)
+
+func main() {
+	userID := flag.Uint64("user", 0, "id of the user the token identifies")
+	ttl := flag.Duration("ttl", time.Hour, "how long the token stays valid")
+	flag.Parse()
+
+	if *userID == 0 || *ttl <= 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	config.LoadEnvFile()
+
+	secret := []byte(os.Getenv("AUTH_TOKEN_SECRET"))
+	if len(secret) < 32 {
+		fmt.Fprintln(os.Stderr, "AUTH_TOKEN_SECRET must be set to at least 32 bytes")
+		os.Exit(1)
+	}
+
+	token, err := authtoken.Sign(secret, authtoken.Claims{
+		UserID:    *userID,
+		ExpiresAt: time.Now().Add(*ttl).Unix(),
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	fmt.Println(token)
+}
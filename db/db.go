@@ -4,8 +4,9 @@ import (
 	"database/sql"
 	"fmt"
 	"os"
+	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"github.com/supermario64bit/whatsapp_connect/pkg/logger"
 )
 
@@ -16,15 +17,7 @@ func New() *sql.DB {
 		return db
 	}
 	var err error
-	dsn := fmt.Sprintf(
-		"postgres://%s:%s@%s:%s/%s?sslmode=disable",
-		os.Getenv("DB_USER"),
-		os.Getenv("DB_PASSWORD"),
-		os.Getenv("DB_HOST"),
-		os.Getenv("DB_PORT"),
-		os.Getenv("DB_NAME"),
-	)
-	db, err = sql.Open("postgres", dsn)
+	db, err = sql.Open("postgres", dsn())
 
 	if err != nil {
 		logger.HighlightedDanger("Unable to connect to db. Error: " + err.Error())
@@ -33,3 +26,32 @@ func New() *sql.DB {
 
 	return db
 }
+
+// Opens a dedicated connection listening for notifications on the given channel. The
+// connection is re-established whenever it drops.
+func NewListener(channel string) (*pq.Listener, error) {
+	listener := pq.NewListener(dsn(), 10*time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			logger.Warning("Listener on " + channel + " lost its connection. Error: " + err.Error())
+		}
+	})
+
+	err := listener.Listen(channel)
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	return listener, nil
+}
+
+func dsn() string {
+	return fmt.Sprintf(
+		"postgres://%s:%s@%s:%s/%s?sslmode=disable",
+		os.Getenv("DB_USER"),
+		os.Getenv("DB_PASSWORD"),
+		os.Getenv("DB_HOST"),
+		os.Getenv("DB_PORT"),
+		os.Getenv("DB_NAME"),
+	)
+}
@@ -0,0 +1,38 @@
+ALTER TABLE organisations ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+
+CREATE OR REPLACE FUNCTION increment_version()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.version = OLD.version + 1;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1
+        FROM pg_trigger
+        WHERE tgname = 'handle_organisation_version'
+        AND tgrelid = 'organisations'::regclass
+    ) THEN
+        CREATE TRIGGER handle_organisation_version
+        BEFORE UPDATE ON organisations
+        FOR EACH ROW
+        EXECUTE FUNCTION increment_version();
+    END IF;
+
+    IF NOT EXISTS (
+        SELECT 1
+        FROM pg_trigger
+        WHERE tgname = 'handle_user_version'
+        AND tgrelid = 'users'::regclass
+    ) THEN
+        CREATE TRIGGER handle_user_version
+        BEFORE UPDATE ON users
+        FOR EACH ROW
+        EXECUTE FUNCTION increment_version();
+    END IF;
+END
+$$;
@@ -0,0 +1,6 @@
+ALTER TABLE organisations ALTER COLUMN contact_number TYPE VARCHAR(16);
+ALTER TABLE users ALTER COLUMN mobile_number TYPE VARCHAR(16);
+
+-- Numbers stored before E.164 support were all 10 digit Indian local numbers
+UPDATE organisations SET contact_number = '+91' || contact_number WHERE contact_number ~ '^[0-9]{10}$';
+UPDATE users SET mobile_number = '+91' || mobile_number WHERE mobile_number ~ '^[0-9]{10}$';
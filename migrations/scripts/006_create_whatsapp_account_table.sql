@@ -0,0 +1,52 @@
+CREATE TABLE IF NOT EXISTS whatsapp_accounts (
+    id SERIAL PRIMARY KEY,
+    organisation_id INTEGER NOT NULL REFERENCES organisations (id),
+    name VARCHAR(100) NOT NULL,
+    business_account_id VARCHAR(50) NOT NULL,
+    phone_number_id VARCHAR(50) NOT NULL,
+    display_phone_number VARCHAR(16) NOT NULL,
+    access_token TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    deleted_at TIMESTAMPTZ,
+    version INTEGER NOT NULL DEFAULT 1,
+    CONSTRAINT status_check CHECK (status IN ('active', 'inactive'))
+);
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_indexes
+        WHERE schemaname = 'public' AND indexname = 'unique_phone_number_id_not_deleted'
+    ) THEN
+        CREATE UNIQUE INDEX unique_phone_number_id_not_deleted
+        ON whatsapp_accounts (phone_number_id) WHERE deleted_at is NULL;
+    END IF;
+
+    IF NOT EXISTS (
+        SELECT 1
+        FROM pg_trigger
+        WHERE tgname = 'handle_whatsapp_account_updated_at'
+        AND tgrelid = 'whatsapp_accounts'::regclass
+    ) THEN
+        CREATE TRIGGER handle_whatsapp_account_updated_at
+        BEFORE UPDATE ON whatsapp_accounts
+        FOR EACH ROW
+        EXECUTE FUNCTION set_updated_at();
+    END IF;
+
+    IF NOT EXISTS (
+        SELECT 1
+        FROM pg_trigger
+        WHERE tgname = 'handle_whatsapp_account_version'
+        AND tgrelid = 'whatsapp_accounts'::regclass
+    ) THEN
+        CREATE TRIGGER handle_whatsapp_account_version
+        BEFORE UPDATE ON whatsapp_accounts
+        FOR EACH ROW
+        EXECUTE FUNCTION increment_version();
+    END IF;
+END
+$$;
@@ -0,0 +1,60 @@
+CREATE TABLE IF NOT EXISTS contacts (
+    id SERIAL PRIMARY KEY,
+    organisation_id INTEGER NOT NULL REFERENCES organisations (id),
+    phone_number VARCHAR(16) NOT NULL,
+    name VARCHAR(100) NOT NULL DEFAULT '',
+    attributes JSONB NOT NULL DEFAULT '{}'::jsonb,
+    tags TEXT[] NOT NULL DEFAULT '{}',
+    opt_in_status VARCHAR(20) NOT NULL DEFAULT 'unknown',
+    last_seen_at TIMESTAMPTZ,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    deleted_at TIMESTAMPTZ,
+    version INTEGER NOT NULL DEFAULT 1,
+    CONSTRAINT opt_in_status_check CHECK (opt_in_status IN ('unknown', 'opted_in', 'opted_out'))
+);
+
+DO $$
+BEGIN
+    -- 009 moves the tags into their own tables and drops the column, so skip the index on later runs
+    IF EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_schema = 'public' AND table_name = 'contacts' AND column_name = 'tags'
+    ) THEN
+        CREATE INDEX IF NOT EXISTS contacts_tags_idx ON contacts USING GIN (tags);
+    END IF;
+
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_indexes
+        WHERE schemaname = 'public' AND indexname = 'unique_contact_phone_number_not_deleted'
+    ) THEN
+        CREATE UNIQUE INDEX unique_contact_phone_number_not_deleted
+        ON contacts (organisation_id, phone_number) WHERE deleted_at is NULL;
+    END IF;
+
+    IF NOT EXISTS (
+        SELECT 1
+        FROM pg_trigger
+        WHERE tgname = 'handle_contact_updated_at'
+        AND tgrelid = 'contacts'::regclass
+    ) THEN
+        CREATE TRIGGER handle_contact_updated_at
+        BEFORE UPDATE ON contacts
+        FOR EACH ROW
+        EXECUTE FUNCTION set_updated_at();
+    END IF;
+
+    IF NOT EXISTS (
+        SELECT 1
+        FROM pg_trigger
+        WHERE tgname = 'handle_contact_version'
+        AND tgrelid = 'contacts'::regclass
+    ) THEN
+        CREATE TRIGGER handle_contact_version
+        BEFORE UPDATE ON contacts
+        FOR EACH ROW
+        EXECUTE FUNCTION increment_version();
+    END IF;
+END
+$$;
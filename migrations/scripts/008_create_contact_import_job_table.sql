@@ -0,0 +1,38 @@
+CREATE TABLE IF NOT EXISTS contact_import_jobs (
+    id SERIAL PRIMARY KEY,
+    organisation_id INTEGER NOT NULL REFERENCES organisations (id),
+    status VARCHAR(20) NOT NULL,
+    dry_run BOOLEAN NOT NULL DEFAULT FALSE,
+    file_name VARCHAR(255) NOT NULL,
+    mapping JSONB NOT NULL DEFAULT '{}'::jsonb,
+    total_rows INTEGER NOT NULL DEFAULT 0,
+    processed_rows INTEGER NOT NULL DEFAULT 0,
+    created_count INTEGER NOT NULL DEFAULT 0,
+    updated_count INTEGER NOT NULL DEFAULT 0,
+    skipped_count INTEGER NOT NULL DEFAULT 0,
+    failed_count INTEGER NOT NULL DEFAULT 0,
+    errors JSONB NOT NULL DEFAULT '[]'::jsonb,
+    created_by INTEGER,
+    started_at TIMESTAMPTZ,
+    finished_at TIMESTAMPTZ,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT status_check CHECK (status IN ('pending', 'running', 'completed', 'failed'))
+);
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1
+        FROM pg_trigger
+        WHERE tgname = 'handle_contact_import_job_updated_at'
+        AND tgrelid = 'contact_import_jobs'::regclass
+    ) THEN
+        CREATE TRIGGER handle_contact_import_job_updated_at
+        BEFORE UPDATE ON contact_import_jobs
+        FOR EACH ROW
+        EXECUTE FUNCTION set_updated_at();
+    END IF;
+END
+$$;
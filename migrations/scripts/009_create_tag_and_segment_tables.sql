@@ -0,0 +1,101 @@
+CREATE TABLE IF NOT EXISTS tags (
+    id SERIAL PRIMARY KEY,
+    organisation_id INTEGER NOT NULL REFERENCES organisations (id),
+    name VARCHAR(50) NOT NULL,
+    color VARCHAR(7) NOT NULL DEFAULT '',
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT unique_tag_name UNIQUE (organisation_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS contact_tags (
+    contact_id INTEGER NOT NULL REFERENCES contacts (id) ON DELETE CASCADE,
+    tag_id INTEGER NOT NULL REFERENCES tags (id) ON DELETE CASCADE,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (contact_id, tag_id)
+);
+
+CREATE INDEX IF NOT EXISTS contact_tags_tag_id_idx ON contact_tags (tag_id);
+
+CREATE TABLE IF NOT EXISTS segments (
+    id SERIAL PRIMARY KEY,
+    organisation_id INTEGER NOT NULL REFERENCES organisations (id),
+    name VARCHAR(100) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    rules JSONB NOT NULL,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    deleted_at TIMESTAMPTZ,
+    version INTEGER NOT NULL DEFAULT 1
+);
+
+DO $$
+BEGIN
+    -- Contacts used to keep their tags in a TEXT[] column. Move them into the tag tables.
+    IF EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_schema = 'public' AND table_name = 'contacts' AND column_name = 'tags'
+    ) THEN
+        INSERT INTO tags (organisation_id, name)
+        SELECT DISTINCT c.organisation_id, t.name
+        FROM contacts c CROSS JOIN LATERAL unnest(c.tags) AS t(name)
+        ON CONFLICT (organisation_id, name) DO NOTHING;
+
+        INSERT INTO contact_tags (contact_id, tag_id)
+        SELECT c.id, tags.id
+        FROM contacts c CROSS JOIN LATERAL unnest(c.tags) AS t(name)
+        JOIN tags ON tags.organisation_id = c.organisation_id AND tags.name = t.name
+        ON CONFLICT DO NOTHING;
+
+        DROP INDEX IF EXISTS contacts_tags_idx;
+        ALTER TABLE contacts DROP COLUMN tags;
+    END IF;
+
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_indexes
+        WHERE schemaname = 'public' AND indexname = 'unique_segment_name_not_deleted'
+    ) THEN
+        CREATE UNIQUE INDEX unique_segment_name_not_deleted
+        ON segments (organisation_id, name) WHERE deleted_at is NULL;
+    END IF;
+
+    IF NOT EXISTS (
+        SELECT 1
+        FROM pg_trigger
+        WHERE tgname = 'handle_tag_updated_at'
+        AND tgrelid = 'tags'::regclass
+    ) THEN
+        CREATE TRIGGER handle_tag_updated_at
+        BEFORE UPDATE ON tags
+        FOR EACH ROW
+        EXECUTE FUNCTION set_updated_at();
+    END IF;
+
+    IF NOT EXISTS (
+        SELECT 1
+        FROM pg_trigger
+        WHERE tgname = 'handle_segment_updated_at'
+        AND tgrelid = 'segments'::regclass
+    ) THEN
+        CREATE TRIGGER handle_segment_updated_at
+        BEFORE UPDATE ON segments
+        FOR EACH ROW
+        EXECUTE FUNCTION set_updated_at();
+    END IF;
+
+    IF NOT EXISTS (
+        SELECT 1
+        FROM pg_trigger
+        WHERE tgname = 'handle_segment_version'
+        AND tgrelid = 'segments'::regclass
+    ) THEN
+        CREATE TRIGGER handle_segment_version
+        BEFORE UPDATE ON segments
+        FOR EACH ROW
+        EXECUTE FUNCTION increment_version();
+    END IF;
+END
+$$;
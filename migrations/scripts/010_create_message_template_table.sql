@@ -0,0 +1,56 @@
+CREATE TABLE IF NOT EXISTS message_templates (
+    id SERIAL PRIMARY KEY,
+    organisation_id INTEGER NOT NULL REFERENCES organisations (id),
+    whatsapp_account_id INTEGER NOT NULL REFERENCES whatsapp_accounts (id),
+    external_id VARCHAR(50) NOT NULL,
+    name VARCHAR(512) NOT NULL,
+    language VARCHAR(15) NOT NULL,
+    category VARCHAR(20) NOT NULL,
+    components JSONB NOT NULL DEFAULT '[]'::jsonb,
+    status VARCHAR(20) NOT NULL,
+    rejection_reason TEXT NOT NULL DEFAULT '',
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    deleted_at TIMESTAMPTZ,
+    version INTEGER NOT NULL DEFAULT 1,
+    CONSTRAINT template_category_check CHECK (category IN ('marketing', 'utility', 'authentication'))
+);
+
+CREATE INDEX IF NOT EXISTS message_templates_external_id_idx ON message_templates (external_id);
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_indexes
+        WHERE schemaname = 'public' AND indexname = 'unique_template_name_language_not_deleted'
+    ) THEN
+        CREATE UNIQUE INDEX unique_template_name_language_not_deleted
+        ON message_templates (whatsapp_account_id, name, language) WHERE deleted_at is NULL;
+    END IF;
+
+    IF NOT EXISTS (
+        SELECT 1
+        FROM pg_trigger
+        WHERE tgname = 'handle_message_template_updated_at'
+        AND tgrelid = 'message_templates'::regclass
+    ) THEN
+        CREATE TRIGGER handle_message_template_updated_at
+        BEFORE UPDATE ON message_templates
+        FOR EACH ROW
+        EXECUTE FUNCTION set_updated_at();
+    END IF;
+
+    IF NOT EXISTS (
+        SELECT 1
+        FROM pg_trigger
+        WHERE tgname = 'handle_message_template_version'
+        AND tgrelid = 'message_templates'::regclass
+    ) THEN
+        CREATE TRIGGER handle_message_template_version
+        BEFORE UPDATE ON message_templates
+        FOR EACH ROW
+        EXECUTE FUNCTION increment_version();
+    END IF;
+END
+$$;
@@ -0,0 +1,45 @@
+CREATE TABLE IF NOT EXISTS messages (
+    id SERIAL PRIMARY KEY,
+    organisation_id INTEGER NOT NULL REFERENCES organisations (id),
+    whatsapp_account_id INTEGER NOT NULL REFERENCES whatsapp_accounts (id),
+    contact_id INTEGER NOT NULL REFERENCES contacts (id),
+    direction VARCHAR(10) NOT NULL,
+    wamid VARCHAR(128),
+    type VARCHAR(20) NOT NULL,
+    template_id INTEGER REFERENCES message_templates (id),
+    body TEXT NOT NULL DEFAULT '',
+    content JSONB NOT NULL DEFAULT '{}'::jsonb,
+    status VARCHAR(20) NOT NULL,
+    error_code VARCHAR(20) NOT NULL DEFAULT '',
+    error_message TEXT NOT NULL DEFAULT '',
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT direction_check CHECK (direction IN ('inbound', 'outbound'))
+);
+
+CREATE INDEX IF NOT EXISTS messages_contact_id_idx ON messages (contact_id, created_at);
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_indexes
+        WHERE schemaname = 'public' AND indexname = 'unique_message_wamid'
+    ) THEN
+        CREATE UNIQUE INDEX unique_message_wamid
+        ON messages (wamid) WHERE wamid IS NOT NULL;
+    END IF;
+
+    IF NOT EXISTS (
+        SELECT 1
+        FROM pg_trigger
+        WHERE tgname = 'handle_message_updated_at'
+        AND tgrelid = 'messages'::regclass
+    ) THEN
+        CREATE TRIGGER handle_message_updated_at
+        BEFORE UPDATE ON messages
+        FOR EACH ROW
+        EXECUTE FUNCTION set_updated_at();
+    END IF;
+END
+$$;
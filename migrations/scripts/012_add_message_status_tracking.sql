@@ -0,0 +1,29 @@
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS queued_at TIMESTAMPTZ;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS sent_at TIMESTAMPTZ;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS delivered_at TIMESTAMPTZ;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS read_at TIMESTAMPTZ;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS failed_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS messages_organisation_id_idx ON messages (organisation_id, created_at);
+
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_schema = 'public' AND table_name = 'messages' AND column_name = 'content'
+    ) THEN
+        ALTER TABLE messages RENAME COLUMN content TO payload;
+    END IF;
+
+    -- Messages Meta accepted used to be stored as accepted, which is now the queued state
+    UPDATE messages SET status = 'queued', queued_at = COALESCE(queued_at, created_at) WHERE status = 'accepted';
+
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_constraint
+        WHERE conname = 'message_status_check' AND conrelid = 'messages'::regclass
+    ) THEN
+        ALTER TABLE messages ADD CONSTRAINT message_status_check
+        CHECK (status IN ('queued', 'sent', 'delivered', 'read', 'failed', 'received'));
+    END IF;
+END
+$$;
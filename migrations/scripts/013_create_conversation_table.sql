@@ -0,0 +1,59 @@
+CREATE TABLE IF NOT EXISTS conversations (
+    id SERIAL PRIMARY KEY,
+    organisation_id INTEGER NOT NULL REFERENCES organisations (id),
+    whatsapp_account_id INTEGER NOT NULL REFERENCES whatsapp_accounts (id),
+    contact_id INTEGER NOT NULL REFERENCES contacts (id),
+    last_message_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    -- Free-form messages may be sent until 24 hours after the customer last wrote
+    last_inbound_at TIMESTAMPTZ,
+    window_expires_at TIMESTAMPTZ,
+
+    -- Billing conversation Meta reported in the latest status callback
+    external_id VARCHAR(128) NOT NULL DEFAULT '',
+    category VARCHAR(30) NOT NULL DEFAULT '',
+    pricing_model VARCHAR(20) NOT NULL DEFAULT '',
+    billable BOOLEAN NOT NULL DEFAULT FALSE,
+    expires_at TIMESTAMPTZ,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT unique_conversation_contact UNIQUE (whatsapp_account_id, contact_id)
+);
+
+CREATE INDEX IF NOT EXISTS conversations_organisation_id_idx ON conversations (organisation_id, last_message_at);
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS conversation_id INTEGER REFERENCES conversations (id);
+
+CREATE INDEX IF NOT EXISTS messages_conversation_id_idx ON messages (conversation_id, created_at);
+
+-- Messages stored before conversations existed are grouped into theirs
+INSERT INTO conversations (organisation_id, whatsapp_account_id, contact_id, last_message_at, last_inbound_at, window_expires_at)
+SELECT organisation_id, whatsapp_account_id, contact_id, MAX(created_at),
+    MAX(created_at) FILTER (WHERE direction = 'inbound'),
+    MAX(created_at) FILTER (WHERE direction = 'inbound') + INTERVAL '24 hours'
+FROM messages
+WHERE conversation_id IS NULL
+GROUP BY organisation_id, whatsapp_account_id, contact_id
+ON CONFLICT (whatsapp_account_id, contact_id) DO NOTHING;
+
+UPDATE messages SET conversation_id = conversations.id
+FROM conversations
+WHERE messages.conversation_id IS NULL
+AND conversations.whatsapp_account_id = messages.whatsapp_account_id
+AND conversations.contact_id = messages.contact_id;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1
+        FROM pg_trigger
+        WHERE tgname = 'handle_conversation_updated_at'
+        AND tgrelid = 'conversations'::regclass
+    ) THEN
+        CREATE TRIGGER handle_conversation_updated_at
+        BEFORE UPDATE ON conversations
+        FOR EACH ROW
+        EXECUTE FUNCTION set_updated_at();
+    END IF;
+END
+$$;
@@ -0,0 +1,54 @@
+CREATE TABLE IF NOT EXISTS organisation_members (
+    organisation_id INTEGER NOT NULL REFERENCES organisations (id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (organisation_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS organisation_members_user_id_idx ON organisation_members (user_id);
+
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'open';
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS assignee_id INTEGER REFERENCES users (id);
+-- Inbound messages since an agent last read the conversation
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS unread_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE conversations ADD COLUMN IF NOT EXISTS last_read_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS conversations_status_idx ON conversations (organisation_id, status, last_message_at);
+
+CREATE TABLE IF NOT EXISTS conversation_notes (
+    id SERIAL PRIMARY KEY,
+    organisation_id INTEGER NOT NULL REFERENCES organisations (id),
+    conversation_id INTEGER NOT NULL REFERENCES conversations (id) ON DELETE CASCADE,
+    author_id INTEGER NOT NULL REFERENCES users (id),
+    body TEXT NOT NULL,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS conversation_notes_conversation_id_idx ON conversation_notes (conversation_id, created_at);
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_constraint
+        WHERE conname = 'conversation_status_check' AND conrelid = 'conversations'::regclass
+    ) THEN
+        ALTER TABLE conversations ADD CONSTRAINT conversation_status_check
+        CHECK (status IN ('open', 'pending', 'resolved'));
+    END IF;
+
+    IF NOT EXISTS (
+        SELECT 1
+        FROM pg_trigger
+        WHERE tgname = 'handle_conversation_note_updated_at'
+        AND tgrelid = 'conversation_notes'::regclass
+    ) THEN
+        CREATE TRIGGER handle_conversation_note_updated_at
+        BEFORE UPDATE ON conversation_notes
+        FOR EACH ROW
+        EXECUTE FUNCTION set_updated_at();
+    END IF;
+END
+$$;
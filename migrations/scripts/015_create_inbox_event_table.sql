@@ -0,0 +1,13 @@
+-- Realtime inbox events, kept for a day so reconnecting clients can resume where they left off
+CREATE TABLE IF NOT EXISTS inbox_events (
+    id BIGSERIAL PRIMARY KEY,
+    organisation_id INTEGER NOT NULL REFERENCES organisations (id) ON DELETE CASCADE,
+    type VARCHAR(50) NOT NULL,
+    conversation_id INTEGER REFERENCES conversations (id) ON DELETE CASCADE,
+    payload JSONB NOT NULL DEFAULT '{}'::jsonb,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS inbox_events_organisation_id_idx ON inbox_events (organisation_id, id);
+CREATE INDEX IF NOT EXISTS inbox_events_created_at_idx ON inbox_events (created_at);
@@ -0,0 +1,39 @@
+CREATE TABLE IF NOT EXISTS media (
+    id SERIAL PRIMARY KEY,
+    organisation_id INTEGER NOT NULL REFERENCES organisations (id),
+    whatsapp_account_id INTEGER NOT NULL REFERENCES whatsapp_accounts (id),
+    -- Media id assigned by WhatsApp
+    external_id VARCHAR(128) NOT NULL,
+    direction VARCHAR(10) NOT NULL,
+    type VARCHAR(20) NOT NULL,
+    filename VARCHAR(255) NOT NULL DEFAULT '',
+    mime_type VARCHAR(100) NOT NULL,
+    size BIGINT NOT NULL,
+    sha256 VARCHAR(64) NOT NULL DEFAULT '',
+    storage_key VARCHAR(512) NOT NULL,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT unique_media_external_id UNIQUE (whatsapp_account_id, external_id),
+    CONSTRAINT media_direction_check CHECK (direction IN ('inbound', 'outbound'))
+);
+
+CREATE INDEX IF NOT EXISTS media_storage_key_idx ON media (storage_key);
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS media_id INTEGER REFERENCES media (id);
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1
+        FROM pg_trigger
+        WHERE tgname = 'handle_media_updated_at'
+        AND tgrelid = 'media'::regclass
+    ) THEN
+        CREATE TRIGGER handle_media_updated_at
+        BEFORE UPDATE ON media
+        FOR EACH ROW
+        EXECUTE FUNCTION set_updated_at();
+    END IF;
+END
+$$;
@@ -0,0 +1,70 @@
+CREATE TABLE IF NOT EXISTS campaigns (
+    id SERIAL PRIMARY KEY,
+    organisation_id INTEGER NOT NULL REFERENCES organisations (id),
+    whatsapp_account_id INTEGER NOT NULL REFERENCES whatsapp_accounts (id),
+    segment_id INTEGER NOT NULL REFERENCES segments (id),
+    template_id INTEGER NOT NULL REFERENCES message_templates (id),
+    name VARCHAR(100) NOT NULL,
+    -- Template parameters, each a fixed value or a contact field filled per recipient
+    template JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'draft',
+    timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    scheduled_at TIMESTAMPTZ,
+    started_at TIMESTAMPTZ,
+    completed_at TIMESTAMPTZ,
+    total_recipients INTEGER NOT NULL DEFAULT 0,
+    -- Set by the worker sending the campaign, so a campaign whose worker died is picked up again
+    locked_until TIMESTAMPTZ,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT campaign_status_check CHECK (status IN ('draft', 'scheduled', 'running', 'completed', 'cancelled'))
+);
+
+CREATE INDEX IF NOT EXISTS campaigns_organisation_id_idx ON campaigns (organisation_id, created_at);
+
+CREATE INDEX IF NOT EXISTS campaigns_due_idx ON campaigns (scheduled_at) WHERE status IN ('scheduled', 'running');
+
+CREATE TABLE IF NOT EXISTS campaign_recipients (
+    id SERIAL PRIMARY KEY,
+    campaign_id INTEGER NOT NULL REFERENCES campaigns (id) ON DELETE CASCADE,
+    contact_id INTEGER NOT NULL REFERENCES contacts (id),
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    message_id INTEGER REFERENCES messages (id),
+    error_message TEXT NOT NULL DEFAULT '',
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT unique_campaign_recipient UNIQUE (campaign_id, contact_id),
+    CONSTRAINT campaign_recipient_status_check CHECK (status IN ('pending', 'sent', 'failed'))
+);
+
+CREATE INDEX IF NOT EXISTS campaign_recipients_message_id_idx ON campaign_recipients (message_id);
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1
+        FROM pg_trigger
+        WHERE tgname = 'handle_campaign_updated_at'
+        AND tgrelid = 'campaigns'::regclass
+    ) THEN
+        CREATE TRIGGER handle_campaign_updated_at
+        BEFORE UPDATE ON campaigns
+        FOR EACH ROW
+        EXECUTE FUNCTION set_updated_at();
+    END IF;
+
+    IF NOT EXISTS (
+        SELECT 1
+        FROM pg_trigger
+        WHERE tgname = 'handle_campaign_recipient_updated_at'
+        AND tgrelid = 'campaign_recipients'::regclass
+    ) THEN
+        CREATE TRIGGER handle_campaign_recipient_updated_at
+        BEFORE UPDATE ON campaign_recipients
+        FOR EACH ROW
+        EXECUTE FUNCTION set_updated_at();
+    END IF;
+END
+$$;
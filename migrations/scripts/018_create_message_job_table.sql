@@ -0,0 +1,52 @@
+CREATE TABLE IF NOT EXISTS message_jobs (
+    id SERIAL PRIMARY KEY,
+    organisation_id INTEGER NOT NULL REFERENCES organisations (id),
+    whatsapp_account_id INTEGER NOT NULL REFERENCES whatsapp_accounts (id),
+    message_id INTEGER NOT NULL REFERENCES messages (id) ON DELETE CASCADE,
+    -- Message as it is posted to the Graph API
+    payload JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL DEFAULT 8,
+    run_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    -- Set while a worker sends the message, so a job whose worker died is picked up again
+    locked_until TIMESTAMPTZ,
+    last_error TEXT NOT NULL DEFAULT '',
+    completed_at TIMESTAMPTZ,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT unique_message_job_message UNIQUE (message_id),
+    CONSTRAINT message_job_status_check CHECK (status IN ('pending', 'running', 'succeeded', 'dead'))
+);
+
+CREATE INDEX IF NOT EXISTS message_jobs_due_idx ON message_jobs (run_at) WHERE status IN ('pending', 'running');
+
+CREATE INDEX IF NOT EXISTS message_jobs_organisation_id_idx ON message_jobs (organisation_id, status, created_at);
+
+DO $$
+BEGIN
+    -- Messages waiting in the send queue are pending until Meta accepts them
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_constraint
+        WHERE conname = 'message_status_check' AND conrelid = 'messages'::regclass
+        AND pg_get_constraintdef(oid) LIKE '%pending%'
+    ) THEN
+        ALTER TABLE messages DROP CONSTRAINT IF EXISTS message_status_check;
+        ALTER TABLE messages ADD CONSTRAINT message_status_check
+        CHECK (status IN ('pending', 'queued', 'sent', 'delivered', 'read', 'failed', 'received'));
+    END IF;
+
+    IF NOT EXISTS (
+        SELECT 1
+        FROM pg_trigger
+        WHERE tgname = 'handle_message_job_updated_at'
+        AND tgrelid = 'message_jobs'::regclass
+    ) THEN
+        CREATE TRIGGER handle_message_job_updated_at
+        BEFORE UPDATE ON message_jobs
+        FOR EACH ROW
+        EXECUTE FUNCTION set_updated_at();
+    END IF;
+END
+$$;
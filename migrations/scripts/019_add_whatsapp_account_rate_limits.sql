@@ -0,0 +1,35 @@
+-- Throughput the phone number is allowed by Meta, and the tier capping the unique customers it
+-- may start conversations with in a rolling 24 hours
+ALTER TABLE whatsapp_accounts ADD COLUMN IF NOT EXISTS messages_per_second INTEGER NOT NULL DEFAULT 80;
+ALTER TABLE whatsapp_accounts ADD COLUMN IF NOT EXISTS messaging_tier VARCHAR(20) NOT NULL DEFAULT 'TIER_1K';
+
+-- Token bucket of each account, refilled at messages_per_second
+CREATE TABLE IF NOT EXISTS whatsapp_account_throughput (
+    whatsapp_account_id INTEGER PRIMARY KEY REFERENCES whatsapp_accounts (id) ON DELETE CASCADE,
+    tokens DOUBLE PRECISION NOT NULL,
+    refilled_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Messages an account sent recently, counted against its limits
+CREATE INDEX IF NOT EXISTS messages_account_queued_at_idx ON messages (whatsapp_account_id, queued_at)
+WHERE direction = 'outbound';
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_constraint
+        WHERE conname = 'whatsapp_account_messages_per_second_check' AND conrelid = 'whatsapp_accounts'::regclass
+    ) THEN
+        ALTER TABLE whatsapp_accounts ADD CONSTRAINT whatsapp_account_messages_per_second_check
+        CHECK (messages_per_second > 0);
+    END IF;
+
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_constraint
+        WHERE conname = 'whatsapp_account_messaging_tier_check' AND conrelid = 'whatsapp_accounts'::regclass
+    ) THEN
+        ALTER TABLE whatsapp_accounts ADD CONSTRAINT whatsapp_account_messaging_tier_check
+        CHECK (messaging_tier IN ('TIER_250', 'TIER_1K', 'TIER_10K', 'TIER_100K', 'TIER_UNLIMITED'));
+    END IF;
+END
+$$;
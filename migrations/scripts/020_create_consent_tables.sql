@@ -0,0 +1,51 @@
+-- History of the consent contacts gave or withdrew. The latest record matches the contact's
+-- opt_in_status.
+CREATE TABLE IF NOT EXISTS contact_consents (
+    id SERIAL PRIMARY KEY,
+    organisation_id INTEGER NOT NULL REFERENCES organisations (id),
+    contact_id INTEGER NOT NULL REFERENCES contacts (id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL,
+    source VARCHAR(20) NOT NULL,
+    -- How the consent was obtained, such as the form it was given on or the message received
+    evidence TEXT NOT NULL DEFAULT '',
+    -- Inbound message the consent was taken from
+    message_id INTEGER REFERENCES messages (id) ON DELETE SET NULL,
+    actor_id INTEGER,
+    -- When the contact gave or withdrew consent, which may precede the record
+    recorded_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT contact_consent_status_check CHECK (status IN ('unknown', 'opted_in', 'opted_out')),
+    CONSTRAINT contact_consent_source_check CHECK (source IN ('api', 'import', 'keyword', 'website', 'whatsapp', 'in_person', 'other'))
+);
+
+CREATE INDEX IF NOT EXISTS contact_consents_contact_id_idx ON contact_consents (contact_id, recorded_at);
+
+-- Inbound messages which opt a contact in or out. Organisations without any use the defaults.
+CREATE TABLE IF NOT EXISTS consent_keywords (
+    id SERIAL PRIMARY KEY,
+    organisation_id INTEGER NOT NULL REFERENCES organisations (id),
+    keyword VARCHAR(50) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT unique_consent_keyword UNIQUE (organisation_id, keyword),
+    CONSTRAINT consent_keyword_status_check CHECK (status IN ('opted_in', 'opted_out'))
+);
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1
+        FROM pg_trigger
+        WHERE tgname = 'handle_consent_keyword_updated_at'
+        AND tgrelid = 'consent_keywords'::regclass
+    ) THEN
+        CREATE TRIGGER handle_consent_keyword_updated_at
+        BEFORE UPDATE ON consent_keywords
+        FOR EACH ROW
+        EXECUTE FUNCTION set_updated_at();
+    END IF;
+END
+$$;
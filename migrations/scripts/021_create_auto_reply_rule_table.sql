@@ -0,0 +1,50 @@
+CREATE TABLE IF NOT EXISTS auto_reply_rules (
+    id SERIAL PRIMARY KEY,
+    organisation_id INTEGER NOT NULL REFERENCES organisations (id),
+    -- Left empty, the rule answers messages to every account of the organisation
+    whatsapp_account_id INTEGER REFERENCES whatsapp_accounts (id),
+    name VARCHAR(100) NOT NULL,
+    match_type VARCHAR(20) NOT NULL,
+    pattern VARCHAR(500) NOT NULL,
+    priority INTEGER NOT NULL DEFAULT 0,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    windows JSONB NOT NULL DEFAULT '[]'::jsonb,
+    response JSONB NOT NULL,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    deleted_at TIMESTAMPTZ,
+    version INTEGER NOT NULL DEFAULT 1,
+    CONSTRAINT auto_reply_match_type_check CHECK (match_type IN ('exact', 'contains', 'regex'))
+);
+
+CREATE INDEX IF NOT EXISTS auto_reply_rules_organisation_id_idx ON auto_reply_rules (organisation_id, priority DESC) WHERE deleted_at IS NULL;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1
+        FROM pg_trigger
+        WHERE tgname = 'handle_auto_reply_rule_updated_at'
+        AND tgrelid = 'auto_reply_rules'::regclass
+    ) THEN
+        CREATE TRIGGER handle_auto_reply_rule_updated_at
+        BEFORE UPDATE ON auto_reply_rules
+        FOR EACH ROW
+        EXECUTE FUNCTION set_updated_at();
+    END IF;
+
+    IF NOT EXISTS (
+        SELECT 1
+        FROM pg_trigger
+        WHERE tgname = 'handle_auto_reply_rule_version'
+        AND tgrelid = 'auto_reply_rules'::regclass
+    ) THEN
+        CREATE TRIGGER handle_auto_reply_rule_version
+        BEFORE UPDATE ON auto_reply_rules
+        FOR EACH ROW
+        EXECUTE FUNCTION increment_version();
+    END IF;
+END
+$$;
@@ -0,0 +1,85 @@
+CREATE TABLE IF NOT EXISTS flows (
+    id SERIAL PRIMARY KEY,
+    organisation_id INTEGER NOT NULL REFERENCES organisations (id),
+    -- Left empty, the flow runs for messages to every account of the organisation
+    whatsapp_account_id INTEGER REFERENCES whatsapp_accounts (id),
+    name VARCHAR(100) NOT NULL,
+    -- Inbound messages matching the trigger start the flow
+    match_type VARCHAR(20) NOT NULL,
+    pattern VARCHAR(500) NOT NULL,
+    priority INTEGER NOT NULL DEFAULT 0,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    definition JSONB NOT NULL,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    deleted_at TIMESTAMPTZ,
+    version INTEGER NOT NULL DEFAULT 1,
+    CONSTRAINT flow_match_type_check CHECK (match_type IN ('exact', 'contains', 'regex'))
+);
+
+CREATE INDEX IF NOT EXISTS flows_organisation_id_idx ON flows (organisation_id, priority DESC) WHERE deleted_at IS NULL;
+
+-- Where a conversation is in a flow, along with the answers collected so far
+CREATE TABLE IF NOT EXISTS flow_sessions (
+    id SERIAL PRIMARY KEY,
+    organisation_id INTEGER NOT NULL REFERENCES organisations (id),
+    flow_id INTEGER NOT NULL REFERENCES flows (id),
+    whatsapp_account_id INTEGER NOT NULL REFERENCES whatsapp_accounts (id),
+    conversation_id INTEGER NOT NULL REFERENCES conversations (id),
+    contact_id INTEGER NOT NULL REFERENCES contacts (id),
+    node_id VARCHAR(100) NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL DEFAULT 'active',
+    variables JSONB NOT NULL DEFAULT '{}'::jsonb,
+    retries INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    ended_at TIMESTAMPTZ,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT flow_session_status_check CHECK (status IN ('active', 'completed', 'handed_over', 'failed', 'expired'))
+);
+
+-- A conversation is in at most one flow at a time
+CREATE UNIQUE INDEX IF NOT EXISTS flow_sessions_active_conversation_idx ON flow_sessions (conversation_id) WHERE status = 'active';
+CREATE INDEX IF NOT EXISTS flow_sessions_flow_id_idx ON flow_sessions (flow_id, created_at DESC);
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1
+        FROM pg_trigger
+        WHERE tgname = 'handle_flow_updated_at'
+        AND tgrelid = 'flows'::regclass
+    ) THEN
+        CREATE TRIGGER handle_flow_updated_at
+        BEFORE UPDATE ON flows
+        FOR EACH ROW
+        EXECUTE FUNCTION set_updated_at();
+    END IF;
+
+    IF NOT EXISTS (
+        SELECT 1
+        FROM pg_trigger
+        WHERE tgname = 'handle_flow_version'
+        AND tgrelid = 'flows'::regclass
+    ) THEN
+        CREATE TRIGGER handle_flow_version
+        BEFORE UPDATE ON flows
+        FOR EACH ROW
+        EXECUTE FUNCTION increment_version();
+    END IF;
+
+    IF NOT EXISTS (
+        SELECT 1
+        FROM pg_trigger
+        WHERE tgname = 'handle_flow_session_updated_at'
+        AND tgrelid = 'flow_sessions'::regclass
+    ) THEN
+        CREATE TRIGGER handle_flow_session_updated_at
+        BEFORE UPDATE ON flow_sessions
+        FOR EACH ROW
+        EXECUTE FUNCTION set_updated_at();
+    END IF;
+END
+$$;
@@ -0,0 +1,48 @@
+CREATE TABLE IF NOT EXISTS business_hours (
+    organisation_id INTEGER PRIMARY KEY REFERENCES organisations (id),
+    timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    windows JSONB NOT NULL DEFAULT '[]'::jsonb,
+    holidays JSONB NOT NULL DEFAULT '[]'::jsonb,
+    away_message JSONB NOT NULL,
+    greeting_message JSONB NOT NULL,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    version INTEGER NOT NULL DEFAULT 1
+);
+
+-- When each contact last got each automatic message, so it is not sent again too soon
+CREATE TABLE IF NOT EXISTS auto_message_deliveries (
+    contact_id INTEGER NOT NULL REFERENCES contacts (id),
+    kind VARCHAR(20) NOT NULL,
+    sent_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (contact_id, kind)
+);
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1
+        FROM pg_trigger
+        WHERE tgname = 'handle_business_hours_updated_at'
+        AND tgrelid = 'business_hours'::regclass
+    ) THEN
+        CREATE TRIGGER handle_business_hours_updated_at
+        BEFORE UPDATE ON business_hours
+        FOR EACH ROW
+        EXECUTE FUNCTION set_updated_at();
+    END IF;
+
+    IF NOT EXISTS (
+        SELECT 1
+        FROM pg_trigger
+        WHERE tgname = 'handle_business_hours_version'
+        AND tgrelid = 'business_hours'::regclass
+    ) THEN
+        CREATE TRIGGER handle_business_hours_version
+        BEFORE UPDATE ON business_hours
+        FOR EACH ROW
+        EXECUTE FUNCTION increment_version();
+    END IF;
+END
+$$;
@@ -0,0 +1,92 @@
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+    id SERIAL PRIMARY KEY,
+    organisation_id INTEGER NOT NULL REFERENCES organisations (id),
+    url VARCHAR(2000) NOT NULL,
+    -- Key the payload signatures are made with
+    secret VARCHAR(200) NOT NULL,
+    event_types TEXT[] NOT NULL DEFAULT '{}',
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    -- Failed attempts since the last successful one. Too many disable the subscription.
+    consecutive_failures INTEGER NOT NULL DEFAULT 0,
+    disabled_reason TEXT NOT NULL DEFAULT '',
+    disabled_at TIMESTAMPTZ,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    deleted_at TIMESTAMPTZ,
+    version INTEGER NOT NULL DEFAULT 1
+);
+
+CREATE INDEX IF NOT EXISTS webhook_subscriptions_organisation_id_idx ON webhook_subscriptions (organisation_id) WHERE deleted_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id SERIAL PRIMARY KEY,
+    organisation_id INTEGER NOT NULL REFERENCES organisations (id),
+    subscription_id INTEGER NOT NULL REFERENCES webhook_subscriptions (id),
+    -- Shared by every delivery of the same event, including redeliveries, so receivers can
+    -- drop duplicates
+    event_id VARCHAR(64) NOT NULL,
+    event_type VARCHAR(50) NOT NULL,
+    -- Body as it is posted to the subscription's URL
+    payload JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL DEFAULT 8,
+    run_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    -- Set while a worker posts the payload, so a delivery whose worker died is picked up again
+    locked_until TIMESTAMPTZ,
+    -- Outcome of the latest attempt
+    response_status INTEGER,
+    response_body TEXT NOT NULL DEFAULT '',
+    duration_ms INTEGER,
+    last_error TEXT NOT NULL DEFAULT '',
+    completed_at TIMESTAMPTZ,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT webhook_delivery_status_check CHECK (status IN ('pending', 'running', 'succeeded', 'dead'))
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx ON webhook_deliveries (run_at) WHERE status IN ('pending', 'running');
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_subscription_id_idx ON webhook_deliveries (subscription_id, created_at);
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1
+        FROM pg_trigger
+        WHERE tgname = 'handle_webhook_subscription_updated_at'
+        AND tgrelid = 'webhook_subscriptions'::regclass
+    ) THEN
+        CREATE TRIGGER handle_webhook_subscription_updated_at
+        BEFORE UPDATE ON webhook_subscriptions
+        FOR EACH ROW
+        EXECUTE FUNCTION set_updated_at();
+    END IF;
+
+    IF NOT EXISTS (
+        SELECT 1
+        FROM pg_trigger
+        WHERE tgname = 'handle_webhook_subscription_version'
+        AND tgrelid = 'webhook_subscriptions'::regclass
+    ) THEN
+        CREATE TRIGGER handle_webhook_subscription_version
+        BEFORE UPDATE ON webhook_subscriptions
+        FOR EACH ROW
+        EXECUTE FUNCTION increment_version();
+    END IF;
+
+    IF NOT EXISTS (
+        SELECT 1
+        FROM pg_trigger
+        WHERE tgname = 'handle_webhook_delivery_updated_at'
+        AND tgrelid = 'webhook_deliveries'::regclass
+    ) THEN
+        CREATE TRIGGER handle_webhook_delivery_updated_at
+        BEFORE UPDATE ON webhook_deliveries
+        FOR EACH ROW
+        EXECUTE FUNCTION set_updated_at();
+    END IF;
+END
+$$;
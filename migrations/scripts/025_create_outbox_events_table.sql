@@ -0,0 +1,43 @@
+CREATE TABLE IF NOT EXISTS outbox_events (
+    id BIGSERIAL PRIMARY KEY,
+    -- Entity the event is about
+    aggregate_type VARCHAR(50) NOT NULL,
+    aggregate_id INTEGER NOT NULL,
+    organisation_id INTEGER,
+    actor_id INTEGER,
+    event_type VARCHAR(100) NOT NULL,
+    payload JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    -- Subscribers and brokers which handled the event already, so retries skip them
+    delivered_to TEXT[] NOT NULL DEFAULT '{}',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    run_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    -- Set while the relay publishes the event, so an event whose relay died is picked up again
+    locked_until TIMESTAMPTZ,
+    last_error TEXT NOT NULL DEFAULT '',
+    published_at TIMESTAMPTZ,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT outbox_event_status_check CHECK (status IN ('pending', 'running', 'published'))
+);
+
+CREATE INDEX IF NOT EXISTS outbox_events_due_idx ON outbox_events (run_at, id) WHERE status IN ('pending', 'running');
+
+CREATE INDEX IF NOT EXISTS outbox_events_published_at_idx ON outbox_events (published_at) WHERE status = 'published';
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1
+        FROM pg_trigger
+        WHERE tgname = 'handle_outbox_event_updated_at'
+        AND tgrelid = 'outbox_events'::regclass
+    ) THEN
+        CREATE TRIGGER handle_outbox_event_updated_at
+        BEFORE UPDATE ON outbox_events
+        FOR EACH ROW
+        EXECUTE FUNCTION set_updated_at();
+    END IF;
+END
+$$;
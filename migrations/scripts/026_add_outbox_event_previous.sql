@@ -0,0 +1,2 @@
+-- Entity as it was before the change, for update and delete events
+ALTER TABLE outbox_events ADD COLUMN IF NOT EXISTS previous JSONB;
@@ -0,0 +1,10 @@
+-- Organisation routes are open to members only. Make the creators of organisations which have
+-- no members yet their members, so existing organisations stay reachable.
+INSERT INTO organisation_members (organisation_id, user_id)
+SELECT a.entity_id, a.actor_id
+FROM audit_log a
+JOIN organisations o ON o.id = a.entity_id AND o.deleted_at IS NULL
+JOIN users u ON u.id = a.actor_id
+WHERE a.entity_type = 'organisation' AND a.action = 'create'
+AND NOT EXISTS (SELECT 1 FROM organisation_members m WHERE m.organisation_id = a.entity_id)
+ON CONFLICT DO NOTHING;
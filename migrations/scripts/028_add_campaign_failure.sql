@@ -0,0 +1,17 @@
+-- Campaigns which cannot be sent, as when their segment was deleted, end up failed with the reason
+ALTER TABLE campaigns ADD COLUMN IF NOT EXISTS failure_reason TEXT NOT NULL DEFAULT '';
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_constraint
+        WHERE conname = 'campaign_status_check'
+        AND conrelid = 'campaigns'::regclass
+        AND pg_get_constraintdef(oid) LIKE '%''failed''%'
+    ) THEN
+        ALTER TABLE campaigns DROP CONSTRAINT IF EXISTS campaign_status_check;
+        ALTER TABLE campaigns ADD CONSTRAINT campaign_status_check
+        CHECK (status IN ('draft', 'scheduled', 'running', 'completed', 'cancelled', 'failed'));
+    END IF;
+END
+$$;
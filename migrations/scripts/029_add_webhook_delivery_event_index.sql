@@ -0,0 +1,2 @@
+-- Events relayed from the outbox may come more than once, and are queued once per subscription
+CREATE INDEX IF NOT EXISTS webhook_deliveries_subscription_event_idx ON webhook_deliveries (subscription_id, event_id);
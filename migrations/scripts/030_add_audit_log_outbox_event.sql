@@ -0,0 +1,4 @@
+-- Entries written by the outbox relay name their event, so an event relayed twice is audited once
+ALTER TABLE audit_log ADD COLUMN IF NOT EXISTS outbox_event_id BIGINT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS audit_log_outbox_event_idx ON audit_log (outbox_event_id);
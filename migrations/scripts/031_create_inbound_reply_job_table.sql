@@ -0,0 +1,42 @@
+-- Inbound messages waiting to be answered by a flow, greeting, away message or auto reply. Jobs
+-- are stored with their message, so a message is answered even when the server stops first.
+CREATE TABLE IF NOT EXISTS inbound_reply_jobs (
+    id SERIAL PRIMARY KEY,
+    organisation_id INTEGER NOT NULL REFERENCES organisations (id),
+    message_id INTEGER NOT NULL REFERENCES messages (id) ON DELETE CASCADE,
+    -- Messages of a conversation are answered one at a time, in the order they came
+    conversation_id INTEGER NOT NULL REFERENCES conversations (id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL DEFAULT 5,
+    run_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    -- Set while a worker answers the message, so a job whose worker died is picked up again
+    locked_until TIMESTAMPTZ,
+    last_error TEXT NOT NULL DEFAULT '',
+    completed_at TIMESTAMPTZ,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT unique_inbound_reply_job_message UNIQUE (message_id),
+    CONSTRAINT inbound_reply_job_status_check CHECK (status IN ('pending', 'running', 'succeeded', 'dead'))
+);
+
+CREATE INDEX IF NOT EXISTS inbound_reply_jobs_due_idx ON inbound_reply_jobs (run_at) WHERE status IN ('pending', 'running');
+
+CREATE INDEX IF NOT EXISTS inbound_reply_jobs_conversation_idx ON inbound_reply_jobs (conversation_id, id) WHERE status IN ('pending', 'running');
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1
+        FROM pg_trigger
+        WHERE tgname = 'handle_inbound_reply_job_updated_at'
+        AND tgrelid = 'inbound_reply_jobs'::regclass
+    ) THEN
+        CREATE TRIGGER handle_inbound_reply_job_updated_at
+        BEFORE UPDATE ON inbound_reply_jobs
+        FOR EACH ROW
+        EXECUTE FUNCTION set_updated_at();
+    END IF;
+END
+$$;
@@ -0,0 +1,41 @@
+-- Media of inbound messages waiting to be downloaded into our storage. Jobs are stored with
+-- their message, so the media is fetched even when the server stops first.
+CREATE TABLE IF NOT EXISTS inbound_media_jobs (
+    id SERIAL PRIMARY KEY,
+    organisation_id INTEGER NOT NULL REFERENCES organisations (id),
+    whatsapp_account_id INTEGER NOT NULL REFERENCES whatsapp_accounts (id),
+    message_id INTEGER NOT NULL REFERENCES messages (id) ON DELETE CASCADE,
+    -- Media as Meta notified it
+    payload JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL DEFAULT 8,
+    run_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    -- Set while a worker downloads the media, so a job whose worker died is picked up again
+    locked_until TIMESTAMPTZ,
+    last_error TEXT NOT NULL DEFAULT '',
+    completed_at TIMESTAMPTZ,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT unique_inbound_media_job_message UNIQUE (message_id),
+    CONSTRAINT inbound_media_job_status_check CHECK (status IN ('pending', 'running', 'succeeded', 'dead'))
+);
+
+CREATE INDEX IF NOT EXISTS inbound_media_jobs_due_idx ON inbound_media_jobs (run_at) WHERE status IN ('pending', 'running');
+
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1
+        FROM pg_trigger
+        WHERE tgname = 'handle_inbound_media_job_updated_at'
+        AND tgrelid = 'inbound_media_jobs'::regclass
+    ) THEN
+        CREATE TRIGGER handle_inbound_media_job_updated_at
+        BEFORE UPDATE ON inbound_media_jobs
+        FOR EACH ROW
+        EXECUTE FUNCTION set_updated_at();
+    END IF;
+END
+$$;
@@ -0,0 +1,82 @@
+package mergepatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"sort"
+)
+
+const ContentType = "application/merge-patch+json"
+
+var ErrNotObject = errors.New("Merge patch document must be a JSON object")
+
+// Applies an RFC 7396 merge patch to the original document. Members set to null in the
+// patch are removed from the result, objects are merged recursively and anything else replaces
+// the original value.
+func Apply(original []byte, patch []byte) ([]byte, error) {
+	target, err := decode(original)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := decode(patch)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(merge(target, p))
+}
+
+// Returns the sorted top level member names of a patch, including the ones set to null
+func Fields(patch []byte) ([]string, error) {
+	p, err := decode(patch)
+	if err != nil {
+		return nil, err
+	}
+
+	obj, ok := p.(map[string]interface{})
+	if !ok {
+		return nil, ErrNotObject
+	}
+
+	fields := make([]string, 0, len(obj))
+	for field := range obj {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	return fields, nil
+}
+
+func merge(target interface{}, patch interface{}) interface{} {
+	patchObj, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObj, ok := target.(map[string]interface{})
+	if !ok {
+		targetObj = map[string]interface{}{}
+	}
+
+	for field, value := range patchObj {
+		if value == nil {
+			delete(targetObj, field)
+			continue
+		}
+		targetObj[field] = merge(targetObj[field], value)
+	}
+
+	return targetObj
+}
+
+// Decodes numbers as json.Number so large integer IDs survive the round trip
+func decode(doc []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(doc))
+	decoder.UseNumber()
+
+	var v interface{}
+	err := decoder.Decode(&v)
+	return v, err
+}
@@ -0,0 +1,102 @@
+package mergepatch
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestApply(t *testing.T) {
+	// Test cases from RFC 7396 appendix A, plus large integers
+	tests := []struct {
+		original string
+		patch    string
+		want     string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+		{`{"id":9007199254740993}`, `{"name":"x"}`, `{"id":9007199254740993,"name":"x"}`},
+	}
+
+	for _, tt := range tests {
+		got, err := Apply([]byte(tt.original), []byte(tt.patch))
+		if err != nil {
+			t.Errorf("Apply(%s, %s) returned error: %v", tt.original, tt.patch, err)
+			continue
+		}
+		if !jsonEqual(t, got, []byte(tt.want)) {
+			t.Errorf("Apply(%s, %s) = %s, want %s", tt.original, tt.patch, got, tt.want)
+		}
+	}
+}
+
+func TestApplyInvalidJSON(t *testing.T) {
+	tests := []struct {
+		original string
+		patch    string
+	}{
+		{`{"a":`, `{}`},
+		{`{}`, `{"a":`},
+		{`{}`, ``},
+	}
+
+	for _, tt := range tests {
+		_, err := Apply([]byte(tt.original), []byte(tt.patch))
+		if err == nil {
+			t.Errorf("Apply(%q, %q) returned no error", tt.original, tt.patch)
+		}
+	}
+}
+
+func TestFields(t *testing.T) {
+	tests := []struct {
+		patch   string
+		want    []string
+		wantErr error
+	}{
+		{`{}`, []string{}, nil},
+		{`{"b":1,"a":null}`, []string{"a", "b"}, nil},
+		{`{"a":{"c":1}}`, []string{"a"}, nil},
+		{`["a"]`, nil, ErrNotObject},
+		{`null`, nil, ErrNotObject},
+		{`"a"`, nil, ErrNotObject},
+	}
+
+	for _, tt := range tests {
+		got, err := Fields([]byte(tt.patch))
+		if err != tt.wantErr {
+			t.Errorf("Fields(%s) error = %v, want %v", tt.patch, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr == nil && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Fields(%s) = %v, want %v", tt.patch, got, tt.want)
+		}
+	}
+}
+
+// Compares documents by value, so member order does not matter
+func jsonEqual(t *testing.T, a []byte, b []byte) bool {
+	t.Helper()
+
+	x, err := decode(a)
+	if err != nil {
+		t.Fatalf("invalid JSON %s: %v", a, err)
+	}
+	y, err := decode(b)
+	if err != nil {
+		t.Fatalf("invalid JSON %s: %v", b, err)
+	}
+	return reflect.DeepEqual(x, y)
+}
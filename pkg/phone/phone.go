@@ -0,0 +1,166 @@
+package phone
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	// E.164 caps a number at 15 digits including the calling code
+	maxDigits         = 15
+	minNationalDigits = 4
+	defaultRegion     = "IN"
+)
+
+var (
+	ErrEmpty         = errors.New("Phone number is empty")
+	ErrInvalidChars  = errors.New("Phone number may only contain digits, spaces, dashes, dots, brackets and a leading +")
+	ErrUnknownRegion = errors.New("Unknown default region for national phone numbers")
+	ErrInvalidLength = errors.New("Phone number has an invalid length")
+)
+
+type Number struct {
+	Region      string
+	CallingCode string
+	National    string
+}
+
+// Formats the number as E.164, e.g. +919876543210
+func (n Number) E164() string {
+	return "+" + n.CallingCode + n.National
+}
+
+func (n Number) String() string {
+	return n.E164()
+}
+
+// Region used to interpret numbers written without a calling code. Read from DEFAULT_PHONE_REGION.
+func DefaultRegion() string {
+	region := strings.ToUpper(strings.TrimSpace(os.Getenv("DEFAULT_PHONE_REGION")))
+	if region == "" {
+		return defaultRegion
+	}
+	return region
+}
+
+// Parses international (+44 20 7946 0000, 0044...) and national (020 7946 0000) notations.
+// National numbers are read in the numbering plan of defaultRegion.
+func Parse(raw string, defaultRegion string) (Number, error) {
+	digits, international, err := clean(raw)
+	if err != nil {
+		return Number{}, err
+	}
+
+	if international {
+		return parseInternational(digits)
+	}
+
+	r, ok := regions[strings.ToUpper(defaultRegion)]
+	if !ok {
+		return Number{}, ErrUnknownRegion
+	}
+
+	national := digits
+	// Numbers written with the calling code but without the +, e.g. 919876543210
+	if strings.HasPrefix(national, r.CallingCode) && validLength(r, len(national)-len(r.CallingCode)) && !validLength(r, len(national)) {
+		national = national[len(r.CallingCode):]
+	}
+	if r.TrunkPrefix != "" && strings.HasPrefix(national, r.TrunkPrefix) && !validLength(r, len(national)) {
+		national = national[len(r.TrunkPrefix):]
+	}
+
+	if !validLength(r, len(national)) {
+		return Number{}, fmt.Errorf("%w for region %s", ErrInvalidLength, strings.ToUpper(defaultRegion))
+	}
+
+	return Number{Region: strings.ToUpper(defaultRegion), CallingCode: r.CallingCode, National: national}, nil
+}
+
+// Parses raw in the default region and formats it as E.164
+func Normalise(raw string) (string, error) {
+	number, err := Parse(raw, DefaultRegion())
+	if err != nil {
+		return "", err
+	}
+	return number.E164(), nil
+}
+
+func IsValid(raw string) bool {
+	_, err := Parse(raw, DefaultRegion())
+	return err == nil
+}
+
+func parseInternational(digits string) (Number, error) {
+	if len(digits) > maxDigits {
+		return Number{}, ErrInvalidLength
+	}
+
+	// Calling codes are prefix free and one to three digits long
+	for size := 1; size <= 3 && size < len(digits); size++ {
+		name, r, ok := regionForCallingCode(digits[:size])
+		if !ok {
+			continue
+		}
+
+		national := digits[size:]
+		if !validLength(r, len(national)) {
+			return Number{}, fmt.Errorf("%w for region %s", ErrInvalidLength, name)
+		}
+		return Number{Region: name, CallingCode: r.CallingCode, National: national}, nil
+	}
+
+	// Unlisted calling code. Assume the shortest plausible one and only check the overall length.
+	if len(digits) < minNationalDigits+1 {
+		return Number{}, ErrInvalidLength
+	}
+	return Number{CallingCode: digits[:1], National: digits[1:]}, nil
+}
+
+// Strips formatting characters, returning the bare digits and whether the number was written
+// in international notation
+func clean(raw string) (string, bool, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", false, ErrEmpty
+	}
+
+	international := false
+	if strings.HasPrefix(raw, "+") {
+		international = true
+		raw = raw[1:]
+	}
+
+	var digits strings.Builder
+	for _, ch := range raw {
+		switch {
+		case ch >= '0' && ch <= '9':
+			digits.WriteRune(ch)
+		case ch == ' ' || ch == '-' || ch == '.' || ch == '(' || ch == ')':
+		default:
+			return "", false, ErrInvalidChars
+		}
+	}
+
+	result := digits.String()
+	if !international && strings.HasPrefix(result, "00") {
+		international = true
+		result = result[2:]
+	}
+
+	if result == "" {
+		return "", false, ErrEmpty
+	}
+
+	return result, international, nil
+}
+
+func validLength(r region, length int) bool {
+	for _, allowed := range r.Lengths {
+		if allowed == length {
+			return true
+		}
+	}
+	return false
+}
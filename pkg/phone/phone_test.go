@@ -0,0 +1,95 @@
+package phone
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		raw    string
+		region string
+		want   string
+		// Region the number is attributed to, empty for unlisted calling codes
+		wantRegion string
+	}{
+		{"+91 98765 43210", "IN", "+919876543210", "IN"},
+		{"+44 20 7946 0000", "IN", "+442079460000", "GB"},
+		{"0044 20 7946 0000", "IN", "+442079460000", "GB"},
+		{"+1 (415) 555-2671", "IN", "+14155552671", "US"},
+		{"+65 6123 4567", "IN", "+6561234567", "SG"},
+		{"+971 50 123 4567", "IN", "+971501234567", "AE"},
+		{"98765 43210", "IN", "+919876543210", "IN"},
+		{"098765 43210", "IN", "+919876543210", "IN"},
+		{"919876543210", "IN", "+919876543210", "IN"},
+		{"020 7946 0000", "GB", "+442079460000", "GB"},
+		{"020 7946 0000", "gb", "+442079460000", "GB"},
+		{"1 415 555 2671", "US", "+14155552671", "US"},
+		{"415.555.2671", "US", "+14155552671", "US"},
+		{"612 3456 78", "ES", "+34612345678", "ES"},
+		// Unlisted calling codes are only held to the overall length
+		{"+7 912 345 6789", "IN", "+79123456789", ""},
+	}
+
+	for _, tt := range tests {
+		got, err := Parse(tt.raw, tt.region)
+		if err != nil {
+			t.Errorf("Parse(%q, %q) returned error: %v", tt.raw, tt.region, err)
+			continue
+		}
+		if got.E164() != tt.want || got.Region != tt.wantRegion {
+			t.Errorf("Parse(%q, %q) = %s in %q, want %s in %q", tt.raw, tt.region, got, got.Region, tt.want, tt.wantRegion)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		raw     string
+		region  string
+		wantErr error
+	}{
+		{"", "IN", ErrEmpty},
+		{"   ", "IN", ErrEmpty},
+		{"+", "IN", ErrEmpty},
+		{"00", "IN", ErrEmpty},
+		{"+91 98765 4321O", "IN", ErrInvalidChars},
+		{"98765/43210", "IN", ErrInvalidChars},
+		{"+91+9876543210", "IN", ErrInvalidChars},
+		{"98765 43210", "XX", ErrUnknownRegion},
+		{"+91 98765 4321", "IN", ErrInvalidLength},
+		{"+91 98765 432100", "IN", ErrInvalidLength},
+		{"+44 20 7946 000", "IN", ErrInvalidLength},
+		{"9876 5432", "IN", ErrInvalidLength},
+		{"+7 123", "IN", ErrInvalidLength},
+		{"+7 1234 5678 9012 3456", "IN", ErrInvalidLength},
+	}
+
+	for _, tt := range tests {
+		_, err := Parse(tt.raw, tt.region)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("Parse(%q, %q) error = %v, want %v", tt.raw, tt.region, err, tt.wantErr)
+		}
+	}
+}
+
+func TestNormaliseUsesDefaultRegion(t *testing.T) {
+	tests := []struct {
+		env  string
+		raw  string
+		want string
+	}{
+		{"", "98765 43210", "+919876543210"},
+		{"gb", "020 7946 0000", "+442079460000"},
+		{" US ", "(415) 555-2671", "+14155552671"},
+	}
+
+	for _, tt := range tests {
+		t.Setenv("DEFAULT_PHONE_REGION", tt.env)
+
+		got, err := Normalise(tt.raw)
+		if err != nil || got != tt.want {
+			t.Errorf("Normalise(%q) with DEFAULT_PHONE_REGION=%q = %q, %v, want %q", tt.raw, tt.env, got, err, tt.want)
+		}
+	}
+}
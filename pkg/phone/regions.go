@@ -0,0 +1,58 @@
+package phone
+
+type region struct {
+	CallingCode string
+	// Prefix dialled before national numbers within the country, stripped when normalising
+	TrunkPrefix string
+	// Allowed lengths of the national significant number
+	Lengths []int
+}
+
+// Numbering plans of the countries we validate strictly. Numbers under any other calling code
+// are only held to the generic E.164 length rules.
+var regions = map[string]region{
+	"AE": {CallingCode: "971", TrunkPrefix: "0", Lengths: []int{8, 9}},
+	"AU": {CallingCode: "61", TrunkPrefix: "0", Lengths: []int{9}},
+	"BD": {CallingCode: "880", TrunkPrefix: "0", Lengths: []int{10}},
+	"BR": {CallingCode: "55", TrunkPrefix: "0", Lengths: []int{10, 11}},
+	"CA": {CallingCode: "1", TrunkPrefix: "1", Lengths: []int{10}},
+	"DE": {CallingCode: "49", TrunkPrefix: "0", Lengths: []int{10, 11}},
+	"ES": {CallingCode: "34", Lengths: []int{9}},
+	"FR": {CallingCode: "33", TrunkPrefix: "0", Lengths: []int{9}},
+	"GB": {CallingCode: "44", TrunkPrefix: "0", Lengths: []int{10}},
+	"ID": {CallingCode: "62", TrunkPrefix: "0", Lengths: []int{9, 10, 11, 12}},
+	"IN": {CallingCode: "91", TrunkPrefix: "0", Lengths: []int{10}},
+	"IT": {CallingCode: "39", Lengths: []int{9, 10}},
+	"KE": {CallingCode: "254", TrunkPrefix: "0", Lengths: []int{9}},
+	"LK": {CallingCode: "94", TrunkPrefix: "0", Lengths: []int{9}},
+	"MX": {CallingCode: "52", Lengths: []int{10}},
+	"MY": {CallingCode: "60", TrunkPrefix: "0", Lengths: []int{9, 10}},
+	"NG": {CallingCode: "234", TrunkPrefix: "0", Lengths: []int{10}},
+	"NL": {CallingCode: "31", TrunkPrefix: "0", Lengths: []int{9}},
+	"NP": {CallingCode: "977", Lengths: []int{10}},
+	"PH": {CallingCode: "63", TrunkPrefix: "0", Lengths: []int{10}},
+	"PK": {CallingCode: "92", TrunkPrefix: "0", Lengths: []int{10}},
+	"SA": {CallingCode: "966", TrunkPrefix: "0", Lengths: []int{9}},
+	"SG": {CallingCode: "65", Lengths: []int{8}},
+	"US": {CallingCode: "1", TrunkPrefix: "1", Lengths: []int{10}},
+	"ZA": {CallingCode: "27", TrunkPrefix: "0", Lengths: []int{9}},
+}
+
+// Calling codes shared by several regions resolve to the one listed here
+var primaryRegions = map[string]string{
+	"1": "US",
+}
+
+func regionForCallingCode(code string) (string, region, bool) {
+	if name, ok := primaryRegions[code]; ok {
+		return name, regions[name], true
+	}
+
+	for name, r := range regions {
+		if r.CallingCode == code {
+			return name, r, true
+		}
+	}
+
+	return "", region{}, false
+}
@@ -0,0 +1,118 @@
+package safehttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Redirects followed before a request is given up, as the default client does
+const maxRedirects = 10
+
+var (
+	ErrBlockedAddress = errors.New("Address is not publicly routable")
+	ErrInvalidURL     = errors.New("URL must be an absolute http or https URL")
+	ErrInsecureURL    = errors.New("URL must use https")
+)
+
+// Ranges outside the public internet which the net/netip predicates do not cover
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // This network
+	netip.MustParsePrefix("100.64.0.0/10"),   // Carrier-grade NAT, also cloud metadata services
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+	netip.MustParsePrefix("192.0.2.0/24"),    // Documentation
+	netip.MustParsePrefix("198.18.0.0/15"),   // Benchmarking
+	netip.MustParsePrefix("198.51.100.0/24"), // Documentation
+	netip.MustParsePrefix("203.0.113.0/24"),  // Documentation
+	netip.MustParsePrefix("240.0.0.0/4"),     // Reserved and broadcast
+	netip.MustParsePrefix("2001:db8::/32"),   // Documentation
+}
+
+// Returns a client for requests to URLs given by users, such as webhooks. It refuses to
+// connect to loopback, private, link-local and metadata addresses, checking the address each
+// host resolves to as it connects, so redirects and DNS answers cannot reach them either.
+// Setting requireHTTPS refuses plain http, redirects included.
+func NewClient(timeout time.Duration, requireHTTPS bool) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network string, address string, conn syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return fmt.Errorf("%w: %s", ErrBlockedAddress, address)
+			}
+			if Blocked(addrPort.Addr()) {
+				return fmt.Errorf("%w: %s", ErrBlockedAddress, addrPort.Addr())
+			}
+			return nil
+		},
+	}
+
+	transport := &http.Transport{
+		// A proxy would connect on the client's behalf, past the address check
+		Proxy: nil,
+		DialContext: func(ctx context.Context, network string, address string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, address)
+		},
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("Stopped after %d redirects", maxRedirects)
+			}
+			return CheckURL(req.URL.String(), requireHTTPS)
+		},
+	}
+}
+
+// Checks a URL before it is stored or requested. Hosts given as addresses or as localhost are
+// checked here, names are checked by the client once they resolve.
+func CheckURL(rawURL string, requireHTTPS bool) error {
+	target, err := url.Parse(rawURL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Hostname() == "" {
+		return ErrInvalidURL
+	}
+	if requireHTTPS && target.Scheme != "https" {
+		return ErrInsecureURL
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(target.Hostname()), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, host)
+	}
+
+	if addr, err := netip.ParseAddr(host); err == nil && Blocked(addr) {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, addr)
+	}
+	return nil
+}
+
+// Reports whether the address is outside the public internet
+func Blocked(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() || addr.IsMulticast() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() {
+		return true
+	}
+
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
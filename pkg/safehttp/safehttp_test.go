@@ -0,0 +1,85 @@
+package safehttp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestBlocked(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{addr: "127.0.0.1", want: true},
+		{addr: "::1", want: true},
+		{addr: "10.1.2.3", want: true},
+		{addr: "172.16.0.1", want: true},
+		{addr: "192.168.1.1", want: true},
+		{addr: "169.254.169.254", want: true},
+		{addr: "100.100.100.200", want: true},
+		{addr: "0.0.0.0", want: true},
+		{addr: "::", want: true},
+		{addr: "fe80::1", want: true},
+		{addr: "fd00:ec2::254", want: true},
+		{addr: "::ffff:127.0.0.1", want: true},
+		{addr: "::ffff:10.0.0.1", want: true},
+		{addr: "224.0.0.1", want: true},
+		{addr: "255.255.255.255", want: true},
+		{addr: "8.8.8.8", want: false},
+		{addr: "157.240.1.35", want: false},
+		{addr: "2a03:2880:f10c:83:face:b00c:0:25de", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			if got := Blocked(netip.MustParseAddr(tt.addr)); got != tt.want {
+				t.Errorf("Blocked(%s) = %v, want %v", tt.addr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	tests := []struct {
+		url          string
+		requireHTTPS bool
+		want         error
+	}{
+		{url: "https://example.com/hook", want: nil},
+		{url: "http://example.com/hook", want: nil},
+		{url: "http://example.com/hook", requireHTTPS: true, want: ErrInsecureURL},
+		{url: "ftp://example.com/hook", want: ErrInvalidURL},
+		{url: "/hook", want: ErrInvalidURL},
+		{url: "https://localhost:8080/hook", want: ErrBlockedAddress},
+		{url: "https://api.localhost./hook", want: ErrBlockedAddress},
+		{url: "https://127.0.0.1/hook", want: ErrBlockedAddress},
+		{url: "https://[::1]/hook", want: ErrBlockedAddress},
+		{url: "http://169.254.169.254/latest/meta-data", want: ErrBlockedAddress},
+		{url: "https://8.8.8.8/hook", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			err := CheckURL(tt.url, tt.requireHTTPS)
+			if !errors.Is(err, tt.want) || (err != nil && tt.want == nil) {
+				t.Errorf("CheckURL(%q) = %v, want %v", tt.url, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientRefusesBlockedAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("internal"))
+	}))
+	defer server.Close()
+
+	_, err := NewClient(time.Second, false).Get(server.URL)
+	if !errors.Is(err, ErrBlockedAddress) {
+		t.Fatalf("Get(%s) error = %v, want %v", server.URL, err, ErrBlockedAddress)
+	}
+}
@@ -0,0 +1,152 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/supermario64bit/whatsapp_connect/pkg/logger"
+)
+
+// Path under which the server hands out locally stored files
+const LocalFilesPath = "/media/files/"
+
+// Stores files on the local filesystem. Signed URLs point back at this server, which checks
+// the signature before serving the file.
+type LocalStorage struct {
+	root       string
+	publicURL  string
+	signingKey []byte
+}
+
+// Creates a local storage rooted at STORAGE_LOCAL_PATH. STORAGE_PUBLIC_URL is the address
+// clients reach this server on and STORAGE_SIGNING_KEY the secret URLs are signed with.
+func NewLocalStorage() *LocalStorage {
+	root := os.Getenv("STORAGE_LOCAL_PATH")
+	if root == "" {
+		root = "storage"
+	}
+
+	signingKey := []byte(os.Getenv("STORAGE_SIGNING_KEY"))
+	if len(signingKey) == 0 {
+		logger.Warning("STORAGE_SIGNING_KEY is not set. Signed media URLs will not survive a restart.")
+		signingKey = make([]byte, 32)
+		rand.Read(signingKey)
+	}
+
+	return &LocalStorage{
+		root:       root,
+		publicURL:  strings.TrimRight(os.Getenv("STORAGE_PUBLIC_URL"), "/"),
+		signingKey: signingKey,
+	}
+}
+
+// Writes to a temporary file first, so readers never see a partial file
+func (local *LocalStorage) Put(key string, body io.Reader, size int64, contentType string) error {
+	path, err := local.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	written, err := io.Copy(tmp, body)
+	closeErr := tmp.Close()
+	if err != nil {
+		return err
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+	if size >= 0 && written != size {
+		return fmt.Errorf("Expected %d bytes for %s but got %d", size, key, written)
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func (local *LocalStorage) Get(key string) (io.ReadCloser, error) {
+	path, err := local.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return file, err
+}
+
+func (local *LocalStorage) Delete(key string) error {
+	path, err := local.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (local *LocalStorage) SignedURL(key string, expiry time.Duration) (string, error) {
+	if !validKey(key) {
+		return "", fmt.Errorf("Invalid storage key %s", key)
+	}
+
+	expires := strconv.FormatInt(time.Now().Add(expiry).Unix(), 10)
+	query := url.Values{
+		"expires":   {expires},
+		"signature": {local.sign(key, expires)},
+	}
+	return local.publicURL + LocalFilesPath + key + "?" + query.Encode(), nil
+}
+
+// Checks a URL handed out by SignedURL is genuine and has not expired
+func (local *LocalStorage) Verify(key string, expires string, signature string) bool {
+	deadline, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > deadline {
+		return false
+	}
+
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	actual, _ := hex.DecodeString(local.sign(key, expires))
+	return hmac.Equal(expected, actual)
+}
+
+func (local *LocalStorage) sign(key string, expires string) string {
+	mac := hmac.New(sha256.New, local.signingKey)
+	mac.Write([]byte(key + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (local *LocalStorage) path(key string) (string, error) {
+	if !validKey(key) {
+		return "", fmt.Errorf("Invalid storage key %s", key)
+	}
+	return filepath.Join(local.root, filepath.FromSlash(key)), nil
+}
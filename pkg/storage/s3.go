@@ -0,0 +1,288 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/supermario64bit/whatsapp_connect/pkg/logger"
+)
+
+const (
+	amzDateFormat    = "20060102T150405Z"
+	unsignedPayload  = "UNSIGNED-PAYLOAD"
+	sigV4Algorithm   = "AWS4-HMAC-SHA256"
+	maxPresignExpiry = 7 * 24 * time.Hour
+)
+
+// Stores files in a bucket of Amazon S3 or any service speaking its API, such as MinIO.
+// Requests are signed with AWS Signature Version 4.
+type S3Storage struct {
+	endpoint   *url.URL
+	region     string
+	bucket     string
+	accessKey  string
+	secretKey  string
+	pathStyle  bool
+	httpClient *http.Client
+	now        func() time.Time
+}
+
+// Creates an S3 storage from S3_BUCKET, S3_REGION, S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY.
+// S3_ENDPOINT points at other S3 compatible services, which usually also need
+// S3_FORCE_PATH_STYLE=true.
+func NewS3Storage() *S3Storage {
+	region := os.Getenv("S3_REGION")
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	endpoint := os.Getenv("S3_ENDPOINT")
+	if endpoint == "" {
+		endpoint = "https://s3." + region + ".amazonaws.com"
+	}
+
+	parsed, err := url.Parse(strings.TrimRight(endpoint, "/"))
+	if err == nil && (parsed.Scheme == "" || parsed.Host == "") {
+		err = errors.New("S3_ENDPOINT must be an absolute URL")
+	}
+	if err == nil && os.Getenv("S3_BUCKET") == "" {
+		err = errors.New("S3_BUCKET is required by the s3 storage driver")
+	}
+	if err != nil {
+		logger.HighlightedDanger("Invalid S3 storage configuration. Error: " + err.Error())
+		panic(err)
+	}
+
+	pathStyle, _ := strconv.ParseBool(os.Getenv("S3_FORCE_PATH_STYLE"))
+
+	return &S3Storage{
+		endpoint:   parsed,
+		region:     region,
+		bucket:     os.Getenv("S3_BUCKET"),
+		accessKey:  os.Getenv("S3_ACCESS_KEY_ID"),
+		secretKey:  os.Getenv("S3_SECRET_ACCESS_KEY"),
+		pathStyle:  pathStyle,
+		httpClient: &http.Client{Timeout: 5 * time.Minute},
+		now:        time.Now,
+	}
+}
+
+func (s3 *S3Storage) Put(key string, body io.Reader, size int64, contentType string) error {
+	req, err := s3.request(http.MethodPut, key, body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	res, err := s3.do(req)
+	if err != nil {
+		return err
+	}
+	return res.Body.Close()
+}
+
+func (s3 *S3Storage) Get(key string) (io.ReadCloser, error) {
+	req, err := s3.request(http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s3.do(req)
+	if err != nil {
+		return nil, err
+	}
+	return res.Body, nil
+}
+
+func (s3 *S3Storage) Delete(key string) error {
+	req, err := s3.request(http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := s3.do(req)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return res.Body.Close()
+}
+
+// Returns a presigned GET URL. S3 caps their validity at a week.
+func (s3 *S3Storage) SignedURL(key string, expiry time.Duration) (string, error) {
+	if !validKey(key) {
+		return "", fmt.Errorf("Invalid storage key %s", key)
+	}
+	if expiry > maxPresignExpiry {
+		expiry = maxPresignExpiry
+	}
+
+	objectURL := s3.objectURL(key)
+	now := s3.now().UTC()
+	amzDate := now.Format(amzDateFormat)
+	scope := s3.scope(now)
+
+	query := url.Values{
+		"X-Amz-Algorithm":     {sigV4Algorithm},
+		"X-Amz-Credential":    {s3.accessKey + "/" + scope},
+		"X-Amz-Date":          {amzDate},
+		"X-Amz-Expires":       {strconv.FormatInt(int64(expiry/time.Second), 10)},
+		"X-Amz-SignedHeaders": {"host"},
+	}
+
+	canonical := strings.Join([]string{
+		http.MethodGet,
+		objectURL.EscapedPath(),
+		canonicalQuery(query),
+		"host:" + objectURL.Host + "\n",
+		"host",
+		unsignedPayload,
+	}, "\n")
+
+	query.Set("X-Amz-Signature", s3.signature(now, canonical))
+	objectURL.RawQuery = canonicalQuery(query)
+	return objectURL.String(), nil
+}
+
+// Builds a request for the object, signed with the payload left unsigned so bodies can be
+// streamed
+func (s3 *S3Storage) request(method string, key string, body io.Reader) (*http.Request, error) {
+	if !validKey(key) {
+		return nil, fmt.Errorf("Invalid storage key %s", key)
+	}
+
+	req, err := http.NewRequest(method, s3.objectURL(key).String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s3.now().UTC()
+	req.Header.Set("X-Amz-Date", now.Format(amzDateFormat))
+	req.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+
+	signedHeaders := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	canonical := strings.Join([]string{
+		method,
+		req.URL.EscapedPath(),
+		"",
+		"host:" + req.URL.Host + "\n" +
+			"x-amz-content-sha256:" + unsignedPayload + "\n" +
+			"x-amz-date:" + now.Format(amzDateFormat) + "\n",
+		strings.Join(signedHeaders, ";"),
+		unsignedPayload,
+	}, "\n")
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, s3.accessKey, s3.scope(now), strings.Join(signedHeaders, ";"), s3.signature(now, canonical)))
+
+	return req, nil
+}
+
+func (s3 *S3Storage) do(req *http.Request) (*http.Response, error) {
+	res, err := s3.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode == http.StatusNotFound {
+		res.Body.Close()
+		return nil, ErrNotFound
+	}
+	if res.StatusCode >= 300 {
+		raw, _ := io.ReadAll(io.LimitReader(res.Body, 4096))
+		res.Body.Close()
+		return nil, fmt.Errorf("S3 %s %s failed with status %d: %s", req.Method, req.URL.Path, res.StatusCode, strings.TrimSpace(string(raw)))
+	}
+
+	return res, nil
+}
+
+// Virtual hosted style puts the bucket in the host name, path style in the path
+func (s3 *S3Storage) objectURL(key string) *url.URL {
+	objectURL := *s3.endpoint
+	path := "/" + key
+	if s3.pathStyle {
+		path = "/" + s3.bucket + path
+	} else {
+		objectURL.Host = s3.bucket + "." + objectURL.Host
+	}
+
+	objectURL.Path = path
+	objectURL.RawPath = escapePath(path)
+	return &objectURL
+}
+
+func (s3 *S3Storage) scope(now time.Time) string {
+	return now.Format("20060102") + "/" + s3.region + "/s3/aws4_request"
+}
+
+// Signs the canonical request with the key derived for the day, region and service
+func (s3 *S3Storage) signature(now time.Time, canonicalRequest string) string {
+	digest := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := sigV4Algorithm + "\n" + now.Format(amzDateFormat) + "\n" + s3.scope(now) + "\n" + hex.EncodeToString(digest[:])
+
+	key := hmacSHA256([]byte("AWS4"+s3.secretKey), now.Format("20060102"))
+	key = hmacSHA256(key, s3.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// Query string with keys sorted and values encoded the way SigV4 expects
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, key := range keys {
+		for _, value := range query[key] {
+			parts = append(parts, uriEncode(key, true)+"="+uriEncode(value, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func escapePath(path string) string {
+	return uriEncode(path, false)
+}
+
+// Percent encodes everything but unreserved characters, and slashes unless encodeSlash is set
+func uriEncode(value string, encodeSlash bool) string {
+	var encoded strings.Builder
+	for _, b := range []byte(value) {
+		switch {
+		case b >= 'A' && b <= 'Z', b >= 'a' && b <= 'z', b >= '0' && b <= '9', b == '-', b == '.', b == '_', b == '~':
+			encoded.WriteByte(b)
+		case b == '/' && !encodeSlash:
+			encoded.WriteByte(b)
+		default:
+			fmt.Fprintf(&encoded, "%%%02X", b)
+		}
+	}
+	return encoded.String()
+}
@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/supermario64bit/whatsapp_connect/pkg/logger"
+)
+
+var ErrNotFound = errors.New("File not found")
+
+// Stores files under slash separated keys
+type Storage interface {
+	// Stores size bytes read from body under the key, replacing any file stored there
+	Put(key string, body io.Reader, size int64, contentType string) error
+	Get(key string) (io.ReadCloser, error)
+	Delete(key string) error
+	// Returns a URL granting read access to the file until it expires
+	SignedURL(key string, expiry time.Duration) (string, error)
+}
+
+var store Storage
+
+// Returns the storage selected by STORAGE_DRIVER, either local (the default) or s3. The storage
+// is shared, so URLs signed by one service verify in another.
+func New() Storage {
+	if store != nil {
+		return store
+	}
+
+	switch strings.ToLower(os.Getenv("STORAGE_DRIVER")) {
+	case "", "local":
+		store = NewLocalStorage()
+		return store
+	case "s3":
+		store = NewS3Storage()
+		return store
+	}
+
+	err := errors.New("Unknown STORAGE_DRIVER " + os.Getenv("STORAGE_DRIVER") + ", expected local or s3")
+	logger.HighlightedDanger(err.Error())
+	panic(err)
+}
+
+// Rejects keys which are empty, absolute or climb out of the storage root
+func validKey(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return false
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return false
+		}
+	}
+	return true
+}
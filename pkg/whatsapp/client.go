@@ -0,0 +1,163 @@
+package whatsapp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const defaultGraphAPIURL = "https://graph.facebook.com/v21.0"
+
+// Error reported by the Graph API
+type APIError struct {
+	StatusCode int    `json:"-"`
+	Message    string `json:"message"`
+	Type       string `json:"type"`
+	Code       int    `json:"code"`
+	Subcode    int    `json:"error_subcode"`
+	UserTitle  string `json:"error_user_title"`
+	UserMsg    string `json:"error_user_msg"`
+	TraceID    string `json:"fbtrace_id"`
+}
+
+func (err *APIError) Error() string {
+	message := err.Message
+	if err.UserMsg != "" {
+		message = err.UserMsg
+	}
+	return fmt.Sprintf("Graph API error %d (status %d): %s", err.Code, err.StatusCode, message)
+}
+
+// Error codes reporting throttling or a temporary problem on Meta's side
+var retryableErrorCodes = map[int]bool{
+	1:      true, // API unknown
+	2:      true, // API service
+	4:      true, // API too many calls
+	80007:  true, // WhatsApp Business Account rate limit hit
+	130429: true, // Throughput rate limit hit
+	131000: true, // Something went wrong
+	131048: true, // Spam rate limit hit
+	131056: true, // Too many messages to the same recipient
+	133004: true, // Server temporarily unavailable
+}
+
+// Reports whether the request may succeed when sent again later
+func (err *APIError) Retryable() bool {
+	return err.StatusCode >= 500 || err.StatusCode == http.StatusTooManyRequests || retryableErrorCodes[err.Code]
+}
+
+// Reports whether a failed call may succeed later. Errors which are not API errors, such as
+// timeouts and dropped connections, never reached Meta and are worth retrying.
+func IsRetryable(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Retryable()
+	}
+	return err != nil
+}
+
+// Talks to the WhatsApp Cloud API on behalf of business accounts. Every call takes the access
+// token of the account it acts for.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	// Media transfers run for much longer than API calls
+	mediaClient *http.Client
+}
+
+// Creates a client for the Graph API at WHATSAPP_GRAPH_API_URL, which includes the API version
+// and defaults to the version this code was written against
+func NewClient() *Client {
+	baseURL := os.Getenv("WHATSAPP_GRAPH_API_URL")
+	if baseURL == "" {
+		baseURL = defaultGraphAPIURL
+	}
+
+	return &Client{
+		baseURL:     strings.TrimRight(baseURL, "/"),
+		httpClient:  &http.Client{Timeout: 30 * time.Second},
+		mediaClient: &http.Client{Timeout: 10 * time.Minute},
+	}
+}
+
+// Sends a request to the given path, encoding body as JSON when not nil and decoding the
+// response into out when not nil
+func (client *Client) do(method string, path string, query url.Values, accessToken string, body interface{}, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(raw)
+	}
+
+	endpoint := path
+	if !strings.HasPrefix(path, "https://") && !strings.HasPrefix(path, "http://") {
+		endpoint = client.baseURL + "/" + strings.TrimLeft(path, "/")
+	}
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequest(method, endpoint, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := client.send(client.httpClient, req, accessToken)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	raw, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(raw, out)
+}
+
+// Sends the request with the access token, turning error responses into an APIError. The
+// caller closes the body of a successful response.
+func (client *Client) send(httpClient *http.Client, req *http.Request, accessToken string) (*http.Response, error) {
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode < 300 {
+		return res, nil
+	}
+	defer res.Body.Close()
+
+	raw, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var envelope struct {
+		Error *APIError `json:"error"`
+	}
+	if json.Unmarshal(raw, &envelope) != nil || envelope.Error == nil {
+		return nil, &APIError{StatusCode: res.StatusCode, Message: http.StatusText(res.StatusCode)}
+	}
+	envelope.Error.StatusCode = res.StatusCode
+	return nil, envelope.Error
+}
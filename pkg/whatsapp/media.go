@@ -0,0 +1,96 @@
+package whatsapp
+
+import (
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strings"
+)
+
+// Media file as described by the Graph API media endpoint
+type Media struct {
+	ID       string `json:"id"`
+	URL      string `json:"url"`
+	MimeType string `json:"mime_type"`
+	SHA256   string `json:"sha256"`
+	FileSize int64  `json:"file_size"`
+}
+
+// Uploads a file for the business phone number to send in messages. Returns the media id.
+func (client *Client) UploadMedia(accessToken string, phoneNumberID string, filename string, mimeType string, file io.Reader) (string, error) {
+	body, writer := io.Pipe()
+	form := multipart.NewWriter(writer)
+
+	// The form is written while the request reads it
+	go func() {
+		err := form.WriteField("messaging_product", "whatsapp")
+		if err == nil {
+			err = form.WriteField("type", mimeType)
+		}
+		if err == nil {
+			header := textproto.MIMEHeader{}
+			header.Set("Content-Disposition", `form-data; name="file"; filename="`+escapeQuotes(filename)+`"`)
+			header.Set("Content-Type", mimeType)
+			var part io.Writer
+			part, err = form.CreatePart(header)
+			if err == nil {
+				_, err = io.Copy(part, file)
+			}
+		}
+		if err == nil {
+			err = form.Close()
+		}
+		writer.CloseWithError(err)
+	}()
+
+	req, err := http.NewRequest(http.MethodPost, client.baseURL+"/"+phoneNumberID+"/media", body)
+	if err != nil {
+		body.Close()
+		return "", err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	res, err := client.send(client.mediaClient, req, accessToken)
+	// Stops the writer when the request failed before reading the whole form
+	body.Close()
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	var uploaded struct {
+		ID string `json:"id"`
+	}
+	err = json.NewDecoder(res.Body).Decode(&uploaded)
+	return uploaded.ID, err
+}
+
+// Looks up a media file. The returned URL is only valid for a few minutes.
+func (client *Client) GetMedia(accessToken string, mediaID string) (*Media, error) {
+	var media Media
+	err := client.do("GET", mediaID, nil, accessToken, nil, &media)
+	if err != nil {
+		return nil, err
+	}
+	return &media, nil
+}
+
+// Downloads the file behind a media URL returned by GetMedia. The caller closes the reader.
+func (client *Client) DownloadMedia(accessToken string, mediaURL string) (io.ReadCloser, error) {
+	req, err := http.NewRequest(http.MethodGet, mediaURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := client.send(client.mediaClient, req, accessToken)
+	if err != nil {
+		return nil, err
+	}
+	return res.Body, nil
+}
+
+func escapeQuotes(value string) string {
+	return strings.NewReplacer("\\", "\\\\", `"`, "\\\"").Replace(value)
+}
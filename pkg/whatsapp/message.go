@@ -0,0 +1,152 @@
+package whatsapp
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Message sent through the Cloud API messages endpoint
+type OutgoingMessage struct {
+	MessagingProduct string           `json:"messaging_product"`
+	RecipientType    string           `json:"recipient_type"`
+	To               string           `json:"to"`
+	Type             string           `json:"type"`
+	Template         *TemplatePayload `json:"template,omitempty"`
+	Text             *TextPayload     `json:"text,omitempty"`
+	Image            *MediaObject     `json:"image,omitempty"`
+	Video            *MediaObject     `json:"video,omitempty"`
+	Audio            *MediaObject     `json:"audio,omitempty"`
+	Document         *MediaObject     `json:"document,omitempty"`
+	Sticker          *MediaObject     `json:"sticker,omitempty"`
+}
+
+type TextPayload struct {
+	Body       string `json:"body"`
+	PreviewURL bool   `json:"preview_url,omitempty"`
+}
+
+type TemplatePayload struct {
+	Name       string             `json:"name"`
+	Language   TemplateLanguage   `json:"language"`
+	Components []ComponentPayload `json:"components,omitempty"`
+}
+
+type TemplateLanguage struct {
+	Code string `json:"code"`
+}
+
+// Parameters filling the placeholders of one template component
+type ComponentPayload struct {
+	Type       string      `json:"type"`
+	SubType    string      `json:"sub_type,omitempty"`
+	Index      string      `json:"index,omitempty"`
+	Parameters []Parameter `json:"parameters"`
+}
+
+type Parameter struct {
+	Type     string       `json:"type"`
+	Text     string       `json:"text,omitempty"`
+	Payload  string       `json:"payload,omitempty"`
+	Image    *MediaObject `json:"image,omitempty"`
+	Video    *MediaObject `json:"video,omitempty"`
+	Document *MediaObject `json:"document,omitempty"`
+}
+
+type MediaObject struct {
+	ID       string `json:"id,omitempty"`
+	Link     string `json:"link,omitempty"`
+	Caption  string `json:"caption,omitempty"`
+	Filename string `json:"filename,omitempty"`
+}
+
+type SendResponse struct {
+	Contacts []struct {
+		Input string `json:"input"`
+		WaID  string `json:"wa_id"`
+	} `json:"contacts"`
+	Messages []struct {
+		ID            string `json:"id"`
+		MessageStatus string `json:"message_status"`
+	} `json:"messages"`
+}
+
+// Builds a template message to the given E.164 phone number
+func NewTemplateMessage(to string, template *TemplatePayload) *OutgoingMessage {
+	return &OutgoingMessage{
+		MessagingProduct: "whatsapp",
+		RecipientType:    "individual",
+		To:               WaIDFromPhoneNumber(to),
+		Type:             "template",
+		Template:         template,
+	}
+}
+
+// Builds a free-form text message to the given E.164 phone number
+func NewTextMessage(to string, text *TextPayload) *OutgoingMessage {
+	return &OutgoingMessage{
+		MessagingProduct: "whatsapp",
+		RecipientType:    "individual",
+		To:               WaIDFromPhoneNumber(to),
+		Type:             "text",
+		Text:             text,
+	}
+}
+
+// Builds a message carrying an uploaded image, video, audio, document or sticker
+func NewMediaMessage(to string, mediaType string, media *MediaObject) *OutgoingMessage {
+	message := &OutgoingMessage{
+		MessagingProduct: "whatsapp",
+		RecipientType:    "individual",
+		To:               WaIDFromPhoneNumber(to),
+		Type:             mediaType,
+	}
+
+	switch mediaType {
+	case "image":
+		message.Image = media
+	case "video":
+		message.Video = media
+	case "audio":
+		message.Audio = media
+	case "document":
+		message.Document = media
+	case "sticker":
+		message.Sticker = media
+	}
+	return message
+}
+
+// Text parameters in the order of the placeholders they fill
+func TextParameters(values []string) []Parameter {
+	params := make([]Parameter, 0, len(values))
+	for _, value := range values {
+		params = append(params, Parameter{Type: "text", Text: value})
+	}
+	return params
+}
+
+// Replaces each {{n}} placeholder with the nth value
+func RenderPlaceholders(text string, values []string) string {
+	return placeholderPattern.ReplaceAllStringFunc(text, func(placeholder string) string {
+		n, err := strconv.Atoi(placeholderPattern.FindStringSubmatch(placeholder)[1])
+		if err != nil || n < 1 || n > len(values) {
+			return placeholder
+		}
+		return values[n-1]
+	})
+}
+
+// WhatsApp ids are phone numbers in international format without the leading +
+func WaIDFromPhoneNumber(phoneNumber string) string {
+	return strings.TrimPrefix(phoneNumber, "+")
+}
+
+// Sends a message from the business phone number and returns the ids Meta assigned
+func (client *Client) SendMessage(accessToken string, phoneNumberID string, message *OutgoingMessage) (*SendResponse, error) {
+	var res SendResponse
+	err := client.do("POST", phoneNumberID+"/messages", nil, accessToken, message, &res)
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
@@ -0,0 +1,148 @@
+package whatsapp
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+const (
+	ComponentHeader  = "HEADER"
+	ComponentBody    = "BODY"
+	ComponentFooter  = "FOOTER"
+	ComponentButtons = "BUTTONS"
+)
+
+var placeholderPattern = regexp.MustCompile(`\{\{\s*(\d+)\s*\}\}`)
+
+// One part of a message template, as the Graph API describes it
+type TemplateComponent struct {
+	Type    string           `json:"type"`
+	Format  string           `json:"format,omitempty"`
+	Text    string           `json:"text,omitempty"`
+	Buttons []TemplateButton `json:"buttons,omitempty"`
+	Example *TemplateExample `json:"example,omitempty"`
+}
+
+type TemplateButton struct {
+	Type        string   `json:"type"`
+	Text        string   `json:"text"`
+	URL         string   `json:"url,omitempty"`
+	PhoneNumber string   `json:"phone_number,omitempty"`
+	Example     []string `json:"example,omitempty"`
+}
+
+// Sample values for the placeholders of a component, required by Meta's review
+type TemplateExample struct {
+	HeaderText   []string   `json:"header_text,omitempty"`
+	HeaderHandle []string   `json:"header_handle,omitempty"`
+	BodyText     [][]string `json:"body_text,omitempty"`
+}
+
+// Message template as created on and returned by the Graph API
+type Template struct {
+	ID                  string              `json:"id,omitempty"`
+	Name                string              `json:"name"`
+	Language            string              `json:"language"`
+	Category            string              `json:"category"`
+	Status              string              `json:"status,omitempty"`
+	RejectedReason      string              `json:"rejected_reason,omitempty"`
+	Components          []TemplateComponent `json:"components"`
+	AllowCategoryChange bool                `json:"allow_category_change,omitempty"`
+}
+
+type templatePage struct {
+	Data   []Template `json:"data"`
+	Paging struct {
+		Next string `json:"next"`
+	} `json:"paging"`
+}
+
+// Counts the {{n}} placeholders of a template text. Placeholders must be numbered 1 to n without
+// gaps, though each may appear more than once.
+func CountPlaceholders(text string) (int, error) {
+	seen := map[int]bool{}
+	for _, match := range placeholderPattern.FindAllStringSubmatch(text, -1) {
+		n, err := strconv.Atoi(match[1])
+		if err != nil {
+			return 0, fmt.Errorf("Invalid placeholder %s", match[0])
+		}
+		seen[n] = true
+	}
+
+	numbers := make([]int, 0, len(seen))
+	for n := range seen {
+		numbers = append(numbers, n)
+	}
+	sort.Ints(numbers)
+
+	for i, n := range numbers {
+		if n != i+1 {
+			return 0, fmt.Errorf("Placeholders must be numbered from {{1}} without gaps, found {{%d}} where {{%d}} was expected", n, i+1)
+		}
+	}
+
+	return len(numbers), nil
+}
+
+// Submits a template for review under the business account. The returned template carries the
+// id and initial status assigned by Meta.
+func (client *Client) CreateTemplate(accessToken string, businessAccountID string, template *Template) (*Template, error) {
+	var created Template
+	err := client.do("POST", businessAccountID+"/message_templates", nil, accessToken, template, &created)
+	if err != nil {
+		return nil, err
+	}
+
+	created.Name = template.Name
+	created.Language = template.Language
+	created.Components = template.Components
+	if created.Category == "" {
+		created.Category = template.Category
+	}
+	return &created, nil
+}
+
+// Replaces the components and category of an existing template, which sends it back to review
+func (client *Client) EditTemplate(accessToken string, templateID string, category string, components []TemplateComponent) error {
+	body := map[string]interface{}{
+		"category":   category,
+		"components": components,
+	}
+	return client.do("POST", templateID, nil, accessToken, body, nil)
+}
+
+// Lists every template of the business account, following pagination
+func (client *Client) ListTemplates(accessToken string, businessAccountID string) ([]Template, error) {
+	query := url.Values{
+		"fields": {"id,name,language,category,status,rejected_reason,components"},
+		"limit":  {"100"},
+	}
+
+	var templates []Template
+	path := businessAccountID + "/message_templates"
+	for path != "" {
+		var page templatePage
+		err := client.do("GET", path, query, accessToken, nil, &page)
+		if err != nil {
+			return nil, err
+		}
+
+		templates = append(templates, page.Data...)
+		// The next link carries the query already
+		path, query = page.Paging.Next, nil
+	}
+
+	return templates, nil
+}
+
+// Deletes one language version of a template, identified by its id and name
+func (client *Client) DeleteTemplate(accessToken string, businessAccountID string, templateID string, name string) error {
+	query := url.Values{
+		"hsm_id": {templateID},
+		"name":   {name},
+	}
+	return client.do("DELETE", businessAccountID+"/message_templates", query, accessToken, nil, nil)
+}
@@ -0,0 +1,194 @@
+package whatsapp
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const SignatureHeader = "X-Hub-Signature-256"
+
+// Notification posted by Meta to the webhook callback URL
+type WebhookPayload struct {
+	Object string         `json:"object"`
+	Entry  []WebhookEntry `json:"entry"`
+}
+
+type WebhookEntry struct {
+	// WhatsApp business account id
+	ID      string          `json:"id"`
+	Changes []WebhookChange `json:"changes"`
+}
+
+type WebhookChange struct {
+	Field string       `json:"field"`
+	Value WebhookValue `json:"value"`
+}
+
+const (
+	WebhookFieldMessages             = "messages"
+	WebhookFieldTemplateStatusUpdate = "message_template_status_update"
+)
+
+type WebhookValue struct {
+	MessagingProduct string           `json:"messaging_product"`
+	Metadata         WebhookMetadata  `json:"metadata"`
+	Contacts         []WebhookContact `json:"contacts"`
+	Messages         []InboundMessage `json:"messages"`
+	Statuses         []MessageStatus  `json:"statuses"`
+
+	// Set on message_template_status_update notifications
+	Event                   string `json:"event"`
+	MessageTemplateID       int64  `json:"message_template_id"`
+	MessageTemplateName     string `json:"message_template_name"`
+	MessageTemplateLanguage string `json:"message_template_language"`
+	Reason                  string `json:"reason"`
+}
+
+type WebhookMetadata struct {
+	DisplayPhoneNumber string `json:"display_phone_number"`
+	PhoneNumberID      string `json:"phone_number_id"`
+}
+
+type WebhookContact struct {
+	Profile struct {
+		Name string `json:"name"`
+	} `json:"profile"`
+	WaID string `json:"wa_id"`
+}
+
+type InboundMessage struct {
+	From      string `json:"from"`
+	ID        string `json:"id"`
+	Timestamp string `json:"timestamp"`
+	Type      string `json:"type"`
+	Text      *struct {
+		Body string `json:"body"`
+	} `json:"text,omitempty"`
+	Image    *InboundMedia `json:"image,omitempty"`
+	Video    *InboundMedia `json:"video,omitempty"`
+	Audio    *InboundMedia `json:"audio,omitempty"`
+	Document *InboundMedia `json:"document,omitempty"`
+	Sticker  *InboundMedia `json:"sticker,omitempty"`
+	Button   *struct {
+		Text    string `json:"text"`
+		Payload string `json:"payload"`
+	} `json:"button,omitempty"`
+
+	// The message exactly as Meta sent it, including the type specific parts not mapped above
+	Raw json.RawMessage `json:"-"`
+}
+
+func (message *InboundMessage) UnmarshalJSON(data []byte) error {
+	type inboundMessage InboundMessage
+	var decoded inboundMessage
+	err := json.Unmarshal(data, &decoded)
+	if err != nil {
+		return err
+	}
+
+	*message = InboundMessage(decoded)
+	message.Raw = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+// Media attached to an inbound message, to be fetched through the media endpoint
+type InboundMedia struct {
+	ID       string `json:"id"`
+	MimeType string `json:"mime_type"`
+	SHA256   string `json:"sha256"`
+	Caption  string `json:"caption"`
+	Filename string `json:"filename"`
+}
+
+// Returns the media of the message, or nil when it carries none
+func (message *InboundMessage) Media() *InboundMedia {
+	for _, media := range []*InboundMedia{message.Image, message.Video, message.Audio, message.Document, message.Sticker} {
+		if media != nil {
+			return media
+		}
+	}
+	return nil
+}
+
+// Delivery status callback for a message sent by the business
+type MessageStatus struct {
+	// wamid of the message the status is about
+	ID          string               `json:"id"`
+	Status      string               `json:"status"`
+	Timestamp   string               `json:"timestamp"`
+	RecipientID string               `json:"recipient_id"`
+	Errors      []MessageStatusError `json:"errors"`
+	// Billing conversation the message belongs to. Left out on read statuses.
+	Conversation *StatusConversation `json:"conversation,omitempty"`
+	Pricing      *StatusPricing      `json:"pricing,omitempty"`
+}
+
+type StatusConversation struct {
+	ID string `json:"id"`
+	// Only sent with the status that opened the conversation
+	ExpirationTimestamp string `json:"expiration_timestamp"`
+	Origin              struct {
+		Type string `json:"type"`
+	} `json:"origin"`
+}
+
+type StatusPricing struct {
+	Billable     bool   `json:"billable"`
+	PricingModel string `json:"pricing_model"`
+	Category     string `json:"category"`
+}
+
+type MessageStatusError struct {
+	Code      int    `json:"code"`
+	Title     string `json:"title"`
+	Message   string `json:"message"`
+	ErrorData struct {
+		Details string `json:"details"`
+	} `json:"error_data"`
+}
+
+// Best description of the error, as Meta fills in different fields depending on the error
+func (e MessageStatusError) Description() string {
+	for _, text := range []string{e.ErrorData.Details, e.Message, e.Title} {
+		if text != "" {
+			return text
+		}
+	}
+	return "Unknown error"
+}
+
+// WhatsApp ids are phone numbers in international format without the leading +
+func PhoneNumberFromWaID(waID string) string {
+	return "+" + strings.TrimPrefix(waID, "+")
+}
+
+// Converts the unix timestamp strings used across webhook payloads, falling back to now
+func ParseTimestamp(ts string) time.Time {
+	seconds, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return time.Now()
+	}
+	return time.Unix(seconds, 0)
+}
+
+// Checks the sha256=<hex hmac> signature Meta computes over the raw body with the app secret
+func VerifySignature(body []byte, signature string, appSecret string) bool {
+	digest, ok := strings.CutPrefix(signature, "sha256=")
+	if !ok {
+		return false
+	}
+
+	expected, err := hex.DecodeString(digest)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(appSecret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
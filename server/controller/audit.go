@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+	"github.com/supermario64bit/whatsapp_connect/server/service"
+	"github.com/supermario64bit/whatsapp_connect/types"
+)
+
+type auditController struct {
+	svc     service.AuditService
+	members service.OrganisationMemberService
+}
+
+type AuditController interface {
+	Find(c *gin.Context)
+}
+
+func NewAuditController() AuditController {
+	return &auditController{
+		svc:     service.NewAuditService(),
+		members: service.NewOrganisationMemberService(),
+	}
+}
+
+func (ctrl *auditController) Find(c *gin.Context) {
+	var filter model.AuditLogFilter
+	err := c.ShouldBindQuery(&filter)
+	if err != nil {
+		types.NewBadRequestError("Invalid Query Params", err).WriteHttpResponse(c)
+		return
+	}
+
+	// Members read their organisation's log. Without an organisation, users only see their own
+	// changes, such as to their account.
+	if filter.OrganisationID > 0 {
+		appErr := ctrl.members.Require(filter.OrganisationID, actorID(c))
+		if appErr != nil {
+			appErr.WriteHttpResponse(c)
+			return
+		}
+	} else if filter.ActorID > 0 && filter.ActorID != actorID(c) {
+		types.NewForbiddenError("Organisation members only", errors.New("organisation_id is required to read the changes of other users")).WriteHttpResponse(c)
+		return
+	} else {
+		filter.ActorID = actorID(c)
+	}
+
+	set, page, appErr := ctrl.svc.Find(&filter)
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	if len(set) == 0 {
+		c.JSON(http.StatusOK, writePaginatedHttpResponseObj("No Audit Logs Found!", "audit_logs", []*model.AuditLog{}, page))
+		return
+	}
+
+	c.JSON(http.StatusOK, writePaginatedHttpResponseObj("Audit Logs Found!", "audit_logs", set, page))
+}
@@ -0,0 +1,96 @@
+package controller
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/supermario64bit/whatsapp_connect/server/service"
+)
+
+const (
+	// Context key the authenticated user's id is stored under
+	actorContextKey = "actor_id"
+	// Route of an organisation, which its members only may reach along with everything below it
+	organisationRoute = "/organisation/:id"
+	// Query parameter carrying a stream token
+	accessTokenParam = "access_token"
+)
+
+// Identifies the caller from the Authorization: Bearer header and refuses the request with 401
+// when the token is missing or invalid
+func Authenticate() gin.HandlerFunc {
+	svc := service.NewAuthService()
+
+	return func(c *gin.Context) {
+		scheme, token, _ := strings.Cut(c.GetHeader("Authorization"), " ")
+		if !strings.EqualFold(scheme, "Bearer") {
+			token = ""
+		}
+
+		userID, appErr := svc.Authenticate(strings.TrimSpace(token))
+		if appErr != nil {
+			c.Header("WWW-Authenticate", `Bearer realm="api"`)
+			appErr.WriteHttpResponse(c)
+			return
+		}
+
+		c.Set(actorContextKey, userID)
+		c.Next()
+	}
+}
+
+// Identifies the caller of an organisation's event stream. Takes an API token in the
+// Authorization header like Authenticate, or else a stream token for the organisation in the
+// access_token query parameter, as browsers cannot set headers on EventSource requests.
+func AuthenticateStream() gin.HandlerFunc {
+	svc := service.NewAuthService()
+	authenticate := Authenticate()
+
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") != "" {
+			authenticate(c)
+			return
+		}
+
+		orgID, ok := uintParam(c, "id")
+		if !ok {
+			return
+		}
+
+		userID, appErr := svc.AuthenticateStream(c.Query(accessTokenParam), orgID)
+		if appErr != nil {
+			appErr.WriteHttpResponse(c)
+			return
+		}
+
+		c.Set(actorContextKey, userID)
+		c.Next()
+	}
+}
+
+// Refuses requests to an organisation's routes, /organisation/:id and below, from users who are
+// not its members. Runs after Authenticate.
+func RequireMember() gin.HandlerFunc {
+	members := service.NewOrganisationMemberService()
+
+	return func(c *gin.Context) {
+		path := c.FullPath()
+		if path != organisationRoute && !strings.HasPrefix(path, organisationRoute+"/") {
+			c.Next()
+			return
+		}
+
+		orgID, ok := uintParam(c, "id")
+		if !ok {
+			return
+		}
+
+		appErr := members.Require(orgID, actorID(c))
+		if appErr != nil {
+			appErr.WriteHttpResponse(c)
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,189 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+	"github.com/supermario64bit/whatsapp_connect/server/service"
+	"github.com/supermario64bit/whatsapp_connect/types"
+)
+
+type autoReplyRuleController struct {
+	svc service.AutoReplyRuleService
+}
+
+type AutoReplyRuleController interface {
+	Create(c *gin.Context)
+	Find(c *gin.Context)
+	FindByID(c *gin.Context)
+	PatchByID(c *gin.Context)
+	DeleteByID(c *gin.Context)
+	Test(c *gin.Context)
+}
+
+func NewAutoReplyRuleController() AutoReplyRuleController {
+	return &autoReplyRuleController{
+		svc: service.NewAutoReplyRuleService(),
+	}
+}
+
+func (ctrl *autoReplyRuleController) Create(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	// Rules are enabled unless created disabled
+	rule := model.AutoReplyRule{Enabled: true}
+	err := c.ShouldBindBodyWithJSON(&rule)
+	if err != nil {
+		types.NewBadRequestError("Invalid Request Body", err).WriteHttpResponse(c)
+		return
+	}
+
+	new, appErr := ctrl.svc.Create(orgID, &rule, actorID(c))
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.Header("ETag", entityTag(new.Version))
+	c.JSON(http.StatusAccepted, writeSuccessHttpResponseObj("Auto reply rule created!", "auto_reply_rule", new))
+}
+
+func (ctrl *autoReplyRuleController) Find(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	set, appErr := ctrl.svc.Find(orgID)
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	if len(set) == 0 {
+		c.JSON(http.StatusOK, writeSuccessHttpResponseObj("No Auto Reply Rules Found!", "", nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, writeSuccessHttpResponseObj("Auto Reply Rules Found!", "auto_reply_rules", set))
+}
+
+func (ctrl *autoReplyRuleController) FindByID(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	id, ok := uintParam(c, "rule_id")
+	if !ok {
+		return
+	}
+
+	rule, appErr := ctrl.svc.FindByID(orgID, id)
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.Header("ETag", entityTag(rule.Version))
+	c.JSON(http.StatusOK, writeSuccessHttpResponseObj("Auto Reply Rule Found!", "auto_reply_rule", rule))
+}
+
+func (ctrl *autoReplyRuleController) PatchByID(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	id, ok := uintParam(c, "rule_id")
+	if !ok {
+		return
+	}
+
+	version, ok := requireIfMatch(c, ctrl.currentVersion(orgID, id))
+	if !ok {
+		return
+	}
+
+	patch, ok := readMergePatch(c)
+	if !ok {
+		return
+	}
+
+	updated, appErr := ctrl.svc.PatchByID(orgID, patch, id, version, actorID(c))
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.Header("ETag", entityTag(updated.Version))
+	c.JSON(http.StatusAccepted, writeSuccessHttpResponseObj("Auto reply rule updated!", "auto_reply_rule", updated))
+}
+
+func (ctrl *autoReplyRuleController) DeleteByID(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	id, ok := uintParam(c, "rule_id")
+	if !ok {
+		return
+	}
+
+	version, ok := requireIfMatch(c, ctrl.currentVersion(orgID, id))
+	if !ok {
+		return
+	}
+
+	appErr := ctrl.svc.DeleteByID(orgID, id, version, actorID(c))
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, writeSuccessHttpResponseObj("Auto Reply Rule Deleted!", "", nil))
+}
+
+// Tries a message against the saved rules without replying to anyone
+func (ctrl *autoReplyRuleController) Test(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var test model.AutoReplyTest
+	err := c.ShouldBindBodyWithJSON(&test)
+	if err != nil {
+		types.NewBadRequestError("Invalid Request Body", err).WriteHttpResponse(c)
+		return
+	}
+
+	result, appErr := ctrl.svc.Test(orgID, &test)
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	if !result.Matched {
+		c.JSON(http.StatusOK, writeSuccessHttpResponseObj("No Auto Reply Rule Matched!", "result", result))
+		return
+	}
+
+	c.JSON(http.StatusOK, writeSuccessHttpResponseObj("Auto Reply Rule Matched!", "result", result))
+}
+
+// Reads the rule's current version, for If-Match headers listing several
+func (ctrl *autoReplyRuleController) currentVersion(orgID uint64, id uint64) func() (uint64, *types.ApplicationError) {
+	return func() (uint64, *types.ApplicationError) {
+		rule, appErr := ctrl.svc.FindByID(orgID, id)
+		if appErr != nil {
+			return 0, appErr
+		}
+		return rule.Version, nil
+	}
+}
@@ -0,0 +1,111 @@
+package controller
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+	"github.com/supermario64bit/whatsapp_connect/server/service"
+	"github.com/supermario64bit/whatsapp_connect/types"
+)
+
+type businessHoursController struct {
+	svc service.BusinessHoursService
+}
+
+type BusinessHoursController interface {
+	Find(c *gin.Context)
+	Update(c *gin.Context)
+	Status(c *gin.Context)
+}
+
+func NewBusinessHoursController() BusinessHoursController {
+	return &businessHoursController{
+		svc: service.NewBusinessHoursService(),
+	}
+}
+
+func (ctrl *businessHoursController) Find(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	hours, appErr := ctrl.svc.Find(orgID)
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	if hours.Version > 0 {
+		c.Header("ETag", entityTag(hours.Version))
+	}
+	c.JSON(http.StatusOK, writeSuccessHttpResponseObj("Business Hours Found!", "business_hours", hours))
+}
+
+// Replaces the business hours. Organisations without hours yet set them with If-Match: *.
+func (ctrl *businessHoursController) Update(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	version, ok := requireIfMatch(c, ctrl.currentVersion(orgID))
+	if !ok {
+		return
+	}
+
+	var hours model.BusinessHours
+	err := c.ShouldBindBodyWithJSON(&hours)
+	if err != nil {
+		types.NewBadRequestError("Invalid Request Body", err).WriteHttpResponse(c)
+		return
+	}
+
+	updated, appErr := ctrl.svc.Update(orgID, &hours, version, actorID(c))
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.Header("ETag", entityTag(updated.Version))
+	c.JSON(http.StatusAccepted, writeSuccessHttpResponseObj("Business hours updated!", "business_hours", updated))
+}
+
+// Tells whether the organisation is open now, or at the time given as ?at= in RFC 3339
+func (ctrl *businessHoursController) Status(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	at := time.Now()
+	if raw := c.Query("at"); raw != "" {
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			types.NewBadRequestError("Invalid Query Params", err).WriteHttpResponse(c)
+			return
+		}
+		at = parsed
+	}
+
+	status, appErr := ctrl.svc.Status(orgID, at)
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, writeSuccessHttpResponseObj("Business Hours Status Found!", "status", status))
+}
+
+// Reads the business hours's current version, for If-Match headers listing several
+func (ctrl *businessHoursController) currentVersion(orgID uint64) func() (uint64, *types.ApplicationError) {
+	return func() (uint64, *types.ApplicationError) {
+		hours, appErr := ctrl.svc.Find(orgID)
+		if appErr != nil {
+			return 0, appErr
+		}
+		return hours.Version, nil
+	}
+}
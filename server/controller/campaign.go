@@ -0,0 +1,183 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+	"github.com/supermario64bit/whatsapp_connect/server/service"
+	"github.com/supermario64bit/whatsapp_connect/types"
+)
+
+type campaignController struct {
+	svc service.CampaignService
+}
+
+type CampaignController interface {
+	Create(c *gin.Context)
+	Find(c *gin.Context)
+	FindByID(c *gin.Context)
+	Schedule(c *gin.Context)
+	Cancel(c *gin.Context)
+	FindRecipients(c *gin.Context)
+	Stats(c *gin.Context)
+}
+
+func NewCampaignController() CampaignController {
+	return &campaignController{
+		svc: service.NewCampaignService(),
+	}
+}
+
+func (ctrl *campaignController) Create(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var campaign model.Campaign
+	err := c.ShouldBindBodyWithJSON(&campaign)
+	if err != nil {
+		types.NewBadRequestError("Invalid Request Body", err).WriteHttpResponse(c)
+		return
+	}
+
+	new, appErr := ctrl.svc.Create(orgID, &campaign, actorID(c))
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, writeSuccessHttpResponseObj("Campaign created!", "campaign", new))
+}
+
+func (ctrl *campaignController) Find(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var page model.Pagination
+	err := c.ShouldBindQuery(&page)
+	if err != nil {
+		types.NewBadRequestError("Invalid Query Params", err).WriteHttpResponse(c)
+		return
+	}
+
+	set, result, appErr := ctrl.svc.Find(orgID, &page)
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	if len(set) == 0 {
+		c.JSON(http.StatusOK, writePaginatedHttpResponseObj("No Campaigns Found!", "campaigns", []*model.Campaign{}, result))
+		return
+	}
+
+	c.JSON(http.StatusOK, writePaginatedHttpResponseObj("Campaigns Found!", "campaigns", set, result))
+}
+
+func (ctrl *campaignController) FindByID(c *gin.Context) {
+	orgID, id, ok := campaignParams(c)
+	if !ok {
+		return
+	}
+
+	campaign, appErr := ctrl.svc.FindByID(orgID, id)
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, writeSuccessHttpResponseObj("Campaign Found!", "campaign", campaign))
+}
+
+func (ctrl *campaignController) Schedule(c *gin.Context) {
+	orgID, id, ok := campaignParams(c)
+	if !ok {
+		return
+	}
+
+	var schedule model.CampaignSchedule
+	err := c.ShouldBindBodyWithJSON(&schedule)
+	if err != nil {
+		types.NewBadRequestError("Invalid Request Body", err).WriteHttpResponse(c)
+		return
+	}
+
+	campaign, appErr := ctrl.svc.Schedule(orgID, id, &schedule, actorID(c))
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, writeSuccessHttpResponseObj("Campaign scheduled!", "campaign", campaign))
+}
+
+func (ctrl *campaignController) Cancel(c *gin.Context) {
+	orgID, id, ok := campaignParams(c)
+	if !ok {
+		return
+	}
+
+	campaign, appErr := ctrl.svc.Cancel(orgID, id, actorID(c))
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, writeSuccessHttpResponseObj("Campaign cancelled!", "campaign", campaign))
+}
+
+func (ctrl *campaignController) FindRecipients(c *gin.Context) {
+	orgID, id, ok := campaignParams(c)
+	if !ok {
+		return
+	}
+
+	var filter model.CampaignRecipientFilter
+	err := c.ShouldBindQuery(&filter)
+	if err != nil {
+		types.NewBadRequestError("Invalid Query Params", err).WriteHttpResponse(c)
+		return
+	}
+
+	set, page, appErr := ctrl.svc.FindRecipients(orgID, id, &filter)
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	if len(set) == 0 {
+		c.JSON(http.StatusOK, writePaginatedHttpResponseObj("No Recipients Found!", "recipients", []*model.CampaignRecipient{}, page))
+		return
+	}
+
+	c.JSON(http.StatusOK, writePaginatedHttpResponseObj("Recipients Found!", "recipients", set, page))
+}
+
+func (ctrl *campaignController) Stats(c *gin.Context) {
+	orgID, id, ok := campaignParams(c)
+	if !ok {
+		return
+	}
+
+	stats, appErr := ctrl.svc.Stats(orgID, id)
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, writeSuccessHttpResponseObj("Campaign Stats Found!", "stats", stats))
+}
+
+func campaignParams(c *gin.Context) (uint64, uint64, bool) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return 0, 0, false
+	}
+
+	id, ok := uintParam(c, "campaign_id")
+	return orgID, id, ok
+}
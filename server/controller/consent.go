@@ -0,0 +1,84 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+	"github.com/supermario64bit/whatsapp_connect/server/service"
+	"github.com/supermario64bit/whatsapp_connect/types"
+)
+
+type consentController struct {
+	svc service.ConsentService
+}
+
+type ConsentController interface {
+	Record(c *gin.Context)
+	Find(c *gin.Context)
+}
+
+func NewConsentController() ConsentController {
+	return &consentController{
+		svc: service.NewConsentService(),
+	}
+}
+
+func (ctrl *consentController) Record(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	contactID, ok := uintParam(c, "contact_id")
+	if !ok {
+		return
+	}
+
+	var consent model.Consent
+	err := c.ShouldBindBodyWithJSON(&consent)
+	if err != nil {
+		types.NewBadRequestError("Invalid Request Body", err).WriteHttpResponse(c)
+		return
+	}
+
+	new, appErr := ctrl.svc.Record(orgID, contactID, &consent, actorID(c))
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, writeSuccessHttpResponseObj("Consent recorded!", "consent", new))
+}
+
+func (ctrl *consentController) Find(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	contactID, ok := uintParam(c, "contact_id")
+	if !ok {
+		return
+	}
+
+	var page model.Pagination
+	err := c.ShouldBindQuery(&page)
+	if err != nil {
+		types.NewBadRequestError("Invalid Query Params", err).WriteHttpResponse(c)
+		return
+	}
+
+	set, result, appErr := ctrl.svc.Find(orgID, contactID, &page)
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	if len(set) == 0 {
+		c.JSON(http.StatusOK, writePaginatedHttpResponseObj("No Consent History Found!", "consents", []*model.Consent{}, result))
+		return
+	}
+
+	c.JSON(http.StatusOK, writePaginatedHttpResponseObj("Consent History Found!", "consents", set, result))
+}
@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+	"github.com/supermario64bit/whatsapp_connect/server/service"
+	"github.com/supermario64bit/whatsapp_connect/types"
+)
+
+type consentKeywordController struct {
+	svc service.ConsentKeywordService
+}
+
+type ConsentKeywordController interface {
+	Create(c *gin.Context)
+	Find(c *gin.Context)
+	DeleteByID(c *gin.Context)
+}
+
+func NewConsentKeywordController() ConsentKeywordController {
+	return &consentKeywordController{
+		svc: service.NewConsentKeywordService(),
+	}
+}
+
+func (ctrl *consentKeywordController) Create(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var keyword model.ConsentKeyword
+	err := c.ShouldBindBodyWithJSON(&keyword)
+	if err != nil {
+		types.NewBadRequestError("Invalid Request Body", err).WriteHttpResponse(c)
+		return
+	}
+
+	new, appErr := ctrl.svc.Create(orgID, &keyword, actorID(c))
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, writeSuccessHttpResponseObj("Consent keyword created!", "consent_keyword", new))
+}
+
+func (ctrl *consentKeywordController) Find(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	set, appErr := ctrl.svc.Find(orgID)
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	if len(set) == 0 {
+		c.JSON(http.StatusOK, writeSuccessHttpResponseObj("No Consent Keywords Found!", "", nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, writeSuccessHttpResponseObj("Consent Keywords Found!", "consent_keywords", set))
+}
+
+func (ctrl *consentKeywordController) DeleteByID(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	id, ok := uintParam(c, "keyword_id")
+	if !ok {
+		return
+	}
+
+	appErr := ctrl.svc.DeleteByID(orgID, id, actorID(c))
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, writeSuccessHttpResponseObj("Consent Keyword Deleted!", "", nil))
+}
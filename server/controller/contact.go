@@ -0,0 +1,166 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+	"github.com/supermario64bit/whatsapp_connect/server/service"
+	"github.com/supermario64bit/whatsapp_connect/types"
+)
+
+type contactController struct {
+	svc service.ContactService
+}
+
+type ContactController interface {
+	Create(c *gin.Context)
+	Find(c *gin.Context)
+	FindByID(c *gin.Context)
+	PatchByID(c *gin.Context)
+	DeleteByID(c *gin.Context)
+}
+
+func NewContactController() ContactController {
+	return &contactController{
+		svc: service.NewContactService(),
+	}
+}
+
+func (ctrl *contactController) Create(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var contact model.Contact
+	err := c.ShouldBindBodyWithJSON(&contact)
+	if err != nil {
+		types.NewBadRequestError("Invalid Request Body", err).WriteHttpResponse(c)
+		return
+	}
+
+	new, appErr := ctrl.svc.Create(orgID, &contact, actorID(c))
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.Header("ETag", entityTag(new.Version))
+	c.JSON(http.StatusAccepted, writeSuccessHttpResponseObj("Contact created!", "contact", new))
+}
+
+func (ctrl *contactController) Find(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var filter model.ContactFilter
+	err := c.ShouldBindQuery(&filter)
+	if err != nil {
+		types.NewBadRequestError("Invalid Query Params", err).WriteHttpResponse(c)
+		return
+	}
+
+	set, page, appErr := ctrl.svc.Find(orgID, &filter)
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	if len(set) == 0 {
+		c.JSON(http.StatusOK, writePaginatedHttpResponseObj("No Contacts Found!", "contacts", []*model.Contact{}, page))
+		return
+	}
+
+	c.JSON(http.StatusOK, writePaginatedHttpResponseObj("Contacts Found!", "contacts", set, page))
+}
+
+func (ctrl *contactController) FindByID(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	id, ok := uintParam(c, "contact_id")
+	if !ok {
+		return
+	}
+
+	contact, appErr := ctrl.svc.FindByID(orgID, id)
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.Header("ETag", entityTag(contact.Version))
+	c.JSON(http.StatusOK, writeSuccessHttpResponseObj("Contact Found!", "contact", contact))
+}
+
+func (ctrl *contactController) PatchByID(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	id, ok := uintParam(c, "contact_id")
+	if !ok {
+		return
+	}
+
+	version, ok := requireIfMatch(c, ctrl.currentVersion(orgID, id))
+	if !ok {
+		return
+	}
+
+	patch, ok := readMergePatch(c)
+	if !ok {
+		return
+	}
+
+	updated, appErr := ctrl.svc.PatchByID(orgID, patch, id, version, actorID(c))
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.Header("ETag", entityTag(updated.Version))
+	c.JSON(http.StatusAccepted, writeSuccessHttpResponseObj("Contact updated!", "contact", updated))
+}
+
+func (ctrl *contactController) DeleteByID(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	id, ok := uintParam(c, "contact_id")
+	if !ok {
+		return
+	}
+
+	version, ok := requireIfMatch(c, ctrl.currentVersion(orgID, id))
+	if !ok {
+		return
+	}
+
+	appErr := ctrl.svc.DeleteByID(orgID, id, version, actorID(c))
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, writeSuccessHttpResponseObj("Contact Deleted!", "", nil))
+}
+
+// Reads the contact's current version, for If-Match headers listing several
+func (ctrl *contactController) currentVersion(orgID uint64, id uint64) func() (uint64, *types.ApplicationError) {
+	return func() (uint64, *types.ApplicationError) {
+		contact, appErr := ctrl.svc.FindByID(orgID, id)
+		if appErr != nil {
+			return 0, appErr
+		}
+		return contact.Version, nil
+	}
+}
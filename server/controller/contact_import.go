@@ -0,0 +1,129 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/supermario64bit/whatsapp_connect/pkg/logger"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+	"github.com/supermario64bit/whatsapp_connect/server/service"
+	"github.com/supermario64bit/whatsapp_connect/types"
+)
+
+const maxImportFileSize = 10 << 20
+
+type contactImportController struct {
+	svc service.ContactImportService
+}
+
+type ContactImportController interface {
+	Import(c *gin.Context)
+	FindByID(c *gin.Context)
+	Export(c *gin.Context)
+}
+
+func NewContactImportController() ContactImportController {
+	return &contactImportController{
+		svc: service.NewContactImportService(),
+	}
+}
+
+// Accepts a multipart form with the CSV in "file", an optional JSON "mapping" of contact field to
+// column header and an optional "dry_run" flag
+func (ctrl *contactImportController) Import(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		types.NewBadRequestError("Invalid Request Body", fmt.Errorf("A CSV file is required in the file field")).WriteHttpResponse(c)
+		return
+	}
+
+	if fileHeader.Size > maxImportFileSize {
+		types.NewBadRequestError("Invalid Request Body", fmt.Errorf("File must not be larger than %d MB", maxImportFileSize>>20)).WriteHttpResponse(c)
+		return
+	}
+
+	mapping := map[string]string{}
+	if raw := c.PostForm("mapping"); raw != "" {
+		err = json.Unmarshal([]byte(raw), &mapping)
+		if err != nil {
+			types.NewBadRequestError("Invalid Request Body", fmt.Errorf("mapping must be a JSON object of contact field to column name")).WriteHttpResponse(c)
+			return
+		}
+	}
+
+	dryRun, err := strconv.ParseBool(c.DefaultPostForm("dry_run", "false"))
+	if err != nil {
+		types.NewBadRequestError("Invalid Request Body", fmt.Errorf("dry_run must be a boolean")).WriteHttpResponse(c)
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		types.NewBadRequestError("Invalid Request Body", err).WriteHttpResponse(c)
+		return
+	}
+	defer file.Close()
+
+	job, appErr := ctrl.svc.Start(orgID, fileHeader.Filename, file, mapping, dryRun, actorID(c))
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, writeSuccessHttpResponseObj("Contact import started!", "import_job", job))
+}
+
+func (ctrl *contactImportController) FindByID(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	id, ok := uintParam(c, "job_id")
+	if !ok {
+		return
+	}
+
+	job, appErr := ctrl.svc.FindByID(orgID, id)
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, writeSuccessHttpResponseObj("Contact Import Job Found!", "import_job", job))
+}
+
+// Streams contacts as CSV. Accepts the same filters as the contact list, without pagination.
+func (ctrl *contactImportController) Export(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var filter model.ContactFilter
+	err := c.ShouldBindQuery(&filter)
+	if err != nil {
+		types.NewBadRequestError("Invalid Query Params", err).WriteHttpResponse(c)
+		return
+	}
+
+	fileName := fmt.Sprintf("contacts-%d-%s.csv", orgID, time.Now().Format("20060102150405"))
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", `attachment; filename="`+fileName+`"`)
+	c.Status(http.StatusOK)
+
+	appErr := ctrl.svc.Export(orgID, &filter, c.Writer)
+	if appErr != nil {
+		// Part of the file may already be sent, so the status can no longer be changed
+		logger.Danger("Contact export for organisation " + strconv.FormatUint(orgID, 10) + " failed. Error: " + appErr.Error())
+	}
+}
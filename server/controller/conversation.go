@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+	"github.com/supermario64bit/whatsapp_connect/server/service"
+	"github.com/supermario64bit/whatsapp_connect/types"
+)
+
+type conversationController struct {
+	svc service.ConversationService
+}
+
+type ConversationController interface {
+	Find(c *gin.Context)
+	FindByID(c *gin.Context)
+}
+
+func NewConversationController() ConversationController {
+	return &conversationController{
+		svc: service.NewConversationService(),
+	}
+}
+
+func (ctrl *conversationController) Find(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var filter model.ConversationFilter
+	err := c.ShouldBindQuery(&filter)
+	if err != nil {
+		types.NewBadRequestError("Invalid Query Params", err).WriteHttpResponse(c)
+		return
+	}
+
+	set, page, appErr := ctrl.svc.Find(orgID, &filter)
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	if len(set) == 0 {
+		c.JSON(http.StatusOK, writePaginatedHttpResponseObj("No Conversations Found!", "conversations", []*model.Conversation{}, page))
+		return
+	}
+
+	c.JSON(http.StatusOK, writePaginatedHttpResponseObj("Conversations Found!", "conversations", set, page))
+}
+
+func (ctrl *conversationController) FindByID(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	id, ok := uintParam(c, "conversation_id")
+	if !ok {
+		return
+	}
+
+	conversation, appErr := ctrl.svc.FindByID(orgID, id)
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, writeSuccessHttpResponseObj("Conversation Found!", "conversation", conversation))
+}
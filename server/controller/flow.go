@@ -0,0 +1,222 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+	"github.com/supermario64bit/whatsapp_connect/server/service"
+	"github.com/supermario64bit/whatsapp_connect/types"
+)
+
+type flowController struct {
+	svc service.FlowService
+}
+
+type FlowController interface {
+	Create(c *gin.Context)
+	Find(c *gin.Context)
+	FindByID(c *gin.Context)
+	PatchByID(c *gin.Context)
+	DeleteByID(c *gin.Context)
+	FindSessions(c *gin.Context)
+	Simulate(c *gin.Context)
+}
+
+func NewFlowController() FlowController {
+	return &flowController{
+		svc: service.NewFlowService(),
+	}
+}
+
+func (ctrl *flowController) Create(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	// Flows are enabled unless created disabled
+	flow := model.Flow{Enabled: true}
+	err := c.ShouldBindBodyWithJSON(&flow)
+	if err != nil {
+		types.NewBadRequestError("Invalid Request Body", err).WriteHttpResponse(c)
+		return
+	}
+
+	new, appErr := ctrl.svc.Create(orgID, &flow, actorID(c))
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.Header("ETag", entityTag(new.Version))
+	c.JSON(http.StatusAccepted, writeSuccessHttpResponseObj("Flow created!", "flow", new))
+}
+
+func (ctrl *flowController) Find(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	set, appErr := ctrl.svc.Find(orgID)
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	if len(set) == 0 {
+		c.JSON(http.StatusOK, writeSuccessHttpResponseObj("No Flows Found!", "", nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, writeSuccessHttpResponseObj("Flows Found!", "flows", set))
+}
+
+func (ctrl *flowController) FindByID(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	id, ok := uintParam(c, "flow_id")
+	if !ok {
+		return
+	}
+
+	flow, appErr := ctrl.svc.FindByID(orgID, id)
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.Header("ETag", entityTag(flow.Version))
+	c.JSON(http.StatusOK, writeSuccessHttpResponseObj("Flow Found!", "flow", flow))
+}
+
+func (ctrl *flowController) PatchByID(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	id, ok := uintParam(c, "flow_id")
+	if !ok {
+		return
+	}
+
+	version, ok := requireIfMatch(c, ctrl.currentVersion(orgID, id))
+	if !ok {
+		return
+	}
+
+	patch, ok := readMergePatch(c)
+	if !ok {
+		return
+	}
+
+	updated, appErr := ctrl.svc.PatchByID(orgID, patch, id, version, actorID(c))
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.Header("ETag", entityTag(updated.Version))
+	c.JSON(http.StatusAccepted, writeSuccessHttpResponseObj("Flow updated!", "flow", updated))
+}
+
+func (ctrl *flowController) DeleteByID(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	id, ok := uintParam(c, "flow_id")
+	if !ok {
+		return
+	}
+
+	version, ok := requireIfMatch(c, ctrl.currentVersion(orgID, id))
+	if !ok {
+		return
+	}
+
+	appErr := ctrl.svc.DeleteByID(orgID, id, version, actorID(c))
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, writeSuccessHttpResponseObj("Flow Deleted!", "", nil))
+}
+
+func (ctrl *flowController) FindSessions(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	id, ok := uintParam(c, "flow_id")
+	if !ok {
+		return
+	}
+
+	var filter model.FlowSessionFilter
+	err := c.ShouldBindQuery(&filter)
+	if err != nil {
+		types.NewBadRequestError("Invalid Query Params", err).WriteHttpResponse(c)
+		return
+	}
+
+	set, page, appErr := ctrl.svc.FindSessions(orgID, id, &filter)
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	if len(set) == 0 {
+		c.JSON(http.StatusOK, writePaginatedHttpResponseObj("No Flow Sessions Found!", "sessions", []*model.FlowSession{}, page))
+		return
+	}
+
+	c.JSON(http.StatusOK, writePaginatedHttpResponseObj("Flow Sessions Found!", "sessions", set, page))
+}
+
+// Plays a conversation through the flow without sending anything
+func (ctrl *flowController) Simulate(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	id, ok := uintParam(c, "flow_id")
+	if !ok {
+		return
+	}
+
+	var simulation model.FlowSimulation
+	err := c.ShouldBindBodyWithJSON(&simulation)
+	if err != nil {
+		types.NewBadRequestError("Invalid Request Body", err).WriteHttpResponse(c)
+		return
+	}
+
+	result, appErr := ctrl.svc.Simulate(orgID, id, &simulation)
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, writeSuccessHttpResponseObj("Flow Simulated!", "result", result))
+}
+
+// Reads the flow's current version, for If-Match headers listing several
+func (ctrl *flowController) currentVersion(orgID uint64, id uint64) func() (uint64, *types.ApplicationError) {
+	return func() (uint64, *types.ApplicationError) {
+		flow, appErr := ctrl.svc.FindByID(orgID, id)
+		if appErr != nil {
+			return 0, appErr
+		}
+		return flow.Version, nil
+	}
+}
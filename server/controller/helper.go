@@ -1,7 +1,14 @@
 package controller
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
+
 	"github.com/gin-gonic/gin"
+	"github.com/supermario64bit/whatsapp_connect/pkg/mergepatch"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+	"github.com/supermario64bit/whatsapp_connect/types"
 )
 
 func writeSuccessHttpResponseObj(message string, resultObjName string, result interface{}) gin.H {
@@ -20,12 +27,116 @@ func writeSuccessHttpResponseObj(message string, resultObjName string, result in
 	}
 }
 
-func writeFailedHttpResponseObj(message string, err error) gin.H {
+// Identifies the user performing the request, as authenticated by Authenticate. Returns 0 on
+// routes which are not authenticated.
+func actorID(c *gin.Context) uint64 {
+	return c.GetUint64(actorContextKey)
+}
+
+func writePaginatedHttpResponseObj(message string, resultObjName string, result interface{}, page *model.Pagination) gin.H {
 	return gin.H{
-		"status":  "failed",
+		"status":  "success",
 		"message": message,
 		"result": gin.H{
-			"error": err.Error(),
+			resultObjName: result,
+			"pagination":  page,
 		},
 	}
 }
+
+func entityTag(version uint64) string {
+	return `"` + strconv.FormatUint(version, 10) + `"`
+}
+
+// Reads the version a conditional request was made against from If-Match, a comma separated
+// list of entity tags. A wildcard matches any version and yields 0. Weak tags never match, as
+// writes need the strong comparison. When the list names several versions, current is called to
+// pick the one the entity is at. Writes the failure response itself and returns false when the
+// header is missing or cannot match the entity.
+func requireIfMatch(c *gin.Context, current func() (uint64, *types.ApplicationError)) (uint64, bool) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" {
+		types.NewPreconditionRequiredError("Precondition Required", fmt.Errorf("If-Match header is required")).WriteHttpResponse(c)
+		return 0, false
+	}
+
+	versions := map[uint64]bool{}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return 0, true
+		}
+
+		version, ok := strongEntityTagVersion(tag)
+		if ok {
+			versions[version] = true
+		}
+	}
+
+	noMatch := types.NewPreconditionFailedError("Precondition Failed", fmt.Errorf("If-Match header %s does not match the current version", header))
+	if len(versions) == 0 {
+		noMatch.WriteHttpResponse(c)
+		return 0, false
+	}
+
+	if len(versions) == 1 {
+		for version := range versions {
+			return version, true
+		}
+	}
+
+	version, appErr := current()
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return 0, false
+	}
+	if !versions[version] {
+		noMatch.WriteHttpResponse(c)
+		return 0, false
+	}
+
+	return version, true
+}
+
+// Parses a strong entity tag as written by entityTag. Returns false for weak tags and tags this
+// server never issued.
+func strongEntityTagVersion(tag string) (uint64, bool) {
+	if len(tag) < 2 || !strings.HasPrefix(tag, `"`) || !strings.HasSuffix(tag, `"`) {
+		return 0, false
+	}
+
+	version, err := strconv.ParseUint(tag[1:len(tag)-1], 10, 64)
+	if err != nil || version == 0 {
+		return 0, false
+	}
+	return version, true
+}
+
+// Reads a JSON merge patch body. Writes the failure response itself and returns false when the
+// request is not sent as application/merge-patch+json, so a plain JSON body meant for PUT is
+// never mistaken for a patch.
+func readMergePatch(c *gin.Context) ([]byte, bool) {
+	if c.ContentType() != mergepatch.ContentType {
+		c.Header("Accept-Patch", mergepatch.ContentType)
+		types.NewUnsupportedMediaTypeError("Unsupported Media Type", fmt.Errorf("Content-Type must be %s", mergepatch.ContentType)).WriteHttpResponse(c)
+		return nil, false
+	}
+
+	patch, err := c.GetRawData()
+	if err != nil {
+		types.NewBadRequestError("Invalid Request Body", err).WriteHttpResponse(c)
+		return nil, false
+	}
+	return patch, true
+}
+
+// Parses a numeric path parameter. Writes the failure response itself and returns false when
+// the parameter is not a valid id.
+func uintParam(c *gin.Context, name string) (uint64, bool) {
+	value, err := strconv.ParseUint(c.Param(name), 10, 64)
+	if err != nil {
+		types.NewBadRequestError("Invalid Request Params", fmt.Errorf("%s must be a positive integer", name)).WriteHttpResponse(c)
+		return 0, false
+	}
+	return value, true
+}
@@ -0,0 +1,211 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+	"github.com/supermario64bit/whatsapp_connect/server/service"
+	"github.com/supermario64bit/whatsapp_connect/types"
+)
+
+type inboxController struct {
+	svc service.InboxService
+}
+
+type InboxController interface {
+	Find(c *gin.Context)
+	Assign(c *gin.Context)
+	Unassign(c *gin.Context)
+	SetStatus(c *gin.Context)
+	MarkRead(c *gin.Context)
+	AddNote(c *gin.Context)
+	FindNotes(c *gin.Context)
+	Reply(c *gin.Context)
+}
+
+func NewInboxController() InboxController {
+	return &inboxController{
+		svc: service.NewInboxService(),
+	}
+}
+
+func (ctrl *inboxController) Find(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var filter model.ConversationFilter
+	err := c.ShouldBindQuery(&filter)
+	if err != nil {
+		types.NewBadRequestError("Invalid Query Params", err).WriteHttpResponse(c)
+		return
+	}
+
+	set, page, appErr := ctrl.svc.Find(orgID, &filter, actorID(c))
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	if len(set) == 0 {
+		c.JSON(http.StatusOK, writePaginatedHttpResponseObj("No Conversations Found!", "conversations", []*model.Conversation{}, page))
+		return
+	}
+
+	c.JSON(http.StatusOK, writePaginatedHttpResponseObj("Conversations Found!", "conversations", set, page))
+}
+
+func (ctrl *inboxController) Assign(c *gin.Context) {
+	orgID, id, ok := conversationParams(c)
+	if !ok {
+		return
+	}
+
+	var assignment model.ConversationAssignment
+	err := c.ShouldBindBodyWithJSON(&assignment)
+	if err != nil {
+		types.NewBadRequestError("Invalid Request Body", err).WriteHttpResponse(c)
+		return
+	}
+
+	conversation, appErr := ctrl.svc.Assign(orgID, id, &assignment, actorID(c))
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, writeSuccessHttpResponseObj("Conversation assigned!", "conversation", conversation))
+}
+
+func (ctrl *inboxController) Unassign(c *gin.Context) {
+	orgID, id, ok := conversationParams(c)
+	if !ok {
+		return
+	}
+
+	conversation, appErr := ctrl.svc.Unassign(orgID, id, actorID(c))
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, writeSuccessHttpResponseObj("Conversation unassigned!", "conversation", conversation))
+}
+
+func (ctrl *inboxController) SetStatus(c *gin.Context) {
+	orgID, id, ok := conversationParams(c)
+	if !ok {
+		return
+	}
+
+	var change model.ConversationStatusChange
+	err := c.ShouldBindBodyWithJSON(&change)
+	if err != nil {
+		types.NewBadRequestError("Invalid Request Body", err).WriteHttpResponse(c)
+		return
+	}
+
+	conversation, appErr := ctrl.svc.SetStatus(orgID, id, &change, actorID(c))
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, writeSuccessHttpResponseObj("Conversation status changed!", "conversation", conversation))
+}
+
+func (ctrl *inboxController) MarkRead(c *gin.Context) {
+	orgID, id, ok := conversationParams(c)
+	if !ok {
+		return
+	}
+
+	conversation, appErr := ctrl.svc.MarkRead(orgID, id, actorID(c))
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, writeSuccessHttpResponseObj("Conversation marked as read!", "conversation", conversation))
+}
+
+func (ctrl *inboxController) AddNote(c *gin.Context) {
+	orgID, id, ok := conversationParams(c)
+	if !ok {
+		return
+	}
+
+	var note model.ConversationNote
+	err := c.ShouldBindBodyWithJSON(&note)
+	if err != nil {
+		types.NewBadRequestError("Invalid Request Body", err).WriteHttpResponse(c)
+		return
+	}
+
+	new, appErr := ctrl.svc.AddNote(orgID, id, &note, actorID(c))
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, writeSuccessHttpResponseObj("Note added!", "note", new))
+}
+
+func (ctrl *inboxController) FindNotes(c *gin.Context) {
+	orgID, id, ok := conversationParams(c)
+	if !ok {
+		return
+	}
+
+	set, appErr := ctrl.svc.FindNotes(orgID, id, actorID(c))
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	if len(set) == 0 {
+		c.JSON(http.StatusOK, writeSuccessHttpResponseObj("No Notes Found!", "", nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, writeSuccessHttpResponseObj("Notes Found!", "notes", set))
+}
+
+func (ctrl *inboxController) Reply(c *gin.Context) {
+	orgID, id, ok := conversationParams(c)
+	if !ok {
+		return
+	}
+
+	var reply model.TextMessageRequest
+	err := c.ShouldBindBodyWithJSON(&reply)
+	if err != nil {
+		types.NewBadRequestError("Invalid Request Body", err).WriteHttpResponse(c)
+		return
+	}
+
+	message, appErr := ctrl.svc.Reply(orgID, id, &reply, actorID(c))
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, writeSuccessHttpResponseObj("Reply sent!", "message", message))
+}
+
+// Reads the organisation and conversation ids every inbox action is nested under
+func conversationParams(c *gin.Context) (uint64, uint64, bool) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return 0, 0, false
+	}
+
+	id, ok := uintParam(c, "conversation_id")
+	if !ok {
+		return 0, 0, false
+	}
+
+	return orgID, id, true
+}
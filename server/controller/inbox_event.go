@@ -0,0 +1,140 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+	"github.com/supermario64bit/whatsapp_connect/server/service"
+	"github.com/supermario64bit/whatsapp_connect/types"
+)
+
+const (
+	lastEventIDHeader = "Last-Event-ID"
+	// Keeps idle connections from being closed by proxies along the way
+	heartbeatInterval = 15 * time.Second
+	// Delay browsers wait before reconnecting, in milliseconds
+	reconnectDelay = 3000
+)
+
+type inboxEventController struct {
+	svc  service.InboxEventService
+	auth service.AuthService
+}
+
+type InboxEventController interface {
+	IssueToken(c *gin.Context)
+	Stream(c *gin.Context)
+}
+
+func NewInboxEventController() InboxEventController {
+	return &inboxEventController{
+		svc:  service.NewInboxEventService(),
+		auth: service.NewAuthService(),
+	}
+}
+
+// Issues a short lived token which opens the organisation's event stream as the caller
+func (ctrl *inboxEventController) IssueToken(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	token, appErr := ctrl.auth.IssueStreamToken(orgID, actorID(c))
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusCreated, writeSuccessHttpResponseObj("Stream token issued!", "stream_token", token))
+}
+
+// Streams the organisation's inbox events as Server-Sent Events. Browsers authenticate with a
+// token from IssueToken in the access_token query parameter. Clients resume after a disconnect
+// by sending the id of the last event they received in the Last-Event-ID header, or the
+// last_event_id query parameter where headers cannot be set.
+func (ctrl *inboxEventController) Stream(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var filter model.InboxEventFilter
+	err := c.ShouldBindQuery(&filter)
+	if err != nil {
+		types.NewBadRequestError("Invalid Query Params", err).WriteHttpResponse(c)
+		return
+	}
+
+	lastEventID := c.GetHeader(lastEventIDHeader)
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+	var lastID uint64
+	if lastEventID != "" {
+		lastID, err = strconv.ParseUint(lastEventID, 10, 64)
+		if err != nil {
+			types.NewBadRequestError("Invalid Last Event ID", fmt.Errorf("Last event id %s is not a valid event id", lastEventID)).WriteHttpResponse(c)
+			return
+		}
+	}
+
+	sub, backlog, appErr := ctrl.svc.Subscribe(orgID, &filter, lastID, actorID(c))
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+	defer ctrl.svc.Unsubscribe(sub)
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	// Stops nginx from buffering the stream
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	c.Render(-1, sse.Event{Event: "ready", Retry: reconnectDelay, Data: gin.H{"last_event_id": lastID}})
+	for _, event := range backlog {
+		writeInboxEvent(c, event)
+		lastID = event.ID
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case event, ok := <-sub.Events():
+			if !ok {
+				// Fell behind. The client reconnects and resumes from the last event it got.
+				return
+			}
+			if event.ID <= lastID {
+				continue
+			}
+			writeInboxEvent(c, event)
+			lastID = event.ID
+			c.Writer.Flush()
+		case <-heartbeat.C:
+			// Comment lines are ignored by clients
+			fmt.Fprint(c.Writer, ": heartbeat\n\n")
+			c.Writer.Flush()
+		}
+	}
+}
+
+func writeInboxEvent(c *gin.Context, event *model.InboxEvent) {
+	c.Render(-1, sse.Event{
+		Id:    strconv.FormatUint(event.ID, 10),
+		Event: event.Type,
+		Data:  event,
+	})
+}
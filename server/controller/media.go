@@ -0,0 +1,102 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/supermario64bit/whatsapp_connect/server/service"
+	"github.com/supermario64bit/whatsapp_connect/types"
+)
+
+type mediaController struct {
+	svc service.MediaService
+}
+
+type MediaController interface {
+	Upload(c *gin.Context)
+	FindByID(c *gin.Context)
+	ServeLocal(c *gin.Context)
+}
+
+func NewMediaController() MediaController {
+	return &mediaController{
+		svc: service.NewMediaService(),
+	}
+}
+
+// Accepts a multipart form with the file in "file" and the account to upload it for in
+// "whatsapp_account_id"
+func (ctrl *mediaController) Upload(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	accountID, err := strconv.ParseUint(c.PostForm("whatsapp_account_id"), 10, 64)
+	if err != nil {
+		types.NewBadRequestError("Invalid Request Body", fmt.Errorf("whatsapp_account_id must be a positive integer")).WriteHttpResponse(c)
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		types.NewBadRequestError("Invalid Request Body", fmt.Errorf("A file is required in the file field")).WriteHttpResponse(c)
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		types.NewBadRequestError("Invalid Request Body", err).WriteHttpResponse(c)
+		return
+	}
+	defer file.Close()
+
+	media, appErr := ctrl.svc.Upload(orgID, accountID, fileHeader.Filename, fileHeader.Header.Get("Content-Type"), fileHeader.Size, file, actorID(c))
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, writeSuccessHttpResponseObj("Media uploaded!", "media", media))
+}
+
+func (ctrl *mediaController) FindByID(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	id, ok := uintParam(c, "media_id")
+	if !ok {
+		return
+	}
+
+	media, appErr := ctrl.svc.FindByID(orgID, id)
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, writeSuccessHttpResponseObj("Media Found!", "media", media))
+}
+
+// Serves a locally stored file to the holder of a signed link
+func (ctrl *mediaController) ServeLocal(c *gin.Context) {
+	key := strings.TrimPrefix(c.Param("key"), "/")
+
+	media, file, appErr := ctrl.svc.OpenLocal(key, c.Query("expires"), c.Query("signature"))
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+	defer file.Close()
+
+	headers := map[string]string{"Cache-Control": "private, max-age=900"}
+	if media.Filename != "" {
+		headers["Content-Disposition"] = fmt.Sprintf("inline; filename=%q", media.Filename)
+	}
+	c.DataFromReader(http.StatusOK, media.Size, media.MimeType, file, headers)
+}
@@ -0,0 +1,191 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+	"github.com/supermario64bit/whatsapp_connect/server/service"
+	"github.com/supermario64bit/whatsapp_connect/types"
+)
+
+type messageController struct {
+	svc service.MessageService
+}
+
+type MessageController interface {
+	Send(c *gin.Context)
+	SendText(c *gin.Context)
+	SendMedia(c *gin.Context)
+	Find(c *gin.Context)
+	FindByContact(c *gin.Context)
+	FindByConversation(c *gin.Context)
+	FindByID(c *gin.Context)
+}
+
+func NewMessageController() MessageController {
+	return &messageController{
+		svc: service.NewMessageService(),
+	}
+}
+
+func (ctrl *messageController) Send(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var req model.SendTemplateRequest
+	err := c.ShouldBindBodyWithJSON(&req)
+	if err != nil {
+		types.NewBadRequestError("Invalid Request Body", err).WriteHttpResponse(c)
+		return
+	}
+
+	message, appErr := ctrl.svc.SendTemplate(orgID, &req, actorID(c))
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, writeSuccessHttpResponseObj("Message sent!", "message", message))
+}
+
+func (ctrl *messageController) SendText(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var req model.SendTextRequest
+	err := c.ShouldBindBodyWithJSON(&req)
+	if err != nil {
+		types.NewBadRequestError("Invalid Request Body", err).WriteHttpResponse(c)
+		return
+	}
+
+	message, appErr := ctrl.svc.SendText(orgID, &req)
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, writeSuccessHttpResponseObj("Message sent!", "message", message))
+}
+
+func (ctrl *messageController) SendMedia(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var req model.SendMediaRequest
+	err := c.ShouldBindBodyWithJSON(&req)
+	if err != nil {
+		types.NewBadRequestError("Invalid Request Body", err).WriteHttpResponse(c)
+		return
+	}
+
+	message, appErr := ctrl.svc.SendMedia(orgID, &req)
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, writeSuccessHttpResponseObj("Message sent!", "message", message))
+}
+
+func (ctrl *messageController) Find(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var filter model.MessageFilter
+	err := c.ShouldBindQuery(&filter)
+	if err != nil {
+		types.NewBadRequestError("Invalid Query Params", err).WriteHttpResponse(c)
+		return
+	}
+
+	set, page, appErr := ctrl.svc.Find(orgID, &filter)
+	writeMessagePage(c, set, page, appErr)
+}
+
+func (ctrl *messageController) FindByContact(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	contactID, ok := uintParam(c, "contact_id")
+	if !ok {
+		return
+	}
+
+	var filter model.MessageFilter
+	err := c.ShouldBindQuery(&filter)
+	if err != nil {
+		types.NewBadRequestError("Invalid Query Params", err).WriteHttpResponse(c)
+		return
+	}
+
+	set, page, appErr := ctrl.svc.FindByContact(orgID, contactID, &filter)
+	writeMessagePage(c, set, page, appErr)
+}
+
+func (ctrl *messageController) FindByConversation(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	conversationID, ok := uintParam(c, "conversation_id")
+	if !ok {
+		return
+	}
+
+	var filter model.MessageFilter
+	err := c.ShouldBindQuery(&filter)
+	if err != nil {
+		types.NewBadRequestError("Invalid Query Params", err).WriteHttpResponse(c)
+		return
+	}
+
+	set, page, appErr := ctrl.svc.FindByConversation(orgID, conversationID, &filter)
+	writeMessagePage(c, set, page, appErr)
+}
+
+func (ctrl *messageController) FindByID(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	id, ok := uintParam(c, "message_id")
+	if !ok {
+		return
+	}
+
+	message, appErr := ctrl.svc.FindByID(orgID, id)
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, writeSuccessHttpResponseObj("Message Found!", "message", message))
+}
+
+func writeMessagePage(c *gin.Context, set []*model.Message, page *model.Pagination, appErr *types.ApplicationError) {
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	if len(set) == 0 {
+		c.JSON(http.StatusOK, writePaginatedHttpResponseObj("No Messages Found!", "messages", []*model.Message{}, page))
+		return
+	}
+
+	c.JSON(http.StatusOK, writePaginatedHttpResponseObj("Messages Found!", "messages", set, page))
+}
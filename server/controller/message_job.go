@@ -0,0 +1,93 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+	"github.com/supermario64bit/whatsapp_connect/server/service"
+	"github.com/supermario64bit/whatsapp_connect/types"
+)
+
+type messageJobController struct {
+	svc service.MessageJobService
+}
+
+type MessageJobController interface {
+	Find(c *gin.Context)
+	FindByID(c *gin.Context)
+	Retry(c *gin.Context)
+}
+
+func NewMessageJobController() MessageJobController {
+	return &messageJobController{
+		svc: service.NewMessageJobService(),
+	}
+}
+
+func (ctrl *messageJobController) Find(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var filter model.MessageJobFilter
+	err := c.ShouldBindQuery(&filter)
+	if err != nil {
+		types.NewBadRequestError("Invalid Query Params", err).WriteHttpResponse(c)
+		return
+	}
+
+	set, page, appErr := ctrl.svc.Find(orgID, &filter)
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	if len(set) == 0 {
+		c.JSON(http.StatusOK, writePaginatedHttpResponseObj("No Message Jobs Found!", "jobs", []*model.MessageJob{}, page))
+		return
+	}
+
+	c.JSON(http.StatusOK, writePaginatedHttpResponseObj("Message Jobs Found!", "jobs", set, page))
+}
+
+func (ctrl *messageJobController) FindByID(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	id, ok := uintParam(c, "job_id")
+	if !ok {
+		return
+	}
+
+	job, appErr := ctrl.svc.FindByID(orgID, id)
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, writeSuccessHttpResponseObj("Message Job Found!", "job", job))
+}
+
+func (ctrl *messageJobController) Retry(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	id, ok := uintParam(c, "job_id")
+	if !ok {
+		return
+	}
+
+	job, appErr := ctrl.svc.Retry(orgID, id, actorID(c))
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, writeSuccessHttpResponseObj("Message Job queued!", "job", job))
+}
@@ -0,0 +1,195 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+	"github.com/supermario64bit/whatsapp_connect/server/service"
+	"github.com/supermario64bit/whatsapp_connect/types"
+)
+
+type messageTemplateController struct {
+	svc service.MessageTemplateService
+}
+
+type MessageTemplateController interface {
+	Create(c *gin.Context)
+	Find(c *gin.Context)
+	FindByID(c *gin.Context)
+	PatchByID(c *gin.Context)
+	DeleteByID(c *gin.Context)
+	Sync(c *gin.Context)
+}
+
+func NewMessageTemplateController() MessageTemplateController {
+	return &messageTemplateController{
+		svc: service.NewMessageTemplateService(),
+	}
+}
+
+// Reads the organisation and whatsapp account ids every template route is nested under
+func accountParams(c *gin.Context) (uint64, uint64, bool) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return 0, 0, false
+	}
+
+	accountID, ok := uintParam(c, "account_id")
+	if !ok {
+		return 0, 0, false
+	}
+
+	return orgID, accountID, true
+}
+
+func (ctrl *messageTemplateController) Create(c *gin.Context) {
+	orgID, accountID, ok := accountParams(c)
+	if !ok {
+		return
+	}
+
+	var tpl model.MessageTemplate
+	err := c.ShouldBindBodyWithJSON(&tpl)
+	if err != nil {
+		types.NewBadRequestError("Invalid Request Body", err).WriteHttpResponse(c)
+		return
+	}
+
+	new, appErr := ctrl.svc.Create(orgID, accountID, &tpl, actorID(c))
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.Header("ETag", entityTag(new.Version))
+	c.JSON(http.StatusAccepted, writeSuccessHttpResponseObj("Message template created!", "message_template", new))
+}
+
+func (ctrl *messageTemplateController) Find(c *gin.Context) {
+	orgID, accountID, ok := accountParams(c)
+	if !ok {
+		return
+	}
+
+	set, appErr := ctrl.svc.Find(orgID, accountID)
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	if len(set) == 0 {
+		c.JSON(http.StatusOK, writeSuccessHttpResponseObj("No Message Templates Found!", "", nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, writeSuccessHttpResponseObj("Message Templates Found!", "message_templates", set))
+}
+
+func (ctrl *messageTemplateController) FindByID(c *gin.Context) {
+	orgID, accountID, ok := accountParams(c)
+	if !ok {
+		return
+	}
+
+	id, ok := uintParam(c, "template_id")
+	if !ok {
+		return
+	}
+
+	tpl, appErr := ctrl.svc.FindByID(orgID, accountID, id)
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.Header("ETag", entityTag(tpl.Version))
+	c.JSON(http.StatusOK, writeSuccessHttpResponseObj("Message Template Found!", "message_template", tpl))
+}
+
+func (ctrl *messageTemplateController) PatchByID(c *gin.Context) {
+	orgID, accountID, ok := accountParams(c)
+	if !ok {
+		return
+	}
+
+	id, ok := uintParam(c, "template_id")
+	if !ok {
+		return
+	}
+
+	version, ok := requireIfMatch(c, ctrl.currentVersion(orgID, accountID, id))
+	if !ok {
+		return
+	}
+
+	patch, ok := readMergePatch(c)
+	if !ok {
+		return
+	}
+
+	updated, appErr := ctrl.svc.PatchByID(orgID, accountID, patch, id, version, actorID(c))
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.Header("ETag", entityTag(updated.Version))
+	c.JSON(http.StatusAccepted, writeSuccessHttpResponseObj("Message template updated!", "message_template", updated))
+}
+
+func (ctrl *messageTemplateController) DeleteByID(c *gin.Context) {
+	orgID, accountID, ok := accountParams(c)
+	if !ok {
+		return
+	}
+
+	id, ok := uintParam(c, "template_id")
+	if !ok {
+		return
+	}
+
+	version, ok := requireIfMatch(c, ctrl.currentVersion(orgID, accountID, id))
+	if !ok {
+		return
+	}
+
+	appErr := ctrl.svc.DeleteByID(orgID, accountID, id, version, actorID(c))
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, writeSuccessHttpResponseObj("Message Template Deleted!", "", nil))
+}
+
+// Pulls templates and their review status from Meta
+func (ctrl *messageTemplateController) Sync(c *gin.Context) {
+	orgID, accountID, ok := accountParams(c)
+	if !ok {
+		return
+	}
+
+	set, appErr := ctrl.svc.Sync(orgID, accountID, actorID(c))
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	if set == nil {
+		set = []*model.MessageTemplate{}
+	}
+
+	c.JSON(http.StatusOK, writeSuccessHttpResponseObj("Message Templates Synced!", "message_templates", set))
+}
+
+// Reads the template's current version, for If-Match headers listing several
+func (ctrl *messageTemplateController) currentVersion(orgID uint64, accountID uint64, id uint64) func() (uint64, *types.ApplicationError) {
+	return func() (uint64, *types.ApplicationError) {
+		tpl, appErr := ctrl.svc.FindByID(orgID, accountID, id)
+		if appErr != nil {
+			return 0, appErr
+		}
+		return tpl.Version, nil
+	}
+}
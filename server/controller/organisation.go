@@ -9,6 +9,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/supermario64bit/whatsapp_connect/server/model"
 	"github.com/supermario64bit/whatsapp_connect/server/service"
+	"github.com/supermario64bit/whatsapp_connect/types"
 )
 
 type organisationController struct {
@@ -20,6 +21,7 @@ type OrganisationController interface {
 	Find(c *gin.Context)
 	FindByID(c *gin.Context)
 	UpdateByID(c *gin.Context)
+	PatchByID(c *gin.Context)
 	DeleteByID(c *gin.Context)
 }
 
@@ -34,32 +36,31 @@ func (ctrl *organisationController) Create(c *gin.Context) {
 
 	err := c.ShouldBindBodyWithJSON(&org)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, writeFailedHttpResponseObj("Invalid Request Body", err))
+		types.NewBadRequestError("Invalid Request Body", err).WriteHttpResponse(c)
 		return
 	}
 
-	new, appErr := ctrl.svc.Create(&org)
+	new, appErr := ctrl.svc.Create(&org, actorID(c))
 	if appErr != nil {
-		c.JSON(appErr.HttpStatus, writeFailedHttpResponseObj(appErr.Message, appErr.Err))
+		appErr.WriteHttpResponse(c)
 		return
 	}
 
+	c.Header("ETag", entityTag(new.Version))
 	c.JSON(http.StatusAccepted, writeSuccessHttpResponseObj("Organisation created!", "organisation", new))
 }
 
 func (ctrl *organisationController) Find(c *gin.Context) {
 	var filter model.Organisation
 	err := c.ShouldBindJSON(&filter)
-	if errors.Is(err, io.EOF) {
-		filter = model.Organisation{}
-	} else {
-		c.JSON(http.StatusBadRequest, writeFailedHttpResponseObj("Invalid Request Body", err))
+	if err != nil && !errors.Is(err, io.EOF) {
+		types.NewBadRequestError("Invalid Request Body", err).WriteHttpResponse(c)
 		return
 	}
 
-	set, appErr := ctrl.svc.Find(&filter)
+	set, appErr := ctrl.svc.Find(&filter, actorID(c))
 	if appErr != nil {
-		c.JSON(appErr.HttpStatus, writeFailedHttpResponseObj(appErr.Message, appErr.Err))
+		appErr.WriteHttpResponse(c)
 		return
 	}
 
@@ -75,21 +76,17 @@ func (ctrl *organisationController) FindByID(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.ParseUint(idStr, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, writeFailedHttpResponseObj("Invalid Request Params", err))
+		types.NewBadRequestError("Invalid Request Params", err).WriteHttpResponse(c)
 		return
 	}
 
 	org, appErr := ctrl.svc.FindByID(id)
 	if appErr != nil {
-		c.JSON(appErr.HttpStatus, writeFailedHttpResponseObj(appErr.Message, appErr.Err))
-		return
-	}
-
-	if org == nil {
-		c.JSON(http.StatusNotFound, writeSuccessHttpResponseObj("No organisations found for the id "+idStr, "", nil))
+		appErr.WriteHttpResponse(c)
 		return
 	}
 
+	c.Header("ETag", entityTag(org.Version))
 	c.JSON(http.StatusOK, writeSuccessHttpResponseObj("Organisation Found!", "organisation", org))
 }
 
@@ -97,22 +94,57 @@ func (ctrl *organisationController) UpdateByID(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.ParseUint(idStr, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, writeFailedHttpResponseObj("Invalid Request Params", err))
+		types.NewBadRequestError("Invalid Request Params", err).WriteHttpResponse(c)
+		return
+	}
+
+	version, ok := requireIfMatch(c, ctrl.currentVersion(id))
+	if !ok {
 		return
 	}
+
 	var updates model.Organisation
 	err = c.ShouldBindBodyWithJSON(&updates)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, writeFailedHttpResponseObj("Invalid Request Body", err))
+		types.NewBadRequestError("Invalid Request Body", err).WriteHttpResponse(c)
+		return
+	}
+
+	updated, appErr := ctrl.svc.UpdateByID(&updates, id, version, actorID(c))
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.Header("ETag", entityTag(updated.Version))
+	c.JSON(http.StatusAccepted, writeSuccessHttpResponseObj("Organisation updated!", "organisation", updated))
+}
+
+func (ctrl *organisationController) PatchByID(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		types.NewBadRequestError("Invalid Request Params", err).WriteHttpResponse(c)
+		return
+	}
+
+	version, ok := requireIfMatch(c, ctrl.currentVersion(id))
+	if !ok {
+		return
+	}
+
+	patch, ok := readMergePatch(c)
+	if !ok {
 		return
 	}
 
-	updated, appErr := ctrl.svc.UpdateByID(&updates, id)
+	updated, appErr := ctrl.svc.PatchByID(patch, id, version, actorID(c))
 	if appErr != nil {
-		c.JSON(appErr.HttpStatus, writeFailedHttpResponseObj(appErr.Message, appErr.Err))
+		appErr.WriteHttpResponse(c)
 		return
 	}
 
+	c.Header("ETag", entityTag(updated.Version))
 	c.JSON(http.StatusAccepted, writeSuccessHttpResponseObj("Organisation updated!", "organisation", updated))
 }
 
@@ -120,15 +152,31 @@ func (ctrl *organisationController) DeleteByID(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.ParseUint(idStr, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, writeFailedHttpResponseObj("Invalid Request Params", err))
+		types.NewBadRequestError("Invalid Request Params", err).WriteHttpResponse(c)
+		return
+	}
+
+	version, ok := requireIfMatch(c, ctrl.currentVersion(id))
+	if !ok {
 		return
 	}
 
-	appErr := ctrl.svc.DeleteByID(id)
+	appErr := ctrl.svc.DeleteByID(id, version, actorID(c))
 	if appErr != nil {
-		c.JSON(appErr.HttpStatus, writeFailedHttpResponseObj(appErr.Message, appErr.Err))
+		appErr.WriteHttpResponse(c)
 		return
 	}
 
 	c.JSON(http.StatusOK, writeSuccessHttpResponseObj("Organisation Deleted!", "", nil))
 }
+
+// Reads the organisation's current version, for If-Match headers listing several
+func (ctrl *organisationController) currentVersion(id uint64) func() (uint64, *types.ApplicationError) {
+	return func() (uint64, *types.ApplicationError) {
+		org, appErr := ctrl.svc.FindByID(id)
+		if appErr != nil {
+			return 0, appErr
+		}
+		return org.Version, nil
+	}
+}
@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+	"github.com/supermario64bit/whatsapp_connect/server/service"
+	"github.com/supermario64bit/whatsapp_connect/types"
+)
+
+type organisationMemberController struct {
+	svc service.OrganisationMemberService
+}
+
+type OrganisationMemberController interface {
+	Create(c *gin.Context)
+	Find(c *gin.Context)
+	DeleteByUserID(c *gin.Context)
+}
+
+func NewOrganisationMemberController() OrganisationMemberController {
+	return &organisationMemberController{
+		svc: service.NewOrganisationMemberService(),
+	}
+}
+
+func (ctrl *organisationMemberController) Create(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var member model.OrganisationMember
+	err := c.ShouldBindBodyWithJSON(&member)
+	if err != nil {
+		types.NewBadRequestError("Invalid Request Body", err).WriteHttpResponse(c)
+		return
+	}
+
+	new, appErr := ctrl.svc.Create(orgID, &member, actorID(c))
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, writeSuccessHttpResponseObj("Member added!", "member", new))
+}
+
+func (ctrl *organisationMemberController) Find(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	set, appErr := ctrl.svc.Find(orgID)
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	if len(set) == 0 {
+		c.JSON(http.StatusOK, writeSuccessHttpResponseObj("No Members Found!", "", nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, writeSuccessHttpResponseObj("Members Found!", "members", set))
+}
+
+func (ctrl *organisationMemberController) DeleteByUserID(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	userID, ok := uintParam(c, "user_id")
+	if !ok {
+		return
+	}
+
+	appErr := ctrl.svc.DeleteByUserID(orgID, userID, actorID(c))
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, writeSuccessHttpResponseObj("Member Removed!", "", nil))
+}
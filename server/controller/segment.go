@@ -0,0 +1,204 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+	"github.com/supermario64bit/whatsapp_connect/server/service"
+	"github.com/supermario64bit/whatsapp_connect/types"
+)
+
+type segmentController struct {
+	svc service.SegmentService
+}
+
+type SegmentController interface {
+	Create(c *gin.Context)
+	Find(c *gin.Context)
+	FindByID(c *gin.Context)
+	PatchByID(c *gin.Context)
+	DeleteByID(c *gin.Context)
+	Preview(c *gin.Context)
+	PreviewByID(c *gin.Context)
+}
+
+func NewSegmentController() SegmentController {
+	return &segmentController{
+		svc: service.NewSegmentService(),
+	}
+}
+
+func (ctrl *segmentController) Create(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var segment model.Segment
+	err := c.ShouldBindBodyWithJSON(&segment)
+	if err != nil {
+		types.NewBadRequestError("Invalid Request Body", err).WriteHttpResponse(c)
+		return
+	}
+
+	new, appErr := ctrl.svc.Create(orgID, &segment, actorID(c))
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.Header("ETag", entityTag(new.Version))
+	c.JSON(http.StatusAccepted, writeSuccessHttpResponseObj("Segment created!", "segment", new))
+}
+
+func (ctrl *segmentController) Find(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	set, appErr := ctrl.svc.Find(orgID)
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	if len(set) == 0 {
+		c.JSON(http.StatusOK, writeSuccessHttpResponseObj("No Segments Found!", "", nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, writeSuccessHttpResponseObj("Segments Found!", "segments", set))
+}
+
+func (ctrl *segmentController) FindByID(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	id, ok := uintParam(c, "segment_id")
+	if !ok {
+		return
+	}
+
+	segment, appErr := ctrl.svc.FindByID(orgID, id)
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.Header("ETag", entityTag(segment.Version))
+	c.JSON(http.StatusOK, writeSuccessHttpResponseObj("Segment Found!", "segment", segment))
+}
+
+func (ctrl *segmentController) PatchByID(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	id, ok := uintParam(c, "segment_id")
+	if !ok {
+		return
+	}
+
+	version, ok := requireIfMatch(c, ctrl.currentVersion(orgID, id))
+	if !ok {
+		return
+	}
+
+	patch, ok := readMergePatch(c)
+	if !ok {
+		return
+	}
+
+	updated, appErr := ctrl.svc.PatchByID(orgID, patch, id, version, actorID(c))
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.Header("ETag", entityTag(updated.Version))
+	c.JSON(http.StatusAccepted, writeSuccessHttpResponseObj("Segment updated!", "segment", updated))
+}
+
+func (ctrl *segmentController) DeleteByID(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	id, ok := uintParam(c, "segment_id")
+	if !ok {
+		return
+	}
+
+	version, ok := requireIfMatch(c, ctrl.currentVersion(orgID, id))
+	if !ok {
+		return
+	}
+
+	appErr := ctrl.svc.DeleteByID(orgID, id, version, actorID(c))
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, writeSuccessHttpResponseObj("Segment Deleted!", "", nil))
+}
+
+// Previews unsaved rules, sent as {"rules": {...}}
+func (ctrl *segmentController) Preview(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var segment model.Segment
+	err := c.ShouldBindBodyWithJSON(&segment)
+	if err != nil {
+		types.NewBadRequestError("Invalid Request Body", err).WriteHttpResponse(c)
+		return
+	}
+
+	preview, appErr := ctrl.svc.Preview(orgID, &segment.Rules)
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, writeSuccessHttpResponseObj("Segment Previewed!", "preview", preview))
+}
+
+func (ctrl *segmentController) PreviewByID(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	id, ok := uintParam(c, "segment_id")
+	if !ok {
+		return
+	}
+
+	preview, appErr := ctrl.svc.PreviewByID(orgID, id)
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, writeSuccessHttpResponseObj("Segment Previewed!", "preview", preview))
+}
+
+// Reads the segment's current version, for If-Match headers listing several
+func (ctrl *segmentController) currentVersion(orgID uint64, id uint64) func() (uint64, *types.ApplicationError) {
+	return func() (uint64, *types.ApplicationError) {
+		segment, appErr := ctrl.svc.FindByID(orgID, id)
+		if appErr != nil {
+			return 0, appErr
+		}
+		return segment.Version, nil
+	}
+}
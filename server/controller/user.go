@@ -9,6 +9,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/supermario64bit/whatsapp_connect/server/model"
 	"github.com/supermario64bit/whatsapp_connect/server/service"
+	"github.com/supermario64bit/whatsapp_connect/types"
 )
 
 type userController struct {
@@ -20,6 +21,7 @@ type UserController interface {
 	Find(c *gin.Context)
 	FindByID(c *gin.Context)
 	UpdateByID(c *gin.Context)
+	PatchByID(c *gin.Context)
 	DeleteByID(c *gin.Context)
 }
 
@@ -34,32 +36,31 @@ func (ctrl *userController) Create(c *gin.Context) {
 
 	err := c.ShouldBindBodyWithJSON(&user)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, writeFailedHttpResponseObj("Invalid Request Body", err))
+		types.NewBadRequestError("Invalid Request Body", err).WriteHttpResponse(c)
 		return
 	}
 
-	new, appErr := ctrl.svc.Create(&user)
+	new, appErr := ctrl.svc.Create(&user, actorID(c))
 	if appErr != nil {
-		c.JSON(appErr.HttpStatus, writeFailedHttpResponseObj(appErr.Message, appErr.Err))
+		appErr.WriteHttpResponse(c)
 		return
 	}
 
+	c.Header("ETag", entityTag(new.Version))
 	c.JSON(http.StatusAccepted, writeSuccessHttpResponseObj("User created!", "user", new))
 }
 
 func (ctrl *userController) Find(c *gin.Context) {
 	var filter model.User
 	err := c.ShouldBindJSON(&filter)
-	if errors.Is(err, io.EOF) {
-		filter = model.User{}
-	} else {
-		c.JSON(http.StatusBadRequest, writeFailedHttpResponseObj("Invalid Request Body", err))
+	if err != nil && !errors.Is(err, io.EOF) {
+		types.NewBadRequestError("Invalid Request Body", err).WriteHttpResponse(c)
 		return
 	}
 
-	set, appErr := ctrl.svc.Find(&filter)
+	set, appErr := ctrl.svc.Find(&filter, actorID(c))
 	if appErr != nil {
-		c.JSON(appErr.HttpStatus, writeFailedHttpResponseObj(appErr.Message, appErr.Err))
+		appErr.WriteHttpResponse(c)
 		return
 	}
 
@@ -75,21 +76,17 @@ func (ctrl *userController) FindByID(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.ParseUint(idStr, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, writeFailedHttpResponseObj("Invalid Request Params", err))
+		types.NewBadRequestError("Invalid Request Params", err).WriteHttpResponse(c)
 		return
 	}
 
-	user, appErr := ctrl.svc.FindByID(id)
+	user, appErr := ctrl.svc.FindByID(id, actorID(c))
 	if appErr != nil {
-		c.JSON(appErr.HttpStatus, writeFailedHttpResponseObj(appErr.Message, appErr.Err))
-		return
-	}
-
-	if user == nil {
-		c.JSON(http.StatusNotFound, writeSuccessHttpResponseObj("No users found for the id "+idStr, "", nil))
+		appErr.WriteHttpResponse(c)
 		return
 	}
 
+	c.Header("ETag", entityTag(user.Version))
 	c.JSON(http.StatusOK, writeSuccessHttpResponseObj("User Found!", "user", user))
 }
 
@@ -97,22 +94,57 @@ func (ctrl *userController) UpdateByID(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.ParseUint(idStr, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, writeFailedHttpResponseObj("Invalid Request Params", err))
+		types.NewBadRequestError("Invalid Request Params", err).WriteHttpResponse(c)
+		return
+	}
+
+	version, ok := requireIfMatch(c, ctrl.currentVersion(id, actorID(c)))
+	if !ok {
 		return
 	}
+
 	var updates model.User
 	err = c.ShouldBindBodyWithJSON(&updates)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, writeFailedHttpResponseObj("Invalid Request Body", err))
+		types.NewBadRequestError("Invalid Request Body", err).WriteHttpResponse(c)
+		return
+	}
+
+	updated, appErr := ctrl.svc.UpdateByID(&updates, id, version, actorID(c))
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.Header("ETag", entityTag(updated.Version))
+	c.JSON(http.StatusAccepted, writeSuccessHttpResponseObj("User updated!", "user", updated))
+}
+
+func (ctrl *userController) PatchByID(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		types.NewBadRequestError("Invalid Request Params", err).WriteHttpResponse(c)
+		return
+	}
+
+	version, ok := requireIfMatch(c, ctrl.currentVersion(id, actorID(c)))
+	if !ok {
+		return
+	}
+
+	patch, ok := readMergePatch(c)
+	if !ok {
 		return
 	}
 
-	updated, appErr := ctrl.svc.UpdateByID(&updates, id)
+	updated, appErr := ctrl.svc.PatchByID(patch, id, version, actorID(c))
 	if appErr != nil {
-		c.JSON(appErr.HttpStatus, writeFailedHttpResponseObj(appErr.Message, appErr.Err))
+		appErr.WriteHttpResponse(c)
 		return
 	}
 
+	c.Header("ETag", entityTag(updated.Version))
 	c.JSON(http.StatusAccepted, writeSuccessHttpResponseObj("User updated!", "user", updated))
 }
 
@@ -120,15 +152,31 @@ func (ctrl *userController) DeleteByID(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.ParseUint(idStr, 10, 64)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, writeFailedHttpResponseObj("Invalid Request Params", err))
+		types.NewBadRequestError("Invalid Request Params", err).WriteHttpResponse(c)
+		return
+	}
+
+	version, ok := requireIfMatch(c, ctrl.currentVersion(id, actorID(c)))
+	if !ok {
 		return
 	}
 
-	appErr := ctrl.svc.DeleteByID(id)
+	appErr := ctrl.svc.DeleteByID(id, version, actorID(c))
 	if appErr != nil {
-		c.JSON(appErr.HttpStatus, writeFailedHttpResponseObj(appErr.Message, appErr.Err))
+		appErr.WriteHttpResponse(c)
 		return
 	}
 
 	c.JSON(http.StatusOK, writeSuccessHttpResponseObj("User Deleted!", "", nil))
 }
+
+// Reads the user's current version, for If-Match headers listing several
+func (ctrl *userController) currentVersion(id uint64, actorID uint64) func() (uint64, *types.ApplicationError) {
+	return func() (uint64, *types.ApplicationError) {
+		user, appErr := ctrl.svc.FindByID(id, actorID)
+		if appErr != nil {
+			return 0, appErr
+		}
+		return user.Version, nil
+	}
+}
@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"github.com/supermario64bit/whatsapp_connect/pkg/logger"
+	"github.com/supermario64bit/whatsapp_connect/pkg/whatsapp"
+	"github.com/supermario64bit/whatsapp_connect/server/service"
+	"github.com/supermario64bit/whatsapp_connect/types"
+)
+
+type webhookController struct {
+	svc         service.WebhookService
+	verifyToken string
+	appSecret   string
+}
+
+type WebhookController interface {
+	Verify(c *gin.Context)
+	Receive(c *gin.Context)
+}
+
+func NewWebhookController() WebhookController {
+	ctrl := &webhookController{
+		svc:         service.NewWebhookService(),
+		verifyToken: os.Getenv("WHATSAPP_VERIFY_TOKEN"),
+		appSecret:   os.Getenv("WHATSAPP_APP_SECRET"),
+	}
+
+	if ctrl.appSecret == "" {
+		logger.Warning("WHATSAPP_APP_SECRET is not set. Webhook deliveries will be refused, as their signatures cannot be verified.")
+	}
+
+	return ctrl
+}
+
+// Answers the subscription handshake Meta performs when the callback URL is configured
+func (ctrl *webhookController) Verify(c *gin.Context) {
+	if ctrl.verifyToken == "" || c.Query("hub.mode") != "subscribe" || c.Query("hub.verify_token") != ctrl.verifyToken {
+		c.String(http.StatusForbidden, "Forbidden")
+		return
+	}
+
+	c.String(http.StatusOK, c.Query("hub.challenge"))
+}
+
+func (ctrl *webhookController) Receive(c *gin.Context) {
+	// Without the secret anyone could post forged messages and statuses, so refuse everything
+	if ctrl.appSecret == "" {
+		types.NewForbiddenError("Webhook Not Configured", errors.New("WHATSAPP_APP_SECRET is not set")).WriteHttpResponse(c)
+		return
+	}
+
+	body, err := c.GetRawData()
+	if err != nil {
+		types.NewBadRequestError("Invalid Request Body", err).WriteHttpResponse(c)
+		return
+	}
+
+	if !whatsapp.VerifySignature(body, c.GetHeader(whatsapp.SignatureHeader), ctrl.appSecret) {
+		types.NewForbiddenError("Invalid Signature", errors.New("Webhook signature does not match the payload")).WriteHttpResponse(c)
+		return
+	}
+
+	var payload whatsapp.WebhookPayload
+	err = json.Unmarshal(body, &payload)
+	if err != nil {
+		types.NewBadRequestError("Invalid Request Body", err).WriteHttpResponse(c)
+		return
+	}
+
+	ctrl.svc.Handle(&payload)
+
+	c.JSON(http.StatusOK, writeSuccessHttpResponseObj("Webhook received!", "", nil))
+}
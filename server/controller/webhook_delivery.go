@@ -0,0 +1,108 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+	"github.com/supermario64bit/whatsapp_connect/server/service"
+	"github.com/supermario64bit/whatsapp_connect/types"
+)
+
+type webhookDeliveryController struct {
+	svc service.WebhookDeliveryService
+}
+
+type WebhookDeliveryController interface {
+	Find(c *gin.Context)
+	FindByID(c *gin.Context)
+	Redeliver(c *gin.Context)
+}
+
+func NewWebhookDeliveryController() WebhookDeliveryController {
+	return &webhookDeliveryController{
+		svc: service.NewWebhookDeliveryService(),
+	}
+}
+
+func (ctrl *webhookDeliveryController) Find(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	subscriptionID, ok := uintParam(c, "webhook_id")
+	if !ok {
+		return
+	}
+
+	var filter model.WebhookDeliveryFilter
+	err := c.ShouldBindQuery(&filter)
+	if err != nil {
+		types.NewBadRequestError("Invalid Query Params", err).WriteHttpResponse(c)
+		return
+	}
+
+	set, page, appErr := ctrl.svc.Find(orgID, subscriptionID, &filter)
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	if len(set) == 0 {
+		c.JSON(http.StatusOK, writePaginatedHttpResponseObj("No Webhook Deliveries Found!", "deliveries", []*model.WebhookDelivery{}, page))
+		return
+	}
+
+	c.JSON(http.StatusOK, writePaginatedHttpResponseObj("Webhook Deliveries Found!", "deliveries", set, page))
+}
+
+func (ctrl *webhookDeliveryController) FindByID(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	subscriptionID, ok := uintParam(c, "webhook_id")
+	if !ok {
+		return
+	}
+
+	id, ok := uintParam(c, "delivery_id")
+	if !ok {
+		return
+	}
+
+	delivery, appErr := ctrl.svc.FindByID(orgID, subscriptionID, id)
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, writeSuccessHttpResponseObj("Webhook Delivery Found!", "delivery", delivery))
+}
+
+func (ctrl *webhookDeliveryController) Redeliver(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	subscriptionID, ok := uintParam(c, "webhook_id")
+	if !ok {
+		return
+	}
+
+	id, ok := uintParam(c, "delivery_id")
+	if !ok {
+		return
+	}
+
+	delivery, appErr := ctrl.svc.Redeliver(orgID, subscriptionID, id, actorID(c))
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, writeSuccessHttpResponseObj("Webhook Delivery queued!", "delivery", delivery))
+}
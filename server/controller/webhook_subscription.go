@@ -0,0 +1,160 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+	"github.com/supermario64bit/whatsapp_connect/server/service"
+	"github.com/supermario64bit/whatsapp_connect/types"
+)
+
+type webhookSubscriptionController struct {
+	svc service.WebhookSubscriptionService
+}
+
+type WebhookSubscriptionController interface {
+	Create(c *gin.Context)
+	Find(c *gin.Context)
+	FindByID(c *gin.Context)
+	PatchByID(c *gin.Context)
+	DeleteByID(c *gin.Context)
+}
+
+func NewWebhookSubscriptionController() WebhookSubscriptionController {
+	return &webhookSubscriptionController{
+		svc: service.NewWebhookSubscriptionService(),
+	}
+}
+
+func (ctrl *webhookSubscriptionController) Create(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	// Subscriptions are enabled unless created disabled
+	subscription := model.WebhookSubscription{Enabled: true}
+	err := c.ShouldBindBodyWithJSON(&subscription)
+	if err != nil {
+		types.NewBadRequestError("Invalid Request Body", err).WriteHttpResponse(c)
+		return
+	}
+
+	new, appErr := ctrl.svc.Create(orgID, &subscription, actorID(c))
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.Header("ETag", entityTag(new.Version))
+	c.JSON(http.StatusAccepted, writeSuccessHttpResponseObj("Webhook subscription created!", "webhook", new))
+}
+
+func (ctrl *webhookSubscriptionController) Find(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	set, appErr := ctrl.svc.Find(orgID)
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	if len(set) == 0 {
+		c.JSON(http.StatusOK, writeSuccessHttpResponseObj("No Webhook Subscriptions Found!", "", nil))
+		return
+	}
+
+	c.JSON(http.StatusOK, writeSuccessHttpResponseObj("Webhook Subscriptions Found!", "webhooks", set))
+}
+
+func (ctrl *webhookSubscriptionController) FindByID(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	id, ok := uintParam(c, "webhook_id")
+	if !ok {
+		return
+	}
+
+	subscription, appErr := ctrl.svc.FindByID(orgID, id)
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.Header("ETag", entityTag(subscription.Version))
+	c.JSON(http.StatusOK, writeSuccessHttpResponseObj("Webhook Subscription Found!", "webhook", subscription))
+}
+
+func (ctrl *webhookSubscriptionController) PatchByID(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	id, ok := uintParam(c, "webhook_id")
+	if !ok {
+		return
+	}
+
+	version, ok := requireIfMatch(c, ctrl.currentVersion(orgID, id))
+	if !ok {
+		return
+	}
+
+	patch, ok := readMergePatch(c)
+	if !ok {
+		return
+	}
+
+	updated, appErr := ctrl.svc.PatchByID(orgID, patch, id, version, actorID(c))
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.Header("ETag", entityTag(updated.Version))
+	c.JSON(http.StatusAccepted, writeSuccessHttpResponseObj("Webhook subscription updated!", "webhook", updated))
+}
+
+func (ctrl *webhookSubscriptionController) DeleteByID(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	id, ok := uintParam(c, "webhook_id")
+	if !ok {
+		return
+	}
+
+	version, ok := requireIfMatch(c, ctrl.currentVersion(orgID, id))
+	if !ok {
+		return
+	}
+
+	appErr := ctrl.svc.DeleteByID(orgID, id, version, actorID(c))
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, writeSuccessHttpResponseObj("Webhook Subscription Deleted!", "", nil))
+}
+
+// Reads the webhook's current version, for If-Match headers listing several
+func (ctrl *webhookSubscriptionController) currentVersion(orgID uint64, id uint64) func() (uint64, *types.ApplicationError) {
+	return func() (uint64, *types.ApplicationError) {
+		subscription, appErr := ctrl.svc.FindByID(orgID, id)
+		if appErr != nil {
+			return 0, appErr
+		}
+		return subscription.Version, nil
+	}
+}
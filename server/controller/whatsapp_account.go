@@ -0,0 +1,189 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+	"github.com/supermario64bit/whatsapp_connect/server/service"
+	"github.com/supermario64bit/whatsapp_connect/types"
+)
+
+type whatsAppAccountController struct {
+	svc        service.WhatsAppAccountService
+	rateLimits service.RateLimitService
+}
+
+type WhatsAppAccountController interface {
+	Create(c *gin.Context)
+	Find(c *gin.Context)
+	FindByID(c *gin.Context)
+	UpdateLimits(c *gin.Context)
+	Usage(c *gin.Context)
+	DeleteByID(c *gin.Context)
+}
+
+func NewWhatsAppAccountController() WhatsAppAccountController {
+	return &whatsAppAccountController{
+		svc:        service.NewWhatsAppAccountService(),
+		rateLimits: service.NewRateLimitService(),
+	}
+}
+
+func (ctrl *whatsAppAccountController) Create(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var account model.WhatsAppAccount
+	err := c.ShouldBindBodyWithJSON(&account)
+	if err != nil {
+		types.NewBadRequestError("Invalid Request Body", err).WriteHttpResponse(c)
+		return
+	}
+
+	new, appErr := ctrl.svc.Create(orgID, &account, actorID(c))
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.Header("ETag", entityTag(new.Version))
+	c.JSON(http.StatusAccepted, writeSuccessHttpResponseObj("WhatsApp account created!", "whatsapp_account", new.Redacted()))
+}
+
+func (ctrl *whatsAppAccountController) Find(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	set, appErr := ctrl.svc.Find(orgID)
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	if len(set) == 0 {
+		c.JSON(http.StatusOK, writeSuccessHttpResponseObj("No WhatsApp Accounts Found!", "", nil))
+		return
+	}
+
+	redacted := make([]*model.WhatsAppAccount, 0, len(set))
+	for _, account := range set {
+		redacted = append(redacted, account.Redacted())
+	}
+
+	c.JSON(http.StatusOK, writeSuccessHttpResponseObj("WhatsApp Accounts Found!", "whatsapp_accounts", redacted))
+}
+
+func (ctrl *whatsAppAccountController) FindByID(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	id, ok := uintParam(c, "account_id")
+	if !ok {
+		return
+	}
+
+	account, appErr := ctrl.svc.FindByID(orgID, id)
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.Header("ETag", entityTag(account.Version))
+	c.JSON(http.StatusOK, writeSuccessHttpResponseObj("WhatsApp Account Found!", "whatsapp_account", account.Redacted()))
+}
+
+func (ctrl *whatsAppAccountController) UpdateLimits(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	id, ok := uintParam(c, "account_id")
+	if !ok {
+		return
+	}
+
+	version, ok := requireIfMatch(c, ctrl.currentVersion(orgID, id))
+	if !ok {
+		return
+	}
+
+	var limits model.WhatsAppAccountLimits
+	err := c.ShouldBindBodyWithJSON(&limits)
+	if err != nil {
+		types.NewBadRequestError("Invalid Request Body", err).WriteHttpResponse(c)
+		return
+	}
+
+	account, appErr := ctrl.svc.UpdateLimits(orgID, id, version, &limits, actorID(c))
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.Header("ETag", entityTag(account.Version))
+	c.JSON(http.StatusOK, writeSuccessHttpResponseObj("WhatsApp Account Limits Updated!", "whatsapp_account", account.Redacted()))
+}
+
+func (ctrl *whatsAppAccountController) Usage(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	id, ok := uintParam(c, "account_id")
+	if !ok {
+		return
+	}
+
+	usage, appErr := ctrl.rateLimits.Usage(orgID, id)
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, writeSuccessHttpResponseObj("WhatsApp Account Usage Found!", "usage", usage))
+}
+
+func (ctrl *whatsAppAccountController) DeleteByID(c *gin.Context) {
+	orgID, ok := uintParam(c, "id")
+	if !ok {
+		return
+	}
+
+	id, ok := uintParam(c, "account_id")
+	if !ok {
+		return
+	}
+
+	version, ok := requireIfMatch(c, ctrl.currentVersion(orgID, id))
+	if !ok {
+		return
+	}
+
+	appErr := ctrl.svc.DeleteByID(orgID, id, version, actorID(c))
+	if appErr != nil {
+		appErr.WriteHttpResponse(c)
+		return
+	}
+
+	c.JSON(http.StatusOK, writeSuccessHttpResponseObj("WhatsApp Account Deleted!", "", nil))
+}
+
+// Reads the account's current version, for If-Match headers listing several
+func (ctrl *whatsAppAccountController) currentVersion(orgID uint64, id uint64) func() (uint64, *types.ApplicationError) {
+	return func() (uint64, *types.ApplicationError) {
+		account, appErr := ctrl.svc.FindByID(orgID, id)
+		if appErr != nil {
+			return 0, appErr
+		}
+		return account.Version, nil
+	}
+}
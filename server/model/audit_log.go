@@ -0,0 +1,53 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	AuditActionCreate = "create"
+	AuditActionUpdate = "update"
+	AuditActionDelete = "delete"
+
+	AuditEntityOrganisation        = "organisation"
+	AuditEntityUser                = "user"
+	AuditEntityWhatsAppAccount     = "whatsapp_account"
+	AuditEntityContact             = "contact"
+	AuditEntityTag                 = "tag"
+	AuditEntitySegment             = "segment"
+	AuditEntityMessageTemplate     = "message_template"
+	AuditEntityMember              = "organisation_member"
+	AuditEntityConversation        = "conversation"
+	AuditEntityNote                = "conversation_note"
+	AuditEntityMedia               = "media"
+	AuditEntityCampaign            = "campaign"
+	AuditEntityMessageJob          = "message_job"
+	AuditEntityConsent             = "consent"
+	AuditEntityConsentKeyword      = "consent_keyword"
+	AuditEntityAutoReplyRule       = "auto_reply_rule"
+	AuditEntityFlow                = "flow"
+	AuditEntityBusinessHours       = "business_hours"
+	AuditEntityWebhookSubscription = "webhook_subscription"
+	AuditEntityWebhookDelivery     = "webhook_delivery"
+)
+
+type AuditLog struct {
+	ID             uint64          `json:"id" db:"id"`
+	ActorID        *uint64         `json:"actor_id" db:"actor_id"`
+	OrganisationID *uint64         `json:"organisation_id" db:"organisation_id"`
+	EntityType     string          `json:"entity_type" db:"entity_type"`
+	EntityID       uint64          `json:"entity_id" db:"entity_id"`
+	Action         string          `json:"action" db:"action"`
+	Diff           json.RawMessage `json:"diff" db:"diff"`
+	CreatedAt      time.Time       `json:"created_at" db:"created_at"`
+	OutboxEventID  *uint64         `json:"outbox_event_id,omitempty" db:"outbox_event_id"`
+}
+
+type AuditLogFilter struct {
+	EntityType     string `form:"entity_type"`
+	EntityID       uint64 `form:"entity_id"`
+	ActorID        uint64 `form:"actor_id"`
+	OrganisationID uint64 `form:"organisation_id"`
+	Pagination
+}
@@ -0,0 +1,218 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/supermario64bit/whatsapp_connect/types"
+)
+
+const (
+	// The whole message is the pattern, ignoring case, spacing and surrounding punctuation
+	AutoReplyMatchExact = "exact"
+	// The message holds the pattern, ignoring case and spacing
+	AutoReplyMatchContains = "contains"
+	// The message matches the pattern as an RE2 regular expression
+	AutoReplyMatchRegex = "regex"
+
+	AutoReplyResponseText     = "text"
+	AutoReplyResponseTemplate = "template"
+)
+
+// Reply sent automatically to inbound messages matching the pattern. Rules are tried from the
+// highest priority down and only the first active match replies.
+type AutoReplyRule struct {
+	ID             uint64 `json:"id" db:"id"`
+	OrganisationID uint64 `json:"organisation_id" db:"organisation_id"`
+	// Account whose messages the rule answers. Left out, the rule answers every account.
+	WhatsAppAccountID *uint64 `json:"whatsapp_account_id" db:"whatsapp_account_id"`
+	Name              string  `json:"name" db:"name" validate:"required,max=100"`
+	MatchType         string  `json:"match_type" db:"match_type" validate:"required,oneof=exact contains regex"`
+	Pattern           string  `json:"pattern" db:"pattern" validate:"required,max=500"`
+	Priority          int     `json:"priority" db:"priority" validate:"min=0,max=1000"`
+	Enabled           bool    `json:"enabled" db:"enabled"`
+	// Zone the time windows are given in
+	Timezone string `json:"timezone" db:"timezone"`
+	// Times the rule is active at. Without windows the rule is always active.
+	Windows   TimeWindows       `json:"windows" db:"windows"`
+	Response  AutoReplyResponse `json:"response" db:"response"`
+	CreatedAt time.Time         `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at" db:"updated_at"`
+	DeletedAt *time.Time        `json:"deleted_at" db:"deleted_at"`
+	Version   uint64            `json:"version" db:"version"`
+}
+
+// Text to reply with, or a template whose parameters are filled from the contact like those
+// of a campaign
+type AutoReplyResponse struct {
+	Type     string            `json:"type"`
+	Text     string            `json:"text,omitempty"`
+	Template *CampaignTemplate `json:"template,omitempty"`
+}
+
+// Message to try the organisation's rules against without replying
+type AutoReplyTest struct {
+	Text              string `json:"text" validate:"required,max=4096"`
+	WhatsAppAccountID uint64 `json:"whatsapp_account_id"`
+	// Contact template parameters are filled from. Left out, parameters take their defaults.
+	ContactID uint64 `json:"contact_id"`
+	// Time the message is taken to arrive at. Defaults to now.
+	At *time.Time `json:"at"`
+}
+
+// Outcome of a rule test, holding the reply the first matching rule would send
+type AutoReplyTestResult struct {
+	Matched  bool                    `json:"matched"`
+	Rule     *AutoReplyRule          `json:"rule"`
+	Text     string                  `json:"text,omitempty"`
+	Template *TemplateMessageRequest `json:"template,omitempty"`
+}
+
+func (rule *AutoReplyRule) Normalise() {
+	rule.Name = strings.TrimSpace(rule.Name)
+	rule.MatchType = strings.ToLower(strings.TrimSpace(rule.MatchType))
+	if rule.MatchType != AutoReplyMatchRegex {
+		rule.Pattern = strings.TrimSpace(rule.Pattern)
+	}
+	rule.Timezone = strings.TrimSpace(rule.Timezone)
+	if rule.Timezone == "" {
+		rule.Timezone = "UTC"
+	}
+	if rule.Windows == nil {
+		rule.Windows = TimeWindows{}
+	}
+	rule.Windows.Normalise()
+
+	rule.Response.Type = strings.ToLower(strings.TrimSpace(rule.Response.Type))
+	rule.Response.Text = strings.TrimSpace(rule.Response.Text)
+	if rule.Response.Template != nil {
+		rule.Response.Template.Name = strings.TrimSpace(rule.Response.Template.Name)
+		rule.Response.Template.Language = strings.TrimSpace(rule.Response.Template.Language)
+	}
+}
+
+// Validates the rule fields, its pattern, windows and response
+func (rule AutoReplyRule) ValidateFields() []types.FieldError {
+	details := types.NewValidationError(validateStruct(rule)).Details
+	return append(details, rule.validateParts()...)
+}
+
+// Validates only the fields named by their json keys, as sent in a partial update. The
+// pattern, windows and response are always checked, as they depend on one another.
+func (rule AutoReplyRule) ValidatePartial(fields []string) []types.FieldError {
+	details := types.NewValidationError(validatePartial(rule, fields)).Details
+	return append(details, rule.validateParts()...)
+}
+
+func (rule AutoReplyRule) validateParts() []types.FieldError {
+	var details []types.FieldError
+
+	if rule.MatchType == AutoReplyMatchRegex {
+		if _, err := regexp.Compile(rule.Pattern); err != nil {
+			details = append(details, types.FieldError{Field: "pattern", Rule: "regex", Message: "must be a valid regular expression: " + err.Error()})
+		}
+	}
+
+	if _, err := time.LoadLocation(rule.Timezone); err != nil {
+		details = append(details, types.FieldError{Field: "timezone", Rule: "timezone", Message: "must be an IANA time zone such as Asia/Kolkata"})
+	}
+
+	details = append(details, rule.Windows.validate("windows")...)
+
+	return append(details, rule.Response.validate("response", rule.WhatsAppAccountID != nil)...)
+}
+
+func (response AutoReplyResponse) validate(path string, hasAccount bool) []types.FieldError {
+	switch response.Type {
+	case AutoReplyResponseText:
+		if response.Text == "" || len(response.Text) > 4096 {
+			return []types.FieldError{{Field: path + ".text", Rule: "size", Message: "must hold between 1 and 4096 characters"}}
+		}
+		if response.Template != nil {
+			return []types.FieldError{{Field: path + ".template", Rule: "absent", Message: "must be left out for text responses"}}
+		}
+		return nil
+
+	case AutoReplyResponseTemplate:
+		if response.Template == nil {
+			return []types.FieldError{{Field: path + ".template", Rule: "required", Message: "must be given for template responses"}}
+		}
+		if !hasAccount {
+			return []types.FieldError{{Field: "whatsapp_account_id", Rule: "required", Message: "must be given for template responses, templates belong to an account"}}
+		}
+
+		// Name and language are checked along with the rule's own fields
+		var details []types.FieldError
+		for i, param := range response.Template.Header {
+			details = append(details, param.validate(fmt.Sprintf("%s.template.header[%d]", path, i))...)
+		}
+		for i, param := range response.Template.Body {
+			details = append(details, param.validate(fmt.Sprintf("%s.template.body[%d]", path, i))...)
+		}
+		for i, button := range response.Template.Buttons {
+			details = append(details, button.validate(fmt.Sprintf("%s.template.buttons[%d]", path, i))...)
+		}
+		return details
+	}
+
+	return []types.FieldError{{Field: path + ".type", Rule: "oneof", Message: "must be one of: text, template"}}
+}
+
+// Reports whether the message text matches the rule's pattern
+func (rule AutoReplyRule) Matches(text string) bool {
+	return matchText(rule.MatchType, rule.Pattern, text)
+}
+
+// Reports whether the text matches the pattern the way the match type compares them
+func matchText(matchType string, pattern string, text string) bool {
+	switch matchType {
+	case AutoReplyMatchExact:
+		pattern := NormaliseKeyword(pattern)
+		return pattern != "" && NormaliseKeyword(text) == pattern
+	case AutoReplyMatchContains:
+		pattern := strings.ToUpper(strings.Join(strings.Fields(pattern), " "))
+		return pattern != "" && strings.Contains(strings.ToUpper(strings.Join(strings.Fields(text), " ")), pattern)
+	case AutoReplyMatchRegex:
+		pattern, err := regexp.Compile(pattern)
+		return err == nil && pattern.MatchString(text)
+	}
+	return false
+}
+
+// Reports whether the rule is enabled and one of its windows holds the given time
+func (rule AutoReplyRule) ActiveAt(at time.Time) bool {
+	if !rule.Enabled {
+		return false
+	}
+	if len(rule.Windows) == 0 {
+		return true
+	}
+	return rule.Windows.Hold(rule.Timezone, at)
+}
+
+func (test *AutoReplyTest) Normalise() {
+	test.Text = strings.TrimSpace(test.Text)
+}
+
+func (test AutoReplyTest) ValidateFields() []error {
+	return validateStruct(test)
+}
+
+func (response AutoReplyResponse) Value() (driver.Value, error) {
+	raw, err := json.Marshal(response)
+	return string(raw), err
+}
+
+func (response *AutoReplyResponse) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, response)
+	case string:
+		return json.Unmarshal([]byte(v), response)
+	}
+	return fmt.Errorf("Cannot scan %T into AutoReplyResponse", src)
+}
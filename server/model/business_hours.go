@@ -0,0 +1,227 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/supermario64bit/whatsapp_connect/types"
+)
+
+const (
+	AutoMessageAway     = "away"
+	AutoMessageGreeting = "greeting"
+
+	// Interval automatic messages are sent at most once in, per contact, unless set otherwise
+	DefaultAutoMessageIntervalMinutes = 24 * 60
+	maxAutoMessageIntervalMinutes     = 30 * 24 * 60
+
+	maxHolidays = 366
+
+	holidayDateLayout = "2006-01-02"
+)
+
+// When an organisation is open, and the messages sent automatically to contacts writing in.
+// Contacts writing outside the hours get the away message, contacts writing during them get
+// the greeting. Each contact gets each message at most once per the message's interval.
+type BusinessHours struct {
+	OrganisationID uint64 `json:"organisation_id" db:"organisation_id"`
+	// Zone the hours and holidays are given in
+	Timezone string `json:"timezone" db:"timezone"`
+	// Weekly hours the organisation is open. Without windows it is open around the clock,
+	// holidays aside.
+	Windows         TimeWindows `json:"windows" db:"windows"`
+	Holidays        Holidays    `json:"holidays" db:"holidays"`
+	AwayMessage     AutoMessage `json:"away_message" db:"away_message"`
+	GreetingMessage AutoMessage `json:"greeting_message" db:"greeting_message"`
+	CreatedAt       time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time   `json:"updated_at" db:"updated_at"`
+	Version         uint64      `json:"version" db:"version"`
+}
+
+// Day the organisation is closed all day, in its zone. Yearly holidays fall on the same day
+// every year.
+//
+//	{"date": "2026-12-25", "name": "Christmas", "yearly": true}
+type Holiday struct {
+	Date   string `json:"date"`
+	Name   string `json:"name"`
+	Yearly bool   `json:"yearly"`
+}
+
+type Holidays []Holiday
+
+type AutoMessage struct {
+	Enabled bool   `json:"enabled"`
+	Text    string `json:"text"`
+	// Minutes after sending the message before the same contact may get it again
+	IntervalMinutes int `json:"interval_minutes"`
+}
+
+// Whether the organisation is open at a time
+type BusinessHoursStatus struct {
+	Open bool      `json:"open"`
+	At   time.Time `json:"at"`
+	// Holiday the organisation is closed for
+	Holiday *Holiday `json:"holiday"`
+}
+
+// Hours used by organisations which have not set any: always open, sending nothing
+func DefaultBusinessHours(orgID uint64) *BusinessHours {
+	return &BusinessHours{
+		OrganisationID:  orgID,
+		Timezone:        "UTC",
+		Windows:         TimeWindows{},
+		Holidays:        Holidays{},
+		AwayMessage:     AutoMessage{IntervalMinutes: DefaultAutoMessageIntervalMinutes},
+		GreetingMessage: AutoMessage{IntervalMinutes: DefaultAutoMessageIntervalMinutes},
+	}
+}
+
+func (hours *BusinessHours) Normalise() {
+	hours.Timezone = strings.TrimSpace(hours.Timezone)
+	if hours.Timezone == "" {
+		hours.Timezone = "UTC"
+	}
+	if hours.Windows == nil {
+		hours.Windows = TimeWindows{}
+	}
+	hours.Windows.Normalise()
+	if hours.Holidays == nil {
+		hours.Holidays = Holidays{}
+	}
+	for i := range hours.Holidays {
+		hours.Holidays[i].Date = strings.TrimSpace(hours.Holidays[i].Date)
+		hours.Holidays[i].Name = strings.TrimSpace(hours.Holidays[i].Name)
+	}
+	hours.AwayMessage.normalise()
+	hours.GreetingMessage.normalise()
+}
+
+func (message *AutoMessage) normalise() {
+	message.Text = strings.TrimSpace(message.Text)
+	if message.IntervalMinutes == 0 {
+		message.IntervalMinutes = DefaultAutoMessageIntervalMinutes
+	}
+}
+
+func (hours BusinessHours) ValidateFields() []types.FieldError {
+	var details []types.FieldError
+	if _, err := time.LoadLocation(hours.Timezone); err != nil {
+		details = append(details, types.FieldError{Field: "timezone", Rule: "timezone", Message: "must be an IANA time zone such as Asia/Kolkata"})
+	}
+
+	details = append(details, hours.Windows.validate("windows")...)
+
+	if len(hours.Holidays) > maxHolidays {
+		details = append(details, types.FieldError{Field: "holidays", Rule: "max", Message: fmt.Sprintf("must hold at most %d holidays", maxHolidays)})
+	}
+	for i, holiday := range hours.Holidays {
+		if _, err := time.Parse(holidayDateLayout, holiday.Date); err != nil {
+			details = append(details, types.FieldError{Field: fmt.Sprintf("holidays[%d].date", i), Rule: "date", Message: "must be a date such as 2026-12-25"})
+		}
+		if len(holiday.Name) > 100 {
+			details = append(details, types.FieldError{Field: fmt.Sprintf("holidays[%d].name", i), Rule: "max", Message: "must hold at most 100 characters"})
+		}
+	}
+
+	details = append(details, hours.AwayMessage.validate("away_message")...)
+	return append(details, hours.GreetingMessage.validate("greeting_message")...)
+}
+
+func (message AutoMessage) validate(path string) []types.FieldError {
+	var details []types.FieldError
+	if len(message.Text) > 4096 {
+		details = append(details, types.FieldError{Field: path + ".text", Rule: "max", Message: "must hold at most 4096 characters"})
+	}
+	if message.Enabled && message.Text == "" {
+		details = append(details, types.FieldError{Field: path + ".text", Rule: "required", Message: "must be given when the message is enabled"})
+	}
+	if message.IntervalMinutes < 1 || message.IntervalMinutes > maxAutoMessageIntervalMinutes {
+		details = append(details, types.FieldError{Field: path + ".interval_minutes", Rule: "range", Message: fmt.Sprintf("must be between 1 and %d", maxAutoMessageIntervalMinutes)})
+	}
+	return details
+}
+
+// Reports whether the organisation is open at the given time
+func (hours BusinessHours) StatusAt(at time.Time) BusinessHoursStatus {
+	status := BusinessHoursStatus{At: at}
+
+	location, err := time.LoadLocation(hours.Timezone)
+	if err != nil {
+		return status
+	}
+	local := at.In(location)
+
+	for i, holiday := range hours.Holidays {
+		if holiday.falls(local) {
+			status.Holiday = &hours.Holidays[i]
+			return status
+		}
+	}
+
+	status.Open = len(hours.Windows) == 0 || hours.Windows.Hold(hours.Timezone, at)
+	return status
+}
+
+func (holiday Holiday) falls(local time.Time) bool {
+	date, err := time.Parse(holidayDateLayout, holiday.Date)
+	if err != nil {
+		return false
+	}
+	if holiday.Yearly {
+		return date.Month() == local.Month() && date.Day() == local.Day()
+	}
+	return date.Year() == local.Year() && date.Month() == local.Month() && date.Day() == local.Day()
+}
+
+// Returns the message a contact writing at the given time gets, if any
+func (hours BusinessHours) MessageAt(at time.Time) (string, *AutoMessage) {
+	if hours.StatusAt(at).Open {
+		if hours.GreetingMessage.Enabled {
+			return AutoMessageGreeting, &hours.GreetingMessage
+		}
+		return "", nil
+	}
+
+	if hours.AwayMessage.Enabled {
+		return AutoMessageAway, &hours.AwayMessage
+	}
+	return "", nil
+}
+
+func (message AutoMessage) Interval() time.Duration {
+	return time.Duration(message.IntervalMinutes) * time.Minute
+}
+
+func (holidays Holidays) Value() (driver.Value, error) {
+	raw, err := json.Marshal(holidays)
+	return string(raw), err
+}
+
+func (holidays *Holidays) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, holidays)
+	case string:
+		return json.Unmarshal([]byte(v), holidays)
+	}
+	return fmt.Errorf("Cannot scan %T into Holidays", src)
+}
+
+func (message AutoMessage) Value() (driver.Value, error) {
+	raw, err := json.Marshal(message)
+	return string(raw), err
+}
+
+func (message *AutoMessage) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, message)
+	case string:
+		return json.Unmarshal([]byte(v), message)
+	}
+	return fmt.Errorf("Cannot scan %T into AutoMessage", src)
+}
@@ -0,0 +1,251 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/supermario64bit/whatsapp_connect/types"
+)
+
+const (
+	CampaignStatusDraft     = "draft"
+	CampaignStatusScheduled = "scheduled"
+	CampaignStatusRunning   = "running"
+	CampaignStatusCompleted = "completed"
+	CampaignStatusCancelled = "cancelled"
+	CampaignStatusFailed    = "failed"
+
+	CampaignRecipientStatusPending = "pending"
+	CampaignRecipientStatusSent    = "sent"
+	CampaignRecipientStatusFailed  = "failed"
+
+	// Contact fields a campaign parameter may be filled from, besides attributes.<key>
+	CampaignFieldName        = "name"
+	CampaignFieldPhoneNumber = "phone_number"
+)
+
+// Layouts accepted for the local time a campaign is sent at
+var campaignSendAtLayouts = []string{"2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02 15:04:05", "2006-01-02 15:04"}
+
+// Template sent to every contact of a segment
+type Campaign struct {
+	ID                uint64           `json:"id" db:"id"`
+	OrganisationID    uint64           `json:"organisation_id" db:"organisation_id"`
+	WhatsAppAccountID uint64           `json:"whatsapp_account_id" db:"whatsapp_account_id" validate:"required"`
+	SegmentID         uint64           `json:"segment_id" db:"segment_id" validate:"required"`
+	TemplateID        uint64           `json:"template_id" db:"template_id"`
+	Name              string           `json:"name" db:"name" validate:"required,max=100"`
+	Template          CampaignTemplate `json:"template" db:"template"`
+	Status            string           `json:"status" db:"status"`
+	// Zone the schedule was given in, shown back to the user
+	Timezone        string     `json:"timezone" db:"timezone"`
+	ScheduledAt     *time.Time `json:"scheduled_at" db:"scheduled_at"`
+	StartedAt       *time.Time `json:"started_at" db:"started_at"`
+	CompletedAt     *time.Time `json:"completed_at" db:"completed_at"`
+	TotalRecipients int        `json:"total_recipients" db:"total_recipients"`
+	LockedUntil     *time.Time `json:"-" db:"locked_until"`
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at" db:"updated_at"`
+	// Why a failed campaign could not be sent
+	FailureReason string `json:"failure_reason" db:"failure_reason"`
+
+	// Local time to send at. Left out, the campaign is kept as a draft.
+	SendAt string `json:"send_at,omitempty" db:"-"`
+}
+
+// Template of a campaign, whose parameters are resolved for each recipient
+type CampaignTemplate struct {
+	Name        string                `json:"name" validate:"required"`
+	Language    string                `json:"language" validate:"required"`
+	Header      []CampaignParameter   `json:"header"`
+	HeaderMedia *TemplateMediaRequest `json:"header_media"`
+	Body        []CampaignParameter   `json:"body"`
+	Buttons     []CampaignButton      `json:"buttons"`
+}
+
+// Placeholder value, either fixed or taken from a contact field. Default is used for contacts
+// lacking the field.
+//
+//	{"value": "20% off"}
+//	{"field": "attributes.city", "default": "your city"}
+type CampaignParameter struct {
+	Value   string `json:"value,omitempty"`
+	Field   string `json:"field,omitempty"`
+	Default string `json:"default,omitempty"`
+}
+
+type CampaignButton struct {
+	Index int `json:"index"`
+	CampaignParameter
+}
+
+type CampaignRecipient struct {
+	ID           uint64    `json:"id" db:"id"`
+	CampaignID   uint64    `json:"campaign_id" db:"campaign_id"`
+	ContactID    uint64    `json:"contact_id" db:"contact_id"`
+	Status       string    `json:"status" db:"status"`
+	MessageID    *uint64   `json:"message_id" db:"message_id"`
+	ErrorMessage string    `json:"error_message" db:"error_message"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type CampaignRecipientFilter struct {
+	Status string `form:"status"`
+	Pagination
+}
+
+// Progress of a campaign. Delivery counts are cumulative, so a read message also counts as sent
+// and delivered.
+type CampaignStats struct {
+	Recipients int `json:"recipients"`
+	Pending    int `json:"pending"`
+	// Messages waiting in our send queue or accepted by Meta and not yet sent on
+	Queued    int `json:"queued"`
+	Sent      int `json:"sent"`
+	Delivered int `json:"delivered"`
+	Read      int `json:"read"`
+	Failed    int `json:"failed"`
+	// Recipients who wrote back after receiving the campaign
+	Replied int `json:"replied"`
+}
+
+// Request to schedule a campaign. Without a send time the campaign is sent right away.
+type CampaignSchedule struct {
+	SendAt   string `json:"send_at"`
+	Timezone string `json:"timezone" validate:"omitempty,timezone"`
+}
+
+func (campaign *Campaign) Normalise() {
+	campaign.Name = strings.TrimSpace(campaign.Name)
+	campaign.Template.Name = strings.TrimSpace(campaign.Template.Name)
+	campaign.Template.Language = strings.TrimSpace(campaign.Template.Language)
+	campaign.Timezone = strings.TrimSpace(campaign.Timezone)
+	if campaign.Timezone == "" {
+		campaign.Timezone = "UTC"
+	}
+}
+
+// Validates the campaign fields and the shape of its parameters. Whether the parameters fit
+// the template is checked against the template itself.
+func (campaign Campaign) ValidateFields() []types.FieldError {
+	details := types.NewValidationError(validateStruct(campaign)).Details
+	details = append(details, types.NewValidationError(validateStruct(campaign.Template)).Details...)
+	if _, err := time.LoadLocation(campaign.Timezone); err != nil {
+		details = append(details, types.FieldError{Field: "timezone", Rule: "timezone", Message: "must be an IANA time zone such as Asia/Kolkata"})
+	}
+
+	for i, param := range campaign.Template.Header {
+		details = append(details, param.validate(fmt.Sprintf("template.header[%d]", i))...)
+	}
+	for i, param := range campaign.Template.Body {
+		details = append(details, param.validate(fmt.Sprintf("template.body[%d]", i))...)
+	}
+	for i, button := range campaign.Template.Buttons {
+		details = append(details, button.validate(fmt.Sprintf("template.buttons[%d]", i))...)
+	}
+	return details
+}
+
+func (param CampaignParameter) validate(path string) []types.FieldError {
+	if (param.Value == "") == (param.Field == "") {
+		return []types.FieldError{{Field: path, Rule: "shape", Message: "must have exactly one of value or field"}}
+	}
+
+	switch {
+	case param.Field == "", param.Field == CampaignFieldName, param.Field == CampaignFieldPhoneNumber:
+	case strings.HasPrefix(param.Field, SegmentFieldAttributePrefix) && len(param.Field) > len(SegmentFieldAttributePrefix):
+	default:
+		return []types.FieldError{{Field: path + ".field", Rule: "oneof", Message: "must be one of: name, phone_number, attributes.<key>"}}
+	}
+	return nil
+}
+
+// Fills the parameters in for the contact
+func (tpl CampaignTemplate) Resolve(contact *Contact) TemplateMessageRequest {
+	req := TemplateMessageRequest{
+		Name:        tpl.Name,
+		Language:    tpl.Language,
+		HeaderMedia: tpl.HeaderMedia,
+	}
+	for _, param := range tpl.Header {
+		req.Header = append(req.Header, param.Resolve(contact))
+	}
+	for _, param := range tpl.Body {
+		req.Body = append(req.Body, param.Resolve(contact))
+	}
+	for _, button := range tpl.Buttons {
+		req.Buttons = append(req.Buttons, TemplateButtonRequest{Index: button.Index, Value: button.Resolve(contact)})
+	}
+	return req
+}
+
+// Returns the fixed value, or the contact's field falling back to the default
+func (param CampaignParameter) Resolve(contact *Contact) string {
+	if param.Field == "" {
+		return param.Value
+	}
+
+	var value string
+	switch param.Field {
+	case CampaignFieldName:
+		value = contact.Name
+	case CampaignFieldPhoneNumber:
+		value = contact.PhoneNumber
+	default:
+		if attribute, ok := contact.Attributes[strings.TrimPrefix(param.Field, SegmentFieldAttributePrefix)]; ok && attribute != nil {
+			value = fmt.Sprint(attribute)
+		}
+	}
+
+	if strings.TrimSpace(value) == "" {
+		return param.Default
+	}
+	return value
+}
+
+// Returns when the schedule is due. A schedule without a send time is due now.
+func (schedule CampaignSchedule) Time(now time.Time) (time.Time, error) {
+	if strings.TrimSpace(schedule.SendAt) == "" {
+		return now, nil
+	}
+
+	zone := schedule.Timezone
+	if zone == "" {
+		zone = "UTC"
+	}
+	location, err := time.LoadLocation(zone)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	for _, layout := range campaignSendAtLayouts {
+		at, err := time.ParseInLocation(layout, strings.TrimSpace(schedule.SendAt), location)
+		if err == nil {
+			return at, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("Invalid send time %q", schedule.SendAt)
+}
+
+func (schedule CampaignSchedule) ValidateFields() []error {
+	return validateStruct(schedule)
+}
+
+func (tpl CampaignTemplate) Value() (driver.Value, error) {
+	raw, err := json.Marshal(tpl)
+	return string(raw), err
+}
+
+func (tpl *CampaignTemplate) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, tpl)
+	case string:
+		return json.Unmarshal([]byte(v), tpl)
+	}
+	return fmt.Errorf("Cannot scan %T into CampaignTemplate", src)
+}
@@ -0,0 +1,99 @@
+package model
+
+import (
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	// Consent set through the API, on the contact or recorded directly
+	ConsentSourceAPI = "api"
+	// Consent taken from a contact import
+	ConsentSourceImport = "import"
+	// Consent given or withdrawn by sending a keyword
+	ConsentSourceKeyword = "keyword"
+	// Consent collected outside the platform, recorded with its evidence
+	ConsentSourceWebsite  = "website"
+	ConsentSourceWhatsApp = "whatsapp"
+	ConsentSourceInPerson = "in_person"
+	ConsentSourceOther    = "other"
+)
+
+// Keywords used by organisations which configured none of their own
+var (
+	DefaultOptOutKeywords = []string{"STOP", "STOPALL", "UNSUBSCRIBE", "END", "QUIT"}
+	DefaultOptInKeywords  = []string{"START", "UNSTOP", "SUBSCRIBE"}
+)
+
+// Change of a contact's consent to be messaged
+type Consent struct {
+	ID             uint64  `json:"id" db:"id"`
+	OrganisationID uint64  `json:"organisation_id" db:"organisation_id"`
+	ContactID      uint64  `json:"contact_id" db:"contact_id"`
+	Status         string  `json:"status" db:"status" validate:"required,oneof=opted_in opted_out"`
+	Source         string  `json:"source" db:"source" validate:"required,oneof=api import keyword website whatsapp in_person other"`
+	Evidence       string  `json:"evidence" db:"evidence" validate:"max=2000"`
+	MessageID      *uint64 `json:"message_id" db:"message_id"`
+	ActorID        *uint64 `json:"actor_id" db:"actor_id"`
+	// When the contact gave or withdrew consent. Defaults to the time it is recorded.
+	RecordedAt time.Time `json:"recorded_at" db:"recorded_at"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+}
+
+// Inbound message text opting a contact in or out
+type ConsentKeyword struct {
+	ID             uint64    `json:"id" db:"id"`
+	OrganisationID uint64    `json:"organisation_id" db:"organisation_id"`
+	Keyword        string    `json:"keyword" db:"keyword" validate:"required,max=50"`
+	Status         string    `json:"status" db:"status" validate:"required,oneof=opted_in opted_out"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at" db:"updated_at"`
+}
+
+func (consent *Consent) Normalise() {
+	consent.Status = strings.TrimSpace(consent.Status)
+	consent.Source = strings.TrimSpace(consent.Source)
+	consent.Evidence = strings.TrimSpace(consent.Evidence)
+	if consent.Source == "" {
+		consent.Source = ConsentSourceAPI
+	}
+}
+
+func (consent Consent) ValidateFields() []error {
+	return validateStruct(consent)
+}
+
+// Reports whether the consent may opt a contact back in after they opted out by keyword. Only
+// consent collected with its own evidence may, a contact update or import never does.
+func (consent Consent) OverridesKeywordOptOut() bool {
+	return consent.Status == OptInStatusOptedIn && consent.Source != ConsentSourceAPI && consent.Source != ConsentSourceImport
+}
+
+func (keyword *ConsentKeyword) Normalise() {
+	keyword.Keyword = NormaliseKeyword(keyword.Keyword)
+	keyword.Status = strings.TrimSpace(keyword.Status)
+}
+
+func (keyword ConsentKeyword) ValidateFields() []error {
+	return validateStruct(keyword)
+}
+
+// Reports whether a contact with the given opt-in status may be sent a message. Contacts who
+// opted out get nothing. Templates reach customers outside a conversation they started, so they
+// also need the contact to have opted in.
+func ConsentAllows(optInStatus string, template bool) bool {
+	if optInStatus == OptInStatusOptedOut {
+		return false
+	}
+	return !template || optInStatus == OptInStatusOptedIn
+}
+
+// Brings message text into the form keywords are compared in: upper case, single spaced and
+// without surrounding punctuation, so "Stop." matches STOP
+func NormaliseKeyword(text string) string {
+	text = strings.TrimFunc(text, func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsPunct(r)
+	})
+	return strings.ToUpper(strings.Join(strings.Fields(text), " "))
+}
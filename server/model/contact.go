@@ -0,0 +1,77 @@
+package model
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	OptInStatusUnknown  = "unknown"
+	OptInStatusOptedIn  = "opted_in"
+	OptInStatusOptedOut = "opted_out"
+)
+
+type Contact struct {
+	ID             uint64     `json:"id" db:"id"`
+	OrganisationID uint64     `json:"organisation_id" db:"organisation_id"`
+	PhoneNumber    string     `json:"phone_number" db:"phone_number" validate:"required,phone"`
+	Name           string     `json:"name" db:"name" validate:"max=100"`
+	Attributes     JSONMap    `json:"attributes" db:"attributes"`
+	Tags           StringList `json:"tags" db:"tags" validate:"max=50,dive,min=1,max=50"`
+	OptInStatus    string     `json:"opt_in_status" db:"opt_in_status" validate:"required,oneof=unknown opted_in opted_out"`
+	LastSeenAt     *time.Time `json:"last_seen_at" db:"last_seen_at"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
+	DeletedAt      *time.Time `json:"deleted_at" db:"deleted_at"`
+	Version        uint64     `json:"version" db:"version"`
+}
+
+type ContactFilter struct {
+	// Matched against the name and phone number
+	Search      string `form:"search"`
+	Tag         string `form:"tag"`
+	OptInStatus string `form:"opt_in_status"`
+	Pagination
+}
+
+// Rewrites the phone number into E.164 and fills in defaults for fields left empty
+func (contact *Contact) Normalise() {
+	contact.PhoneNumber = normalisePhone(contact.PhoneNumber)
+	if contact.OptInStatus == "" {
+		contact.OptInStatus = OptInStatusUnknown
+	}
+	if contact.Attributes == nil {
+		contact.Attributes = JSONMap{}
+	}
+
+	// Tags are assigned by name, so blanks and repeats are dropped. They are kept sorted the way
+	// the repository reads them back, so reordering alone is never seen as a change.
+	tags := StringList{}
+	for _, tag := range contact.Tags {
+		tag = strings.TrimSpace(tag)
+		if tag != "" && !containsTag(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	sort.Strings(tags)
+	contact.Tags = tags
+}
+
+func containsTag(tags StringList, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func (contact Contact) ValidateFields() []error {
+	return validateStruct(contact)
+}
+
+// Validates only the fields named by their json keys, as sent in a partial update
+func (contact Contact) ValidatePartial(fields []string) []error {
+	return validatePartial(contact, fields)
+}
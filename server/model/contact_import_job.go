@@ -0,0 +1,68 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	ImportStatusPending   = "pending"
+	ImportStatusRunning   = "running"
+	ImportStatusCompleted = "completed"
+	ImportStatusFailed    = "failed"
+)
+
+type ContactImportJob struct {
+	ID             uint64          `json:"id" db:"id"`
+	OrganisationID uint64          `json:"organisation_id" db:"organisation_id"`
+	Status         string          `json:"status" db:"status"`
+	DryRun         bool            `json:"dry_run" db:"dry_run"`
+	FileName       string          `json:"file_name" db:"file_name"`
+	Mapping        JSONMap         `json:"mapping" db:"mapping"`
+	TotalRows      int             `json:"total_rows" db:"total_rows"`
+	ProcessedRows  int             `json:"processed_rows" db:"processed_rows"`
+	CreatedCount   int             `json:"created_count" db:"created_count"`
+	UpdatedCount   int             `json:"updated_count" db:"updated_count"`
+	SkippedCount   int             `json:"skipped_count" db:"skipped_count"`
+	FailedCount    int             `json:"failed_count" db:"failed_count"`
+	Errors         ImportRowErrors `json:"errors" db:"errors"`
+	CreatedBy      *uint64         `json:"created_by" db:"created_by"`
+	StartedAt      *time.Time      `json:"started_at" db:"started_at"`
+	FinishedAt     *time.Time      `json:"finished_at" db:"finished_at"`
+	CreatedAt      time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at" db:"updated_at"`
+}
+
+// Problem found with one row of an imported file. Rows are numbered from 1, excluding the header.
+type ImportRowError struct {
+	Row     int    `json:"row"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+type ImportRowErrors []ImportRowError
+
+func (e ImportRowErrors) Value() (driver.Value, error) {
+	if e == nil {
+		return "[]", nil
+	}
+
+	raw, err := json.Marshal(e)
+	return string(raw), err
+}
+
+func (e *ImportRowErrors) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, e)
+	case string:
+		return json.Unmarshal([]byte(v), e)
+	case nil:
+		*e = ImportRowErrors{}
+		return nil
+	default:
+		return fmt.Errorf("Cannot scan %T into ImportRowErrors", src)
+	}
+}
@@ -0,0 +1,73 @@
+package model
+
+import "time"
+
+// How long after the customer's last message free-form messages may be sent
+const CustomerServiceWindow = 24 * time.Hour
+
+const (
+	ConversationStatusOpen     = "open"
+	ConversationStatusPending  = "pending"
+	ConversationStatusResolved = "resolved"
+)
+
+// Thread of the messages exchanged between one contact and one WhatsApp account
+type Conversation struct {
+	ID                uint64     `json:"id" db:"id"`
+	OrganisationID    uint64     `json:"organisation_id" db:"organisation_id"`
+	WhatsAppAccountID uint64     `json:"whatsapp_account_id" db:"whatsapp_account_id"`
+	ContactID         uint64     `json:"contact_id" db:"contact_id"`
+	LastMessageAt     time.Time  `json:"last_message_at" db:"last_message_at"`
+	LastInboundAt     *time.Time `json:"last_inbound_at" db:"last_inbound_at"`
+	WindowExpiresAt   *time.Time `json:"window_expires_at" db:"window_expires_at"`
+	// Billing conversation Meta reported for the latest outbound message
+	ExternalID   string     `json:"external_id" db:"external_id"`
+	Category     string     `json:"category" db:"category"`
+	PricingModel string     `json:"pricing_model" db:"pricing_model"`
+	Billable     bool       `json:"billable" db:"billable"`
+	ExpiresAt    *time.Time `json:"expires_at" db:"expires_at"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at" db:"updated_at"`
+	Status       string     `json:"status" db:"status"`
+	// Member working the conversation
+	AssigneeID *uint64 `json:"assignee_id" db:"assignee_id"`
+	// Inbound messages since an agent last read the conversation
+	UnreadCount int        `json:"unread_count" db:"unread_count"`
+	LastReadAt  *time.Time `json:"last_read_at" db:"last_read_at"`
+
+	// Whether free-form messages may be sent right now
+	WindowOpen bool `json:"window_open" db:"-"`
+}
+
+type ConversationFilter struct {
+	ContactID         uint64 `form:"contact_id"`
+	WhatsAppAccountID uint64 `form:"whatsapp_account_id"`
+	// Only conversations whose customer service window is open, or closed
+	WindowOpen *bool  `form:"window_open"`
+	Status     string `form:"status"`
+	AssigneeID uint64 `form:"assignee_id"`
+	// Only conversations nobody is assigned to
+	Unassigned bool `form:"unassigned"`
+	Pagination
+}
+
+type ConversationAssignment struct {
+	UserID uint64 `json:"user_id" validate:"required"`
+}
+
+func (assignment ConversationAssignment) ValidateFields() []error {
+	return validateStruct(assignment)
+}
+
+type ConversationStatusChange struct {
+	Status string `json:"status" validate:"required,oneof=open pending resolved"`
+}
+
+func (change ConversationStatusChange) ValidateFields() []error {
+	return validateStruct(change)
+}
+
+// Reports whether the customer service window is open at the given time
+func (conversation Conversation) IsWindowOpen(at time.Time) bool {
+	return conversation.WindowExpiresAt != nil && at.Before(*conversation.WindowExpiresAt)
+}
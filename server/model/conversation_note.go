@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// Internal note left on a conversation by an agent. Notes are never sent to the contact.
+type ConversationNote struct {
+	ID             uint64    `json:"id" db:"id"`
+	OrganisationID uint64    `json:"organisation_id" db:"organisation_id"`
+	ConversationID uint64    `json:"conversation_id" db:"conversation_id"`
+	AuthorID       uint64    `json:"author_id" db:"author_id"`
+	Body           string    `json:"body" db:"body" validate:"required,max=4096"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at" db:"updated_at"`
+}
+
+func (note ConversationNote) ValidateFields() []error {
+	return validateStruct(note)
+}
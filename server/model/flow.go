@@ -0,0 +1,534 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/supermario64bit/whatsapp_connect/pkg/phone"
+	"github.com/supermario64bit/whatsapp_connect/pkg/safehttp"
+	"github.com/supermario64bit/whatsapp_connect/types"
+)
+
+const (
+	// Sends a text and moves on
+	FlowNodeSendMessage = "send_message"
+	// Sends a question and waits for the contact's answer
+	FlowNodeAskQuestion = "ask_question"
+	// Moves on to the node of the first condition holding
+	FlowNodeBranch = "branch"
+	// Writes a contact attribute
+	FlowNodeSetAttribute = "set_attribute"
+	// Calls an outside URL, keeping its response for later nodes
+	FlowNodeCallWebhook = "call_webhook"
+	// Ends the flow and leaves the conversation to the team inbox
+	FlowNodeHandover = "handover"
+
+	FlowAnswerText   = "text"
+	FlowAnswerNumber = "number"
+	FlowAnswerEmail  = "email"
+	FlowAnswerPhone  = "phone"
+	FlowAnswerChoice = "choice"
+	FlowAnswerRegex  = "regex"
+
+	FlowOperatorEquals    = "equals"
+	FlowOperatorNotEquals = "not_equals"
+	FlowOperatorContains  = "contains"
+	FlowOperatorMatches   = "matches"
+	FlowOperatorExists    = "exists"
+	FlowOperatorNotExists = "not_exists"
+	FlowOperatorGreater   = "gt"
+	FlowOperatorLess      = "lt"
+
+	// Flow values a condition or placeholder may read, besides the contact fields
+	FlowFieldAnswer          = "answer"
+	FlowFieldVariablesPrefix = "variables."
+
+	maxFlowNodes       = 200
+	maxFlowConditions  = 20
+	maxFlowRetries     = 10
+	maxFlowWebhookWait = 30
+)
+
+// Node ids are referenced from other nodes, so they are kept to plain names
+var flowNodeIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,100}$`)
+
+// Placeholders such as {{name}} or {{variables.order.status}} in texts and values
+var flowPlaceholderPattern = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_.-]+)\s*\}\}`)
+
+// Multi-step conversation run by the bot. Inbound messages matching the trigger start the flow
+// for their conversation, and the contact's next messages answer its questions until it ends.
+type Flow struct {
+	ID             uint64 `json:"id" db:"id"`
+	OrganisationID uint64 `json:"organisation_id" db:"organisation_id"`
+	// Account whose conversations the flow runs in. Left out, the flow runs for every account.
+	WhatsAppAccountID *uint64 `json:"whatsapp_account_id" db:"whatsapp_account_id"`
+	Name              string  `json:"name" db:"name" validate:"required,max=100"`
+	MatchType         string  `json:"match_type" db:"match_type" validate:"required,oneof=exact contains regex"`
+	Pattern           string  `json:"pattern" db:"pattern" validate:"required,max=500"`
+	// Flows are tried from the highest priority down, the first trigger matching starts
+	Priority   int            `json:"priority" db:"priority" validate:"min=0,max=1000"`
+	Enabled    bool           `json:"enabled" db:"enabled"`
+	Definition FlowDefinition `json:"definition" db:"definition"`
+	CreatedAt  time.Time      `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at" db:"updated_at"`
+	DeletedAt  *time.Time     `json:"deleted_at" db:"deleted_at"`
+	Version    uint64         `json:"version" db:"version"`
+}
+
+// Nodes of a flow, run from the start node. A node without a next node ends the flow.
+//
+//	{
+//	  "start": "ask_size",
+//	  "nodes": [
+//	    {"id": "ask_size", "type": "ask_question", "text": "Which size? S, M or L",
+//	     "validation": {"type": "choice", "choices": ["S", "M", "L"]}, "attribute": "size", "next": "thanks"},
+//	    {"id": "thanks", "type": "send_message", "text": "Thanks {{name}}, size {{answer}} it is"}
+//	  ]
+//	}
+type FlowDefinition struct {
+	Start string     `json:"start"`
+	Nodes []FlowNode `json:"nodes"`
+}
+
+// Step of a flow. Which fields apply depends on the type.
+type FlowNode struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	// Message sent by send_message, ask_question and handover nodes, with placeholders filled in
+	Text string `json:"text,omitempty"`
+	Next string `json:"next,omitempty"`
+
+	// Contact attribute an ask_question node saves the answer to, or a set_attribute node writes
+	Attribute string `json:"attribute,omitempty"`
+	// Value a set_attribute node writes, with placeholders filled in
+	Value string `json:"value,omitempty"`
+
+	// Answers an ask_question node accepts. Left out, any text is accepted.
+	Validation *FlowAnswerValidation `json:"validation,omitempty"`
+	// Sent in place of the question after an invalid answer
+	RetryText string `json:"retry_text,omitempty"`
+	// Invalid answers allowed before the node gives up
+	MaxRetries int `json:"max_retries,omitempty"`
+	// Node to go on with once the retries run out. Left out, the flow fails.
+	OnInvalid string `json:"on_invalid,omitempty"`
+
+	// Conditions of a branch node, tried in order
+	Conditions []FlowCondition `json:"conditions,omitempty"`
+	// Node a branch goes on with when no condition holds. Left out, the flow ends.
+	Default string `json:"default,omitempty"`
+
+	Webhook *FlowWebhook `json:"webhook,omitempty"`
+	// Node to go on with when the webhook fails. Left out, the flow fails.
+	OnError string `json:"on_error,omitempty"`
+
+	// Member a handover node assigns the conversation to. Left out, it stays unassigned.
+	AssigneeID *uint64 `json:"assignee_id,omitempty"`
+}
+
+type FlowAnswerValidation struct {
+	Type string `json:"type"`
+	// Answers a choice accepts. The contact may also answer with a choice's position, from 1.
+	Choices []string `json:"choices,omitempty"`
+	// Expression a regex answer must match
+	Pattern string `json:"pattern,omitempty"`
+}
+
+// Compares a flow value against a fixed value
+//
+//	{"field": "attributes.plan", "operator": "equals", "value": "gold", "next": "vip"}
+type FlowCondition struct {
+	Field    string `json:"field"`
+	Operator string `json:"operator"`
+	Value    string `json:"value,omitempty"`
+	Next     string `json:"next"`
+}
+
+// Outside call made by a call_webhook node. Without a body, POST requests send the contact and
+// the flow variables as JSON. A JSON response is kept under variables.<save_as>.
+type FlowWebhook struct {
+	URL     string            `json:"url"`
+	Method  string            `json:"method"`
+	Headers map[string]string `json:"headers,omitempty"`
+	// Request body, with placeholders filled in
+	Body           string `json:"body,omitempty"`
+	SaveAs         string `json:"save_as,omitempty"`
+	TimeoutSeconds int    `json:"timeout_seconds,omitempty"`
+}
+
+func (flow *Flow) Normalise() {
+	flow.Name = strings.TrimSpace(flow.Name)
+	flow.MatchType = strings.ToLower(strings.TrimSpace(flow.MatchType))
+	if flow.MatchType != AutoReplyMatchRegex {
+		flow.Pattern = strings.TrimSpace(flow.Pattern)
+	}
+	flow.Definition.Normalise()
+}
+
+func (definition *FlowDefinition) Normalise() {
+	definition.Start = strings.TrimSpace(definition.Start)
+	for i := range definition.Nodes {
+		node := &definition.Nodes[i]
+		node.ID = strings.TrimSpace(node.ID)
+		node.Type = strings.ToLower(strings.TrimSpace(node.Type))
+		node.Text = strings.TrimSpace(node.Text)
+		node.Next = strings.TrimSpace(node.Next)
+		node.Attribute = strings.TrimSpace(node.Attribute)
+		node.RetryText = strings.TrimSpace(node.RetryText)
+		node.OnInvalid = strings.TrimSpace(node.OnInvalid)
+		node.Default = strings.TrimSpace(node.Default)
+		node.OnError = strings.TrimSpace(node.OnError)
+		if node.Validation != nil {
+			node.Validation.Type = strings.ToLower(strings.TrimSpace(node.Validation.Type))
+		}
+		for j := range node.Conditions {
+			node.Conditions[j].Field = strings.TrimSpace(node.Conditions[j].Field)
+			node.Conditions[j].Operator = strings.ToLower(strings.TrimSpace(node.Conditions[j].Operator))
+			node.Conditions[j].Next = strings.TrimSpace(node.Conditions[j].Next)
+		}
+		if node.Webhook != nil {
+			node.Webhook.URL = strings.TrimSpace(node.Webhook.URL)
+			node.Webhook.Method = strings.ToUpper(strings.TrimSpace(node.Webhook.Method))
+			if node.Webhook.Method == "" {
+				node.Webhook.Method = "POST"
+			}
+			node.Webhook.SaveAs = strings.TrimSpace(node.Webhook.SaveAs)
+		}
+	}
+}
+
+// Validates the flow fields, its trigger and its definition
+func (flow Flow) ValidateFields() []types.FieldError {
+	details := types.NewValidationError(validateStruct(flow)).Details
+	return append(details, flow.validateParts()...)
+}
+
+// Validates only the fields named by their json keys, as sent in a partial update. The
+// trigger pattern and the definition are always checked.
+func (flow Flow) ValidatePartial(fields []string) []types.FieldError {
+	details := types.NewValidationError(validatePartial(flow, fields)).Details
+	return append(details, flow.validateParts()...)
+}
+
+func (flow Flow) validateParts() []types.FieldError {
+	var details []types.FieldError
+	if flow.MatchType == AutoReplyMatchRegex {
+		if _, err := regexp.Compile(flow.Pattern); err != nil {
+			details = append(details, types.FieldError{Field: "pattern", Rule: "regex", Message: "must be a valid regular expression: " + err.Error()})
+		}
+	}
+	return append(details, flow.Definition.Validate("definition")...)
+}
+
+// Validates the nodes and that every node they lead to exists
+func (definition FlowDefinition) Validate(path string) []types.FieldError {
+	if len(definition.Nodes) == 0 || len(definition.Nodes) > maxFlowNodes {
+		return []types.FieldError{{Field: path + ".nodes", Rule: "size", Message: fmt.Sprintf("must hold between 1 and %d nodes", maxFlowNodes)}}
+	}
+
+	var details []types.FieldError
+	ids := map[string]bool{}
+	for i, node := range definition.Nodes {
+		if !flowNodeIDPattern.MatchString(node.ID) {
+			details = append(details, types.FieldError{Field: fmt.Sprintf("%s.nodes[%d].id", path, i), Rule: "format", Message: "must be up to 100 letters, digits, dashes or underscores"})
+		} else if ids[node.ID] {
+			details = append(details, types.FieldError{Field: fmt.Sprintf("%s.nodes[%d].id", path, i), Rule: "unique", Message: "must differ from the ids of the other nodes"})
+		}
+		ids[node.ID] = true
+	}
+
+	if !ids[definition.Start] {
+		details = append(details, types.FieldError{Field: path + ".start", Rule: "exists", Message: "must be the id of a node"})
+	}
+
+	for i, node := range definition.Nodes {
+		nodePath := fmt.Sprintf("%s.nodes[%d]", path, i)
+		details = append(details, node.validate(nodePath)...)
+
+		for _, target := range node.targets() {
+			if target[1] != "" && !ids[target[1]] {
+				details = append(details, types.FieldError{Field: nodePath + "." + target[0], Rule: "exists", Message: "must be the id of a node"})
+			}
+		}
+	}
+	return details
+}
+
+// Returns the nodes the node may lead to, each along with the field naming it
+func (node FlowNode) targets() [][2]string {
+	targets := [][2]string{{"next", node.Next}, {"on_invalid", node.OnInvalid}, {"default", node.Default}, {"on_error", node.OnError}}
+	for i, condition := range node.Conditions {
+		targets = append(targets, [2]string{fmt.Sprintf("conditions[%d].next", i), condition.Next})
+	}
+	return targets
+}
+
+func (node FlowNode) validate(path string) []types.FieldError {
+	var details []types.FieldError
+	requireText := func() {
+		if node.Text == "" || len(node.Text) > 4096 {
+			details = append(details, types.FieldError{Field: path + ".text", Rule: "size", Message: "must hold between 1 and 4096 characters"})
+		}
+	}
+
+	switch node.Type {
+	case FlowNodeSendMessage:
+		requireText()
+
+	case FlowNodeAskQuestion:
+		requireText()
+		if node.Attribute != "" && !validAttributeKey(node.Attribute) {
+			details = append(details, types.FieldError{Field: path + ".attribute", Rule: "max", Message: "must hold at most 100 characters"})
+		}
+		if len(node.RetryText) > 4096 {
+			details = append(details, types.FieldError{Field: path + ".retry_text", Rule: "max", Message: "must hold at most 4096 characters"})
+		}
+		if node.MaxRetries < 0 || node.MaxRetries > maxFlowRetries {
+			details = append(details, types.FieldError{Field: path + ".max_retries", Rule: "range", Message: fmt.Sprintf("must be between 0 and %d", maxFlowRetries)})
+		}
+		if node.Validation != nil {
+			details = append(details, node.Validation.validate(path+".validation")...)
+		}
+
+	case FlowNodeBranch:
+		if len(node.Conditions) == 0 || len(node.Conditions) > maxFlowConditions {
+			details = append(details, types.FieldError{Field: path + ".conditions", Rule: "size", Message: fmt.Sprintf("must hold between 1 and %d conditions", maxFlowConditions)})
+		}
+		for i, condition := range node.Conditions {
+			details = append(details, condition.validate(fmt.Sprintf("%s.conditions[%d]", path, i))...)
+		}
+
+	case FlowNodeSetAttribute:
+		if !validAttributeKey(node.Attribute) {
+			details = append(details, types.FieldError{Field: path + ".attribute", Rule: "size", Message: "must hold between 1 and 100 characters"})
+		}
+		if len(node.Value) > 1000 {
+			details = append(details, types.FieldError{Field: path + ".value", Rule: "max", Message: "must hold at most 1000 characters"})
+		}
+
+	case FlowNodeCallWebhook:
+		if node.Webhook == nil {
+			details = append(details, types.FieldError{Field: path + ".webhook", Rule: "required", Message: "must be given for call_webhook nodes"})
+		} else {
+			details = append(details, node.Webhook.validate(path+".webhook")...)
+		}
+
+	case FlowNodeHandover:
+		if len(node.Text) > 4096 {
+			details = append(details, types.FieldError{Field: path + ".text", Rule: "max", Message: "must hold at most 4096 characters"})
+		}
+		if node.Next != "" {
+			details = append(details, types.FieldError{Field: path + ".next", Rule: "absent", Message: "must be left out, a handover ends the flow"})
+		}
+
+	default:
+		details = append(details, types.FieldError{Field: path + ".type", Rule: "oneof", Message: "must be one of: send_message, ask_question, branch, set_attribute, call_webhook, handover"})
+	}
+
+	return details
+}
+
+func (validation FlowAnswerValidation) validate(path string) []types.FieldError {
+	switch validation.Type {
+	case FlowAnswerText, FlowAnswerNumber, FlowAnswerEmail, FlowAnswerPhone:
+		return nil
+	case FlowAnswerChoice:
+		if len(validation.Choices) == 0 {
+			return []types.FieldError{{Field: path + ".choices", Rule: "required", Message: "must be given for choice answers"}}
+		}
+		for i, choice := range validation.Choices {
+			if NormaliseKeyword(choice) == "" {
+				return []types.FieldError{{Field: fmt.Sprintf("%s.choices[%d]", path, i), Rule: "required", Message: "must not be blank"}}
+			}
+		}
+		return nil
+	case FlowAnswerRegex:
+		if _, err := regexp.Compile(validation.Pattern); err != nil || validation.Pattern == "" {
+			return []types.FieldError{{Field: path + ".pattern", Rule: "regex", Message: "must be a valid regular expression"}}
+		}
+		return nil
+	}
+	return []types.FieldError{{Field: path + ".type", Rule: "oneof", Message: "must be one of: text, number, email, phone, choice, regex"}}
+}
+
+func (condition FlowCondition) validate(path string) []types.FieldError {
+	var details []types.FieldError
+	if !validFlowField(condition.Field) {
+		details = append(details, types.FieldError{Field: path + ".field", Rule: "oneof", Message: "must be one of: answer, name, phone_number, attributes.<key>, variables.<path>"})
+	}
+
+	switch condition.Operator {
+	case FlowOperatorExists, FlowOperatorNotExists:
+	case FlowOperatorEquals, FlowOperatorNotEquals, FlowOperatorContains:
+		if condition.Value == "" {
+			details = append(details, types.FieldError{Field: path + ".value", Rule: "required", Message: "must be given for " + condition.Operator})
+		}
+	case FlowOperatorMatches:
+		if _, err := regexp.Compile(condition.Value); err != nil || condition.Value == "" {
+			details = append(details, types.FieldError{Field: path + ".value", Rule: "regex", Message: "must be a valid regular expression"})
+		}
+	case FlowOperatorGreater, FlowOperatorLess:
+		if _, err := strconv.ParseFloat(condition.Value, 64); err != nil {
+			details = append(details, types.FieldError{Field: path + ".value", Rule: "numeric", Message: "must be a number for " + condition.Operator})
+		}
+	default:
+		details = append(details, types.FieldError{Field: path + ".operator", Rule: "oneof", Message: "must be one of: equals, not_equals, contains, matches, exists, not_exists, gt, lt"})
+	}
+
+	if condition.Next == "" {
+		details = append(details, types.FieldError{Field: path + ".next", Rule: "required", Message: "must be the id of a node"})
+	}
+	return details
+}
+
+func (webhook FlowWebhook) validate(path string) []types.FieldError {
+	var details []types.FieldError
+	err := safehttp.CheckURL(webhook.URL, false)
+	if errors.Is(err, safehttp.ErrBlockedAddress) {
+		details = append(details, types.FieldError{Field: path + ".url", Rule: "public", Message: "must not point to a local or private address"})
+	} else if err != nil {
+		details = append(details, types.FieldError{Field: path + ".url", Rule: "url", Message: "must be an absolute http or https URL"})
+	}
+	if webhook.Method != "GET" && webhook.Method != "POST" {
+		details = append(details, types.FieldError{Field: path + ".method", Rule: "oneof", Message: "must be one of: GET, POST"})
+	}
+	if webhook.Method == "GET" && webhook.Body != "" {
+		details = append(details, types.FieldError{Field: path + ".body", Rule: "absent", Message: "must be left out for GET requests"})
+	}
+	if webhook.SaveAs != "" && !flowNodeIDPattern.MatchString(webhook.SaveAs) {
+		details = append(details, types.FieldError{Field: path + ".save_as", Rule: "format", Message: "must be up to 100 letters, digits, dashes or underscores"})
+	}
+	if webhook.TimeoutSeconds < 0 || webhook.TimeoutSeconds > maxFlowWebhookWait {
+		details = append(details, types.FieldError{Field: path + ".timeout_seconds", Rule: "range", Message: fmt.Sprintf("must be between 0 and %d", maxFlowWebhookWait)})
+	}
+	return details
+}
+
+func validAttributeKey(key string) bool {
+	return key != "" && len(key) <= 100
+}
+
+// Reports whether a condition may read the field
+func validFlowField(field string) bool {
+	switch {
+	case field == FlowFieldAnswer, field == CampaignFieldName, field == CampaignFieldPhoneNumber:
+		return true
+	case strings.HasPrefix(field, SegmentFieldAttributePrefix):
+		return validAttributeKey(strings.TrimPrefix(field, SegmentFieldAttributePrefix))
+	case strings.HasPrefix(field, FlowFieldVariablesPrefix):
+		return len(field) > len(FlowFieldVariablesPrefix)
+	}
+	return false
+}
+
+// Reports whether the message text matches the flow's trigger
+func (flow Flow) Matches(text string) bool {
+	return matchText(flow.MatchType, flow.Pattern, text)
+}
+
+// Returns the node with the given id
+func (definition FlowDefinition) Node(id string) *FlowNode {
+	for i := range definition.Nodes {
+		if definition.Nodes[i].ID == id {
+			return &definition.Nodes[i]
+		}
+	}
+	return nil
+}
+
+// Checks an answer, returning the value to keep for it. Numbers are kept as numbers, phone
+// numbers in E.164 and choices as written in the node.
+func (validation *FlowAnswerValidation) Check(answer string) (interface{}, bool) {
+	answer = strings.TrimSpace(answer)
+	if answer == "" {
+		return nil, false
+	}
+	if validation == nil {
+		return answer, true
+	}
+
+	switch validation.Type {
+	case FlowAnswerNumber:
+		number, err := strconv.ParseFloat(strings.ReplaceAll(answer, ",", ""), 64)
+		return number, err == nil
+	case FlowAnswerEmail:
+		address, err := mail.ParseAddress(answer)
+		return answer, err == nil && address.Address == answer
+	case FlowAnswerPhone:
+		normalised, err := phone.Normalise(answer)
+		return normalised, err == nil
+	case FlowAnswerChoice:
+		if position, err := strconv.Atoi(answer); err == nil && position >= 1 && position <= len(validation.Choices) {
+			return validation.Choices[position-1], true
+		}
+		for _, choice := range validation.Choices {
+			if NormaliseKeyword(choice) == NormaliseKeyword(answer) {
+				return choice, true
+			}
+		}
+		return nil, false
+	case FlowAnswerRegex:
+		pattern, err := regexp.Compile(validation.Pattern)
+		return answer, err == nil && pattern.MatchString(answer)
+	}
+	return answer, true
+}
+
+// Reports whether the condition holds for the value read from its field
+func (condition FlowCondition) Holds(value interface{}, found bool) bool {
+	text := ""
+	if found {
+		text = strings.TrimSpace(flowText(value))
+	}
+
+	switch condition.Operator {
+	case FlowOperatorExists:
+		return text != ""
+	case FlowOperatorNotExists:
+		return text == ""
+	case FlowOperatorEquals:
+		return strings.EqualFold(text, strings.TrimSpace(condition.Value))
+	case FlowOperatorNotEquals:
+		return !strings.EqualFold(text, strings.TrimSpace(condition.Value))
+	case FlowOperatorContains:
+		return strings.Contains(strings.ToUpper(text), strings.ToUpper(strings.TrimSpace(condition.Value)))
+	case FlowOperatorMatches:
+		pattern, err := regexp.Compile(condition.Value)
+		return err == nil && pattern.MatchString(text)
+	case FlowOperatorGreater, FlowOperatorLess:
+		left, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return false
+		}
+		right, err := strconv.ParseFloat(condition.Value, 64)
+		if err != nil {
+			return false
+		}
+		if condition.Operator == FlowOperatorGreater {
+			return left > right
+		}
+		return left < right
+	}
+	return false
+}
+
+func (definition FlowDefinition) Value() (driver.Value, error) {
+	raw, err := json.Marshal(definition)
+	return string(raw), err
+}
+
+func (definition *FlowDefinition) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, definition)
+	case string:
+		return json.Unmarshal([]byte(v), definition)
+	}
+	return fmt.Errorf("Cannot scan %T into FlowDefinition", src)
+}
@@ -0,0 +1,170 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Waiting for the contact to answer the question of its node
+	FlowSessionStatusActive     = "active"
+	FlowSessionStatusCompleted  = "completed"
+	FlowSessionStatusHandedOver = "handed_over"
+	FlowSessionStatusFailed     = "failed"
+	// Left unanswered for too long, the next message starts afresh
+	FlowSessionStatusExpired = "expired"
+
+	FlowStepReceive       = "receive"
+	FlowStepSend          = "send"
+	FlowStepAsk           = "ask"
+	FlowStepInvalidAnswer = "invalid_answer"
+	FlowStepAnswer        = "answer"
+	FlowStepBranch        = "branch"
+	FlowStepSetAttribute  = "set_attribute"
+	FlowStepCallWebhook   = "call_webhook"
+	FlowStepHandover      = "handover"
+	FlowStepComplete      = "complete"
+	FlowStepFail          = "fail"
+)
+
+// Run of a flow in one conversation. Answers and webhook responses are kept in the variables,
+// the latest answer also under "answer".
+type FlowSession struct {
+	ID                uint64 `json:"id" db:"id"`
+	OrganisationID    uint64 `json:"organisation_id" db:"organisation_id"`
+	FlowID            uint64 `json:"flow_id" db:"flow_id"`
+	WhatsAppAccountID uint64 `json:"whatsapp_account_id" db:"whatsapp_account_id"`
+	ConversationID    uint64 `json:"conversation_id" db:"conversation_id"`
+	ContactID         uint64 `json:"contact_id" db:"contact_id"`
+	// Node the session is at. For active sessions, the question waiting for an answer.
+	NodeID    string     `json:"node_id" db:"node_id"`
+	Status    string     `json:"status" db:"status"`
+	Variables JSONMap    `json:"variables" db:"variables"`
+	Retries   int        `json:"retries" db:"retries"`
+	LastError string     `json:"last_error" db:"last_error"`
+	EndedAt   *time.Time `json:"ended_at" db:"ended_at"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+type FlowSessionFilter struct {
+	Status string `form:"status"`
+	Pagination
+}
+
+// What a flow did while it ran, in order
+type FlowStep struct {
+	NodeID string `json:"node_id,omitempty"`
+	Action string `json:"action"`
+	// Text received or sent
+	Text      string      `json:"text,omitempty"`
+	Attribute string      `json:"attribute,omitempty"`
+	Value     interface{} `json:"value,omitempty"`
+	// Node the step led to
+	Next string `json:"next,omitempty"`
+	// Response status of a webhook call
+	Status int    `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Conversation to play through a flow without sending anything or changing the contact
+type FlowSimulation struct {
+	// Definition to play instead of the saved one, to try changes before saving them
+	Definition *FlowDefinition `json:"definition"`
+	// Contact whose fields the flow reads. Left out, a contact without any fields is used.
+	ContactID uint64 `json:"contact_id"`
+	// Attributes set on the contact for the simulation, over the ones it has
+	Attributes JSONMap `json:"attributes"`
+	// Messages the contact sends, in order. The first one starts the flow.
+	Messages []string `json:"messages" validate:"required,min=1,max=50,dive,max=4096"`
+	// Responses webhook nodes get, keyed by node id. Webhooks are never called while simulating,
+	// nodes without a response here get an empty 200 response.
+	WebhookResponses map[string]FlowWebhookResponse `json:"webhook_responses"`
+}
+
+type FlowWebhookResponse struct {
+	Status int             `json:"status"`
+	Body   json.RawMessage `json:"body"`
+}
+
+type FlowSimulationResult struct {
+	// Whether the first message would start the flow through its trigger
+	Triggered  bool       `json:"triggered"`
+	Status     string     `json:"status"`
+	NodeID     string     `json:"node_id"`
+	Variables  JSONMap    `json:"variables"`
+	Attributes JSONMap    `json:"attributes"`
+	Steps      []FlowStep `json:"steps"`
+}
+
+func (simulation FlowSimulation) ValidateFields() []error {
+	return validateStruct(simulation)
+}
+
+// Returns the value of a flow field for the session's contact: the latest answer, a contact
+// field, or a variable, whose path may reach into webhook responses.
+func (session *FlowSession) Lookup(field string, contact *Contact) (interface{}, bool) {
+	switch {
+	case field == FlowFieldAnswer:
+		value, ok := session.Variables[FlowFieldAnswer]
+		return value, ok
+	case field == CampaignFieldName:
+		return contact.Name, contact.Name != ""
+	case field == CampaignFieldPhoneNumber:
+		return contact.PhoneNumber, contact.PhoneNumber != ""
+	case strings.HasPrefix(field, SegmentFieldAttributePrefix):
+		value, ok := contact.Attributes[strings.TrimPrefix(field, SegmentFieldAttributePrefix)]
+		return value, ok
+	case strings.HasPrefix(field, FlowFieldVariablesPrefix):
+		var value interface{} = map[string]interface{}(session.Variables)
+		for _, key := range strings.Split(strings.TrimPrefix(field, FlowFieldVariablesPrefix), ".") {
+			object, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			value, ok = object[key]
+			if !ok {
+				return nil, false
+			}
+		}
+		return value, true
+	}
+	return nil, false
+}
+
+// Fills the placeholders of the text in. Placeholders of missing fields are left empty.
+func (session *FlowSession) Render(text string, contact *Contact) string {
+	return flowPlaceholderPattern.ReplaceAllStringFunc(text, func(placeholder string) string {
+		field := flowPlaceholderPattern.FindStringSubmatch(placeholder)[1]
+		value, ok := session.Lookup(field, contact)
+		if !ok {
+			return ""
+		}
+		return flowText(value)
+	})
+}
+
+// Writes a flow value out as text. Objects and lists, as found in webhook responses, are
+// written as JSON.
+func flowText(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case map[string]interface{}, []interface{}:
+		raw, _ := json.Marshal(v)
+		return string(raw)
+	}
+	return fmt.Sprint(value)
+}
+
+// Reports whether the session was left unanswered for longer than the timeout
+func (session *FlowSession) IsStale(at time.Time, timeout time.Duration) bool {
+	return session.Status == FlowSessionStatusActive && at.Sub(session.UpdatedAt) > timeout
+}
@@ -0,0 +1,28 @@
+package model
+
+import "time"
+
+const (
+	InboundReplyJobStatusPending   = "pending"
+	InboundReplyJobStatusRunning   = "running"
+	InboundReplyJobStatusSucceeded = "succeeded"
+	// Jobs which ran out of attempts. The message goes unanswered.
+	InboundReplyJobStatusDead = "dead"
+)
+
+// Inbound message waiting to be answered by a flow, greeting, away message or auto reply
+type InboundReplyJob struct {
+	ID             uint64     `json:"id" db:"id"`
+	OrganisationID uint64     `json:"organisation_id" db:"organisation_id"`
+	MessageID      uint64     `json:"message_id" db:"message_id"`
+	ConversationID uint64     `json:"conversation_id" db:"conversation_id"`
+	Status         string     `json:"status" db:"status"`
+	Attempts       int        `json:"attempts" db:"attempts"`
+	MaxAttempts    int        `json:"max_attempts" db:"max_attempts"`
+	RunAt          time.Time  `json:"run_at" db:"run_at"`
+	LockedUntil    *time.Time `json:"-" db:"locked_until"`
+	LastError      string     `json:"last_error" db:"last_error"`
+	CompletedAt    *time.Time `json:"completed_at" db:"completed_at"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
+}
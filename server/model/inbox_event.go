@@ -0,0 +1,55 @@
+package model
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+const (
+	InboxEventMessageReceived      = "message.received"
+	InboxEventMessageStatus        = "message.status"
+	InboxEventConversationAssigned = "conversation.assigned"
+)
+
+// Change streamed to the agents working an organisation's inbox
+type InboxEvent struct {
+	ID             uint64          `json:"id" db:"id"`
+	OrganisationID uint64          `json:"organisation_id" db:"organisation_id"`
+	Type           string          `json:"type" db:"type"`
+	ConversationID *uint64         `json:"conversation_id" db:"conversation_id"`
+	Payload        json.RawMessage `json:"payload" db:"payload"`
+	CreatedAt      time.Time       `json:"created_at" db:"created_at"`
+}
+
+// Narrows the events streamed to one connection
+type InboxEventFilter struct {
+	// Event types to stream, repeated or comma separated. All types when empty.
+	Types          []string `form:"types"`
+	ConversationID uint64   `form:"conversation_id"`
+}
+
+// Short lived token opening an organisation's event stream, passed as the access_token query
+// parameter by clients which cannot send an Authorization header
+type StreamToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (filter InboxEventFilter) Matches(event *InboxEvent) bool {
+	if filter.ConversationID > 0 && (event.ConversationID == nil || *event.ConversationID != filter.ConversationID) {
+		return false
+	}
+
+	if len(filter.Types) == 0 {
+		return true
+	}
+	for _, types := range filter.Types {
+		for _, eventType := range strings.Split(types, ",") {
+			if strings.TrimSpace(eventType) == event.Type {
+				return true
+			}
+		}
+	}
+	return false
+}
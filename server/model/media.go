@@ -0,0 +1,104 @@
+package model
+
+import (
+	"mime"
+	"strings"
+	"time"
+)
+
+const (
+	MediaTypeImage    = "image"
+	MediaTypeVideo    = "video"
+	MediaTypeAudio    = "audio"
+	MediaTypeDocument = "document"
+	MediaTypeSticker  = "sticker"
+
+	megabyte = 1 << 20
+)
+
+// File sent or received in messages, kept in our storage under StorageKey
+type Media struct {
+	ID                uint64    `json:"id" db:"id"`
+	OrganisationID    uint64    `json:"organisation_id" db:"organisation_id"`
+	WhatsAppAccountID uint64    `json:"whatsapp_account_id" db:"whatsapp_account_id"`
+	ExternalID        string    `json:"external_id" db:"external_id"`
+	Direction         string    `json:"direction" db:"direction"`
+	Type              string    `json:"type" db:"type"`
+	Filename          string    `json:"filename" db:"filename"`
+	MimeType          string    `json:"mime_type" db:"mime_type"`
+	Size              int64     `json:"size" db:"size"`
+	SHA256            string    `json:"sha256" db:"sha256"`
+	StorageKey        string    `json:"-" db:"storage_key"`
+	CreatedAt         time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at" db:"updated_at"`
+
+	// Short lived link to download the file
+	URL string `json:"url,omitempty" db:"-"`
+}
+
+// What WhatsApp accepts for one MIME type
+type MediaLimit struct {
+	Type    string
+	MaxSize int64
+}
+
+// MIME types WhatsApp supports with their message type and size limit
+var mediaLimits = map[string]MediaLimit{
+	"image/jpeg": {MediaTypeImage, 5 * megabyte},
+	"image/png":  {MediaTypeImage, 5 * megabyte},
+
+	"video/mp4":  {MediaTypeVideo, 16 * megabyte},
+	"video/3gpp": {MediaTypeVideo, 16 * megabyte},
+
+	"audio/aac":  {MediaTypeAudio, 16 * megabyte},
+	"audio/amr":  {MediaTypeAudio, 16 * megabyte},
+	"audio/mpeg": {MediaTypeAudio, 16 * megabyte},
+	"audio/mp4":  {MediaTypeAudio, 16 * megabyte},
+	"audio/ogg":  {MediaTypeAudio, 16 * megabyte},
+
+	"text/plain":         {MediaTypeDocument, 100 * megabyte},
+	"application/pdf":    {MediaTypeDocument, 100 * megabyte},
+	"application/msword": {MediaTypeDocument, 100 * megabyte},
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document": {MediaTypeDocument, 100 * megabyte},
+	"application/vnd.ms-excel": {MediaTypeDocument, 100 * megabyte},
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":         {MediaTypeDocument, 100 * megabyte},
+	"application/vnd.ms-powerpoint":                                             {MediaTypeDocument, 100 * megabyte},
+	"application/vnd.openxmlformats-officedocument.presentationml.presentation": {MediaTypeDocument, 100 * megabyte},
+
+	// Animated stickers may be up to 500KB, static ones up to 100KB
+	"image/webp": {MediaTypeSticker, 500 << 10},
+}
+
+// Returns the limit for the MIME type, ignoring parameters such as codecs
+func MediaLimitFor(mimeType string) (MediaLimit, bool) {
+	limit, ok := mediaLimits[NormaliseMimeType(mimeType)]
+	return limit, ok
+}
+
+// Lowercases the MIME type and drops its parameters
+func NormaliseMimeType(mimeType string) string {
+	mediaType, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(mimeType))
+	}
+	return mediaType
+}
+
+// Request to send an uploaded file, which like any free-form message is only allowed within
+// the customer service window
+type SendMediaRequest struct {
+	// Sending account. May be left out when the organisation has a single active account.
+	WhatsAppAccountID uint64 `json:"whatsapp_account_id"`
+	To                string `json:"to" validate:"required,phone"`
+	MediaID           uint64 `json:"media_id" validate:"required"`
+	Caption           string `json:"caption" validate:"max=1024"`
+}
+
+func (req *SendMediaRequest) Normalise() {
+	req.To = normalisePhone(strings.TrimSpace(req.To))
+	req.Caption = strings.TrimSpace(req.Caption)
+}
+
+func (req SendMediaRequest) ValidateFields() []error {
+	return validateStruct(req)
+}
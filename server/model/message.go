@@ -0,0 +1,151 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	MessageDirectionInbound  = "inbound"
+	MessageDirectionOutbound = "outbound"
+
+	MessageTypeTemplate = "template"
+	MessageTypeText     = "text"
+	// Reply made by tapping a quick reply button of a template
+	MessageTypeButton = "button"
+
+	// Lifecycle of outbound messages. Pending messages wait in our send queue, queued messages
+	// were accepted by Meta, and the other states are reported back through webhook status
+	// callbacks.
+	MessageStatusPending   = "pending"
+	MessageStatusQueued    = "queued"
+	MessageStatusSent      = "sent"
+	MessageStatusDelivered = "delivered"
+	MessageStatusRead      = "read"
+	MessageStatusFailed    = "failed"
+
+	// Inbound messages have no lifecycle
+	MessageStatusReceived = "received"
+)
+
+// Order of the outbound states. A status callback never moves a message back, as Meta may
+// deliver callbacks out of order.
+var messageStatusRanks = map[string]int{
+	MessageStatusQueued:    1,
+	MessageStatusSent:      2,
+	MessageStatusDelivered: 3,
+	MessageStatusRead:      4,
+	MessageStatusFailed:    5,
+}
+
+type Message struct {
+	ID                uint64  `json:"id" db:"id"`
+	OrganisationID    uint64  `json:"organisation_id" db:"organisation_id"`
+	WhatsAppAccountID uint64  `json:"whatsapp_account_id" db:"whatsapp_account_id"`
+	ContactID         uint64  `json:"contact_id" db:"contact_id"`
+	ConversationID    *uint64 `json:"conversation_id" db:"conversation_id"`
+	Direction         string  `json:"direction" db:"direction"`
+	WAMID             *string `json:"wamid" db:"wamid"`
+	Type              string  `json:"type" db:"type"`
+	TemplateID        *uint64 `json:"template_id" db:"template_id"`
+	MediaID           *uint64 `json:"media_id" db:"media_id"`
+	// Text of the message as the recipient sees it, with placeholders filled in
+	Body string `json:"body" db:"body"`
+	// Message as sent to or received from the Graph API
+	Payload      JSONMap    `json:"payload" db:"payload"`
+	Status       string     `json:"status" db:"status"`
+	ErrorCode    string     `json:"error_code" db:"error_code"`
+	ErrorMessage string     `json:"error_message" db:"error_message"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at" db:"updated_at"`
+	QueuedAt     *time.Time `json:"queued_at" db:"queued_at"`
+	SentAt       *time.Time `json:"sent_at" db:"sent_at"`
+	DeliveredAt  *time.Time `json:"delivered_at" db:"delivered_at"`
+	ReadAt       *time.Time `json:"read_at" db:"read_at"`
+	FailedAt     *time.Time `json:"failed_at" db:"failed_at"`
+}
+
+type MessageFilter struct {
+	ContactID         uint64 `form:"contact_id"`
+	ConversationID    uint64 `form:"conversation_id"`
+	WhatsAppAccountID uint64 `form:"whatsapp_account_id"`
+	Direction         string `form:"direction"`
+	Status            string `form:"status"`
+	Pagination
+}
+
+// Returns the rank of an outbound status, zero for unknown ones
+func MessageStatusRank(status string) int {
+	return messageStatusRanks[status]
+}
+
+// Request to send an approved template to a phone number
+type SendTemplateRequest struct {
+	// Sending account. May be left out when the organisation has a single active account.
+	WhatsAppAccountID uint64                 `json:"whatsapp_account_id"`
+	To                string                 `json:"to" validate:"required,phone"`
+	Template          TemplateMessageRequest `json:"template"`
+	// Campaign recipient the message is for, set by campaigns only
+	CampaignRecipientID uint64 `json:"-"`
+}
+
+type TemplateMessageRequest struct {
+	Name     string `json:"name" validate:"required"`
+	Language string `json:"language" validate:"required"`
+	// Values for the placeholders of a text header, in order
+	Header []string `json:"header" validate:"dive,required,max=60"`
+	// Media shown in an image, video or document header
+	HeaderMedia *TemplateMediaRequest `json:"header_media"`
+	// Values for the placeholders of the body, in order
+	Body    []string                `json:"body" validate:"dive,required,max=1024"`
+	Buttons []TemplateButtonRequest `json:"buttons" validate:"dive"`
+}
+
+type TemplateMediaRequest struct {
+	Link     string `json:"link" validate:"required,url"`
+	Filename string `json:"filename" validate:"max=240"`
+}
+
+// Parameter of a URL button with a dynamic suffix, or payload of a quick reply button
+type TemplateButtonRequest struct {
+	Index int    `json:"index" validate:"gte=0,lte=9"`
+	Value string `json:"value" validate:"required,max=2000"`
+}
+
+// Request to send a free-form text message, which is only allowed within the customer service
+// window
+type SendTextRequest struct {
+	// Sending account. May be left out when the organisation has a single active account.
+	WhatsAppAccountID uint64             `json:"whatsapp_account_id"`
+	To                string             `json:"to" validate:"required,phone"`
+	Text              TextMessageRequest `json:"text"`
+}
+
+type TextMessageRequest struct {
+	Body string `json:"body" validate:"required,max=4096"`
+	// Shows a preview of the first link in the body
+	PreviewURL bool `json:"preview_url"`
+}
+
+func (req TextMessageRequest) ValidateFields() []error {
+	return validateStruct(req)
+}
+
+func (req *SendTextRequest) Normalise() {
+	req.To = normalisePhone(strings.TrimSpace(req.To))
+	req.Text.Body = strings.TrimSpace(req.Text.Body)
+}
+
+func (req SendTextRequest) ValidateFields() []error {
+	return validateStruct(req)
+}
+
+func (req *SendTemplateRequest) Normalise() {
+	req.To = normalisePhone(strings.TrimSpace(req.To))
+	req.Template.Name = strings.TrimSpace(req.Template.Name)
+	req.Template.Language = strings.TrimSpace(req.Template.Language)
+}
+
+func (req SendTemplateRequest) ValidateFields() []error {
+	return validateStruct(req)
+}
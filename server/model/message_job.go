@@ -0,0 +1,38 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	MessageJobStatusPending   = "pending"
+	MessageJobStatusRunning   = "running"
+	MessageJobStatusSucceeded = "succeeded"
+	// Jobs which ran out of attempts or failed for good. They stay until retried by hand.
+	MessageJobStatusDead = "dead"
+)
+
+// Outbound message waiting in the send queue
+type MessageJob struct {
+	ID                uint64          `json:"id" db:"id"`
+	OrganisationID    uint64          `json:"organisation_id" db:"organisation_id"`
+	WhatsAppAccountID uint64          `json:"whatsapp_account_id" db:"whatsapp_account_id"`
+	MessageID         uint64          `json:"message_id" db:"message_id"`
+	Payload           json.RawMessage `json:"payload" db:"payload"`
+	Status            string          `json:"status" db:"status"`
+	Attempts          int             `json:"attempts" db:"attempts"`
+	MaxAttempts       int             `json:"max_attempts" db:"max_attempts"`
+	RunAt             time.Time       `json:"run_at" db:"run_at"`
+	LockedUntil       *time.Time      `json:"-" db:"locked_until"`
+	LastError         string          `json:"last_error" db:"last_error"`
+	CompletedAt       *time.Time      `json:"completed_at" db:"completed_at"`
+	CreatedAt         time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time       `json:"updated_at" db:"updated_at"`
+}
+
+type MessageJobFilter struct {
+	Status            string `form:"status"`
+	WhatsAppAccountID uint64 `form:"whatsapp_account_id"`
+	Pagination
+}
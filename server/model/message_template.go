@@ -0,0 +1,213 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/supermario64bit/whatsapp_connect/pkg/whatsapp"
+	"github.com/supermario64bit/whatsapp_connect/types"
+)
+
+const (
+	TemplateCategoryMarketing      = "marketing"
+	TemplateCategoryUtility        = "utility"
+	TemplateCategoryAuthentication = "authentication"
+
+	TemplateStatusPending  = "pending"
+	TemplateStatusApproved = "approved"
+	TemplateStatusRejected = "rejected"
+	TemplateStatusPaused   = "paused"
+	TemplateStatusDisabled = "disabled"
+	TemplateStatusDeleted  = "deleted"
+
+	maxTemplateBodyLength   = 1024
+	maxTemplateHeaderLength = 60
+	maxTemplateFooterLength = 60
+	maxTemplateButtons      = 10
+)
+
+type MessageTemplate struct {
+	ID                uint64             `json:"id" db:"id"`
+	OrganisationID    uint64             `json:"organisation_id" db:"organisation_id"`
+	WhatsAppAccountID uint64             `json:"whatsapp_account_id" db:"whatsapp_account_id"`
+	ExternalID        string             `json:"external_id" db:"external_id"`
+	Name              string             `json:"name" db:"name" validate:"required,max=512,template_name"`
+	Language          string             `json:"language" db:"language" validate:"required,max=15"`
+	Category          string             `json:"category" db:"category" validate:"required,oneof=marketing utility authentication"`
+	Components        TemplateComponents `json:"components" db:"components"`
+	Status            string             `json:"status" db:"status"`
+	RejectionReason   string             `json:"rejection_reason" db:"rejection_reason"`
+	CreatedAt         time.Time          `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time          `json:"updated_at" db:"updated_at"`
+	DeletedAt         *time.Time         `json:"deleted_at" db:"deleted_at"`
+	Version           uint64             `json:"version" db:"version"`
+}
+
+// Components of a template stored in a JSONB column
+type TemplateComponents []whatsapp.TemplateComponent
+
+func (tpl *MessageTemplate) Normalise() {
+	tpl.Name = strings.TrimSpace(tpl.Name)
+	tpl.Language = strings.TrimSpace(tpl.Language)
+	tpl.Category = strings.ToLower(strings.TrimSpace(tpl.Category))
+	for i := range tpl.Components {
+		tpl.Components[i].Type = strings.ToUpper(strings.TrimSpace(tpl.Components[i].Type))
+		tpl.Components[i].Format = strings.ToUpper(strings.TrimSpace(tpl.Components[i].Format))
+	}
+}
+
+// Validates the template fields and its components
+func (tpl MessageTemplate) ValidateFields() []types.FieldError {
+	details := types.NewValidationError(validateStruct(tpl)).Details
+	return append(details, tpl.Components.Validate("components")...)
+}
+
+// Validates only the fields named by their json keys, as sent in a partial update
+func (tpl MessageTemplate) ValidatePartial(fields []string) []types.FieldError {
+	details := types.NewValidationError(validatePartial(tpl, fields)).Details
+	for _, field := range fields {
+		if field == "components" {
+			details = append(details, tpl.Components.Validate("components")...)
+		}
+	}
+	return details
+}
+
+// Returns the component of the given type, or nil when the template has none
+func (tpl MessageTemplate) Component(componentType string) *whatsapp.TemplateComponent {
+	for i := range tpl.Components {
+		if tpl.Components[i].Type == componentType {
+			return &tpl.Components[i]
+		}
+	}
+	return nil
+}
+
+// Returns the Graph API representation of the template
+func (tpl MessageTemplate) ToGraph() *whatsapp.Template {
+	return &whatsapp.Template{
+		ID:         tpl.ExternalID,
+		Name:       tpl.Name,
+		Language:   tpl.Language,
+		Category:   strings.ToUpper(tpl.Category),
+		Components: tpl.Components,
+	}
+}
+
+// Maps a status reported by the Graph API, in templates or webhook events, onto ours
+func TemplateStatusFromGraph(status string) string {
+	switch strings.ToUpper(status) {
+	case "REINSTATED":
+		return TemplateStatusApproved
+	case "PENDING_DELETION":
+		return TemplateStatusDeleted
+	}
+	return strings.ToLower(status)
+}
+
+// Checks the component layout Meta accepts: exactly one body, at most one of every other type,
+// texts within their length limits and placeholders numbered without gaps, each with an example
+func (components TemplateComponents) Validate(path string) []types.FieldError {
+	var details []types.FieldError
+	seen := map[string]bool{}
+
+	for i, component := range components {
+		componentPath := fmt.Sprintf("%s[%d]", path, i)
+		if seen[component.Type] {
+			details = append(details, ruleError(componentPath+".type", "unique", "must not repeat a component type"))
+			continue
+		}
+		seen[component.Type] = true
+
+		switch component.Type {
+		case whatsapp.ComponentHeader:
+			details = append(details, validateHeader(component, componentPath)...)
+		case whatsapp.ComponentBody:
+			details = append(details, validateComponentText(component, componentPath, maxTemplateBodyLength, -1)...)
+		case whatsapp.ComponentFooter:
+			details = append(details, validateComponentText(component, componentPath, maxTemplateFooterLength, 0)...)
+		case whatsapp.ComponentButtons:
+			if len(component.Buttons) == 0 || len(component.Buttons) > maxTemplateButtons {
+				details = append(details, ruleError(componentPath+".buttons", "size", fmt.Sprintf("must hold between 1 and %d buttons", maxTemplateButtons)))
+			}
+			for j, button := range component.Buttons {
+				if strings.TrimSpace(button.Text) == "" || len(button.Text) > 25 {
+					details = append(details, ruleError(fmt.Sprintf("%s.buttons[%d].text", componentPath, j), "required", "must be between 1 and 25 characters long"))
+				}
+			}
+		default:
+			details = append(details, ruleError(componentPath+".type", "oneof", "must be one of: HEADER, BODY, FOOTER, BUTTONS"))
+		}
+	}
+
+	if !seen[whatsapp.ComponentBody] {
+		details = append(details, ruleError(path, "body", "must include a BODY component"))
+	}
+
+	return details
+}
+
+func validateHeader(component whatsapp.TemplateComponent, path string) []types.FieldError {
+	switch component.Format {
+	case "TEXT":
+		return validateComponentText(component, path, maxTemplateHeaderLength, 1)
+	case "IMAGE", "VIDEO", "DOCUMENT", "LOCATION":
+		return nil
+	}
+	return []types.FieldError{ruleError(path+".format", "oneof", "must be one of: TEXT, IMAGE, VIDEO, DOCUMENT, LOCATION")}
+}
+
+// Checks a component's text and that its examples fill every placeholder. A negative
+// maxPlaceholders allows any number.
+func validateComponentText(component whatsapp.TemplateComponent, path string, maxLength int, maxPlaceholders int) []types.FieldError {
+	if strings.TrimSpace(component.Text) == "" || len(component.Text) > maxLength {
+		return []types.FieldError{ruleError(path+".text", "length", fmt.Sprintf("must be between 1 and %d characters long", maxLength))}
+	}
+
+	count, err := whatsapp.CountPlaceholders(component.Text)
+	if err != nil {
+		return []types.FieldError{ruleError(path+".text", "placeholders", err.Error())}
+	}
+	if maxPlaceholders >= 0 && count > maxPlaceholders {
+		return []types.FieldError{ruleError(path+".text", "placeholders", fmt.Sprintf("must have at most %d placeholder(s)", maxPlaceholders))}
+	}
+	if count == 0 {
+		return nil
+	}
+
+	examples := 0
+	if component.Example != nil {
+		if component.Type == whatsapp.ComponentHeader {
+			examples = len(component.Example.HeaderText)
+		} else if len(component.Example.BodyText) > 0 {
+			examples = len(component.Example.BodyText[0])
+		}
+	}
+	if examples != count {
+		return []types.FieldError{ruleError(path+".example", "placeholders", fmt.Sprintf("must give %d example value(s), one for each placeholder, but gives %d", count, examples))}
+	}
+
+	return nil
+}
+
+func (components TemplateComponents) Value() (driver.Value, error) {
+	if components == nil {
+		return "[]", nil
+	}
+
+	raw, err := json.Marshal(components)
+	return string(raw), err
+}
+
+func (components *TemplateComponents) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, components)
+	case string:
+		return json.Unmarshal([]byte(v), components)
+	}
+	return fmt.Errorf("Cannot scan %T into TemplateComponents", src)
+}
@@ -2,33 +2,35 @@ package model
 
 import (
 	"time"
-
-	"github.com/go-playground/validator/v10"
 )
 
 type Organisation struct {
 	ID            uint64     `json:"id" db:"id"`
 	Name          string     `json:"name" db:"name" validate:"required,min=2,max=100"`
-	ContactNumber string     `json:"contact_number" db:"contact_number" validate:"required,len=10,numeric"`
+	ContactNumber string     `json:"contact_number" db:"contact_number" validate:"required,phone"`
 	Email         string     `json:"email" db:"email" validate:"required,email"`
 	Status        string     `json:"status" db:"status" validate:"required,oneof=active inactive"`
 	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
 	UpdatedAt     time.Time  `json:"updated_at" db:"updated_at"`
 	DeletedAt     *time.Time `json:"deleted_at" db:"deleted_at"`
+	Version       uint64     `json:"version" db:"version"`
 }
 
-func (org Organisation) ValidateFields() []error {
-	validate := validator.New()
-	err := validate.Struct(org)
+// Rewrites the contact number into E.164 so it is stored and compared in one format
+func (org *Organisation) Normalise() {
+	org.ContactNumber = normalisePhone(org.ContactNumber)
+}
 
-	var errors []error
-	if err == nil {
-		return errors
-	}
+func (org Organisation) ValidateFields() []error {
+	return validateStruct(org)
+}
 
-	for _, err := range err.(validator.ValidationErrors) {
-		errors = append(errors, err)
-	}
+// Validates only the fields named by their json keys, as sent in a partial update
+func (org Organisation) ValidatePartial(fields []string) []error {
+	return validatePartial(org, fields)
+}
 
-	return errors
+// Validates the fields a full update writes, which are the ones it supplies
+func (org Organisation) ValidateSupplied() []error {
+	return validatePartial(org, suppliedFields(org))
 }
@@ -0,0 +1,14 @@
+package model
+
+import "time"
+
+// User who works the organisation's inbox
+type OrganisationMember struct {
+	OrganisationID uint64    `json:"organisation_id" db:"organisation_id"`
+	UserID         uint64    `json:"user_id" db:"user_id" validate:"required"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+}
+
+func (member OrganisationMember) ValidateFields() []error {
+	return validateStruct(member)
+}
@@ -0,0 +1,95 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	OutboxAggregateUser         = "user"
+	OutboxAggregateOrganisation = "organisation"
+	OutboxAggregateContact      = "contact"
+	OutboxAggregateMessage      = "message"
+	OutboxAggregateConversation = "conversation"
+
+	OutboxEventUserCreated         = "user.created"
+	OutboxEventUserUpdated         = "user.updated"
+	OutboxEventUserDeleted         = "user.deleted"
+	OutboxEventOrganisationCreated = "organisation.created"
+	OutboxEventOrganisationUpdated = "organisation.updated"
+	OutboxEventOrganisationDeleted = "organisation.deleted"
+	OutboxEventContactCreated      = "contact.created"
+	OutboxEventMessageReceived     = "message.received"
+	// Outbound message moved on, as when it was sent, delivered, read or failed
+	OutboxEventMessageStatus        = "message.status"
+	OutboxEventConversationAssigned = "conversation.assigned"
+
+	OutboxEventStatusPending   = "pending"
+	OutboxEventStatusRunning   = "running"
+	OutboxEventStatusPublished = "published"
+)
+
+// Domain event stored in the same transaction as the change it reports, and published by the
+// outbox relay once that change committed. Every subscriber and broker gets each event at least
+// once, and may get it again when the relay stops before recording its delivery.
+type OutboxEvent struct {
+	ID             uint64  `json:"id" db:"id"`
+	AggregateType  string  `json:"aggregate_type" db:"aggregate_type"`
+	AggregateID    uint64  `json:"aggregate_id" db:"aggregate_id"`
+	OrganisationID *uint64 `json:"organisation_id" db:"organisation_id"`
+	ActorID        *uint64 `json:"actor_id" db:"actor_id"`
+	EventType      string  `json:"event_type" db:"event_type"`
+	// Entity as it was right after the change
+	Payload     json.RawMessage `json:"payload" db:"payload"`
+	Status      string          `json:"status" db:"status"`
+	DeliveredTo StringList      `json:"delivered_to" db:"delivered_to"`
+	Attempts    int             `json:"attempts" db:"attempts"`
+	RunAt       time.Time       `json:"run_at" db:"run_at"`
+	LockedUntil *time.Time      `json:"-" db:"locked_until"`
+	LastError   string          `json:"last_error" db:"last_error"`
+	PublishedAt *time.Time      `json:"published_at" db:"published_at"`
+	CreatedAt   time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at" db:"updated_at"`
+	// Entity as it was before the change. Empty for created events.
+	Previous json.RawMessage `json:"previous" db:"previous"`
+}
+
+// Returns the event for a change made by the actor, from the entity before and after it. Pass
+// nil for before on create, and 0 for changes made by the system.
+func NewOutboxEvent(aggregateType string, aggregateID uint64, orgID *uint64, actorID uint64, eventType string, before interface{}, after interface{}) (*OutboxEvent, error) {
+	payload, err := json.Marshal(after)
+	if err != nil {
+		return nil, err
+	}
+
+	var previous json.RawMessage
+	if before != nil {
+		previous, err = json.Marshal(before)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	event := &OutboxEvent{
+		AggregateType:  aggregateType,
+		AggregateID:    aggregateID,
+		OrganisationID: orgID,
+		EventType:      eventType,
+		Payload:        payload,
+		Previous:       previous,
+	}
+	if actorID > 0 {
+		event.ActorID = &actorID
+	}
+	return event, nil
+}
+
+// Reports whether the named subscriber or broker handled the event already
+func (event *OutboxEvent) DeliveredBy(name string) bool {
+	for _, delivered := range event.DeliveredTo {
+		if delivered == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,29 @@
+package model
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+type Pagination struct {
+	Page     int `json:"page" form:"page"`
+	PageSize int `json:"page_size" form:"page_size"`
+	Total    int `json:"total" form:"-"`
+}
+
+// Clamps page and page size to sane bounds
+func (p *Pagination) Normalise() {
+	if p.Page < 1 {
+		p.Page = 1
+	}
+	if p.PageSize < 1 {
+		p.PageSize = defaultPageSize
+	}
+	if p.PageSize > maxPageSize {
+		p.PageSize = maxPageSize
+	}
+}
+
+func (p Pagination) Offset() int {
+	return (p.Page - 1) * p.PageSize
+}
@@ -0,0 +1,258 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/supermario64bit/whatsapp_connect/types"
+)
+
+const (
+	SegmentFieldTag         = "tag"
+	SegmentFieldOptInStatus = "opt_in_status"
+	SegmentFieldLastSeenAt  = "last_seen_at"
+	// Prefix of fields comparing a custom attribute, e.g. attributes.city
+	SegmentFieldAttributePrefix = "attributes."
+
+	// Nesting and size limits keep the generated SQL reasonable
+	maxSegmentRuleDepth    = 5
+	maxSegmentRuleChildren = 20
+)
+
+// Operators each kind of field accepts
+var segmentRuleOps = map[string][]string{
+	SegmentFieldTag:             {"has", "not_has"},
+	SegmentFieldOptInStatus:     {"eq", "neq", "in", "not_in"},
+	SegmentFieldLastSeenAt:      {"within", "older_than", "never"},
+	SegmentFieldAttributePrefix: {"eq", "neq", "gt", "gte", "lt", "lte", "contains", "exists", "not_exists"},
+}
+
+type Segment struct {
+	ID             uint64      `json:"id" db:"id"`
+	OrganisationID uint64      `json:"organisation_id" db:"organisation_id"`
+	Name           string      `json:"name" db:"name" validate:"required,max=100"`
+	Description    string      `json:"description" db:"description" validate:"max=500"`
+	Rules          SegmentRule `json:"rules" db:"rules"`
+	CreatedAt      time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time   `json:"updated_at" db:"updated_at"`
+	DeletedAt      *time.Time  `json:"deleted_at" db:"deleted_at"`
+	Version        uint64      `json:"version" db:"version"`
+}
+
+// A segment rule is either a group combining other rules with all, any or not, or a single
+// condition comparing a contact field. For example
+//
+//	{"all": [
+//	  {"field": "tag", "op": "has", "value": "vip"},
+//	  {"field": "attributes.city", "op": "eq", "value": "Pune"},
+//	  {"field": "last_seen_at", "op": "within", "value": "7d"}
+//	]}
+type SegmentRule struct {
+	All   []SegmentRule `json:"all,omitempty"`
+	Any   []SegmentRule `json:"any,omitempty"`
+	Not   *SegmentRule  `json:"not,omitempty"`
+	Field string        `json:"field,omitempty"`
+	Op    string        `json:"op,omitempty"`
+	// Compared against, serialised as value
+	Operand interface{} `json:"value,omitempty"`
+}
+
+// Number of contacts a segment matches along with a few of them
+type SegmentPreview struct {
+	Count  int        `json:"count"`
+	Sample []*Contact `json:"sample"`
+}
+
+func (segment *Segment) Normalise() {
+	segment.Name = strings.TrimSpace(segment.Name)
+	segment.Description = strings.TrimSpace(segment.Description)
+}
+
+// Validates the segment fields and its rules
+func (segment Segment) ValidateFields() []types.FieldError {
+	details := types.NewValidationError(validateStruct(segment)).Details
+	return append(details, segment.Rules.Validate("rules")...)
+}
+
+// Validates only the fields named by their json keys, as sent in a partial update
+func (segment Segment) ValidatePartial(fields []string) []types.FieldError {
+	details := types.NewValidationError(validatePartial(segment, fields)).Details
+	for _, field := range fields {
+		if field == "rules" {
+			details = append(details, segment.Rules.Validate("rules")...)
+		}
+	}
+	return details
+}
+
+// Reports every problem with the rule, naming each by its path from the given root
+func (rule SegmentRule) Validate(path string) []types.FieldError {
+	return rule.validate(path, 1)
+}
+
+func (rule SegmentRule) validate(path string, depth int) []types.FieldError {
+	kinds := 0
+	for _, set := range []bool{rule.All != nil, rule.Any != nil, rule.Not != nil, rule.Field != ""} {
+		if set {
+			kinds++
+		}
+	}
+	if kinds != 1 {
+		return []types.FieldError{ruleError(path, "shape", "must have exactly one of all, any, not or field")}
+	}
+
+	if depth > maxSegmentRuleDepth && rule.Field == "" {
+		return []types.FieldError{ruleError(path, "max_depth", fmt.Sprintf("must not nest groups more than %d levels deep", maxSegmentRuleDepth))}
+	}
+
+	switch {
+	case rule.All != nil:
+		return validateRuleGroup(rule.All, path+".all", depth)
+	case rule.Any != nil:
+		return validateRuleGroup(rule.Any, path+".any", depth)
+	case rule.Not != nil:
+		return rule.Not.validate(path+".not", depth+1)
+	}
+
+	return rule.validateCondition(path)
+}
+
+func validateRuleGroup(rules []SegmentRule, path string, depth int) []types.FieldError {
+	if len(rules) == 0 || len(rules) > maxSegmentRuleChildren {
+		return []types.FieldError{ruleError(path, "size", fmt.Sprintf("must hold between 1 and %d rules", maxSegmentRuleChildren))}
+	}
+
+	var details []types.FieldError
+	for i, child := range rules {
+		details = append(details, child.validate(fmt.Sprintf("%s[%d]", path, i), depth+1)...)
+	}
+	return details
+}
+
+func (rule SegmentRule) validateCondition(path string) []types.FieldError {
+	kind := rule.Field
+	if strings.HasPrefix(kind, SegmentFieldAttributePrefix) {
+		if rule.AttributeKey() == "" || len(rule.AttributeKey()) > 100 {
+			return []types.FieldError{ruleError(path+".field", "attribute", "must name an attribute of at most 100 characters")}
+		}
+		kind = SegmentFieldAttributePrefix
+	}
+
+	ops, ok := segmentRuleOps[kind]
+	if !ok {
+		return []types.FieldError{ruleError(path+".field", "oneof", "must be one of: tag, opt_in_status, last_seen_at, attributes.<key>")}
+	}
+	if !containsTag(ops, rule.Op) {
+		return []types.FieldError{ruleError(path+".op", "oneof", "must be one of: "+strings.Join(ops, ", "))}
+	}
+
+	valuePath := path + ".value"
+	switch kind {
+	case SegmentFieldTag:
+		if s, ok := rule.Operand.(string); !ok || strings.TrimSpace(s) == "" {
+			return []types.FieldError{ruleError(valuePath, "required", "must be a tag name")}
+		}
+	case SegmentFieldOptInStatus:
+		values, ok := rule.OperandList()
+		if !ok || len(values) == 0 || (len(values) > 1 && (rule.Op == "eq" || rule.Op == "neq")) {
+			return []types.FieldError{ruleError(valuePath, "required", "must be an opt in status, or a list of them for in and not_in")}
+		}
+		for _, value := range values {
+			if value != OptInStatusUnknown && value != OptInStatusOptedIn && value != OptInStatusOptedOut {
+				return []types.FieldError{ruleError(valuePath, "oneof", "must be one of: unknown, opted_in, opted_out")}
+			}
+		}
+	case SegmentFieldLastSeenAt:
+		if rule.Op == "never" {
+			break
+		}
+		s, _ := rule.Operand.(string)
+		if _, err := ParseRuleDuration(s); err != nil {
+			return []types.FieldError{ruleError(valuePath, "duration", "must be a duration such as 30m, 12h, 7d or 2w")}
+		}
+	case SegmentFieldAttributePrefix:
+		if rule.Op == "exists" || rule.Op == "not_exists" {
+			break
+		}
+		switch rule.Operand.(type) {
+		case string, float64, bool:
+		default:
+			return []types.FieldError{ruleError(valuePath, "required", "must be a string, number or boolean")}
+		}
+		if _, isBool := rule.Operand.(bool); isBool && rule.Op != "eq" && rule.Op != "neq" {
+			return []types.FieldError{ruleError(valuePath, "type", "can only be compared with eq or neq when it is a boolean")}
+		}
+	}
+
+	return nil
+}
+
+// Returns the attribute key an attributes.<key> condition compares
+func (rule SegmentRule) AttributeKey() string {
+	return strings.TrimPrefix(rule.Field, SegmentFieldAttributePrefix)
+}
+
+// Returns the operand as a list of strings, accepting a single string as a list of one
+func (rule SegmentRule) OperandList() ([]string, bool) {
+	switch value := rule.Operand.(type) {
+	case string:
+		return []string{value}, true
+	case []interface{}:
+		values := make([]string, 0, len(value))
+		for _, v := range value {
+			s, ok := v.(string)
+			if !ok {
+				return nil, false
+			}
+			values = append(values, s)
+		}
+		return values, true
+	}
+	return nil, false
+}
+
+func (rule SegmentRule) Value() (driver.Value, error) {
+	raw, err := json.Marshal(rule)
+	return string(raw), err
+}
+
+func (rule *SegmentRule) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, rule)
+	case string:
+		return json.Unmarshal([]byte(v), rule)
+	}
+	return fmt.Errorf("Cannot scan %T into SegmentRule", src)
+}
+
+// Parses durations written as a whole number followed by m, h, d or w
+func ParseRuleDuration(s string) (time.Duration, error) {
+	units := map[byte]time.Duration{
+		'm': time.Minute,
+		'h': time.Hour,
+		'd': 24 * time.Hour,
+		'w': 7 * 24 * time.Hour,
+	}
+
+	s = strings.TrimSpace(s)
+	if len(s) < 2 {
+		return 0, fmt.Errorf("Invalid duration %q", s)
+	}
+
+	unit, ok := units[s[len(s)-1]]
+	n, err := strconv.Atoi(s[:len(s)-1])
+	if !ok || err != nil || n <= 0 || n > 3650 {
+		return 0, fmt.Errorf("Invalid duration %q", s)
+	}
+
+	return time.Duration(n) * unit, nil
+}
+
+func ruleError(path string, rule string, message string) types.FieldError {
+	return types.FieldError{Field: path, Rule: rule, Message: message}
+}
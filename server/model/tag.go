@@ -0,0 +1,34 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+type Tag struct {
+	ID             uint64    `json:"id" db:"id"`
+	OrganisationID uint64    `json:"organisation_id" db:"organisation_id"`
+	Name           string    `json:"name" db:"name" validate:"required,max=50"`
+	Color          string    `json:"color" db:"color" validate:"omitempty,hexcolor"`
+	ContactCount   int       `json:"contact_count"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// Contacts to add to or remove from a tag
+type TagAssignment struct {
+	ContactIDs []uint64 `json:"contact_ids" validate:"required,min=1,max=1000"`
+}
+
+func (tag *Tag) Normalise() {
+	tag.Name = strings.TrimSpace(tag.Name)
+	tag.Color = strings.ToLower(strings.TrimSpace(tag.Color))
+}
+
+func (tag Tag) ValidateFields() []error {
+	return validateStruct(tag)
+}
+
+func (assignment TagAssignment) ValidateFields() []error {
+	return validateStruct(assignment)
+}
@@ -0,0 +1,154 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/supermario64bit/whatsapp_connect/types"
+)
+
+const maxTimeWindows = 20
+
+// Days a time window may name, keyed by their short names
+var weekDays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// Weekly time window, read in the zone of whatever it belongs to. A window ending before it
+// starts runs past midnight into the next day. Without days the window applies every day.
+//
+//	{"days": ["mon", "tue", "wed", "thu", "fri"], "start": "18:00", "end": "09:00"}
+type TimeWindow struct {
+	Days  []string `json:"days"`
+	Start string   `json:"start"`
+	End   string   `json:"end"`
+}
+
+type TimeWindows []TimeWindow
+
+func (windows TimeWindows) Normalise() {
+	for i := range windows {
+		for j, day := range windows[i].Days {
+			windows[i].Days[j] = strings.ToLower(strings.TrimSpace(day))
+		}
+		windows[i].Start = strings.TrimSpace(windows[i].Start)
+		windows[i].End = strings.TrimSpace(windows[i].End)
+	}
+}
+
+// Reports whether one of the windows holds the given time, read in the zone
+func (windows TimeWindows) Hold(timezone string, at time.Time) bool {
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return false
+	}
+	local := at.In(location)
+
+	for _, window := range windows {
+		if window.holds(local) {
+			return true
+		}
+	}
+	return false
+}
+
+func (windows TimeWindows) validate(path string) []types.FieldError {
+	if len(windows) > maxTimeWindows {
+		return []types.FieldError{{Field: path, Rule: "max", Message: fmt.Sprintf("must hold at most %d windows", maxTimeWindows)}}
+	}
+
+	var details []types.FieldError
+	for i, window := range windows {
+		details = append(details, window.validate(fmt.Sprintf("%s[%d]", path, i))...)
+	}
+	return details
+}
+
+func (window TimeWindow) validate(path string) []types.FieldError {
+	var details []types.FieldError
+	for j, day := range window.Days {
+		if _, ok := weekDays[day]; !ok {
+			details = append(details, types.FieldError{Field: fmt.Sprintf("%s.days[%d]", path, j), Rule: "oneof", Message: "must be one of: mon, tue, wed, thu, fri, sat, sun"})
+		}
+	}
+
+	start, startErr := parseClock(window.Start)
+	if startErr != nil {
+		details = append(details, types.FieldError{Field: path + ".start", Rule: "time", Message: "must be a time of day such as 09:00"})
+	}
+	end, endErr := parseClock(window.End)
+	if endErr != nil {
+		details = append(details, types.FieldError{Field: path + ".end", Rule: "time", Message: "must be a time of day such as 17:30"})
+	}
+	if startErr == nil && endErr == nil && start == end {
+		details = append(details, types.FieldError{Field: path + ".end", Rule: "ne", Message: "must differ from start"})
+	}
+	return details
+}
+
+func (window TimeWindow) holds(local time.Time) bool {
+	start, err := parseClock(window.Start)
+	if err != nil {
+		return false
+	}
+	end, err := parseClock(window.End)
+	if err != nil {
+		return false
+	}
+
+	minute := local.Hour()*60 + local.Minute()
+	if start < end {
+		return window.onDay(local.Weekday()) && minute >= start && minute < end
+	}
+
+	// Runs past midnight, so the early hours belong to the window which started the day before
+	if minute >= start {
+		return window.onDay(local.Weekday())
+	}
+	return minute < end && window.onDay((local.Weekday()+6)%7)
+}
+
+func (window TimeWindow) onDay(day time.Weekday) bool {
+	if len(window.Days) == 0 {
+		return true
+	}
+	for _, name := range window.Days {
+		if weekDays[name] == day {
+			return true
+		}
+	}
+	return false
+}
+
+// Parses a time of day written as HH:MM into minutes past midnight
+func parseClock(value string) (int, error) {
+	at, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, err
+	}
+	return at.Hour()*60 + at.Minute(), nil
+}
+
+func (windows TimeWindows) Value() (driver.Value, error) {
+	raw, err := json.Marshal(windows)
+	return string(raw), err
+}
+
+func (windows *TimeWindows) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, windows)
+	case string:
+		return json.Unmarshal([]byte(v), windows)
+	}
+	return fmt.Errorf("Cannot scan %T into TimeWindows", src)
+}
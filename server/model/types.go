@@ -0,0 +1,61 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// Free form JSON object stored in a JSONB column
+type JSONMap map[string]interface{}
+
+func (m JSONMap) Value() (driver.Value, error) {
+	if m == nil {
+		return "{}", nil
+	}
+
+	raw, err := json.Marshal(m)
+	return string(raw), err
+}
+
+func (m *JSONMap) Scan(src interface{}) error {
+	var raw []byte
+	switch v := src.(type) {
+	case nil:
+		*m = JSONMap{}
+		return nil
+	case []byte:
+		raw = v
+	case string:
+		raw = []byte(v)
+	default:
+		return fmt.Errorf("Cannot scan %T into JSONMap", src)
+	}
+
+	return json.Unmarshal(raw, m)
+}
+
+// List of strings stored in a TEXT[] column
+type StringList []string
+
+func (l StringList) Value() (driver.Value, error) {
+	if l == nil {
+		return pq.StringArray{}.Value()
+	}
+	return pq.StringArray(l).Value()
+}
+
+func (l *StringList) Scan(src interface{}) error {
+	var arr pq.StringArray
+	if err := arr.Scan(src); err != nil {
+		return err
+	}
+
+	*l = StringList(arr)
+	if *l == nil {
+		*l = StringList{}
+	}
+	return nil
+}
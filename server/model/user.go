@@ -2,34 +2,36 @@ package model
 
 import (
 	"time"
-
-	"github.com/go-playground/validator/v10"
 )
 
 type User struct {
 	ID        uint64     `json:"id" db:"id"`
 	Name      string     `json:"name" db:"name" validate:"required,min=2,max=100"`
-	Handle    string     `json:"handle" db:"handle" validate:"required,min=2,max=100"`
-	Mobile    string     `json:"mobile_number" db:"mobile_number" validate:"required,len=10,numeric"`
+	Handle    string     `json:"handle" db:"handle" validate:"required,min=2,max=10"`
+	Mobile    string     `json:"mobile_number" db:"mobile_number" validate:"required,phone"`
 	Email     string     `json:"email" db:"email" validate:"required,email"`
 	Status    string     `json:"status" db:"status" validate:"required,oneof=active inactive"`
 	CreatedAt time.Time  `json:"created_at" db:"created_at"`
 	UpdatedAt time.Time  `json:"updated_at" db:"updated_at"`
 	DeletedAt *time.Time `json:"deleted_at" db:"deleted_at"`
+	Version   uint64     `json:"version" db:"version"`
 }
 
-func (org User) ValidateFields() []error {
-	validate := validator.New()
-	err := validate.Struct(org)
+// Rewrites the mobile number into E.164 so it is stored and compared in one format
+func (org *User) Normalise() {
+	org.Mobile = normalisePhone(org.Mobile)
+}
 
-	var errors []error
-	if err == nil {
-		return errors
-	}
+func (org User) ValidateFields() []error {
+	return validateStruct(org)
+}
 
-	for _, err := range err.(validator.ValidationErrors) {
-		errors = append(errors, err)
-	}
+// Validates only the fields named by their json keys, as sent in a partial update
+func (org User) ValidatePartial(fields []string) []error {
+	return validatePartial(org, fields)
+}
 
-	return errors
+// Validates the fields a full update writes, which are the ones it supplies
+func (org User) ValidateSupplied() []error {
+	return validatePartial(org, suppliedFields(org))
 }
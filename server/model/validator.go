@@ -0,0 +1,115 @@
+package model
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/supermario64bit/whatsapp_connect/pkg/phone"
+	"github.com/supermario64bit/whatsapp_connect/pkg/safehttp"
+)
+
+var validate = newValidator()
+
+// Meta only accepts lowercase letters, digits and underscores in template names
+var templateNamePattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+	// Report field errors by their json keys, the names clients actually send
+	v.RegisterTagNameFunc(JSONName)
+	v.RegisterValidation("phone", validatePhone)
+	v.RegisterValidation("template_name", func(fl validator.FieldLevel) bool {
+		return templateNamePattern.MatchString(fl.Field().String())
+	})
+	// URLs the server posts to, which must not reach into its own network
+	v.RegisterValidation("public_https_url", func(fl validator.FieldLevel) bool {
+		return safehttp.CheckURL(fl.Field().String(), true) == nil
+	})
+	return v
+}
+
+// Accepts phone numbers already normalised to E.164 which are valid for their country
+func validatePhone(fl validator.FieldLevel) bool {
+	value := fl.Field().String()
+	normalised, err := phone.Normalise(value)
+	return err == nil && normalised == value
+}
+
+// Returns raw in E.164 when it parses, otherwise leaves it as is for validation to report
+func normalisePhone(raw string) string {
+	if raw == "" {
+		return raw
+	}
+
+	normalised, err := phone.Normalise(raw)
+	if err != nil {
+		return raw
+	}
+	return normalised
+}
+
+func validateStruct(entity interface{}) []error {
+	return collectValidationErrors(validate.Struct(entity))
+}
+
+// Validates only the given fields, named by their json keys. Unknown names are ignored.
+func validatePartial(entity interface{}, jsonFields []string) []error {
+	structFields := []string{}
+	typ := reflect.Indirect(reflect.ValueOf(entity)).Type()
+	for _, jsonField := range jsonFields {
+		for i := 0; i < typ.NumField(); i++ {
+			if JSONName(typ.Field(i)) == jsonField {
+				structFields = append(structFields, typ.Field(i).Name)
+			}
+		}
+	}
+
+	if len(structFields) == 0 {
+		return nil
+	}
+
+	return collectValidationErrors(validate.StructPartial(entity, structFields...))
+}
+
+// Returns the json keys of the string fields holding more than whitespace, which are the fields
+// a full update writes
+func suppliedFields(entity interface{}) []string {
+	fields := []string{}
+	value := reflect.Indirect(reflect.ValueOf(entity))
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Field(i)
+		if field.Kind() == reflect.String && strings.TrimSpace(field.String()) != "" {
+			fields = append(fields, JSONName(value.Type().Field(i)))
+		}
+	}
+	return fields
+}
+
+// Returns the json key a struct field is (un)marshalled with
+func JSONName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+func collectValidationErrors(err error) []error {
+	var errors []error
+	if err == nil {
+		return errors
+	}
+
+	validationErrors, ok := err.(validator.ValidationErrors)
+	if !ok {
+		return append(errors, err)
+	}
+
+	for _, err := range validationErrors {
+		errors = append(errors, err)
+	}
+
+	return errors
+}
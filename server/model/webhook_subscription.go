@@ -0,0 +1,125 @@
+package model
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/supermario64bit/whatsapp_connect/types"
+)
+
+const (
+	WebhookEventMessageReceived = "message.received"
+	WebhookEventMessageStatus   = "message.status"
+	WebhookEventContactCreated  = "contact.created"
+
+	WebhookDeliveryStatusPending   = "pending"
+	WebhookDeliveryStatusRunning   = "running"
+	WebhookDeliveryStatusSucceeded = "succeeded"
+	// Deliveries which ran out of attempts. They stay until redelivered by hand.
+	WebhookDeliveryStatusDead = "dead"
+
+	// Headers sent along with every delivery. The signature is the hex HMAC-SHA256 of the
+	// timestamp, a dot and the body, keyed with the subscription's secret:
+	//
+	//	X-Webhook-Signature: sha256=hex(hmac_sha256(secret, timestamp + "." + body))
+	WebhookHeaderEventID   = "X-Webhook-Id"
+	WebhookHeaderEventType = "X-Webhook-Event"
+	WebhookHeaderDelivery  = "X-Webhook-Delivery"
+	WebhookHeaderTimestamp = "X-Webhook-Timestamp"
+	WebhookHeaderSignature = "X-Webhook-Signature"
+)
+
+// Tenant endpoint the organisation's events are posted to
+type WebhookSubscription struct {
+	ID             uint64 `json:"id" db:"id"`
+	OrganisationID uint64 `json:"organisation_id" db:"organisation_id"`
+	URL            string `json:"url" db:"url" validate:"required,public_https_url,max=2000"`
+	// Key the payloads are signed with. Generated when left out, and only shown when it is set.
+	Secret     string     `json:"secret,omitempty" db:"secret" validate:"omitempty,min=16,max=200"`
+	EventTypes StringList `json:"event_types" db:"event_types" validate:"required,min=1,dive,oneof=message.received message.status contact.created"`
+	Enabled    bool       `json:"enabled" db:"enabled"`
+	// Failed attempts since the last successful one. Too many disable the subscription.
+	ConsecutiveFailures int        `json:"consecutive_failures" db:"consecutive_failures"`
+	DisabledReason      string     `json:"disabled_reason" db:"disabled_reason"`
+	DisabledAt          *time.Time `json:"disabled_at" db:"disabled_at"`
+	CreatedAt           time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at" db:"updated_at"`
+	DeletedAt           *time.Time `json:"deleted_at" db:"deleted_at"`
+	Version             uint64     `json:"version" db:"version"`
+}
+
+// Event posted to a subscription. Retries and redeliveries post the same payload again.
+type WebhookDelivery struct {
+	ID             uint64 `json:"id" db:"id"`
+	OrganisationID uint64 `json:"organisation_id" db:"organisation_id"`
+	SubscriptionID uint64 `json:"subscription_id" db:"subscription_id"`
+	EventID        string `json:"event_id" db:"event_id"`
+	EventType      string `json:"event_type" db:"event_type"`
+	// Body as it is posted, a WebhookPayload
+	Payload     json.RawMessage `json:"payload" db:"payload"`
+	Status      string          `json:"status" db:"status"`
+	Attempts    int             `json:"attempts" db:"attempts"`
+	MaxAttempts int             `json:"max_attempts" db:"max_attempts"`
+	RunAt       time.Time       `json:"run_at" db:"run_at"`
+	LockedUntil *time.Time      `json:"-" db:"locked_until"`
+	// Outcome of the latest attempt
+	ResponseStatus *int       `json:"response_status" db:"response_status"`
+	ResponseBody   string     `json:"response_body" db:"response_body"`
+	DurationMs     *int       `json:"duration_ms" db:"duration_ms"`
+	LastError      string     `json:"last_error" db:"last_error"`
+	CompletedAt    *time.Time `json:"completed_at" db:"completed_at"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// Outcome of posting a delivery once
+type WebhookAttempt struct {
+	ResponseStatus *int
+	ResponseBody   string
+	DurationMs     int
+	Error          string
+}
+
+type WebhookDeliveryFilter struct {
+	Status    string `form:"status"`
+	EventType string `form:"event_type"`
+	Pagination
+}
+
+// Body of every delivery
+//
+//	{"id": "3f2a…", "type": "message.received", "organisation_id": 1, "created_at": "…", "data": {…}}
+type WebhookPayload struct {
+	ID             string      `json:"id"`
+	Type           string      `json:"type"`
+	OrganisationID uint64      `json:"organisation_id"`
+	CreatedAt      time.Time   `json:"created_at"`
+	Data           interface{} `json:"data"`
+}
+
+func (subscription *WebhookSubscription) Normalise() {
+	subscription.URL = strings.TrimSpace(subscription.URL)
+	subscription.Secret = strings.TrimSpace(subscription.Secret)
+	if subscription.EventTypes == nil {
+		subscription.EventTypes = StringList{}
+	}
+	for i, eventType := range subscription.EventTypes {
+		subscription.EventTypes[i] = strings.ToLower(strings.TrimSpace(eventType))
+	}
+}
+
+func (subscription WebhookSubscription) ValidateFields() []types.FieldError {
+	return types.NewValidationError(validateStruct(subscription)).Details
+}
+
+// Validates only the fields named by their json keys, as sent in a partial update
+func (subscription WebhookSubscription) ValidatePartial(fields []string) []types.FieldError {
+	return types.NewValidationError(validatePartial(subscription, fields)).Details
+}
+
+// Returns a copy safe to send to clients, without the secret
+func (subscription WebhookSubscription) Redacted() *WebhookSubscription {
+	subscription.Secret = ""
+	return &subscription
+}
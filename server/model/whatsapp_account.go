@@ -0,0 +1,105 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	WhatsAppAccountStatusActive   = "active"
+	WhatsAppAccountStatusInactive = "inactive"
+
+	// Messaging limit tiers Meta assigns phone numbers
+	WhatsAppAccountTier250       = "TIER_250"
+	WhatsAppAccountTier1K        = "TIER_1K"
+	WhatsAppAccountTier10K       = "TIER_10K"
+	WhatsAppAccountTier100K      = "TIER_100K"
+	WhatsAppAccountTierUnlimited = "TIER_UNLIMITED"
+
+	// Throughput of Cloud API phone numbers unless Meta raised it
+	DefaultMessagesPerSecond = 80
+)
+
+// Unique customers an account may message first in a rolling 24 hours, per tier. Unlimited
+// tiers are left out.
+var messagingTierLimits = map[string]int{
+	WhatsAppAccountTier250:  250,
+	WhatsAppAccountTier1K:   1000,
+	WhatsAppAccountTier10K:  10000,
+	WhatsAppAccountTier100K: 100000,
+}
+
+type WhatsAppAccount struct {
+	ID                 uint64     `json:"id" db:"id"`
+	OrganisationID     uint64     `json:"organisation_id" db:"organisation_id"`
+	Name               string     `json:"name" db:"name" validate:"required,min=2,max=100"`
+	BusinessAccountID  string     `json:"business_account_id" db:"business_account_id" validate:"required,numeric,max=50"`
+	PhoneNumberID      string     `json:"phone_number_id" db:"phone_number_id" validate:"required,numeric,max=50"`
+	DisplayPhoneNumber string     `json:"display_phone_number" db:"display_phone_number" validate:"required,phone"`
+	AccessToken        string     `json:"access_token,omitempty" db:"access_token" validate:"required"`
+	Status             string     `json:"status" db:"status" validate:"required,oneof=active inactive"`
+	CreatedAt          time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at" db:"updated_at"`
+	DeletedAt          *time.Time `json:"deleted_at" db:"deleted_at"`
+	Version            uint64     `json:"version" db:"version"`
+	MessagesPerSecond  int        `json:"messages_per_second" db:"messages_per_second" validate:"min=1,max=1000"`
+	MessagingTier      string     `json:"messaging_tier" db:"messaging_tier" validate:"oneof=TIER_250 TIER_1K TIER_10K TIER_100K TIER_UNLIMITED"`
+}
+
+// Sending limits of an account, set to what Meta shows for the phone number
+type WhatsAppAccountLimits struct {
+	MessagesPerSecond int    `json:"messages_per_second" validate:"required,min=1,max=1000"`
+	MessagingTier     string `json:"messaging_tier" validate:"required,oneof=TIER_250 TIER_1K TIER_10K TIER_100K TIER_UNLIMITED"`
+}
+
+// Current use of an account's sending limits. Daily figures are nil for unlimited tiers.
+type WhatsAppAccountUsage struct {
+	WhatsAppAccountID uint64 `json:"whatsapp_account_id"`
+	MessagesPerSecond int    `json:"messages_per_second"`
+	MessagingTier     string `json:"messaging_tier"`
+	// Messages Meta accepted from the account in the last minute
+	SentLastMinute int `json:"sent_last_minute"`
+	// Unique customers sent a template in the last 24 hours
+	UniqueRecipients24h int  `json:"unique_recipients_24h"`
+	DailyLimit          *int `json:"daily_limit"`
+	DailyRemaining      *int `json:"daily_remaining"`
+	// Jobs of the account waiting in the send queue, including throttled ones
+	PendingJobs int `json:"pending_jobs"`
+}
+
+// Rewrites the display phone number into E.164 so it is stored and compared in one format
+func (account *WhatsAppAccount) Normalise() {
+	account.DisplayPhoneNumber = normalisePhone(account.DisplayPhoneNumber)
+	account.MessagingTier = strings.ToUpper(strings.TrimSpace(account.MessagingTier))
+	if account.MessagingTier == "" {
+		account.MessagingTier = WhatsAppAccountTier1K
+	}
+	if account.MessagesPerSecond == 0 {
+		account.MessagesPerSecond = DefaultMessagesPerSecond
+	}
+}
+
+// Returns the unique customers the account may message first in 24 hours, false when its tier
+// has no limit
+func (account WhatsAppAccount) DailyLimit() (int, bool) {
+	limit, ok := messagingTierLimits[account.MessagingTier]
+	return limit, ok
+}
+
+func (limits *WhatsAppAccountLimits) Normalise() {
+	limits.MessagingTier = strings.ToUpper(strings.TrimSpace(limits.MessagingTier))
+}
+
+func (limits WhatsAppAccountLimits) ValidateFields() []error {
+	return validateStruct(limits)
+}
+
+func (account WhatsAppAccount) ValidateFields() []error {
+	return validateStruct(account)
+}
+
+// Returns a copy safe to send to clients, without the access token
+func (account WhatsAppAccount) Redacted() *WhatsAppAccount {
+	account.AccessToken = ""
+	return &account
+}
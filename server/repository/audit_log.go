@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/supermario64bit/whatsapp_connect/db"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+)
+
+const audit_log_table_name string = "audit_log"
+
+type auditLogRepository struct {
+	db *sql.DB
+}
+
+type AuditLogRepository interface {
+	Create(entry *model.AuditLog) (*model.AuditLog, error)
+	Find(filter *model.AuditLogFilter) ([]*model.AuditLog, int, error)
+}
+
+func NewAuditLogRepository() AuditLogRepository {
+	return &auditLogRepository{
+		db: db.New(),
+	}
+}
+
+// Stores the entry. Entries for an outbox event already audited are skipped, returning
+// sql.ErrNoRows.
+func (repo *auditLogRepository) Create(entry *model.AuditLog) (*model.AuditLog, error) {
+	if entry == nil {
+		return nil, fmt.Errorf("Cannot create audit log for nil reference")
+	}
+
+	if entry.EntityType == "" || entry.EntityID == 0 || entry.Action == "" {
+		return nil, fmt.Errorf("Entity Type, Entity ID and Action field should not be empty")
+	}
+
+	diff := entry.Diff
+	if len(diff) == 0 {
+		diff = []byte("{}")
+	}
+
+	colNames := []string{"actor_id", "organisation_id", "entity_type", "entity_id", "action", "diff", "outbox_event_id"}
+	values := [][]interface{}{
+		{entry.ActorID, entry.OrganisationID, entry.EntityType, entry.EntityID, entry.Action, string(diff), entry.OutboxEventID},
+	}
+
+	qry, args := generateInsertStatement(audit_log_table_name, colNames, values)
+	qry += " ON CONFLICT (outbox_event_id) DO NOTHING RETURNING *"
+
+	var created model.AuditLog
+	err := repo.db.QueryRow(qry, args...).Scan(
+		&created.ID,
+		&created.ActorID,
+		&created.OrganisationID,
+		&created.EntityType,
+		&created.EntityID,
+		&created.Action,
+		&created.Diff,
+		&created.CreatedAt,
+		&created.OutboxEventID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &created, nil
+}
+
+// Returns one page of matching entries, newest first, along with the total match count
+func (repo *auditLogRepository) Find(filter *model.AuditLogFilter) ([]*model.AuditLog, int, error) {
+	args := []interface{}{}
+	whereParts := []string{}
+	if strings.TrimSpace(filter.EntityType) != "" {
+		args = append(args, strings.TrimSpace(filter.EntityType))
+		whereParts = append(whereParts, fmt.Sprintf("entity_type = $%d", len(args)))
+	}
+
+	if filter.EntityID > 0 {
+		args = append(args, filter.EntityID)
+		whereParts = append(whereParts, fmt.Sprintf("entity_id = $%d", len(args)))
+	}
+
+	if filter.ActorID > 0 {
+		args = append(args, filter.ActorID)
+		whereParts = append(whereParts, fmt.Sprintf("actor_id = $%d", len(args)))
+	}
+
+	if filter.OrganisationID > 0 {
+		args = append(args, filter.OrganisationID)
+		whereParts = append(whereParts, fmt.Sprintf("organisation_id = $%d", len(args)))
+	}
+
+	whereClause := ""
+	if len(whereParts) > 0 {
+		whereClause = " WHERE " + strings.Join(whereParts, " AND ")
+	}
+
+	var total int
+	err := repo.db.QueryRow("SELECT COUNT(*) FROM "+audit_log_table_name+whereClause, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	args = append(args, filter.PageSize, filter.Offset())
+	qry := "SELECT * FROM " + audit_log_table_name + whereClause +
+		fmt.Sprintf(" ORDER BY created_at DESC, id DESC LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	rows, err := repo.db.Query(qry, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	defer rows.Close()
+
+	var entries []*model.AuditLog
+
+	for rows.Next() {
+		var entry model.AuditLog
+
+		err := rows.Scan(
+			&entry.ID,
+			&entry.ActorID,
+			&entry.OrganisationID,
+			&entry.EntityType,
+			&entry.EntityID,
+			&entry.Action,
+			&entry.Diff,
+			&entry.CreatedAt,
+			&entry.OutboxEventID,
+		)
+
+		if err != nil {
+			return nil, 0, err
+		}
+
+		entries = append(entries, &entry)
+	}
+
+	return entries, total, nil
+}
@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/supermario64bit/whatsapp_connect/db"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+)
+
+const auto_reply_rule_table_name string = "auto_reply_rules"
+
+type autoReplyRuleRepository struct {
+	db *sql.DB
+}
+
+type AutoReplyRuleRepository interface {
+	Create(rule *model.AutoReplyRule) (*model.AutoReplyRule, error)
+	Find(orgID uint64) ([]*model.AutoReplyRule, error)
+	FindByID(orgID uint64, id uint64) (*model.AutoReplyRule, error)
+	FindEnabled(orgID uint64, accountID uint64) ([]*model.AutoReplyRule, error)
+	PatchByID(id uint64, version uint64, changes map[string]interface{}) (*model.AutoReplyRule, error)
+	DeleteByID(orgID uint64, id uint64, version uint64) error
+}
+
+func NewAutoReplyRuleRepository() AutoReplyRuleRepository {
+	return &autoReplyRuleRepository{
+		db: db.New(),
+	}
+}
+
+func (repo *autoReplyRuleRepository) Create(rule *model.AutoReplyRule) (*model.AutoReplyRule, error) {
+	if rule == nil {
+		return nil, fmt.Errorf("Cannot create auto reply rule for nil reference")
+	}
+
+	if rule.ID > 0 {
+		return nil, fmt.Errorf("ID field should be empty")
+	}
+
+	colNames := []string{"organisation_id", "whatsapp_account_id", "name", "match_type", "pattern", "priority", "enabled", "timezone", "windows", "response"}
+	values := [][]interface{}{
+		{rule.OrganisationID, rule.WhatsAppAccountID, rule.Name, rule.MatchType, rule.Pattern, rule.Priority, rule.Enabled, rule.Timezone, rule.Windows, rule.Response},
+	}
+
+	qry, args := generateInsertQuery(auto_reply_rule_table_name, colNames, values)
+
+	created, err := scanAutoReplyRule(repo.db.QueryRow(qry, args...))
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return created, nil
+}
+
+// Returns the organisation's rules in the order they are tried, highest priority first
+func (repo *autoReplyRuleRepository) Find(orgID uint64) ([]*model.AutoReplyRule, error) {
+	qry := "SELECT * FROM " + auto_reply_rule_table_name + " WHERE organisation_id = $1 AND deleted_at IS NULL ORDER BY priority DESC, id"
+	return repo.query(qry, orgID)
+}
+
+func (repo *autoReplyRuleRepository) FindByID(orgID uint64, id uint64) (*model.AutoReplyRule, error) {
+	qry := "SELECT * FROM " + auto_reply_rule_table_name + " WHERE id = $1 AND organisation_id = $2 AND deleted_at IS NULL LIMIT 1"
+
+	return scanAutoReplyRule(repo.db.QueryRow(qry, id, orgID))
+}
+
+// Returns the enabled rules answering messages to the account, in the order they are tried
+func (repo *autoReplyRuleRepository) FindEnabled(orgID uint64, accountID uint64) ([]*model.AutoReplyRule, error) {
+	qry := "SELECT * FROM " + auto_reply_rule_table_name + " WHERE organisation_id = $1 AND enabled AND deleted_at IS NULL " +
+		"AND (whatsapp_account_id IS NULL OR whatsapp_account_id = $2) ORDER BY priority DESC, id"
+	return repo.query(qry, orgID, accountID)
+}
+
+// Writes only the given column values. Callers are expected to have validated them.
+func (repo *autoReplyRuleRepository) PatchByID(id uint64, version uint64, changes map[string]interface{}) (*model.AutoReplyRule, error) {
+	qry, args := generateUpdateQuery(auto_reply_rule_table_name, changes, id, version)
+
+	updated, err := scanAutoReplyRule(repo.db.QueryRow(qry, args...))
+	if err == sql.ErrNoRows {
+		return nil, ErrVersionMismatch
+	}
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return updated, nil
+}
+
+func (repo *autoReplyRuleRepository) DeleteByID(orgID uint64, id uint64, version uint64) error {
+	current, err := repo.FindByID(orgID, id)
+	if err != nil {
+		return err
+	}
+
+	if version > 0 && current.Version != version {
+		return ErrVersionMismatch
+	}
+
+	qry := "UPDATE " + auto_reply_rule_table_name + " SET deleted_at = $1 WHERE id = $2 AND version = $3 AND deleted_at IS NULL"
+	res, err := repo.db.Exec(qry, time.Now(), id, current.Version)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrVersionMismatch
+	}
+
+	return nil
+}
+
+func (repo *autoReplyRuleRepository) query(qry string, args ...interface{}) ([]*model.AutoReplyRule, error) {
+	rows, err := repo.db.Query(qry, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var rules []*model.AutoReplyRule
+
+	for rows.Next() {
+		rule, err := scanAutoReplyRule(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, rows.Err()
+}
+
+func scanAutoReplyRule(row rowScanner) (*model.AutoReplyRule, error) {
+	var rule model.AutoReplyRule
+
+	err := row.Scan(
+		&rule.ID,
+		&rule.OrganisationID,
+		&rule.WhatsAppAccountID,
+		&rule.Name,
+		&rule.MatchType,
+		&rule.Pattern,
+		&rule.Priority,
+		&rule.Enabled,
+		&rule.Timezone,
+		&rule.Windows,
+		&rule.Response,
+		&rule.CreatedAt,
+		&rule.UpdatedAt,
+		&rule.DeletedAt,
+		&rule.Version,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &rule, nil
+}
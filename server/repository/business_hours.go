@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/supermario64bit/whatsapp_connect/db"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+)
+
+const (
+	business_hours_table_name        string = "business_hours"
+	auto_message_delivery_table_name string = "auto_message_deliveries"
+)
+
+type businessHoursRepository struct {
+	db *sql.DB
+}
+
+type BusinessHoursRepository interface {
+	FindByOrganisation(orgID uint64) (*model.BusinessHours, error)
+	Save(hours *model.BusinessHours, version uint64) (*model.BusinessHours, error)
+	ClaimDelivery(contactID uint64, kind string, at time.Time, interval time.Duration) (bool, error)
+	ReleaseDelivery(contactID uint64, kind string, at time.Time) error
+}
+
+func NewBusinessHoursRepository() BusinessHoursRepository {
+	return &businessHoursRepository{
+		db: db.New(),
+	}
+}
+
+// Returns sql.ErrNoRows when the organisation has not set its hours
+func (repo *businessHoursRepository) FindByOrganisation(orgID uint64) (*model.BusinessHours, error) {
+	qry := "SELECT * FROM " + business_hours_table_name + " WHERE organisation_id = $1 LIMIT 1"
+
+	return scanBusinessHours(repo.db.QueryRow(qry, orgID))
+}
+
+// Creates or replaces the organisation's hours. A version above zero must match the stored
+// hours, which must then exist.
+func (repo *businessHoursRepository) Save(hours *model.BusinessHours, version uint64) (*model.BusinessHours, error) {
+	if hours == nil {
+		return nil, fmt.Errorf("Cannot save business hours for nil reference")
+	}
+
+	args := []interface{}{hours.OrganisationID, hours.Timezone, hours.Windows, hours.Holidays, hours.AwayMessage, hours.GreetingMessage}
+	qry := "INSERT INTO " + business_hours_table_name + " (organisation_id, timezone, windows, holidays, away_message, greeting_message) " +
+		"VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT (organisation_id) DO UPDATE SET timezone = EXCLUDED.timezone, " +
+		"windows = EXCLUDED.windows, holidays = EXCLUDED.holidays, away_message = EXCLUDED.away_message, " +
+		"greeting_message = EXCLUDED.greeting_message RETURNING *"
+
+	if version > 0 {
+		// Matching a version means replacing existing hours, never creating them
+		args = append(args, version)
+		qry = "UPDATE " + business_hours_table_name + " SET timezone = $2, windows = $3, holidays = $4, away_message = $5, " +
+			"greeting_message = $6 WHERE organisation_id = $1 AND version = $7 RETURNING *"
+	}
+
+	saved, err := scanBusinessHours(repo.db.QueryRow(qry, args...))
+	if err == sql.ErrNoRows {
+		return nil, ErrVersionMismatch
+	}
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return saved, nil
+}
+
+// Records that the contact gets the message at the given time, unless it got it less than
+// the interval before. Reports whether the message may be sent. Concurrent claims for the
+// same contact let only one through.
+func (repo *businessHoursRepository) ClaimDelivery(contactID uint64, kind string, at time.Time, interval time.Duration) (bool, error) {
+	qry := "INSERT INTO " + auto_message_delivery_table_name + " (contact_id, kind, sent_at) VALUES ($1, $2, $3) " +
+		"ON CONFLICT (contact_id, kind) DO UPDATE SET sent_at = EXCLUDED.sent_at " +
+		"WHERE " + auto_message_delivery_table_name + ".sent_at <= $3 - $4 * INTERVAL '1 second' RETURNING contact_id"
+
+	var claimed uint64
+	err := repo.db.QueryRow(qry, contactID, kind, at, interval.Seconds()).Scan(&claimed)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Forgets a claim whose message could not be sent, so the contact may get it next time
+func (repo *businessHoursRepository) ReleaseDelivery(contactID uint64, kind string, at time.Time) error {
+	qry := "DELETE FROM " + auto_message_delivery_table_name + " WHERE contact_id = $1 AND kind = $2 AND sent_at = $3"
+	_, err := repo.db.Exec(qry, contactID, kind, at)
+	return err
+}
+
+func scanBusinessHours(row rowScanner) (*model.BusinessHours, error) {
+	var hours model.BusinessHours
+
+	err := row.Scan(
+		&hours.OrganisationID,
+		&hours.Timezone,
+		&hours.Windows,
+		&hours.Holidays,
+		&hours.AwayMessage,
+		&hours.GreetingMessage,
+		&hours.CreatedAt,
+		&hours.UpdatedAt,
+		&hours.Version,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &hours, nil
+}
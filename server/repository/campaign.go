@@ -0,0 +1,182 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/supermario64bit/whatsapp_connect/db"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+)
+
+const campaign_table_name string = "campaigns"
+
+type campaignRepository struct {
+	db *sql.DB
+}
+
+type CampaignRepository interface {
+	Create(campaign *model.Campaign) (*model.Campaign, error)
+	Find(orgID uint64, page *model.Pagination) ([]*model.Campaign, int, error)
+	FindByID(orgID uint64, id uint64) (*model.Campaign, error)
+	Schedule(orgID uint64, id uint64, at time.Time, timezone string) (*model.Campaign, error)
+	Cancel(orgID uint64, id uint64) (*model.Campaign, error)
+	ClaimDue(lease time.Duration) (*model.Campaign, error)
+	ExtendLease(id uint64, lease time.Duration) (bool, error)
+	Complete(id uint64) error
+	Fail(id uint64, reason string) error
+}
+
+func NewCampaignRepository() CampaignRepository {
+	return &campaignRepository{
+		db: db.New(),
+	}
+}
+
+func (repo *campaignRepository) Create(campaign *model.Campaign) (*model.Campaign, error) {
+	if campaign == nil {
+		return nil, fmt.Errorf("Cannot create campaign for nil reference")
+	}
+
+	if campaign.ID > 0 {
+		return nil, fmt.Errorf("ID field should be empty")
+	}
+
+	colNames := []string{"organisation_id", "whatsapp_account_id", "segment_id", "template_id", "name", "template", "status", "timezone", "scheduled_at"}
+	values := [][]interface{}{
+		{campaign.OrganisationID, campaign.WhatsAppAccountID, campaign.SegmentID, campaign.TemplateID, campaign.Name, campaign.Template, campaign.Status, campaign.Timezone, campaign.ScheduledAt},
+	}
+
+	qry, args := generateInsertQuery(campaign_table_name, colNames, values)
+
+	created, err := scanCampaign(repo.db.QueryRow(qry, args...))
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return created, nil
+}
+
+// Returns one page of the organisation's campaigns, newest first, along with the total count
+func (repo *campaignRepository) Find(orgID uint64, page *model.Pagination) ([]*model.Campaign, int, error) {
+	var total int
+	err := repo.db.QueryRow("SELECT COUNT(*) FROM "+campaign_table_name+" WHERE organisation_id = $1", orgID).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	qry := "SELECT * FROM " + campaign_table_name + " WHERE organisation_id = $1 ORDER BY created_at DESC, id DESC LIMIT $2 OFFSET $3"
+	rows, err := repo.db.Query(qry, orgID, page.PageSize, page.Offset())
+	if err != nil {
+		return nil, 0, err
+	}
+
+	defer rows.Close()
+
+	var campaigns []*model.Campaign
+
+	for rows.Next() {
+		campaign, err := scanCampaign(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		campaigns = append(campaigns, campaign)
+	}
+
+	return campaigns, total, rows.Err()
+}
+
+func (repo *campaignRepository) FindByID(orgID uint64, id uint64) (*model.Campaign, error) {
+	qry := "SELECT * FROM " + campaign_table_name + " WHERE id = $1 AND organisation_id = $2 LIMIT 1"
+
+	return scanCampaign(repo.db.QueryRow(qry, id, orgID))
+}
+
+// Sets the send time of a draft or scheduled campaign. Returns sql.ErrNoRows when the campaign
+// has started already.
+func (repo *campaignRepository) Schedule(orgID uint64, id uint64, at time.Time, timezone string) (*model.Campaign, error) {
+	qry := "UPDATE " + campaign_table_name + " SET status = $3, scheduled_at = $4, timezone = $5 " +
+		"WHERE id = $1 AND organisation_id = $2 AND status IN ($6, $3) RETURNING *"
+
+	return scanCampaign(repo.db.QueryRow(qry, id, orgID, model.CampaignStatusScheduled, at, timezone, model.CampaignStatusDraft))
+}
+
+// Stops a campaign which has not completed. Recipients already sent to keep their messages.
+// Returns sql.ErrNoRows when the campaign has completed or was cancelled before.
+func (repo *campaignRepository) Cancel(orgID uint64, id uint64) (*model.Campaign, error) {
+	qry := "UPDATE " + campaign_table_name + " SET status = $3, completed_at = NOW(), locked_until = NULL " +
+		"WHERE id = $1 AND organisation_id = $2 AND status IN ($4, $5, $6) RETURNING *"
+
+	return scanCampaign(repo.db.QueryRow(qry, id, orgID, model.CampaignStatusCancelled,
+		model.CampaignStatusDraft, model.CampaignStatusScheduled, model.CampaignStatusRunning))
+}
+
+// Takes the next due campaign for sending, leasing it to the caller. Campaigns whose lease ran
+// out, because the worker sending them stopped, are taken again. Returns sql.ErrNoRows when
+// nothing is due.
+func (repo *campaignRepository) ClaimDue(lease time.Duration) (*model.Campaign, error) {
+	qry := "UPDATE " + campaign_table_name + " SET status = $1, started_at = COALESCE(started_at, NOW()), " +
+		"locked_until = NOW() + $2 * INTERVAL '1 second' WHERE id = (" +
+		"SELECT id FROM " + campaign_table_name + " WHERE status IN ($1, $3) AND scheduled_at <= NOW() " +
+		"AND (locked_until IS NULL OR locked_until < NOW()) ORDER BY scheduled_at LIMIT 1 FOR UPDATE SKIP LOCKED" +
+		") RETURNING *"
+
+	return scanCampaign(repo.db.QueryRow(qry, model.CampaignStatusRunning, lease.Seconds(), model.CampaignStatusScheduled))
+}
+
+// Keeps the lease on a running campaign. Reports false once the campaign is no longer running,
+// as when it was cancelled meanwhile.
+func (repo *campaignRepository) ExtendLease(id uint64, lease time.Duration) (bool, error) {
+	qry := "UPDATE " + campaign_table_name + " SET locked_until = NOW() + $2 * INTERVAL '1 second' WHERE id = $1 AND status = $3"
+	res, err := repo.db.Exec(qry, id, lease.Seconds(), model.CampaignStatusRunning)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	return affected > 0, err
+}
+
+func (repo *campaignRepository) Complete(id uint64) error {
+	qry := "UPDATE " + campaign_table_name + " SET status = $2, completed_at = NOW(), locked_until = NULL WHERE id = $1 AND status = $3"
+	_, err := repo.db.Exec(qry, id, model.CampaignStatusCompleted, model.CampaignStatusRunning)
+	return err
+}
+
+// Stops a running campaign which cannot be sent. Recipients not sent to yet are left pending.
+func (repo *campaignRepository) Fail(id uint64, reason string) error {
+	qry := "UPDATE " + campaign_table_name + " SET status = $2, failure_reason = $3, completed_at = NOW(), locked_until = NULL WHERE id = $1 AND status = $4"
+	_, err := repo.db.Exec(qry, id, model.CampaignStatusFailed, reason, model.CampaignStatusRunning)
+	return err
+}
+
+func scanCampaign(row rowScanner) (*model.Campaign, error) {
+	var campaign model.Campaign
+
+	err := row.Scan(
+		&campaign.ID,
+		&campaign.OrganisationID,
+		&campaign.WhatsAppAccountID,
+		&campaign.SegmentID,
+		&campaign.TemplateID,
+		&campaign.Name,
+		&campaign.Template,
+		&campaign.Status,
+		&campaign.Timezone,
+		&campaign.ScheduledAt,
+		&campaign.StartedAt,
+		&campaign.CompletedAt,
+		&campaign.TotalRecipients,
+		&campaign.LockedUntil,
+		&campaign.CreatedAt,
+		&campaign.UpdatedAt,
+		&campaign.FailureReason,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &campaign, nil
+}
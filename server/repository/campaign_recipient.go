@@ -0,0 +1,217 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/supermario64bit/whatsapp_connect/db"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+)
+
+const campaign_recipient_table_name string = "campaign_recipients"
+
+// Returned when queueing a message for a campaign recipient who is no longer pending
+var ErrRecipientNotPending = errors.New("Campaign recipient was sent to already")
+
+type campaignRecipientRepository struct {
+	db *sql.DB
+}
+
+type CampaignRecipientRepository interface {
+	AddMatching(campaign *model.Campaign, rule *model.SegmentRule) (int, error)
+	Find(campaignID uint64, filter *model.CampaignRecipientFilter) ([]*model.CampaignRecipient, int, error)
+	FindPending(campaignID uint64, limit int) ([]*model.CampaignRecipient, error)
+	MarkFailed(id uint64, errorMessage string) error
+	Stats(campaignID uint64) (*model.CampaignStats, error)
+}
+
+func NewCampaignRecipientRepository() CampaignRecipientRepository {
+	return &campaignRecipientRepository{
+		db: db.New(),
+	}
+}
+
+// Adds every contact matching the segment rule as a recipient and records their count. Only
+// the first call for a campaign adds recipients, so contacts joining the segment while the
+// campaign is being sent are left out.
+func (repo *campaignRecipientRepository) AddMatching(campaign *model.Campaign, rule *model.SegmentRule) (int, error) {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Serialises concurrent calls for the same campaign
+	var total int
+	err = tx.QueryRow("SELECT total_recipients FROM "+campaign_table_name+" WHERE id = $1 FOR UPDATE", campaign.ID).Scan(&total)
+	if err != nil {
+		return 0, err
+	}
+
+	var exists bool
+	err = tx.QueryRow("SELECT EXISTS (SELECT 1 FROM "+campaign_recipient_table_name+" WHERE campaign_id = $1)", campaign.ID).Scan(&exists)
+	if err != nil || exists {
+		return total, err
+	}
+
+	ruleClause, args, err := segmentRuleClause(rule, []interface{}{campaign.ID, campaign.OrganisationID}, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := tx.Exec("INSERT INTO "+campaign_recipient_table_name+" (campaign_id, contact_id) SELECT $1, id FROM "+contact_table_name+
+		" WHERE organisation_id = $2 AND deleted_at IS NULL AND "+ruleClause+" ORDER BY id ON CONFLICT DO NOTHING", args...)
+	if err != nil {
+		return 0, err
+	}
+
+	added, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec("UPDATE "+campaign_table_name+" SET total_recipients = $2 WHERE id = $1", campaign.ID, added)
+	if err != nil {
+		return 0, err
+	}
+
+	return int(added), tx.Commit()
+}
+
+// Returns one page of the campaign's recipients along with the total match count
+func (repo *campaignRecipientRepository) Find(campaignID uint64, filter *model.CampaignRecipientFilter) ([]*model.CampaignRecipient, int, error) {
+	args := []interface{}{campaignID}
+	whereClause := " WHERE campaign_id = $1"
+
+	if strings.TrimSpace(filter.Status) != "" {
+		args = append(args, strings.TrimSpace(filter.Status))
+		whereClause += fmt.Sprintf(" AND status = $%d", len(args))
+	}
+
+	var total int
+	err := repo.db.QueryRow("SELECT COUNT(*) FROM "+campaign_recipient_table_name+whereClause, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	args = append(args, filter.PageSize, filter.Offset())
+	recipients, err := repo.query("SELECT * FROM "+campaign_recipient_table_name+whereClause+
+		fmt.Sprintf(" ORDER BY id LIMIT $%d OFFSET $%d", len(args)-1, len(args)), args...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return recipients, total, nil
+}
+
+// Returns the next recipients still to be sent to, in the order they were added
+func (repo *campaignRecipientRepository) FindPending(campaignID uint64, limit int) ([]*model.CampaignRecipient, error) {
+	qry := "SELECT * FROM " + campaign_recipient_table_name + " WHERE campaign_id = $1 AND status = $2 ORDER BY id LIMIT $3"
+
+	return repo.query(qry, campaignID, model.CampaignRecipientStatusPending, limit)
+}
+
+// Links a pending recipient to the message sent to them, in the transaction queueing the
+// message. Returns ErrRecipientNotPending when the recipient was sent to already, so the message
+// is dropped rather than sent twice.
+func markRecipientSent(tx *sql.Tx, id uint64, messageID uint64) error {
+	qry := "UPDATE " + campaign_recipient_table_name + " SET status = $2, message_id = $3 WHERE id = $1 AND status = $4"
+	res, err := tx.Exec(qry, id, model.CampaignRecipientStatusSent, messageID, model.CampaignRecipientStatusPending)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrRecipientNotPending
+	}
+	return nil
+}
+
+func (repo *campaignRecipientRepository) MarkFailed(id uint64, errorMessage string) error {
+	qry := "UPDATE " + campaign_recipient_table_name + " SET status = $2, error_message = $3 WHERE id = $1 AND status = $4"
+	_, err := repo.db.Exec(qry, id, model.CampaignRecipientStatusFailed, errorMessage, model.CampaignRecipientStatusPending)
+	return err
+}
+
+// Counts the recipients by how far their message got, using the delivery timestamps so counts
+// are cumulative
+func (repo *campaignRecipientRepository) Stats(campaignID uint64) (*model.CampaignStats, error) {
+	qry := "SELECT COUNT(*), " +
+		"COUNT(*) FILTER (WHERE r.status = 'pending'), " +
+		"COUNT(*) FILTER (WHERE m.status IN ('pending', 'queued')), " +
+		"COUNT(*) FILTER (WHERE COALESCE(m.sent_at, m.delivered_at, m.read_at) IS NOT NULL), " +
+		"COUNT(*) FILTER (WHERE COALESCE(m.delivered_at, m.read_at) IS NOT NULL), " +
+		"COUNT(*) FILTER (WHERE m.read_at IS NOT NULL), " +
+		"COUNT(*) FILTER (WHERE r.status = 'failed' OR m.status = 'failed'), " +
+		"COUNT(*) FILTER (WHERE EXISTS (SELECT 1 FROM " + message_table_name + " i WHERE i.conversation_id = m.conversation_id " +
+		"AND i.direction = 'inbound' AND i.created_at > m.created_at)) " +
+		"FROM " + campaign_recipient_table_name + " r LEFT JOIN " + message_table_name + " m ON m.id = r.message_id " +
+		"WHERE r.campaign_id = $1"
+
+	var stats model.CampaignStats
+	err := repo.db.QueryRow(qry, campaignID).Scan(
+		&stats.Recipients,
+		&stats.Pending,
+		&stats.Queued,
+		&stats.Sent,
+		&stats.Delivered,
+		&stats.Read,
+		&stats.Failed,
+		&stats.Replied,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &stats, nil
+}
+
+func (repo *campaignRecipientRepository) query(qry string, args ...interface{}) ([]*model.CampaignRecipient, error) {
+	rows, err := repo.db.Query(qry, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var recipients []*model.CampaignRecipient
+
+	for rows.Next() {
+		recipient, err := scanCampaignRecipient(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		recipients = append(recipients, recipient)
+	}
+
+	return recipients, rows.Err()
+}
+
+func scanCampaignRecipient(row rowScanner) (*model.CampaignRecipient, error) {
+	var recipient model.CampaignRecipient
+
+	err := row.Scan(
+		&recipient.ID,
+		&recipient.CampaignID,
+		&recipient.ContactID,
+		&recipient.Status,
+		&recipient.MessageID,
+		&recipient.ErrorMessage,
+		&recipient.CreatedAt,
+		&recipient.UpdatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &recipient, nil
+}
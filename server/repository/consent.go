@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/supermario64bit/whatsapp_connect/db"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+)
+
+const consent_table_name string = "contact_consents"
+
+// Source of the consent stored last for the contact. Ranked by id, as recorded_at is supplied
+// by the caller.
+const latestConsentSourceQuery = "SELECT source FROM " + consent_table_name + " WHERE contact_id = $1 ORDER BY id DESC LIMIT 1"
+
+// Returned when consent would opt a contact back in over an opt-out they sent by keyword
+var ErrKeywordOptOut = errors.New("Contact opted out by keyword")
+
+type consentRepository struct {
+	db *sql.DB
+}
+
+type ConsentRepository interface {
+	Record(consent *model.Consent) (*model.Consent, error)
+	Find(orgID uint64, contactID uint64, page *model.Pagination) ([]*model.Consent, int, error)
+	FindStatusByMessage(messageID uint64) (string, error)
+	OptedOutByKeyword(orgID uint64, contactID uint64) (bool, error)
+}
+
+func NewConsentRepository() ConsentRepository {
+	return &consentRepository{
+		db: db.New(),
+	}
+}
+
+// Adds the consent to the contact's history and moves their opt-in status along with it. The
+// contact is left untouched when their status already matches. Returns sql.ErrNoRows when the
+// contact does not exist, and ErrKeywordOptOut when an opt-in would override their keyword
+// opt-out.
+func (repo *consentRepository) Record(consent *model.Consent) (*model.Consent, error) {
+	if consent == nil {
+		return nil, fmt.Errorf("Cannot record consent for nil reference")
+	}
+
+	if consent.ID > 0 {
+		return nil, fmt.Errorf("ID field should be empty")
+	}
+
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var current string
+	err = tx.QueryRow("SELECT opt_in_status FROM "+contact_table_name+" WHERE id = $1 AND organisation_id = $2 AND deleted_at IS NULL FOR UPDATE",
+		consent.ContactID, consent.OrganisationID).Scan(&current)
+	if err != nil {
+		return nil, err
+	}
+
+	if current == model.OptInStatusOptedOut && consent.Status == model.OptInStatusOptedIn && !consent.OverridesKeywordOptOut() {
+		var source string
+		err = tx.QueryRow(latestConsentSourceQuery, consent.ContactID).Scan(&source)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+		if source == model.ConsentSourceKeyword {
+			return nil, ErrKeywordOptOut
+		}
+	}
+
+	if current != consent.Status {
+		_, err = tx.Exec("UPDATE "+contact_table_name+" SET opt_in_status = $2 WHERE id = $1", consent.ContactID, consent.Status)
+		if err != nil {
+			return nil, translateError(err)
+		}
+	}
+
+	colNames := []string{"organisation_id", "contact_id", "status", "source", "evidence", "message_id", "actor_id", "recorded_at"}
+	values := [][]interface{}{
+		{consent.OrganisationID, consent.ContactID, consent.Status, consent.Source, consent.Evidence, consent.MessageID, consent.ActorID, consent.RecordedAt},
+	}
+
+	qry, args := generateInsertQuery(consent_table_name, colNames, values)
+
+	created, err := scanConsent(tx.QueryRow(qry, args...))
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return created, tx.Commit()
+}
+
+// Returns one page of the contact's consent history, latest first, along with its length
+func (repo *consentRepository) Find(orgID uint64, contactID uint64, page *model.Pagination) ([]*model.Consent, int, error) {
+	var total int
+	err := repo.db.QueryRow("SELECT COUNT(*) FROM "+consent_table_name+" WHERE organisation_id = $1 AND contact_id = $2", orgID, contactID).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	qry := "SELECT * FROM " + consent_table_name + " WHERE organisation_id = $1 AND contact_id = $2 " +
+		"ORDER BY recorded_at DESC, id DESC LIMIT $3 OFFSET $4"
+
+	rows, err := repo.db.Query(qry, orgID, contactID, page.PageSize, page.Offset())
+	if err != nil {
+		return nil, 0, err
+	}
+
+	defer rows.Close()
+
+	var consents []*model.Consent
+
+	for rows.Next() {
+		consent, err := scanConsent(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		consents = append(consents, consent)
+	}
+
+	return consents, total, rows.Err()
+}
+
+// Returns the current opt-in status of the contact a message is addressed to
+func (repo *consentRepository) FindStatusByMessage(messageID uint64) (string, error) {
+	qry := "SELECT c.opt_in_status FROM " + contact_table_name + " c JOIN " + message_table_name + " m ON m.contact_id = c.id WHERE m.id = $1"
+
+	var status string
+	err := repo.db.QueryRow(qry, messageID).Scan(&status)
+	return status, err
+}
+
+// Reports whether the contact's latest consent is an opt-out they sent by keyword
+func (repo *consentRepository) OptedOutByKeyword(orgID uint64, contactID uint64) (bool, error) {
+	var status string
+	err := repo.db.QueryRow("SELECT opt_in_status FROM "+contact_table_name+" WHERE id = $1 AND organisation_id = $2 AND deleted_at IS NULL",
+		contactID, orgID).Scan(&status)
+	if err != nil || status != model.OptInStatusOptedOut {
+		return false, err
+	}
+
+	var source string
+	err = repo.db.QueryRow(latestConsentSourceQuery, contactID).Scan(&source)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return source == model.ConsentSourceKeyword, err
+}
+
+func scanConsent(row rowScanner) (*model.Consent, error) {
+	var consent model.Consent
+
+	err := row.Scan(
+		&consent.ID,
+		&consent.OrganisationID,
+		&consent.ContactID,
+		&consent.Status,
+		&consent.Source,
+		&consent.Evidence,
+		&consent.MessageID,
+		&consent.ActorID,
+		&consent.RecordedAt,
+		&consent.CreatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &consent, nil
+}
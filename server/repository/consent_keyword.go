@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/supermario64bit/whatsapp_connect/db"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+)
+
+const consent_keyword_table_name string = "consent_keywords"
+
+type consentKeywordRepository struct {
+	db *sql.DB
+}
+
+type ConsentKeywordRepository interface {
+	Create(keyword *model.ConsentKeyword) (*model.ConsentKeyword, error)
+	Find(orgID uint64) ([]*model.ConsentKeyword, error)
+	FindByID(orgID uint64, id uint64) (*model.ConsentKeyword, error)
+	DeleteByID(orgID uint64, id uint64) error
+}
+
+func NewConsentKeywordRepository() ConsentKeywordRepository {
+	return &consentKeywordRepository{
+		db: db.New(),
+	}
+}
+
+func (repo *consentKeywordRepository) Create(keyword *model.ConsentKeyword) (*model.ConsentKeyword, error) {
+	if keyword == nil {
+		return nil, fmt.Errorf("Cannot create consent keyword for nil reference")
+	}
+
+	if keyword.ID > 0 {
+		return nil, fmt.Errorf("ID field should be empty")
+	}
+
+	colNames := []string{"organisation_id", "keyword", "status"}
+	values := [][]interface{}{
+		{keyword.OrganisationID, keyword.Keyword, keyword.Status},
+	}
+
+	qry, args := generateInsertQuery(consent_keyword_table_name, colNames, values)
+
+	created, err := scanConsentKeyword(repo.db.QueryRow(qry, args...))
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return created, nil
+}
+
+func (repo *consentKeywordRepository) Find(orgID uint64) ([]*model.ConsentKeyword, error) {
+	rows, err := repo.db.Query("SELECT * FROM "+consent_keyword_table_name+" WHERE organisation_id = $1 ORDER BY status, keyword", orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var keywords []*model.ConsentKeyword
+
+	for rows.Next() {
+		keyword, err := scanConsentKeyword(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		keywords = append(keywords, keyword)
+	}
+
+	return keywords, rows.Err()
+}
+
+func (repo *consentKeywordRepository) FindByID(orgID uint64, id uint64) (*model.ConsentKeyword, error) {
+	qry := "SELECT * FROM " + consent_keyword_table_name + " WHERE id = $1 AND organisation_id = $2 LIMIT 1"
+
+	return scanConsentKeyword(repo.db.QueryRow(qry, id, orgID))
+}
+
+func (repo *consentKeywordRepository) DeleteByID(orgID uint64, id uint64) error {
+	res, err := repo.db.Exec("DELETE FROM "+consent_keyword_table_name+" WHERE id = $1 AND organisation_id = $2", id, orgID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func scanConsentKeyword(row rowScanner) (*model.ConsentKeyword, error) {
+	var keyword model.ConsentKeyword
+
+	err := row.Scan(
+		&keyword.ID,
+		&keyword.OrganisationID,
+		&keyword.Keyword,
+		&keyword.Status,
+		&keyword.CreatedAt,
+		&keyword.UpdatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &keyword, nil
+}
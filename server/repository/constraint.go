@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+const (
+	ConstraintUnique     = "unique"
+	ConstraintCheck      = "check"
+	ConstraintForeignKey = "foreign_key"
+	ConstraintNotNull    = "not_null"
+)
+
+// Maps postgres error code names onto the kind of constraint that was violated
+var constraintKinds = map[string]string{
+	"unique_violation":      ConstraintUnique,
+	"check_violation":       ConstraintCheck,
+	"foreign_key_violation": ConstraintForeignKey,
+	"not_null_violation":    ConstraintNotNull,
+}
+
+// Maps constraint and index names onto the json field they guard
+var constraintFields = map[string]string{
+	"unique_email_not_deleted":                   "email",
+	"unique_mobile_number_not_deleted":           "mobile_number",
+	"users_email_key":                            "email",
+	"users_mobile_number_key":                    "mobile_number",
+	"users_handle_key":                           "handle",
+	"status_check":                               "status",
+	"unique_phone_number_id_not_deleted":         "phone_number_id",
+	"unique_contact_phone_number_not_deleted":    "phone_number",
+	"opt_in_status_check":                        "opt_in_status",
+	"whatsapp_accounts_organisation_id_fkey":     "organisation_id",
+	"contacts_organisation_id_fkey":              "organisation_id",
+	"unique_tag_name":                            "name",
+	"tags_organisation_id_fkey":                  "organisation_id",
+	"unique_segment_name_not_deleted":            "name",
+	"segments_organisation_id_fkey":              "organisation_id",
+	"unique_template_name_language_not_deleted":  "name",
+	"template_category_check":                    "category",
+	"message_templates_whatsapp_account_id_fkey": "whatsapp_account_id",
+	"unique_conversation_contact":                "contact_id",
+	"conversation_status_check":                  "status",
+	"organisation_members_pkey":                  "user_id",
+	"organisation_members_user_id_fkey":          "user_id",
+	"conversations_assignee_id_fkey":             "user_id",
+	"unique_media_external_id":                   "external_id",
+	"campaigns_organisation_id_fkey":             "organisation_id",
+	"campaigns_segment_id_fkey":                  "segment_id",
+	"campaigns_template_id_fkey":                 "template_id",
+	"unique_consent_keyword":                     "keyword",
+	"auto_reply_rules_whatsapp_account_id_fkey":  "whatsapp_account_id",
+	"auto_reply_match_type_check":                "match_type",
+	"flows_whatsapp_account_id_fkey":             "whatsapp_account_id",
+	"flow_match_type_check":                      "match_type",
+}
+
+// Returned by writes rejected by a database constraint
+type ConstraintError struct {
+	Kind       string
+	Constraint string
+	Table      string
+	Field      string
+	Err        *pq.Error
+}
+
+func (err *ConstraintError) Error() string {
+	if err.Field == "" {
+		return fmt.Sprintf("%s constraint %s violated on %s", err.Kind, err.Constraint, err.Table)
+	}
+	return fmt.Sprintf("%s constraint %s violated on %s.%s", err.Kind, err.Constraint, err.Table, err.Field)
+}
+
+func (err *ConstraintError) Unwrap() error {
+	return err.Err
+}
+
+// Converts constraint violations reported by postgres into a *ConstraintError. Any other error
+// is returned unchanged.
+func translateError(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return err
+	}
+
+	kind, ok := constraintKinds[pqErr.Code.Name()]
+	if !ok {
+		return err
+	}
+
+	field, ok := constraintFields[pqErr.Constraint]
+	if !ok {
+		// Not null violations carry no constraint name but do name the column
+		field = pqErr.Column
+	}
+
+	return &ConstraintError{
+		Kind:       kind,
+		Constraint: pqErr.Constraint,
+		Table:      pqErr.Table,
+		Field:      field,
+		Err:        pqErr,
+	}
+}
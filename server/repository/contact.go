@@ -0,0 +1,353 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/supermario64bit/whatsapp_connect/db"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+)
+
+const contact_table_name string = "contacts"
+
+// Contacts are always read with their tag names, gathered from contact_tags and sorted bytewise
+// the way model.Contact keeps them
+const contact_columns string = "id, organisation_id, phone_number, name, attributes, " +
+	"COALESCE((SELECT array_agg(tags.name ORDER BY tags.name COLLATE \"C\") FROM contact_tags " +
+	"JOIN tags ON tags.id = contact_tags.tag_id WHERE contact_tags.contact_id = contacts.id), '{}') AS tags, " +
+	"opt_in_status, last_seen_at, created_at, updated_at, deleted_at, version"
+
+const contact_select string = "SELECT " + contact_columns + " FROM " + contact_table_name
+
+type contactRepository struct {
+	db *sql.DB
+}
+
+type ContactRepository interface {
+	Create(contact *model.Contact) (*model.Contact, error)
+	Find(orgID uint64, filter *model.ContactFilter) ([]*model.Contact, int, error)
+	Each(orgID uint64, filter *model.ContactFilter, fn func(contact *model.Contact) error) error
+	FindByID(orgID uint64, id uint64) (*model.Contact, error)
+	FindByPhoneNumber(orgID uint64, phoneNumber string) (*model.Contact, error)
+	FindMatching(orgID uint64, rule *model.SegmentRule, limit int) ([]*model.Contact, int, error)
+	PatchByID(id uint64, version uint64, changes map[string]interface{}) (*model.Contact, error)
+	TouchLastSeen(id uint64, seenAt time.Time) error
+	DeleteByID(orgID uint64, id uint64, version uint64) error
+}
+
+func NewContactRepository() ContactRepository {
+	return &contactRepository{
+		db: db.New(),
+	}
+}
+
+func (repo *contactRepository) Create(contact *model.Contact) (*model.Contact, error) {
+	if contact == nil {
+		return nil, fmt.Errorf("Cannot create contact for nil reference")
+	}
+
+	if contact.ID > 0 {
+		return nil, fmt.Errorf("ID field should be empty")
+	}
+
+	if contact.OrganisationID == 0 || contact.PhoneNumber == "" {
+		return nil, fmt.Errorf("Organisation and Phone Number field should not be empty")
+	}
+
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	colNames := []string{"organisation_id", "phone_number", "name", "attributes", "opt_in_status", "last_seen_at"}
+	values := [][]interface{}{
+		{contact.OrganisationID, contact.PhoneNumber, contact.Name, contact.Attributes, contact.OptInStatus, contact.LastSeenAt},
+	}
+
+	qry, args := generateInsertStatement(contact_table_name, colNames, values)
+
+	var id uint64
+	err = tx.QueryRow(qry+" RETURNING id", args...).Scan(&id)
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	err = setContactTags(tx, contact.OrganisationID, id, contact.Tags)
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	created, err := scanContact(tx.QueryRow(contact_select+" WHERE id = $1", id))
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = insertOutboxEvent(tx, model.OutboxAggregateContact, created.ID, &created.OrganisationID, 0, model.OutboxEventContactCreated, nil, created)
+	if err != nil {
+		return nil, err
+	}
+
+	return created, tx.Commit()
+}
+
+// Returns one page of matching contacts along with the total match count
+func (repo *contactRepository) Find(orgID uint64, filter *model.ContactFilter) ([]*model.Contact, int, error) {
+	whereClause, args := contactFilterClause(orgID, filter)
+
+	var total int
+	err := repo.db.QueryRow("SELECT COUNT(*) FROM "+contact_table_name+whereClause, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	args = append(args, filter.PageSize, filter.Offset())
+	qry := contact_select + whereClause +
+		fmt.Sprintf(" ORDER BY id LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	contacts, err := repo.query(qry, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return contacts, total, nil
+}
+
+// Returns up to limit contacts matching the segment rule along with the total match count
+func (repo *contactRepository) FindMatching(orgID uint64, rule *model.SegmentRule, limit int) ([]*model.Contact, int, error) {
+	ruleClause, args, err := segmentRuleClause(rule, []interface{}{orgID}, time.Now())
+	if err != nil {
+		return nil, 0, err
+	}
+
+	whereClause := " WHERE organisation_id = $1 AND deleted_at IS NULL AND " + ruleClause
+
+	var total int
+	err = repo.db.QueryRow("SELECT COUNT(*) FROM "+contact_table_name+whereClause, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	args = append(args, limit)
+	contacts, err := repo.query(contact_select+whereClause+fmt.Sprintf(" ORDER BY id LIMIT $%d", len(args)), args...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return contacts, total, nil
+}
+
+// Streams every matching contact to fn, ignoring pagination. Stops at the first error fn returns.
+func (repo *contactRepository) Each(orgID uint64, filter *model.ContactFilter, fn func(contact *model.Contact) error) error {
+	whereClause, args := contactFilterClause(orgID, filter)
+
+	rows, err := repo.db.Query(contact_select+whereClause+" ORDER BY id", args...)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		contact, err := scanContact(rows)
+		if err != nil {
+			return err
+		}
+
+		err = fn(contact)
+		if err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func (repo *contactRepository) FindByID(orgID uint64, id uint64) (*model.Contact, error) {
+	qry := contact_select + " WHERE id = $1 AND organisation_id = $2 AND deleted_at IS NULL LIMIT 1"
+
+	return scanContact(repo.db.QueryRow(qry, id, orgID))
+}
+
+func (repo *contactRepository) FindByPhoneNumber(orgID uint64, phoneNumber string) (*model.Contact, error) {
+	qry := contact_select + " WHERE organisation_id = $1 AND phone_number = $2 AND deleted_at IS NULL LIMIT 1"
+
+	return scanContact(repo.db.QueryRow(qry, orgID, phoneNumber))
+}
+
+// Writes only the given column values. Callers are expected to have validated them. A tags value
+// replaces the contact's tags, creating tags which do not exist yet.
+func (repo *contactRepository) PatchByID(id uint64, version uint64, changes map[string]interface{}) (*model.Contact, error) {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	columns := map[string]interface{}{}
+	for column, value := range changes {
+		if column != "tags" {
+			columns[column] = value
+		}
+	}
+	if len(columns) == 0 {
+		// Tag changes still move the version on, so the ETag changes along with the tags
+		columns["updated_at"] = time.Now()
+	}
+
+	qry, args := generateUpdateStatement(contact_table_name, columns, id, version)
+
+	var orgID uint64
+	err = tx.QueryRow(qry+" RETURNING organisation_id", args...).Scan(&orgID)
+	if err == sql.ErrNoRows {
+		return nil, ErrVersionMismatch
+	}
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	if tags, ok := changes["tags"]; ok {
+		err = setContactTags(tx, orgID, id, tags.(model.StringList))
+		if err != nil {
+			return nil, translateError(err)
+		}
+	}
+
+	updated, err := scanContact(tx.QueryRow(contact_select+" WHERE id = $1", id))
+	if err != nil {
+		return nil, err
+	}
+
+	return updated, tx.Commit()
+}
+
+// Moves last_seen_at forward. Notifications delivered out of order never move it back.
+func (repo *contactRepository) TouchLastSeen(id uint64, seenAt time.Time) error {
+	qry := "UPDATE " + contact_table_name + " SET last_seen_at = $1 WHERE id = $2 AND (last_seen_at IS NULL OR last_seen_at < $1)"
+	_, err := repo.db.Exec(qry, seenAt, id)
+	return err
+}
+
+func (repo *contactRepository) DeleteByID(orgID uint64, id uint64, version uint64) error {
+	current, err := repo.FindByID(orgID, id)
+	if err != nil {
+		return err
+	}
+
+	if version > 0 && current.Version != version {
+		return ErrVersionMismatch
+	}
+
+	qry := "UPDATE " + contact_table_name + " SET deleted_at = $1 WHERE id = $2 AND version = $3 AND deleted_at IS NULL"
+	res, err := repo.db.Exec(qry, time.Now(), id, current.Version)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrVersionMismatch
+	}
+
+	return nil
+}
+
+func contactFilterClause(orgID uint64, filter *model.ContactFilter) (string, []interface{}) {
+	args := []interface{}{orgID}
+	whereParts := []string{"organisation_id = $1", "deleted_at IS NULL"}
+
+	if strings.TrimSpace(filter.Search) != "" {
+		args = append(args, "%"+strings.TrimSpace(filter.Search)+"%")
+		whereParts = append(whereParts, fmt.Sprintf("(name ILIKE $%d OR phone_number LIKE $%d)", len(args), len(args)))
+	}
+
+	if strings.TrimSpace(filter.Tag) != "" {
+		args = append(args, strings.TrimSpace(filter.Tag))
+		whereParts = append(whereParts, hasTagClause(len(args)))
+	}
+
+	if strings.TrimSpace(filter.OptInStatus) != "" {
+		args = append(args, strings.TrimSpace(filter.OptInStatus))
+		whereParts = append(whereParts, fmt.Sprintf("opt_in_status = $%d", len(args)))
+	}
+
+	return " WHERE " + strings.Join(whereParts, " AND "), args
+}
+
+func (repo *contactRepository) query(qry string, args ...interface{}) ([]*model.Contact, error) {
+	rows, err := repo.db.Query(qry, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var contacts []*model.Contact
+
+	for rows.Next() {
+		contact, err := scanContact(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		contacts = append(contacts, contact)
+	}
+
+	return contacts, rows.Err()
+}
+
+// Makes the given tag names exactly the contact's tags, creating tags the organisation lacks
+func setContactTags(tx *sql.Tx, orgID uint64, contactID uint64, names model.StringList) error {
+	_, err := tx.Exec("INSERT INTO "+tag_table_name+" (organisation_id, name) SELECT $1, unnest($2::text[]) "+
+		"ON CONFLICT (organisation_id, name) DO NOTHING", orgID, names)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM contact_tags WHERE contact_id = $1 AND tag_id NOT IN "+
+		"(SELECT id FROM "+tag_table_name+" WHERE organisation_id = $2 AND name = ANY($3))", contactID, orgID, names)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("INSERT INTO contact_tags (contact_id, tag_id) SELECT $1, id FROM "+tag_table_name+
+		" WHERE organisation_id = $2 AND name = ANY($3) ON CONFLICT DO NOTHING", contactID, orgID, names)
+	return err
+}
+
+// Matches contacts carrying the tag named by the given placeholder
+func hasTagClause(argPos int) string {
+	return fmt.Sprintf("EXISTS (SELECT 1 FROM contact_tags JOIN tags ON tags.id = contact_tags.tag_id "+
+		"WHERE contact_tags.contact_id = contacts.id AND tags.name = $%d)", argPos)
+}
+
+func scanContact(row rowScanner) (*model.Contact, error) {
+	var contact model.Contact
+
+	err := row.Scan(
+		&contact.ID,
+		&contact.OrganisationID,
+		&contact.PhoneNumber,
+		&contact.Name,
+		&contact.Attributes,
+		&contact.Tags,
+		&contact.OptInStatus,
+		&contact.LastSeenAt,
+		&contact.CreatedAt,
+		&contact.UpdatedAt,
+		&contact.DeletedAt,
+		&contact.Version,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &contact, nil
+}
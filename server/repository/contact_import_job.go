@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/supermario64bit/whatsapp_connect/db"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+)
+
+const contact_import_job_table_name string = "contact_import_jobs"
+
+type contactImportJobRepository struct {
+	db *sql.DB
+}
+
+type ContactImportJobRepository interface {
+	Create(job *model.ContactImportJob) (*model.ContactImportJob, error)
+	FindByID(orgID uint64, id uint64) (*model.ContactImportJob, error)
+	UpdateProgress(job *model.ContactImportJob) error
+	FailStale(idleFor time.Duration) (int64, error)
+}
+
+func NewContactImportJobRepository() ContactImportJobRepository {
+	return &contactImportJobRepository{
+		db: db.New(),
+	}
+}
+
+func (repo *contactImportJobRepository) Create(job *model.ContactImportJob) (*model.ContactImportJob, error) {
+	if job == nil {
+		return nil, fmt.Errorf("Cannot create contact import job for nil reference")
+	}
+
+	if job.ID > 0 {
+		return nil, fmt.Errorf("ID field should be empty")
+	}
+
+	colNames := []string{"organisation_id", "status", "dry_run", "file_name", "mapping", "total_rows", "errors", "created_by"}
+	values := [][]interface{}{
+		{job.OrganisationID, job.Status, job.DryRun, job.FileName, job.Mapping, job.TotalRows, job.Errors, job.CreatedBy},
+	}
+
+	qry, args := generateInsertQuery(contact_import_job_table_name, colNames, values)
+
+	created, err := scanContactImportJob(repo.db.QueryRow(qry, args...))
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return created, nil
+}
+
+func (repo *contactImportJobRepository) FindByID(orgID uint64, id uint64) (*model.ContactImportJob, error) {
+	qry := "SELECT * FROM " + contact_import_job_table_name + " WHERE id = $1 AND organisation_id = $2 LIMIT 1"
+
+	return scanContactImportJob(repo.db.QueryRow(qry, id, orgID))
+}
+
+// Persists the status, counters and errors of a running job. Returns sql.ErrNoRows once the job
+// has finished, as when it was failed for going stale.
+func (repo *contactImportJobRepository) UpdateProgress(job *model.ContactImportJob) error {
+	qry := "UPDATE " + contact_import_job_table_name + " SET status = $1, processed_rows = $2, created_count = $3, updated_count = $4, " +
+		"skipped_count = $5, failed_count = $6, errors = $7, started_at = $8, finished_at = $9 WHERE id = $10 AND status IN ($11, $12)"
+
+	res, err := repo.db.Exec(qry, job.Status, job.ProcessedRows, job.CreatedCount, job.UpdatedCount,
+		job.SkippedCount, job.FailedCount, job.Errors, job.StartedAt, job.FinishedAt, job.ID,
+		model.ImportStatusPending, model.ImportStatusRunning)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Fails pending and running jobs whose progress has not been saved for idleFor, as their worker
+// stopped with the process running it. Returns the number of jobs failed.
+func (repo *contactImportJobRepository) FailStale(idleFor time.Duration) (int64, error) {
+	qry := "UPDATE " + contact_import_job_table_name + " SET status = $1, finished_at = NOW() " +
+		"WHERE status IN ($2, $3) AND updated_at < NOW() - $4 * INTERVAL '1 second'"
+
+	res, err := repo.db.Exec(qry, model.ImportStatusFailed, model.ImportStatusPending, model.ImportStatusRunning, idleFor.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func scanContactImportJob(row rowScanner) (*model.ContactImportJob, error) {
+	var job model.ContactImportJob
+
+	err := row.Scan(
+		&job.ID,
+		&job.OrganisationID,
+		&job.Status,
+		&job.DryRun,
+		&job.FileName,
+		&job.Mapping,
+		&job.TotalRows,
+		&job.ProcessedRows,
+		&job.CreatedCount,
+		&job.UpdatedCount,
+		&job.SkippedCount,
+		&job.FailedCount,
+		&job.Errors,
+		&job.CreatedBy,
+		&job.StartedAt,
+		&job.FinishedAt,
+		&job.CreatedAt,
+		&job.UpdatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &job, nil
+}
@@ -0,0 +1,246 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/supermario64bit/whatsapp_connect/db"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+)
+
+const conversation_table_name string = "conversations"
+
+type conversationRepository struct {
+	db *sql.DB
+}
+
+type ConversationRepository interface {
+	Record(orgID uint64, accountID uint64, contactID uint64, at time.Time, inbound bool) (*model.Conversation, error)
+	Find(orgID uint64, filter *model.ConversationFilter) ([]*model.Conversation, int, error)
+	FindByID(orgID uint64, id uint64) (*model.Conversation, error)
+	FindByContact(accountID uint64, contactID uint64) (*model.Conversation, error)
+	UpdatePricing(id uint64, externalID string, category string, pricingModel string, billable *bool, expiresAt *time.Time) (*model.Conversation, error)
+	Assign(orgID uint64, id uint64, assigneeID *uint64) (*model.Conversation, error)
+	SetStatus(orgID uint64, id uint64, status string) (*model.Conversation, error)
+	MarkRead(orgID uint64, id uint64, at time.Time) (*model.Conversation, error)
+}
+
+func NewConversationRepository() ConversationRepository {
+	return &conversationRepository{
+		db: db.New(),
+	}
+}
+
+// Moves the conversation of the contact with the account forward to a message at the given
+// time, starting the conversation on the first message. An inbound message also reopens the
+// customer service window, counts as unread and reopens a resolved conversation. Times only ever
+// move forward, so late webhooks change nothing.
+func (repo *conversationRepository) Record(orgID uint64, accountID uint64, contactID uint64, at time.Time, inbound bool) (*model.Conversation, error) {
+	var lastInboundAt, windowExpiresAt *time.Time
+	unread := 0
+	if inbound {
+		expiresAt := at.Add(model.CustomerServiceWindow)
+		lastInboundAt, windowExpiresAt = &at, &expiresAt
+		unread = 1
+	}
+
+	qry := "INSERT INTO " + conversation_table_name +
+		" (organisation_id, whatsapp_account_id, contact_id, last_message_at, last_inbound_at, window_expires_at, unread_count)" +
+		" VALUES ($1, $2, $3, $4, $5, $6, $7)" +
+		" ON CONFLICT (whatsapp_account_id, contact_id) DO UPDATE SET" +
+		" last_message_at = GREATEST(" + conversation_table_name + ".last_message_at, EXCLUDED.last_message_at)," +
+		" last_inbound_at = GREATEST(" + conversation_table_name + ".last_inbound_at, EXCLUDED.last_inbound_at)," +
+		" window_expires_at = GREATEST(" + conversation_table_name + ".window_expires_at, EXCLUDED.window_expires_at)," +
+		" unread_count = " + conversation_table_name + ".unread_count + EXCLUDED.unread_count," +
+		" status = CASE WHEN EXCLUDED.unread_count > 0 AND " + conversation_table_name + ".status = $8" +
+		" THEN $9 ELSE " + conversation_table_name + ".status END" +
+		" RETURNING *"
+
+	conversation, err := scanConversation(repo.db.QueryRow(qry, orgID, accountID, contactID, at, lastInboundAt, windowExpiresAt, unread,
+		model.ConversationStatusResolved, model.ConversationStatusOpen))
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return conversation, nil
+}
+
+// Returns one page of matching conversations, most recently active first, along with the total
+// match count
+func (repo *conversationRepository) Find(orgID uint64, filter *model.ConversationFilter) ([]*model.Conversation, int, error) {
+	args := []interface{}{orgID}
+	whereParts := []string{"organisation_id = $1"}
+
+	if filter.ContactID > 0 {
+		args = append(args, filter.ContactID)
+		whereParts = append(whereParts, fmt.Sprintf("contact_id = $%d", len(args)))
+	}
+
+	if filter.WhatsAppAccountID > 0 {
+		args = append(args, filter.WhatsAppAccountID)
+		whereParts = append(whereParts, fmt.Sprintf("whatsapp_account_id = $%d", len(args)))
+	}
+
+	if strings.TrimSpace(filter.Status) != "" {
+		args = append(args, strings.TrimSpace(filter.Status))
+		whereParts = append(whereParts, fmt.Sprintf("status = $%d", len(args)))
+	}
+
+	if filter.AssigneeID > 0 {
+		args = append(args, filter.AssigneeID)
+		whereParts = append(whereParts, fmt.Sprintf("assignee_id = $%d", len(args)))
+	}
+
+	if filter.Unassigned {
+		whereParts = append(whereParts, "assignee_id IS NULL")
+	}
+
+	if filter.WindowOpen != nil {
+		if *filter.WindowOpen {
+			whereParts = append(whereParts, "window_expires_at > NOW()")
+		} else {
+			whereParts = append(whereParts, "(window_expires_at IS NULL OR window_expires_at <= NOW())")
+		}
+	}
+
+	whereClause := " WHERE " + strings.Join(whereParts, " AND ")
+
+	var total int
+	err := repo.db.QueryRow("SELECT COUNT(*) FROM "+conversation_table_name+whereClause, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	args = append(args, filter.PageSize, filter.Offset())
+	qry := "SELECT * FROM " + conversation_table_name + whereClause +
+		fmt.Sprintf(" ORDER BY last_message_at DESC, id DESC LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	rows, err := repo.db.Query(qry, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	defer rows.Close()
+
+	var conversations []*model.Conversation
+
+	for rows.Next() {
+		conversation, err := scanConversation(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		conversations = append(conversations, conversation)
+	}
+
+	return conversations, total, rows.Err()
+}
+
+func (repo *conversationRepository) FindByID(orgID uint64, id uint64) (*model.Conversation, error) {
+	qry := "SELECT * FROM " + conversation_table_name + " WHERE id = $1 AND organisation_id = $2 LIMIT 1"
+
+	return scanConversation(repo.db.QueryRow(qry, id, orgID))
+}
+
+func (repo *conversationRepository) FindByContact(accountID uint64, contactID uint64) (*model.Conversation, error) {
+	qry := "SELECT * FROM " + conversation_table_name + " WHERE whatsapp_account_id = $1 AND contact_id = $2 LIMIT 1"
+
+	return scanConversation(repo.db.QueryRow(qry, accountID, contactID))
+}
+
+// Stores the billing conversation, category and pricing Meta reported. Empty and nil values
+// keep what is already stored.
+func (repo *conversationRepository) UpdatePricing(id uint64, externalID string, category string, pricingModel string, billable *bool, expiresAt *time.Time) (*model.Conversation, error) {
+	qry := "UPDATE " + conversation_table_name +
+		" SET external_id = COALESCE(NULLIF($2, ''), external_id), category = COALESCE(NULLIF($3, ''), category)," +
+		" pricing_model = COALESCE(NULLIF($4, ''), pricing_model), billable = COALESCE($5, billable), expires_at = COALESCE($6, expires_at)" +
+		" WHERE id = $1 RETURNING *"
+
+	updated, err := scanConversation(repo.db.QueryRow(qry, id, externalID, category, pricingModel, billable, expiresAt))
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return updated, nil
+}
+
+// Assigns the conversation to the given user, or to nobody when assigneeID is nil
+func (repo *conversationRepository) Assign(orgID uint64, id uint64, assigneeID *uint64) (*model.Conversation, error) {
+	qry := "UPDATE " + conversation_table_name + " SET assignee_id = $3 WHERE id = $1 AND organisation_id = $2 RETURNING *"
+
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	updated, err := scanConversation(tx.QueryRow(qry, id, orgID, assigneeID))
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	_, err = insertOutboxEvent(tx, model.OutboxAggregateConversation, updated.ID, &updated.OrganisationID, 0, model.OutboxEventConversationAssigned, nil, updated)
+	if err != nil {
+		return nil, err
+	}
+
+	return updated, tx.Commit()
+}
+
+func (repo *conversationRepository) SetStatus(orgID uint64, id uint64, status string) (*model.Conversation, error) {
+	qry := "UPDATE " + conversation_table_name + " SET status = $3 WHERE id = $1 AND organisation_id = $2 RETURNING *"
+
+	updated, err := scanConversation(repo.db.QueryRow(qry, id, orgID, status))
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return updated, nil
+}
+
+// Clears the unread count of the conversation
+func (repo *conversationRepository) MarkRead(orgID uint64, id uint64, at time.Time) (*model.Conversation, error) {
+	qry := "UPDATE " + conversation_table_name + " SET unread_count = 0, last_read_at = $3 WHERE id = $1 AND organisation_id = $2 RETURNING *"
+
+	updated, err := scanConversation(repo.db.QueryRow(qry, id, orgID, at))
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return updated, nil
+}
+
+func scanConversation(row rowScanner) (*model.Conversation, error) {
+	var conversation model.Conversation
+
+	err := row.Scan(
+		&conversation.ID,
+		&conversation.OrganisationID,
+		&conversation.WhatsAppAccountID,
+		&conversation.ContactID,
+		&conversation.LastMessageAt,
+		&conversation.LastInboundAt,
+		&conversation.WindowExpiresAt,
+		&conversation.ExternalID,
+		&conversation.Category,
+		&conversation.PricingModel,
+		&conversation.Billable,
+		&conversation.ExpiresAt,
+		&conversation.CreatedAt,
+		&conversation.UpdatedAt,
+		&conversation.Status,
+		&conversation.AssigneeID,
+		&conversation.UnreadCount,
+		&conversation.LastReadAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	conversation.WindowOpen = conversation.IsWindowOpen(time.Now())
+
+	return &conversation, nil
+}
@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/supermario64bit/whatsapp_connect/db"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+)
+
+const conversation_note_table_name string = "conversation_notes"
+
+type conversationNoteRepository struct {
+	db *sql.DB
+}
+
+type ConversationNoteRepository interface {
+	Create(note *model.ConversationNote) (*model.ConversationNote, error)
+	Find(conversationID uint64) ([]*model.ConversationNote, error)
+}
+
+func NewConversationNoteRepository() ConversationNoteRepository {
+	return &conversationNoteRepository{
+		db: db.New(),
+	}
+}
+
+func (repo *conversationNoteRepository) Create(note *model.ConversationNote) (*model.ConversationNote, error) {
+	if note == nil {
+		return nil, fmt.Errorf("Cannot create conversation note for nil reference")
+	}
+
+	if note.ID > 0 {
+		return nil, fmt.Errorf("ID field should be empty")
+	}
+
+	colNames := []string{"organisation_id", "conversation_id", "author_id", "body"}
+	values := [][]interface{}{
+		{note.OrganisationID, note.ConversationID, note.AuthorID, note.Body},
+	}
+
+	qry, args := generateInsertQuery(conversation_note_table_name, colNames, values)
+
+	created, err := scanConversationNote(repo.db.QueryRow(qry, args...))
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return created, nil
+}
+
+// Returns the notes of the conversation, oldest first
+func (repo *conversationNoteRepository) Find(conversationID uint64) ([]*model.ConversationNote, error) {
+	qry := "SELECT * FROM " + conversation_note_table_name + " WHERE conversation_id = $1 ORDER BY created_at, id"
+	rows, err := repo.db.Query(qry, conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var notes []*model.ConversationNote
+
+	for rows.Next() {
+		note, err := scanConversationNote(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		notes = append(notes, note)
+	}
+
+	return notes, rows.Err()
+}
+
+func scanConversationNote(row rowScanner) (*model.ConversationNote, error) {
+	var note model.ConversationNote
+
+	err := row.Scan(
+		&note.ID,
+		&note.OrganisationID,
+		&note.ConversationID,
+		&note.AuthorID,
+		&note.Body,
+		&note.CreatedAt,
+		&note.UpdatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &note, nil
+}
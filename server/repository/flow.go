@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/supermario64bit/whatsapp_connect/db"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+)
+
+const flow_table_name string = "flows"
+
+type flowRepository struct {
+	db *sql.DB
+}
+
+type FlowRepository interface {
+	Create(flow *model.Flow) (*model.Flow, error)
+	Find(orgID uint64) ([]*model.Flow, error)
+	FindByID(orgID uint64, id uint64) (*model.Flow, error)
+	FindEnabled(orgID uint64, accountID uint64) ([]*model.Flow, error)
+	PatchByID(id uint64, version uint64, changes map[string]interface{}) (*model.Flow, error)
+	DeleteByID(orgID uint64, id uint64, version uint64) error
+}
+
+func NewFlowRepository() FlowRepository {
+	return &flowRepository{
+		db: db.New(),
+	}
+}
+
+func (repo *flowRepository) Create(flow *model.Flow) (*model.Flow, error) {
+	if flow == nil {
+		return nil, fmt.Errorf("Cannot create flow for nil reference")
+	}
+
+	if flow.ID > 0 {
+		return nil, fmt.Errorf("ID field should be empty")
+	}
+
+	colNames := []string{"organisation_id", "whatsapp_account_id", "name", "match_type", "pattern", "priority", "enabled", "definition"}
+	values := [][]interface{}{
+		{flow.OrganisationID, flow.WhatsAppAccountID, flow.Name, flow.MatchType, flow.Pattern, flow.Priority, flow.Enabled, flow.Definition},
+	}
+
+	qry, args := generateInsertQuery(flow_table_name, colNames, values)
+
+	created, err := scanFlow(repo.db.QueryRow(qry, args...))
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return created, nil
+}
+
+// Returns the organisation's flows in the order their triggers are tried, highest priority first
+func (repo *flowRepository) Find(orgID uint64) ([]*model.Flow, error) {
+	qry := "SELECT * FROM " + flow_table_name + " WHERE organisation_id = $1 AND deleted_at IS NULL ORDER BY priority DESC, id"
+	return repo.query(qry, orgID)
+}
+
+func (repo *flowRepository) FindByID(orgID uint64, id uint64) (*model.Flow, error) {
+	qry := "SELECT * FROM " + flow_table_name + " WHERE id = $1 AND organisation_id = $2 AND deleted_at IS NULL LIMIT 1"
+
+	return scanFlow(repo.db.QueryRow(qry, id, orgID))
+}
+
+// Returns the enabled flows running for the account, in the order their triggers are tried
+func (repo *flowRepository) FindEnabled(orgID uint64, accountID uint64) ([]*model.Flow, error) {
+	qry := "SELECT * FROM " + flow_table_name + " WHERE organisation_id = $1 AND enabled AND deleted_at IS NULL " +
+		"AND (whatsapp_account_id IS NULL OR whatsapp_account_id = $2) ORDER BY priority DESC, id"
+	return repo.query(qry, orgID, accountID)
+}
+
+// Writes only the given column values. Callers are expected to have validated them.
+func (repo *flowRepository) PatchByID(id uint64, version uint64, changes map[string]interface{}) (*model.Flow, error) {
+	qry, args := generateUpdateQuery(flow_table_name, changes, id, version)
+
+	updated, err := scanFlow(repo.db.QueryRow(qry, args...))
+	if err == sql.ErrNoRows {
+		return nil, ErrVersionMismatch
+	}
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return updated, nil
+}
+
+func (repo *flowRepository) DeleteByID(orgID uint64, id uint64, version uint64) error {
+	current, err := repo.FindByID(orgID, id)
+	if err != nil {
+		return err
+	}
+
+	if version > 0 && current.Version != version {
+		return ErrVersionMismatch
+	}
+
+	qry := "UPDATE " + flow_table_name + " SET deleted_at = $1 WHERE id = $2 AND version = $3 AND deleted_at IS NULL"
+	res, err := repo.db.Exec(qry, time.Now(), id, current.Version)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrVersionMismatch
+	}
+
+	return nil
+}
+
+func (repo *flowRepository) query(qry string, args ...interface{}) ([]*model.Flow, error) {
+	rows, err := repo.db.Query(qry, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var flows []*model.Flow
+
+	for rows.Next() {
+		flow, err := scanFlow(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		flows = append(flows, flow)
+	}
+
+	return flows, rows.Err()
+}
+
+func scanFlow(row rowScanner) (*model.Flow, error) {
+	var flow model.Flow
+
+	err := row.Scan(
+		&flow.ID,
+		&flow.OrganisationID,
+		&flow.WhatsAppAccountID,
+		&flow.Name,
+		&flow.MatchType,
+		&flow.Pattern,
+		&flow.Priority,
+		&flow.Enabled,
+		&flow.Definition,
+		&flow.CreatedAt,
+		&flow.UpdatedAt,
+		&flow.DeletedAt,
+		&flow.Version,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &flow, nil
+}
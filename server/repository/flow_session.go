@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/supermario64bit/whatsapp_connect/db"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+)
+
+const flow_session_table_name string = "flow_sessions"
+
+type flowSessionRepository struct {
+	db *sql.DB
+}
+
+type FlowSessionRepository interface {
+	Start(session *model.FlowSession) (*model.FlowSession, error)
+	Find(orgID uint64, flowID uint64, filter *model.FlowSessionFilter) ([]*model.FlowSession, int, error)
+	FindActive(conversationID uint64) (*model.FlowSession, error)
+	Save(session *model.FlowSession) (*model.FlowSession, error)
+}
+
+func NewFlowSessionRepository() FlowSessionRepository {
+	return &flowSessionRepository{
+		db: db.New(),
+	}
+}
+
+// Stores a new active session. Returns sql.ErrNoRows when the conversation is in a flow already.
+func (repo *flowSessionRepository) Start(session *model.FlowSession) (*model.FlowSession, error) {
+	if session == nil {
+		return nil, fmt.Errorf("Cannot start flow session for nil reference")
+	}
+
+	if session.ID > 0 {
+		return nil, fmt.Errorf("ID field should be empty")
+	}
+
+	qry := "INSERT INTO " + flow_session_table_name + " (organisation_id, flow_id, whatsapp_account_id, conversation_id, contact_id, node_id, variables) " +
+		"VALUES ($1, $2, $3, $4, $5, $6, $7) ON CONFLICT (conversation_id) WHERE status = 'active' DO NOTHING RETURNING *"
+
+	created, err := scanFlowSession(repo.db.QueryRow(qry, session.OrganisationID, session.FlowID, session.WhatsAppAccountID,
+		session.ConversationID, session.ContactID, session.NodeID, session.Variables))
+	if err != nil && err != sql.ErrNoRows {
+		return nil, translateError(err)
+	}
+
+	return created, err
+}
+
+// Returns one page of the flow's sessions, newest first, along with the total match count
+func (repo *flowSessionRepository) Find(orgID uint64, flowID uint64, filter *model.FlowSessionFilter) ([]*model.FlowSession, int, error) {
+	args := []interface{}{orgID, flowID}
+	whereParts := []string{"organisation_id = $1", "flow_id = $2"}
+
+	if strings.TrimSpace(filter.Status) != "" {
+		args = append(args, strings.TrimSpace(filter.Status))
+		whereParts = append(whereParts, fmt.Sprintf("status = $%d", len(args)))
+	}
+
+	whereClause := " WHERE " + strings.Join(whereParts, " AND ")
+
+	var total int
+	err := repo.db.QueryRow("SELECT COUNT(*) FROM "+flow_session_table_name+whereClause, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	args = append(args, filter.PageSize, filter.Offset())
+	qry := "SELECT * FROM " + flow_session_table_name + whereClause +
+		fmt.Sprintf(" ORDER BY created_at DESC, id DESC LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	rows, err := repo.db.Query(qry, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	defer rows.Close()
+
+	var sessions []*model.FlowSession
+
+	for rows.Next() {
+		session, err := scanFlowSession(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	return sessions, total, rows.Err()
+}
+
+func (repo *flowSessionRepository) FindActive(conversationID uint64) (*model.FlowSession, error) {
+	qry := "SELECT * FROM " + flow_session_table_name + " WHERE conversation_id = $1 AND status = $2 LIMIT 1"
+
+	return scanFlowSession(repo.db.QueryRow(qry, conversationID, model.FlowSessionStatusActive))
+}
+
+// Writes where the session got to. Returns sql.ErrNoRows when the session ended meanwhile.
+func (repo *flowSessionRepository) Save(session *model.FlowSession) (*model.FlowSession, error) {
+	qry := "UPDATE " + flow_session_table_name + " SET node_id = $2, status = $3, variables = $4, retries = $5, last_error = $6, ended_at = $7 " +
+		"WHERE id = $1 AND status = $8 RETURNING *"
+
+	return scanFlowSession(repo.db.QueryRow(qry, session.ID, session.NodeID, session.Status, session.Variables,
+		session.Retries, session.LastError, session.EndedAt, model.FlowSessionStatusActive))
+}
+
+func scanFlowSession(row rowScanner) (*model.FlowSession, error) {
+	var session model.FlowSession
+
+	err := row.Scan(
+		&session.ID,
+		&session.OrganisationID,
+		&session.FlowID,
+		&session.WhatsAppAccountID,
+		&session.ConversationID,
+		&session.ContactID,
+		&session.NodeID,
+		&session.Status,
+		&session.Variables,
+		&session.Retries,
+		&session.LastError,
+		&session.EndedAt,
+		&session.CreatedAt,
+		&session.UpdatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
@@ -1,11 +1,33 @@
 package repository
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
 )
 
+// Returned when a write is conditioned on a row version that is no longer current
+var ErrVersionMismatch = errors.New("Version mismatch. The record has been modified since it was last read")
+
+// Common interface of *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// Common interface of *sql.DB and *sql.Tx for single row queries
+type rowQuerier interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
 func generateInsertQuery(table_name string, column_names []string, values [][]interface{}) (string, []interface{}) {
+	qry, args := generateInsertStatement(table_name, column_names, values)
+	return qry + " RETURNING *", args
+}
+
+// Same as generateInsertQuery, without a RETURNING clause
+func generateInsertStatement(table_name string, column_names []string, values [][]interface{}) (string, []interface{}) {
 	colNames := "(" + strings.Join(column_names, ", ") + ")"
 
 	valStrings := []string{}
@@ -21,6 +43,37 @@ func generateInsertQuery(table_name string, column_names []string, values [][]in
 		valStrings = append(valStrings, "("+strings.Join(placeholders, ", ")+")")
 	}
 
-	return fmt.Sprintf("INSERT INTO %s %s VALUES %s RETURNING *", table_name, colNames, strings.Join(valStrings, ", ")), args
+	return fmt.Sprintf("INSERT INTO %s %s VALUES %s", table_name, colNames, strings.Join(valStrings, ", ")), args
+}
+
+// Builds an UPDATE for the given column values, conditioned on the row id and, when non zero, its version
+func generateUpdateQuery(table_name string, changes map[string]interface{}, id uint64, version uint64) (string, []interface{}) {
+	qry, args := generateUpdateStatement(table_name, changes, id, version)
+	return qry + " RETURNING *", args
+}
+
+// Same as generateUpdateQuery, without a RETURNING clause
+func generateUpdateStatement(table_name string, changes map[string]interface{}, id uint64, version uint64) (string, []interface{}) {
+	columns := make([]string, 0, len(changes))
+	for column := range changes {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	setParts := []string{}
+	args := []interface{}{}
+	for _, column := range columns {
+		args = append(args, changes[column])
+		setParts = append(setParts, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+
+	args = append(args, id)
+	qry := fmt.Sprintf("UPDATE %s SET %s WHERE id = $%d AND deleted_at IS NULL", table_name, strings.Join(setParts, ", "), len(args))
+
+	if version > 0 {
+		args = append(args, version)
+		qry += fmt.Sprintf(" AND version = $%d", len(args))
+	}
 
+	return qry, args
 }
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/supermario64bit/whatsapp_connect/db"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+)
+
+const inbound_media_job_table_name string = "inbound_media_jobs"
+
+type inboundMediaJobRepository struct {
+	db *sql.DB
+}
+
+type InboundMediaJobRepository interface {
+	Claim(lease time.Duration) (*model.InboundMediaJob, error)
+	Succeed(job *model.InboundMediaJob, at time.Time) error
+	Retry(job *model.InboundMediaJob, runAt time.Time, lastError string) error
+	Bury(job *model.InboundMediaJob, at time.Time, lastError string) error
+}
+
+func NewInboundMediaJobRepository() InboundMediaJobRepository {
+	return &inboundMediaJobRepository{
+		db: db.New(),
+	}
+}
+
+// Takes the next due job, leasing it to the caller and counting the attempt. Jobs whose lease
+// ran out, because the worker downloading them stopped, are taken again. Returns sql.ErrNoRows
+// when nothing is due.
+func (repo *inboundMediaJobRepository) Claim(lease time.Duration) (*model.InboundMediaJob, error) {
+	qry := "UPDATE " + inbound_media_job_table_name + " SET status = $1, attempts = attempts + 1, " +
+		"locked_until = NOW() + $2 * INTERVAL '1 second' WHERE id = (" +
+		"SELECT id FROM " + inbound_media_job_table_name + " WHERE run_at <= NOW() AND " +
+		"(status = $3 OR (status = $1 AND locked_until < NOW())) " +
+		"ORDER BY run_at, id LIMIT 1 FOR UPDATE SKIP LOCKED) RETURNING *"
+
+	return scanInboundMediaJob(repo.db.QueryRow(qry, model.InboundMediaJobStatusRunning, lease.Seconds(), model.InboundMediaJobStatusPending))
+}
+
+func (repo *inboundMediaJobRepository) Succeed(job *model.InboundMediaJob, at time.Time) error {
+	return repo.finish(job, model.InboundMediaJobStatusSucceeded, at, &at, "")
+}
+
+// Puts the job back to be tried again at runAt
+func (repo *inboundMediaJobRepository) Retry(job *model.InboundMediaJob, runAt time.Time, lastError string) error {
+	return repo.finish(job, model.InboundMediaJobStatusPending, runAt, nil, lastError)
+}
+
+// Gives up on the job, leaving the message without its media
+func (repo *inboundMediaJobRepository) Bury(job *model.InboundMediaJob, at time.Time, lastError string) error {
+	return repo.finish(job, model.InboundMediaJobStatusDead, at, &at, lastError)
+}
+
+func (repo *inboundMediaJobRepository) finish(job *model.InboundMediaJob, status string, runAt time.Time, completedAt *time.Time, lastError string) error {
+	qry := "UPDATE " + inbound_media_job_table_name + " SET status = $2, run_at = $3, completed_at = $4, locked_until = NULL, last_error = $5 WHERE id = $1"
+	_, err := repo.db.Exec(qry, job.ID, status, runAt, completedAt, lastError)
+	return err
+}
+
+// Queues the media of the inbound message to be downloaded, as part of the transaction storing it
+func insertInboundMediaJob(tx *sql.Tx, message *model.Message, media json.RawMessage) error {
+	colNames := []string{"organisation_id", "whatsapp_account_id", "message_id", "payload"}
+	values := [][]interface{}{
+		{message.OrganisationID, message.WhatsAppAccountID, message.ID, string(media)},
+	}
+
+	qry, args := generateInsertStatement(inbound_media_job_table_name, colNames, values)
+	_, err := tx.Exec(qry, args...)
+	return err
+}
+
+func scanInboundMediaJob(row rowScanner) (*model.InboundMediaJob, error) {
+	var job model.InboundMediaJob
+
+	err := row.Scan(
+		&job.ID,
+		&job.OrganisationID,
+		&job.WhatsAppAccountID,
+		&job.MessageID,
+		&job.Payload,
+		&job.Status,
+		&job.Attempts,
+		&job.MaxAttempts,
+		&job.RunAt,
+		&job.LockedUntil,
+		&job.LastError,
+		&job.CompletedAt,
+		&job.CreatedAt,
+		&job.UpdatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &job, nil
+}
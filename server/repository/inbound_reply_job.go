@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/supermario64bit/whatsapp_connect/db"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+)
+
+const inbound_reply_job_table_name string = "inbound_reply_jobs"
+
+type inboundReplyJobRepository struct {
+	db *sql.DB
+}
+
+type InboundReplyJobRepository interface {
+	Claim(lease time.Duration) (*model.InboundReplyJob, error)
+	Succeed(job *model.InboundReplyJob, at time.Time) error
+	Retry(job *model.InboundReplyJob, runAt time.Time, lastError string) error
+	Bury(job *model.InboundReplyJob, at time.Time, lastError string) error
+}
+
+func NewInboundReplyJobRepository() InboundReplyJobRepository {
+	return &inboundReplyJobRepository{
+		db: db.New(),
+	}
+}
+
+// Takes the next due job, leasing it to the caller and counting the attempt. A job waits while
+// an earlier message of its conversation is still to be answered, so flows and auto replies see
+// a conversation's messages one at a time and in order. Returns sql.ErrNoRows when nothing is
+// due.
+func (repo *inboundReplyJobRepository) Claim(lease time.Duration) (*model.InboundReplyJob, error) {
+	qry := "UPDATE " + inbound_reply_job_table_name + " SET status = $1, attempts = attempts + 1, " +
+		"locked_until = NOW() + $2 * INTERVAL '1 second' WHERE id = (" +
+		"SELECT j.id FROM " + inbound_reply_job_table_name + " j WHERE j.run_at <= NOW() AND " +
+		"(j.status = $3 OR (j.status = $1 AND j.locked_until < NOW())) AND NOT EXISTS (" +
+		"SELECT 1 FROM " + inbound_reply_job_table_name + " e WHERE e.conversation_id = j.conversation_id " +
+		"AND e.id < j.id AND e.status IN ($1, $3)) " +
+		"ORDER BY j.run_at, j.id LIMIT 1 FOR UPDATE SKIP LOCKED) RETURNING *"
+
+	return scanInboundReplyJob(repo.db.QueryRow(qry, model.InboundReplyJobStatusRunning, lease.Seconds(), model.InboundReplyJobStatusPending))
+}
+
+func (repo *inboundReplyJobRepository) Succeed(job *model.InboundReplyJob, at time.Time) error {
+	return repo.finish(job, model.InboundReplyJobStatusSucceeded, at, &at, "")
+}
+
+// Puts the job back to be tried again at runAt
+func (repo *inboundReplyJobRepository) Retry(job *model.InboundReplyJob, runAt time.Time, lastError string) error {
+	return repo.finish(job, model.InboundReplyJobStatusPending, runAt, nil, lastError)
+}
+
+// Gives up on the job, letting later messages of the conversation be answered
+func (repo *inboundReplyJobRepository) Bury(job *model.InboundReplyJob, at time.Time, lastError string) error {
+	return repo.finish(job, model.InboundReplyJobStatusDead, at, &at, lastError)
+}
+
+func (repo *inboundReplyJobRepository) finish(job *model.InboundReplyJob, status string, runAt time.Time, completedAt *time.Time, lastError string) error {
+	qry := "UPDATE " + inbound_reply_job_table_name + " SET status = $2, run_at = $3, completed_at = $4, locked_until = NULL, last_error = $5 WHERE id = $1"
+	_, err := repo.db.Exec(qry, job.ID, status, runAt, completedAt, lastError)
+	return err
+}
+
+// Queues the inbound message to be answered, as part of the transaction storing it
+func insertInboundReplyJob(tx *sql.Tx, message *model.Message) error {
+	colNames := []string{"organisation_id", "message_id", "conversation_id"}
+	values := [][]interface{}{
+		{message.OrganisationID, message.ID, message.ConversationID},
+	}
+
+	qry, args := generateInsertStatement(inbound_reply_job_table_name, colNames, values)
+	_, err := tx.Exec(qry, args...)
+	return err
+}
+
+func scanInboundReplyJob(row rowScanner) (*model.InboundReplyJob, error) {
+	var job model.InboundReplyJob
+
+	err := row.Scan(
+		&job.ID,
+		&job.OrganisationID,
+		&job.MessageID,
+		&job.ConversationID,
+		&job.Status,
+		&job.Attempts,
+		&job.MaxAttempts,
+		&job.RunAt,
+		&job.LockedUntil,
+		&job.LastError,
+		&job.CompletedAt,
+		&job.CreatedAt,
+		&job.UpdatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &job, nil
+}
@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/supermario64bit/whatsapp_connect/db"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+)
+
+const inbox_event_table_name string = "inbox_events"
+
+// Notification channel carrying the id of every new inbox event
+const InboxEventChannel string = "inbox_events"
+
+type inboxEventRepository struct {
+	db *sql.DB
+}
+
+type InboxEventRepository interface {
+	Create(event *model.InboxEvent) (*model.InboxEvent, error)
+	FindByID(id uint64) (*model.InboxEvent, error)
+	FindAfter(orgID uint64, afterID uint64, limit int) ([]*model.InboxEvent, error)
+	DeleteBefore(before time.Time) (int64, error)
+}
+
+func NewInboxEventRepository() InboxEventRepository {
+	return &inboxEventRepository{
+		db: db.New(),
+	}
+}
+
+// Stores the event and notifies every listener on the inbox event channel. The notification is
+// only delivered once the insert commits.
+func (repo *inboxEventRepository) Create(event *model.InboxEvent) (*model.InboxEvent, error) {
+	if event == nil {
+		return nil, fmt.Errorf("Cannot create inbox event for nil reference")
+	}
+
+	if event.ID > 0 {
+		return nil, fmt.Errorf("ID field should be empty")
+	}
+
+	colNames := []string{"organisation_id", "type", "conversation_id", "payload"}
+	values := [][]interface{}{
+		{event.OrganisationID, event.Type, event.ConversationID, []byte(event.Payload)},
+	}
+
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	qry, args := generateInsertQuery(inbox_event_table_name, colNames, values)
+
+	created, err := scanInboxEvent(tx.QueryRow(qry, args...))
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	_, err = tx.Exec("SELECT pg_notify($1, $2)", InboxEventChannel, strconv.FormatUint(created.ID, 10))
+	if err != nil {
+		return nil, err
+	}
+
+	return created, tx.Commit()
+}
+
+func (repo *inboxEventRepository) FindByID(id uint64) (*model.InboxEvent, error) {
+	qry := "SELECT * FROM " + inbox_event_table_name + " WHERE id = $1 LIMIT 1"
+
+	return scanInboxEvent(repo.db.QueryRow(qry, id))
+}
+
+// Returns up to limit events of the organisation following the given event, oldest first
+func (repo *inboxEventRepository) FindAfter(orgID uint64, afterID uint64, limit int) ([]*model.InboxEvent, error) {
+	qry := "SELECT * FROM " + inbox_event_table_name + " WHERE organisation_id = $1 AND id > $2 ORDER BY id LIMIT $3"
+	rows, err := repo.db.Query(qry, orgID, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var events []*model.InboxEvent
+
+	for rows.Next() {
+		event, err := scanInboxEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+// Deletes the events created before the given time. Returns the number deleted.
+func (repo *inboxEventRepository) DeleteBefore(before time.Time) (int64, error) {
+	res, err := repo.db.Exec("DELETE FROM "+inbox_event_table_name+" WHERE created_at < $1", before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func scanInboxEvent(row rowScanner) (*model.InboxEvent, error) {
+	var event model.InboxEvent
+
+	err := row.Scan(
+		&event.ID,
+		&event.OrganisationID,
+		&event.Type,
+		&event.ConversationID,
+		&event.Payload,
+		&event.CreatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &event, nil
+}
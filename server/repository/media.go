@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/supermario64bit/whatsapp_connect/db"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+)
+
+const media_table_name string = "media"
+
+type mediaRepository struct {
+	db *sql.DB
+}
+
+type MediaRepository interface {
+	Create(media *model.Media) (*model.Media, error)
+	FindByID(orgID uint64, id uint64) (*model.Media, error)
+	FindByExternalID(accountID uint64, externalID string) (*model.Media, error)
+	FindByStorageKey(key string) (*model.Media, error)
+}
+
+func NewMediaRepository() MediaRepository {
+	return &mediaRepository{
+		db: db.New(),
+	}
+}
+
+func (repo *mediaRepository) Create(media *model.Media) (*model.Media, error) {
+	if media == nil {
+		return nil, fmt.Errorf("Cannot create media for nil reference")
+	}
+
+	if media.ID > 0 {
+		return nil, fmt.Errorf("ID field should be empty")
+	}
+
+	colNames := []string{"organisation_id", "whatsapp_account_id", "external_id", "direction", "type", "filename", "mime_type", "size", "sha256", "storage_key"}
+	values := [][]interface{}{
+		{media.OrganisationID, media.WhatsAppAccountID, media.ExternalID, media.Direction, media.Type, media.Filename, media.MimeType, media.Size, media.SHA256, media.StorageKey},
+	}
+
+	qry, args := generateInsertQuery(media_table_name, colNames, values)
+
+	created, err := scanMedia(repo.db.QueryRow(qry, args...))
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return created, nil
+}
+
+func (repo *mediaRepository) FindByID(orgID uint64, id uint64) (*model.Media, error) {
+	qry := "SELECT * FROM " + media_table_name + " WHERE id = $1 AND organisation_id = $2 LIMIT 1"
+
+	return scanMedia(repo.db.QueryRow(qry, id, orgID))
+}
+
+func (repo *mediaRepository) FindByExternalID(accountID uint64, externalID string) (*model.Media, error) {
+	qry := "SELECT * FROM " + media_table_name + " WHERE whatsapp_account_id = $1 AND external_id = $2 LIMIT 1"
+
+	return scanMedia(repo.db.QueryRow(qry, accountID, externalID))
+}
+
+func (repo *mediaRepository) FindByStorageKey(key string) (*model.Media, error) {
+	qry := "SELECT * FROM " + media_table_name + " WHERE storage_key = $1 LIMIT 1"
+
+	return scanMedia(repo.db.QueryRow(qry, key))
+}
+
+func scanMedia(row rowScanner) (*model.Media, error) {
+	var media model.Media
+
+	err := row.Scan(
+		&media.ID,
+		&media.OrganisationID,
+		&media.WhatsAppAccountID,
+		&media.ExternalID,
+		&media.Direction,
+		&media.Type,
+		&media.Filename,
+		&media.MimeType,
+		&media.Size,
+		&media.SHA256,
+		&media.StorageKey,
+		&media.CreatedAt,
+		&media.UpdatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &media, nil
+}
@@ -0,0 +1,267 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/supermario64bit/whatsapp_connect/db"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+)
+
+const message_table_name string = "messages"
+
+// Column recording when a message reached each state
+var messageStatusColumns = map[string]string{
+	model.MessageStatusQueued:    "queued_at",
+	model.MessageStatusSent:      "sent_at",
+	model.MessageStatusDelivered: "delivered_at",
+	model.MessageStatusRead:      "read_at",
+	model.MessageStatusFailed:    "failed_at",
+}
+
+type messageRepository struct {
+	db *sql.DB
+}
+
+type MessageRepository interface {
+	Create(message *model.Message, media json.RawMessage) (*model.Message, error)
+	Find(orgID uint64, filter *model.MessageFilter) ([]*model.Message, int, error)
+	FindByID(orgID uint64, id uint64) (*model.Message, error)
+	FindByWAMID(wamid string) (*model.Message, error)
+	SetMedia(id uint64, mediaID uint64) (*model.Message, error)
+	UpdateStatus(wamid string, status string, at time.Time, errorCode string, errorMessage string) (*model.Message, error)
+}
+
+func NewMessageRepository() MessageRepository {
+	return &messageRepository{
+		db: db.New(),
+	}
+}
+
+// Stores the message. Inbound messages are stored together with their message.received outbox
+// event, the job answering them and, when they carry media, the job downloading it.
+func (repo *messageRepository) Create(message *model.Message, media json.RawMessage) (*model.Message, error) {
+	if message == nil {
+		return nil, fmt.Errorf("Cannot create message for nil reference")
+	}
+
+	if message.ID > 0 {
+		return nil, fmt.Errorf("ID field should be empty")
+	}
+
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	created, err := insertMessage(tx, message)
+	if err != nil {
+		return nil, err
+	}
+
+	if created.Direction == model.MessageDirectionInbound {
+		_, err = insertOutboxEvent(tx, model.OutboxAggregateMessage, created.ID, &created.OrganisationID, 0, model.OutboxEventMessageReceived, nil, created)
+		if err != nil {
+			return nil, err
+		}
+
+		if created.ConversationID != nil {
+			err = insertInboundReplyJob(tx, created)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if len(media) > 0 {
+			err = insertInboundMediaJob(tx, created, media)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return created, tx.Commit()
+}
+
+// Returns one page of matching messages, newest first, along with the total match count
+func (repo *messageRepository) Find(orgID uint64, filter *model.MessageFilter) ([]*model.Message, int, error) {
+	args := []interface{}{orgID}
+	whereParts := []string{"organisation_id = $1"}
+
+	if filter.ContactID > 0 {
+		args = append(args, filter.ContactID)
+		whereParts = append(whereParts, fmt.Sprintf("contact_id = $%d", len(args)))
+	}
+
+	if filter.ConversationID > 0 {
+		args = append(args, filter.ConversationID)
+		whereParts = append(whereParts, fmt.Sprintf("conversation_id = $%d", len(args)))
+	}
+
+	if filter.WhatsAppAccountID > 0 {
+		args = append(args, filter.WhatsAppAccountID)
+		whereParts = append(whereParts, fmt.Sprintf("whatsapp_account_id = $%d", len(args)))
+	}
+
+	if strings.TrimSpace(filter.Direction) != "" {
+		args = append(args, strings.TrimSpace(filter.Direction))
+		whereParts = append(whereParts, fmt.Sprintf("direction = $%d", len(args)))
+	}
+
+	if strings.TrimSpace(filter.Status) != "" {
+		args = append(args, strings.TrimSpace(filter.Status))
+		whereParts = append(whereParts, fmt.Sprintf("status = $%d", len(args)))
+	}
+
+	whereClause := " WHERE " + strings.Join(whereParts, " AND ")
+
+	var total int
+	err := repo.db.QueryRow("SELECT COUNT(*) FROM "+message_table_name+whereClause, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	args = append(args, filter.PageSize, filter.Offset())
+	qry := "SELECT * FROM " + message_table_name + whereClause +
+		fmt.Sprintf(" ORDER BY created_at DESC, id DESC LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	rows, err := repo.db.Query(qry, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	defer rows.Close()
+
+	var messages []*model.Message
+
+	for rows.Next() {
+		message, err := scanMessage(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		messages = append(messages, message)
+	}
+
+	return messages, total, rows.Err()
+}
+
+func (repo *messageRepository) FindByID(orgID uint64, id uint64) (*model.Message, error) {
+	qry := "SELECT * FROM " + message_table_name + " WHERE id = $1 AND organisation_id = $2 LIMIT 1"
+
+	return scanMessage(repo.db.QueryRow(qry, id, orgID))
+}
+
+func (repo *messageRepository) FindByWAMID(wamid string) (*model.Message, error) {
+	qry := "SELECT * FROM " + message_table_name + " WHERE wamid = $1 LIMIT 1"
+
+	return scanMessage(repo.db.QueryRow(qry, wamid))
+}
+
+// Links the message to its media once the file is stored
+func (repo *messageRepository) SetMedia(id uint64, mediaID uint64) (*model.Message, error) {
+	qry := "UPDATE " + message_table_name + " SET media_id = $2 WHERE id = $1 RETURNING *"
+
+	updated, err := scanMessage(repo.db.QueryRow(qry, id, mediaID))
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return updated, nil
+}
+
+// Records that the message reached the given state at the given time. The first time reported
+// for a state is kept, and the status itself only ever moves forward.
+func (repo *messageRepository) UpdateStatus(wamid string, status string, at time.Time, errorCode string, errorMessage string) (*model.Message, error) {
+	column, ok := messageStatusColumns[status]
+	if !ok {
+		return nil, fmt.Errorf("Unknown message status %s", status)
+	}
+
+	// The CASE lists the states in rank order, so array_position gives the current rank
+	qry := "UPDATE " + message_table_name + " SET " +
+		column + " = COALESCE(" + column + ", $2), " +
+		"status = CASE WHEN COALESCE(array_position(ARRAY['queued', 'sent', 'delivered', 'read', 'failed']::VARCHAR[], status), 0) < $3 THEN $4 ELSE status END, " +
+		"error_code = CASE WHEN $5 <> '' THEN $5 ELSE error_code END, " +
+		"error_message = CASE WHEN $6 <> '' THEN $6 ELSE error_message END " +
+		"WHERE wamid = $1 AND direction = $7 RETURNING *"
+
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	updated, err := scanMessage(tx.QueryRow(qry, wamid, at, model.MessageStatusRank(status), status, errorCode, errorMessage, model.MessageDirectionOutbound))
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	err = insertMessageStatusEvent(tx, updated)
+	if err != nil {
+		return nil, err
+	}
+
+	return updated, tx.Commit()
+}
+
+// Stores the outbox event reporting the message's new status, in the transaction which set it
+func insertMessageStatusEvent(q rowQuerier, message *model.Message) error {
+	_, err := insertOutboxEvent(q, model.OutboxAggregateMessage, message.ID, &message.OrganisationID, 0, model.OutboxEventMessageStatus, nil, message)
+	return err
+}
+
+func insertMessage(q rowQuerier, message *model.Message) (*model.Message, error) {
+	colNames := []string{"organisation_id", "whatsapp_account_id", "contact_id", "direction", "wamid", "type", "template_id", "body", "payload", "status", "error_code", "error_message", "queued_at", "failed_at", "conversation_id", "media_id"}
+	values := [][]interface{}{
+		{message.OrganisationID, message.WhatsAppAccountID, message.ContactID, message.Direction, message.WAMID, message.Type, message.TemplateID, message.Body, message.Payload, message.Status, message.ErrorCode, message.ErrorMessage, message.QueuedAt, message.FailedAt, message.ConversationID, message.MediaID},
+	}
+
+	qry, args := generateInsertQuery(message_table_name, colNames, values)
+
+	created, err := scanMessage(q.QueryRow(qry, args...))
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return created, nil
+}
+
+func scanMessage(row rowScanner) (*model.Message, error) {
+	var message model.Message
+
+	err := row.Scan(
+		&message.ID,
+		&message.OrganisationID,
+		&message.WhatsAppAccountID,
+		&message.ContactID,
+		&message.Direction,
+		&message.WAMID,
+		&message.Type,
+		&message.TemplateID,
+		&message.Body,
+		&message.Payload,
+		&message.Status,
+		&message.ErrorCode,
+		&message.ErrorMessage,
+		&message.CreatedAt,
+		&message.UpdatedAt,
+		&message.QueuedAt,
+		&message.SentAt,
+		&message.DeliveredAt,
+		&message.ReadAt,
+		&message.FailedAt,
+		&message.ConversationID,
+		&message.MediaID,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &message, nil
+}
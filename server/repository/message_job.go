@@ -0,0 +1,273 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/supermario64bit/whatsapp_connect/db"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+)
+
+const message_job_table_name string = "message_jobs"
+
+type messageJobRepository struct {
+	db *sql.DB
+}
+
+type MessageJobRepository interface {
+	Enqueue(message *model.Message, payload json.RawMessage, maxAttempts int, campaignRecipientID uint64) (*model.Message, *model.MessageJob, error)
+	Find(orgID uint64, filter *model.MessageJobFilter) ([]*model.MessageJob, int, error)
+	FindByID(orgID uint64, id uint64) (*model.MessageJob, error)
+	Claim(lease time.Duration) (*model.MessageJob, error)
+	Succeed(job *model.MessageJob, wamid string, at time.Time) (*model.Message, error)
+	Retry(job *model.MessageJob, runAt time.Time, lastError string) error
+	Postpone(job *model.MessageJob, runAt time.Time) error
+	Bury(job *model.MessageJob, at time.Time, errorCode string, errorMessage string) (*model.Message, error)
+	Requeue(orgID uint64, id uint64) (*model.MessageJob, error)
+}
+
+func NewMessageJobRepository() MessageJobRepository {
+	return &messageJobRepository{
+		db: db.New(),
+	}
+}
+
+// Stores the message together with the job sending it, so no message is left without a job.
+// A message to a campaign recipient is linked to them in the same transaction, so a recipient
+// is never sent to twice.
+func (repo *messageJobRepository) Enqueue(message *model.Message, payload json.RawMessage, maxAttempts int, campaignRecipientID uint64) (*model.Message, *model.MessageJob, error) {
+	if message == nil {
+		return nil, nil, fmt.Errorf("Cannot enqueue message for nil reference")
+	}
+
+	if message.ID > 0 {
+		return nil, nil, fmt.Errorf("ID field should be empty")
+	}
+
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	created, err := insertMessage(tx, message)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	colNames := []string{"organisation_id", "whatsapp_account_id", "message_id", "payload", "max_attempts"}
+	values := [][]interface{}{
+		{created.OrganisationID, created.WhatsAppAccountID, created.ID, string(payload), maxAttempts},
+	}
+
+	qry, args := generateInsertQuery(message_job_table_name, colNames, values)
+
+	job, err := scanMessageJob(tx.QueryRow(qry, args...))
+	if err != nil {
+		return nil, nil, translateError(err)
+	}
+
+	if campaignRecipientID > 0 {
+		err = markRecipientSent(tx, campaignRecipientID, created.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return created, job, tx.Commit()
+}
+
+// Returns one page of matching jobs, newest first, along with the total match count
+func (repo *messageJobRepository) Find(orgID uint64, filter *model.MessageJobFilter) ([]*model.MessageJob, int, error) {
+	args := []interface{}{orgID}
+	whereParts := []string{"organisation_id = $1"}
+
+	if strings.TrimSpace(filter.Status) != "" {
+		args = append(args, strings.TrimSpace(filter.Status))
+		whereParts = append(whereParts, fmt.Sprintf("status = $%d", len(args)))
+	}
+
+	if filter.WhatsAppAccountID > 0 {
+		args = append(args, filter.WhatsAppAccountID)
+		whereParts = append(whereParts, fmt.Sprintf("whatsapp_account_id = $%d", len(args)))
+	}
+
+	whereClause := " WHERE " + strings.Join(whereParts, " AND ")
+
+	var total int
+	err := repo.db.QueryRow("SELECT COUNT(*) FROM "+message_job_table_name+whereClause, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	args = append(args, filter.PageSize, filter.Offset())
+	qry := "SELECT * FROM " + message_job_table_name + whereClause +
+		fmt.Sprintf(" ORDER BY created_at DESC, id DESC LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	rows, err := repo.db.Query(qry, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	defer rows.Close()
+
+	var jobs []*model.MessageJob
+
+	for rows.Next() {
+		job, err := scanMessageJob(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		jobs = append(jobs, job)
+	}
+
+	return jobs, total, rows.Err()
+}
+
+func (repo *messageJobRepository) FindByID(orgID uint64, id uint64) (*model.MessageJob, error) {
+	qry := "SELECT * FROM " + message_job_table_name + " WHERE id = $1 AND organisation_id = $2 LIMIT 1"
+
+	return scanMessageJob(repo.db.QueryRow(qry, id, orgID))
+}
+
+// Takes the next due job, leasing it to the caller and counting the attempt. Jobs whose lease
+// ran out, because the worker sending them stopped, are taken again. Concurrent workers skip
+// each other's jobs. Returns sql.ErrNoRows when nothing is due.
+func (repo *messageJobRepository) Claim(lease time.Duration) (*model.MessageJob, error) {
+	qry := "UPDATE " + message_job_table_name + " SET status = $1, attempts = attempts + 1, " +
+		"locked_until = NOW() + $2 * INTERVAL '1 second' WHERE id = (" +
+		"SELECT id FROM " + message_job_table_name + " WHERE run_at <= NOW() AND " +
+		"(status = $3 OR (status = $1 AND locked_until < NOW())) " +
+		"ORDER BY run_at, id LIMIT 1 FOR UPDATE SKIP LOCKED) RETURNING *"
+
+	return scanMessageJob(repo.db.QueryRow(qry, model.MessageJobStatusRunning, lease.Seconds(), model.MessageJobStatusPending))
+}
+
+// Completes the job and records that Meta accepted the message
+func (repo *messageJobRepository) Succeed(job *model.MessageJob, wamid string, at time.Time) (*model.Message, error) {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("UPDATE "+message_job_table_name+" SET status = $2, completed_at = $3, locked_until = NULL, last_error = '' WHERE id = $1",
+		job.ID, model.MessageJobStatusSucceeded, at)
+	if err != nil {
+		return nil, err
+	}
+
+	// A status callback may have moved the message on already
+	qry := "UPDATE " + message_table_name + " SET wamid = $2, queued_at = COALESCE(queued_at, $3), " +
+		"status = CASE WHEN status = $4 THEN $5 ELSE status END, error_code = '', error_message = '' WHERE id = $1 RETURNING *"
+	message, err := scanMessage(tx.QueryRow(qry, job.MessageID, wamid, at, model.MessageStatusPending, model.MessageStatusQueued))
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	err = insertMessageStatusEvent(tx, message)
+	if err != nil {
+		return nil, err
+	}
+
+	return message, tx.Commit()
+}
+
+// Puts the job back to be tried again at runAt
+func (repo *messageJobRepository) Retry(job *model.MessageJob, runAt time.Time, lastError string) error {
+	qry := "UPDATE " + message_job_table_name + " SET status = $2, run_at = $3, locked_until = NULL, last_error = $4 WHERE id = $1"
+	_, err := repo.db.Exec(qry, job.ID, model.MessageJobStatusPending, runAt, lastError)
+	return err
+}
+
+// Puts the job back to run at runAt without counting the attempt, for jobs held back by a
+// rate limit
+func (repo *messageJobRepository) Postpone(job *model.MessageJob, runAt time.Time) error {
+	qry := "UPDATE " + message_job_table_name + " SET status = $2, run_at = $3, locked_until = NULL, attempts = GREATEST(attempts - 1, 0) WHERE id = $1"
+	_, err := repo.db.Exec(qry, job.ID, model.MessageJobStatusPending, runAt)
+	return err
+}
+
+// Gives up on the job and marks its message failed
+func (repo *messageJobRepository) Bury(job *model.MessageJob, at time.Time, errorCode string, errorMessage string) (*model.Message, error) {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("UPDATE "+message_job_table_name+" SET status = $2, completed_at = $3, locked_until = NULL, last_error = $4 WHERE id = $1",
+		job.ID, model.MessageJobStatusDead, at, errorMessage)
+	if err != nil {
+		return nil, err
+	}
+
+	qry := "UPDATE " + message_table_name + " SET status = $2, failed_at = $3, error_code = $4, error_message = $5 WHERE id = $1 RETURNING *"
+	message, err := scanMessage(tx.QueryRow(qry, job.MessageID, model.MessageStatusFailed, at, errorCode, errorMessage))
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	err = insertMessageStatusEvent(tx, message)
+	if err != nil {
+		return nil, err
+	}
+
+	return message, tx.Commit()
+}
+
+// Gives a dead job a fresh set of attempts and puts its message back to pending. Returns
+// sql.ErrNoRows when the job is not dead.
+func (repo *messageJobRepository) Requeue(orgID uint64, id uint64) (*model.MessageJob, error) {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	qry := "UPDATE " + message_job_table_name + " SET status = $3, attempts = 0, run_at = NOW(), locked_until = NULL, completed_at = NULL " +
+		"WHERE id = $1 AND organisation_id = $2 AND status = $4 RETURNING *"
+	job, err := scanMessageJob(tx.QueryRow(qry, id, orgID, model.MessageJobStatusPending, model.MessageJobStatusDead))
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec("UPDATE "+message_table_name+" SET status = $2, failed_at = NULL, error_code = '', error_message = '' WHERE id = $1",
+		job.MessageID, model.MessageStatusPending)
+	if err != nil {
+		return nil, err
+	}
+
+	return job, tx.Commit()
+}
+
+func scanMessageJob(row rowScanner) (*model.MessageJob, error) {
+	var job model.MessageJob
+
+	err := row.Scan(
+		&job.ID,
+		&job.OrganisationID,
+		&job.WhatsAppAccountID,
+		&job.MessageID,
+		&job.Payload,
+		&job.Status,
+		&job.Attempts,
+		&job.MaxAttempts,
+		&job.RunAt,
+		&job.LockedUntil,
+		&job.LastError,
+		&job.CompletedAt,
+		&job.CreatedAt,
+		&job.UpdatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &job, nil
+}
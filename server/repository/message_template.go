@@ -0,0 +1,168 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/supermario64bit/whatsapp_connect/db"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+)
+
+const message_template_table_name string = "message_templates"
+
+type messageTemplateRepository struct {
+	db *sql.DB
+}
+
+type MessageTemplateRepository interface {
+	Create(tpl *model.MessageTemplate) (*model.MessageTemplate, error)
+	Find(accountID uint64) ([]*model.MessageTemplate, error)
+	FindByID(accountID uint64, id uint64) (*model.MessageTemplate, error)
+	FindByName(accountID uint64, name string, language string) (*model.MessageTemplate, error)
+	FindByExternalID(externalID string) (*model.MessageTemplate, error)
+	PatchByID(id uint64, version uint64, changes map[string]interface{}) (*model.MessageTemplate, error)
+	DeleteByID(accountID uint64, id uint64, version uint64) error
+}
+
+func NewMessageTemplateRepository() MessageTemplateRepository {
+	return &messageTemplateRepository{
+		db: db.New(),
+	}
+}
+
+func (repo *messageTemplateRepository) Create(tpl *model.MessageTemplate) (*model.MessageTemplate, error) {
+	if tpl == nil {
+		return nil, fmt.Errorf("Cannot create message template for nil reference")
+	}
+
+	if tpl.ID > 0 {
+		return nil, fmt.Errorf("ID field should be empty")
+	}
+
+	colNames := []string{"organisation_id", "whatsapp_account_id", "external_id", "name", "language", "category", "components", "status", "rejection_reason"}
+	values := [][]interface{}{
+		{tpl.OrganisationID, tpl.WhatsAppAccountID, tpl.ExternalID, tpl.Name, tpl.Language, tpl.Category, tpl.Components, tpl.Status, tpl.RejectionReason},
+	}
+
+	qry, args := generateInsertQuery(message_template_table_name, colNames, values)
+
+	created, err := scanMessageTemplate(repo.db.QueryRow(qry, args...))
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return created, nil
+}
+
+func (repo *messageTemplateRepository) Find(accountID uint64) ([]*model.MessageTemplate, error) {
+	qry := "SELECT * FROM " + message_template_table_name + " WHERE whatsapp_account_id = $1 AND deleted_at IS NULL ORDER BY name, language"
+	rows, err := repo.db.Query(qry, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var templates []*model.MessageTemplate
+
+	for rows.Next() {
+		tpl, err := scanMessageTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		templates = append(templates, tpl)
+	}
+
+	return templates, rows.Err()
+}
+
+func (repo *messageTemplateRepository) FindByID(accountID uint64, id uint64) (*model.MessageTemplate, error) {
+	qry := "SELECT * FROM " + message_template_table_name + " WHERE id = $1 AND whatsapp_account_id = $2 AND deleted_at IS NULL LIMIT 1"
+
+	return scanMessageTemplate(repo.db.QueryRow(qry, id, accountID))
+}
+
+func (repo *messageTemplateRepository) FindByName(accountID uint64, name string, language string) (*model.MessageTemplate, error) {
+	qry := "SELECT * FROM " + message_template_table_name + " WHERE whatsapp_account_id = $1 AND name = $2 AND language = $3 AND deleted_at IS NULL LIMIT 1"
+
+	return scanMessageTemplate(repo.db.QueryRow(qry, accountID, name, language))
+}
+
+// Finds a template by the id Meta assigned to it
+func (repo *messageTemplateRepository) FindByExternalID(externalID string) (*model.MessageTemplate, error) {
+	qry := "SELECT * FROM " + message_template_table_name + " WHERE external_id = $1 AND deleted_at IS NULL LIMIT 1"
+
+	return scanMessageTemplate(repo.db.QueryRow(qry, externalID))
+}
+
+// Writes only the given column values. Callers are expected to have validated them.
+func (repo *messageTemplateRepository) PatchByID(id uint64, version uint64, changes map[string]interface{}) (*model.MessageTemplate, error) {
+	qry, args := generateUpdateQuery(message_template_table_name, changes, id, version)
+
+	updated, err := scanMessageTemplate(repo.db.QueryRow(qry, args...))
+	if err == sql.ErrNoRows {
+		return nil, ErrVersionMismatch
+	}
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return updated, nil
+}
+
+func (repo *messageTemplateRepository) DeleteByID(accountID uint64, id uint64, version uint64) error {
+	current, err := repo.FindByID(accountID, id)
+	if err != nil {
+		return err
+	}
+
+	if version > 0 && current.Version != version {
+		return ErrVersionMismatch
+	}
+
+	qry := "UPDATE " + message_template_table_name + " SET deleted_at = $1 WHERE id = $2 AND version = $3 AND deleted_at IS NULL"
+	res, err := repo.db.Exec(qry, time.Now(), id, current.Version)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrVersionMismatch
+	}
+
+	return nil
+}
+
+func scanMessageTemplate(row rowScanner) (*model.MessageTemplate, error) {
+	var tpl model.MessageTemplate
+
+	err := row.Scan(
+		&tpl.ID,
+		&tpl.OrganisationID,
+		&tpl.WhatsAppAccountID,
+		&tpl.ExternalID,
+		&tpl.Name,
+		&tpl.Language,
+		&tpl.Category,
+		&tpl.Components,
+		&tpl.Status,
+		&tpl.RejectionReason,
+		&tpl.CreatedAt,
+		&tpl.UpdatedAt,
+		&tpl.DeletedAt,
+		&tpl.Version,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &tpl, nil
+}
@@ -17,11 +17,12 @@ type organisationRepository struct {
 }
 
 type OrganisationRepository interface {
-	Create(org *model.Organisation) (*model.Organisation, error)
-	Find(filter *model.Organisation) ([]*model.Organisation, error)
+	Create(org *model.Organisation, actorID uint64) (*model.Organisation, error)
+	Find(filter *model.Organisation, memberID uint64) ([]*model.Organisation, error)
 	FindByID(id uint64) (*model.Organisation, error)
-	UpdateByID(updates *model.Organisation, id uint64) (*model.Organisation, error)
-	DeleteByID(id uint64) error
+	UpdateByID(updates *model.Organisation, id uint64, version uint64, actorID uint64) (*model.Organisation, error)
+	PatchByID(id uint64, version uint64, changes map[string]interface{}, actorID uint64) (*model.Organisation, error)
+	DeleteByID(id uint64, version uint64, actorID uint64) error
 }
 
 func NewOrganisationRepository() OrganisationRepository {
@@ -30,7 +31,9 @@ func NewOrganisationRepository() OrganisationRepository {
 	}
 }
 
-func (repo *organisationRepository) Create(org *model.Organisation) (*model.Organisation, error) {
+// Stores the organisation together with its organisation.created outbox event, and makes the
+// creator its first member
+func (repo *organisationRepository) Create(org *model.Organisation, actorID uint64) (*model.Organisation, error) {
 	if org == nil {
 		return nil, fmt.Errorf("Cannot create organisation for nil reference")
 	}
@@ -44,29 +47,47 @@ func (repo *organisationRepository) Create(org *model.Organisation) (*model.Orga
 
 	}
 
-	if len(org.ContactNumber) != 10 {
-		return nil, fmt.Errorf("Contact Number should be 10 digit")
-	}
-
 	colNames := []string{"name", "contact_number", "email", "status"}
 	values := [][]interface{}{
 		{org.Name, org.ContactNumber, org.Email, org.Status},
 	}
 
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
 	qry, args := generateInsertQuery(org_table_name, colNames, values)
 
-	var createdOrg model.Organisation
-	err := repo.db.QueryRow(qry, args...).Scan(&createdOrg.ID, &createdOrg.Name, &createdOrg.ContactNumber, &createdOrg.Email, &createdOrg.Status, &createdOrg.CreatedAt, &createdOrg.UpdatedAt, &createdOrg.DeletedAt)
+	created, err := scanOrganisation(tx.QueryRow(qry, args...))
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	if actorID > 0 {
+		qry, args = generateInsertQuery(organisation_member_table_name, []string{"organisation_id", "user_id"}, [][]interface{}{{created.ID, actorID}})
+		_, err = tx.Exec(qry, args...)
+		if err != nil {
+			return nil, translateError(err)
+		}
+	}
+
+	_, err = insertOutboxEvent(tx, model.OutboxAggregateOrganisation, created.ID, &created.ID, actorID, model.OutboxEventOrganisationCreated, nil, created)
 	if err != nil {
 		return nil, err
 	}
 
-	return &createdOrg, nil
+	return created, tx.Commit()
 }
 
-func (repo *organisationRepository) Find(filter *model.Organisation) ([]*model.Organisation, error) {
-	args := []interface{}{}
-	whereParts := []string{}
+// Finds the organisations matching the filter which the user is a member of
+func (repo *organisationRepository) Find(filter *model.Organisation, memberID uint64) ([]*model.Organisation, error) {
+	args := []interface{}{memberID}
+	whereParts := []string{
+		"deleted_at IS NULL",
+		"id IN (SELECT organisation_id FROM " + organisation_member_table_name + " WHERE user_id = $1)",
+	}
 	if filter != nil {
 		if strings.TrimSpace(filter.Name) != "" {
 			args = append(args, "%"+strings.TrimSpace(filter.Name)+"%")
@@ -90,18 +111,7 @@ func (repo *organisationRepository) Find(filter *model.Organisation) ([]*model.O
 		}
 	}
 
-	whereClause := ""
-	if len(whereClause) > 0 {
-		whereClause = "WHERE " + strings.Join(whereParts, " AND ")
-	}
-
-	if whereClause != "" {
-		whereClause = whereClause + " AND deleted_at IS NULL"
-	} else {
-		whereClause = whereClause + " WHERE deleted_at IS NULL"
-	}
-
-	qry := fmt.Sprintf("SELECT * FROM %s ", org_table_name) + whereClause
+	qry := fmt.Sprintf("SELECT * FROM %s WHERE ", org_table_name) + strings.Join(whereParts, " AND ")
 	rows, err := repo.db.Query(qry, args...)
 	if err != nil {
 		return nil, err
@@ -112,24 +122,12 @@ func (repo *organisationRepository) Find(filter *model.Organisation) ([]*model.O
 	var orgs []*model.Organisation
 
 	for rows.Next() {
-		var org model.Organisation
-
-		err := rows.Scan(
-			&org.ID,
-			&org.Name,
-			&org.ContactNumber,
-			&org.Email,
-			&org.Status,
-			&org.CreatedAt,
-			&org.UpdatedAt,
-			&org.DeletedAt,
-		)
-
+		org, err := scanOrganisation(rows)
 		if err != nil {
 			return nil, err
 		}
 
-		orgs = append(orgs, &org)
+		orgs = append(orgs, org)
 	}
 
 	return orgs, nil
@@ -138,33 +136,27 @@ func (repo *organisationRepository) Find(filter *model.Organisation) ([]*model.O
 func (repo *organisationRepository) FindByID(id uint64) (*model.Organisation, error) {
 	qry := "SELECT * FROM " + org_table_name + " WHERE id = $1 AND deleted_at IS NULL LIMIT 1"
 
-	var org model.Organisation
-
-	err := repo.db.QueryRow(qry, id).Scan(
-		&org.ID,
-		&org.Name,
-		&org.ContactNumber,
-		&org.Email,
-		&org.Status,
-		&org.CreatedAt,
-		&org.UpdatedAt,
-		&org.DeletedAt,
-	)
+	return scanOrganisation(repo.db.QueryRow(qry, id))
+}
 
+// Writes the non-empty fields of updates and stores the organisation.updated outbox event in the
+// same transaction
+func (repo *organisationRepository) UpdateByID(updates *model.Organisation, id uint64, version uint64, actorID uint64) (*model.Organisation, error) {
+	tx, err := repo.db.Begin()
 	if err != nil {
 		return nil, err
 	}
+	defer tx.Rollback()
 
-	return &org, nil
-}
-
-func (repo *organisationRepository) UpdateByID(updates *model.Organisation, id uint64) (*model.Organisation, error) {
-	_, err := repo.FindByID(id)
-
+	current, err := lockOrganisation(tx, id)
 	if err != nil {
 		return nil, err
 	}
 
+	if version > 0 && current.Version != version {
+		return nil, ErrVersionMismatch
+	}
+
 	updatesParam := []string{}
 	args := []interface{}{}
 	argPos := 1
@@ -192,13 +184,109 @@ func (repo *organisationRepository) UpdateByID(updates *model.Organisation, id u
 		argPos++
 	}
 
+	if len(updatesParam) == 0 {
+		return current, nil
+	}
+
+	// The row is locked, so the version read above is still current
 	qry := "UPDATE " + org_table_name + " SET " + strings.Join(updatesParam, ", ") +
-		fmt.Sprintf(" WHERE id = $%d AND deleted_at IS NULL RETURNING *", argPos)
+		fmt.Sprintf(" WHERE id = $%d RETURNING *", argPos)
 	args = append(args, id)
 
-	row := repo.db.QueryRow(qry, args...)
+	updated, err := scanOrganisation(tx.QueryRow(qry, args...))
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	_, err = insertOutboxEvent(tx, model.OutboxAggregateOrganisation, id, &id, actorID, model.OutboxEventOrganisationUpdated, current, updated)
+	if err != nil {
+		return nil, err
+	}
+
+	return updated, tx.Commit()
+}
+
+// Writes only the given column values, and stores the organisation.updated outbox event in the
+// same transaction. Callers are expected to have validated the values.
+func (repo *organisationRepository) PatchByID(id uint64, version uint64, changes map[string]interface{}, actorID uint64) (*model.Organisation, error) {
+	if len(changes) == 0 {
+		return repo.FindByID(id)
+	}
+
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	current, err := lockOrganisation(tx, id)
+	if err == sql.ErrNoRows {
+		return nil, ErrVersionMismatch
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	qry, args := generateUpdateQuery(org_table_name, changes, id, version)
+
+	updated, err := scanOrganisation(tx.QueryRow(qry, args...))
+	if err == sql.ErrNoRows {
+		return nil, ErrVersionMismatch
+	}
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	_, err = insertOutboxEvent(tx, model.OutboxAggregateOrganisation, id, &id, actorID, model.OutboxEventOrganisationUpdated, current, updated)
+	if err != nil {
+		return nil, err
+	}
+
+	return updated, tx.Commit()
+}
+
+// Soft deletes the row and stores the organisation.deleted outbox event in the same transaction
+func (repo *organisationRepository) DeleteByID(id uint64, version uint64, actorID uint64) error {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	current, err := lockOrganisation(tx, id)
+	if err != nil {
+		return err
+	}
+
+	if version > 0 && current.Version != version {
+		return ErrVersionMismatch
+	}
+
+	qry := "UPDATE " + org_table_name + " SET deleted_at = $1 WHERE id = $2 RETURNING *"
+	deleted, err := scanOrganisation(tx.QueryRow(qry, time.Now(), id))
+	if err != nil {
+		return err
+	}
+
+	_, err = insertOutboxEvent(tx, model.OutboxAggregateOrganisation, id, &id, actorID, model.OutboxEventOrganisationDeleted, current, deleted)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Reads the row for update, holding writers of the same row off until the transaction ends
+func lockOrganisation(q rowQuerier, id uint64) (*model.Organisation, error) {
+	qry := "SELECT * FROM " + org_table_name + " WHERE id = $1 AND deleted_at IS NULL FOR UPDATE"
+
+	return scanOrganisation(q.QueryRow(qry, id))
+}
+
+func scanOrganisation(row rowScanner) (*model.Organisation, error) {
 	var org model.Organisation
-	err = row.Scan(
+
+	err := row.Scan(
 		&org.ID,
 		&org.Name,
 		&org.ContactNumber,
@@ -207,21 +295,12 @@ func (repo *organisationRepository) UpdateByID(updates *model.Organisation, id u
 		&org.CreatedAt,
 		&org.UpdatedAt,
 		&org.DeletedAt,
+		&org.Version,
 	)
+
 	if err != nil {
 		return nil, err
 	}
 
 	return &org, nil
 }
-
-func (repo *organisationRepository) DeleteByID(id uint64) error {
-	_, err := repo.FindByID(id)
-	if err != nil {
-		return err
-	}
-
-	qry := "UPDATE " + org_table_name + " SET deleted_at = $1 WHERE id = $2 AND deleted_at IS NULL"
-	_, err = repo.db.Exec(qry, time.Now(), id)
-	return err
-}
@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/supermario64bit/whatsapp_connect/db"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+)
+
+const organisation_member_table_name string = "organisation_members"
+
+type organisationMemberRepository struct {
+	db *sql.DB
+}
+
+type OrganisationMemberRepository interface {
+	Create(member *model.OrganisationMember) (*model.OrganisationMember, error)
+	Find(orgID uint64) ([]*model.OrganisationMember, error)
+	FindByUserID(orgID uint64, userID uint64) (*model.OrganisationMember, error)
+	DeleteByUserID(orgID uint64, userID uint64) error
+}
+
+func NewOrganisationMemberRepository() OrganisationMemberRepository {
+	return &organisationMemberRepository{
+		db: db.New(),
+	}
+}
+
+func (repo *organisationMemberRepository) Create(member *model.OrganisationMember) (*model.OrganisationMember, error) {
+	if member == nil {
+		return nil, fmt.Errorf("Cannot create organisation member for nil reference")
+	}
+
+	colNames := []string{"organisation_id", "user_id"}
+	values := [][]interface{}{
+		{member.OrganisationID, member.UserID},
+	}
+
+	qry, args := generateInsertQuery(organisation_member_table_name, colNames, values)
+
+	created, err := scanOrganisationMember(repo.db.QueryRow(qry, args...))
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return created, nil
+}
+
+func (repo *organisationMemberRepository) Find(orgID uint64) ([]*model.OrganisationMember, error) {
+	qry := "SELECT * FROM " + organisation_member_table_name + " WHERE organisation_id = $1 ORDER BY created_at, user_id"
+	rows, err := repo.db.Query(qry, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var members []*model.OrganisationMember
+
+	for rows.Next() {
+		member, err := scanOrganisationMember(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		members = append(members, member)
+	}
+
+	return members, rows.Err()
+}
+
+func (repo *organisationMemberRepository) FindByUserID(orgID uint64, userID uint64) (*model.OrganisationMember, error) {
+	qry := "SELECT * FROM " + organisation_member_table_name + " WHERE organisation_id = $1 AND user_id = $2 LIMIT 1"
+
+	return scanOrganisationMember(repo.db.QueryRow(qry, orgID, userID))
+}
+
+// Removes the member and hands the conversations assigned to them back to the team
+func (repo *organisationMemberRepository) DeleteByUserID(orgID uint64, userID uint64) error {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("DELETE FROM "+organisation_member_table_name+" WHERE organisation_id = $1 AND user_id = $2", orgID, userID)
+	if err != nil {
+		return translateError(err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	_, err = tx.Exec("UPDATE "+conversation_table_name+" SET assignee_id = NULL WHERE organisation_id = $1 AND assignee_id = $2", orgID, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func scanOrganisationMember(row rowScanner) (*model.OrganisationMember, error) {
+	var member model.OrganisationMember
+
+	err := row.Scan(
+		&member.OrganisationID,
+		&member.UserID,
+		&member.CreatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &member, nil
+}
@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/supermario64bit/whatsapp_connect/db"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+)
+
+const outbox_event_table_name string = "outbox_events"
+
+type outboxEventRepository struct {
+	db *sql.DB
+}
+
+type OutboxEventRepository interface {
+	Claim(lease time.Duration) (*model.OutboxEvent, error)
+	MarkDelivered(event *model.OutboxEvent, name string) error
+	Publish(event *model.OutboxEvent, at time.Time) error
+	Retry(event *model.OutboxEvent, runAt time.Time, lastError string) error
+	DeletePublishedBefore(before time.Time) (int64, error)
+	Notify(channel string, payload string) error
+}
+
+func NewOutboxEventRepository() OutboxEventRepository {
+	return &outboxEventRepository{
+		db: db.New(),
+	}
+}
+
+// Stores the event through the transaction making the change it reports, so the event exists
+// exactly when the change does
+func insertOutboxEvent(q rowQuerier, aggregateType string, aggregateID uint64, orgID *uint64, actorID uint64, eventType string, before interface{}, after interface{}) (*model.OutboxEvent, error) {
+	event, err := model.NewOutboxEvent(aggregateType, aggregateID, orgID, actorID, eventType, before, after)
+	if err != nil {
+		return nil, err
+	}
+
+	var previous interface{}
+	if event.Previous != nil {
+		previous = string(event.Previous)
+	}
+
+	colNames := []string{"aggregate_type", "aggregate_id", "organisation_id", "actor_id", "event_type", "payload", "previous"}
+	values := [][]interface{}{
+		{event.AggregateType, event.AggregateID, event.OrganisationID, event.ActorID, event.EventType, string(event.Payload), previous},
+	}
+
+	qry, args := generateInsertQuery(outbox_event_table_name, colNames, values)
+
+	created, err := scanOutboxEvent(q.QueryRow(qry, args...))
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return created, nil
+}
+
+// Takes the next due event, leasing it to the caller and counting the attempt, the same way
+// message jobs are claimed. Returns sql.ErrNoRows when nothing is due.
+func (repo *outboxEventRepository) Claim(lease time.Duration) (*model.OutboxEvent, error) {
+	qry := "UPDATE " + outbox_event_table_name + " SET status = $1, attempts = attempts + 1, " +
+		"locked_until = NOW() + $2 * INTERVAL '1 second' WHERE id = (" +
+		"SELECT id FROM " + outbox_event_table_name + " WHERE run_at <= NOW() AND " +
+		"(status = $3 OR (status = $1 AND locked_until < NOW())) " +
+		"ORDER BY run_at, id LIMIT 1 FOR UPDATE SKIP LOCKED) RETURNING *"
+
+	return scanOutboxEvent(repo.db.QueryRow(qry, model.OutboxEventStatusRunning, lease.Seconds(), model.OutboxEventStatusPending))
+}
+
+// Records that the named subscriber or broker handled the event
+func (repo *outboxEventRepository) MarkDelivered(event *model.OutboxEvent, name string) error {
+	qry := "UPDATE " + outbox_event_table_name + " SET delivered_to = array_append(delivered_to, $2) WHERE id = $1 AND NOT ($2 = ANY(delivered_to))"
+	_, err := repo.db.Exec(qry, event.ID, name)
+	return err
+}
+
+// Completes the event once every subscriber and broker handled it
+func (repo *outboxEventRepository) Publish(event *model.OutboxEvent, at time.Time) error {
+	qry := "UPDATE " + outbox_event_table_name + " SET status = $2, published_at = $3, locked_until = NULL, last_error = '' WHERE id = $1"
+	_, err := repo.db.Exec(qry, event.ID, model.OutboxEventStatusPublished, at)
+	return err
+}
+
+// Puts the event back to be published again at runAt, to the ones which have not handled it
+func (repo *outboxEventRepository) Retry(event *model.OutboxEvent, runAt time.Time, lastError string) error {
+	qry := "UPDATE " + outbox_event_table_name + " SET status = $2, run_at = $3, locked_until = NULL, last_error = $4 WHERE id = $1"
+	_, err := repo.db.Exec(qry, event.ID, model.OutboxEventStatusPending, runAt, lastError)
+	return err
+}
+
+// Deletes the events published before the given time. Returns the number deleted.
+func (repo *outboxEventRepository) DeletePublishedBefore(before time.Time) (int64, error) {
+	res, err := repo.db.Exec("DELETE FROM "+outbox_event_table_name+" WHERE status = $1 AND published_at < $2", model.OutboxEventStatusPublished, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Sends a Postgres notification on the channel to every listening connection
+func (repo *outboxEventRepository) Notify(channel string, payload string) error {
+	_, err := repo.db.Exec("SELECT pg_notify($1, $2)", channel, payload)
+	return err
+}
+
+func scanOutboxEvent(row rowScanner) (*model.OutboxEvent, error) {
+	var event model.OutboxEvent
+	var payload, previous []byte
+
+	err := row.Scan(
+		&event.ID,
+		&event.AggregateType,
+		&event.AggregateID,
+		&event.OrganisationID,
+		&event.ActorID,
+		&event.EventType,
+		&payload,
+		&event.Status,
+		&event.DeliveredTo,
+		&event.Attempts,
+		&event.RunAt,
+		&event.LockedUntil,
+		&event.LastError,
+		&event.PublishedAt,
+		&event.CreatedAt,
+		&event.UpdatedAt,
+		&previous,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	event.Payload = json.RawMessage(payload)
+	if previous != nil {
+		event.Previous = json.RawMessage(previous)
+	}
+	return &event, nil
+}
@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/supermario64bit/whatsapp_connect/db"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+)
+
+const whatsapp_account_throughput_table_name string = "whatsapp_account_throughput"
+
+type rateLimitRepository struct {
+	db *sql.DB
+}
+
+type RateLimitRepository interface {
+	TakeToken(account *model.WhatsAppAccount) (bool, error)
+	TemplateRecipients(accountID uint64, since time.Time) (int, *time.Time, error)
+	HasTemplateRecipient(accountID uint64, messageID uint64, since time.Time) (bool, error)
+	SentSince(accountID uint64, since time.Time) (int, error)
+	PendingJobs(accountID uint64) (int, error)
+}
+
+func NewRateLimitRepository() RateLimitRepository {
+	return &rateLimitRepository{
+		db: db.New(),
+	}
+}
+
+// Takes a token from the account's bucket, which holds up to a second of throughput and refills
+// at messages_per_second. Returns false when the bucket is empty. The bucket lives in the
+// database so every instance draws from the same one.
+func (repo *rateLimitRepository) TakeToken(account *model.WhatsAppAccount) (bool, error) {
+	refilled := "LEAST($2, " + whatsapp_account_throughput_table_name + ".tokens + EXTRACT(EPOCH FROM NOW() - " +
+		whatsapp_account_throughput_table_name + ".refilled_at) * $2)"
+
+	qry := "INSERT INTO " + whatsapp_account_throughput_table_name + " (whatsapp_account_id, tokens, refilled_at) VALUES ($1, $2 - 1, NOW()) " +
+		"ON CONFLICT (whatsapp_account_id) DO UPDATE SET tokens = " + refilled + " - 1, refilled_at = NOW() WHERE " + refilled + " >= 1"
+
+	res, err := repo.db.Exec(qry, account.ID, float64(account.MessagesPerSecond))
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected > 0, nil
+}
+
+// Counts the unique contacts Meta accepted a template for since the given time, along with when
+// the earliest of those templates was accepted
+func (repo *rateLimitRepository) TemplateRecipients(accountID uint64, since time.Time) (int, *time.Time, error) {
+	qry := "SELECT COUNT(DISTINCT contact_id), MIN(queued_at) FROM " + message_table_name +
+		" WHERE whatsapp_account_id = $1 AND direction = $2 AND type = $3 AND queued_at > $4"
+
+	var count int
+	var earliest *time.Time
+	err := repo.db.QueryRow(qry, accountID, model.MessageDirectionOutbound, model.MessageTypeTemplate, since).Scan(&count, &earliest)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return count, earliest, nil
+}
+
+// Reports whether the contact of the message was already sent a template since the given time,
+// so messaging them again takes nothing more from the tier
+func (repo *rateLimitRepository) HasTemplateRecipient(accountID uint64, messageID uint64, since time.Time) (bool, error) {
+	qry := "SELECT EXISTS (SELECT 1 FROM " + message_table_name + " WHERE whatsapp_account_id = $1 AND direction = $2 AND type = $3 " +
+		"AND queued_at > $4 AND contact_id = (SELECT contact_id FROM " + message_table_name + " WHERE id = $5))"
+
+	var exists bool
+	err := repo.db.QueryRow(qry, accountID, model.MessageDirectionOutbound, model.MessageTypeTemplate, since, messageID).Scan(&exists)
+	return exists, err
+}
+
+// Counts the messages Meta accepted from the account since the given time
+func (repo *rateLimitRepository) SentSince(accountID uint64, since time.Time) (int, error) {
+	qry := "SELECT COUNT(*) FROM " + message_table_name + " WHERE whatsapp_account_id = $1 AND direction = $2 AND queued_at > $3"
+
+	var count int
+	err := repo.db.QueryRow(qry, accountID, model.MessageDirectionOutbound, since).Scan(&count)
+	return count, err
+}
+
+// Counts the account's jobs waiting in the send queue or being sent
+func (repo *rateLimitRepository) PendingJobs(accountID uint64) (int, error) {
+	qry := "SELECT COUNT(*) FROM " + message_job_table_name + " WHERE whatsapp_account_id = $1 AND status IN ($2, $3)"
+
+	var count int
+	err := repo.db.QueryRow(qry, accountID, model.MessageJobStatusPending, model.MessageJobStatusRunning).Scan(&count)
+	return count, err
+}
@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/supermario64bit/whatsapp_connect/db"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+)
+
+const segment_table_name string = "segments"
+
+type segmentRepository struct {
+	db *sql.DB
+}
+
+type SegmentRepository interface {
+	Create(segment *model.Segment) (*model.Segment, error)
+	Find(orgID uint64) ([]*model.Segment, error)
+	FindByID(orgID uint64, id uint64) (*model.Segment, error)
+	PatchByID(id uint64, version uint64, changes map[string]interface{}) (*model.Segment, error)
+	DeleteByID(orgID uint64, id uint64, version uint64) error
+}
+
+func NewSegmentRepository() SegmentRepository {
+	return &segmentRepository{
+		db: db.New(),
+	}
+}
+
+func (repo *segmentRepository) Create(segment *model.Segment) (*model.Segment, error) {
+	if segment == nil {
+		return nil, fmt.Errorf("Cannot create segment for nil reference")
+	}
+
+	if segment.ID > 0 {
+		return nil, fmt.Errorf("ID field should be empty")
+	}
+
+	colNames := []string{"organisation_id", "name", "description", "rules"}
+	values := [][]interface{}{
+		{segment.OrganisationID, segment.Name, segment.Description, segment.Rules},
+	}
+
+	qry, args := generateInsertQuery(segment_table_name, colNames, values)
+
+	created, err := scanSegment(repo.db.QueryRow(qry, args...))
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return created, nil
+}
+
+func (repo *segmentRepository) Find(orgID uint64) ([]*model.Segment, error) {
+	qry := "SELECT * FROM " + segment_table_name + " WHERE organisation_id = $1 AND deleted_at IS NULL ORDER BY id"
+	rows, err := repo.db.Query(qry, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var segments []*model.Segment
+
+	for rows.Next() {
+		segment, err := scanSegment(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		segments = append(segments, segment)
+	}
+
+	return segments, rows.Err()
+}
+
+func (repo *segmentRepository) FindByID(orgID uint64, id uint64) (*model.Segment, error) {
+	qry := "SELECT * FROM " + segment_table_name + " WHERE id = $1 AND organisation_id = $2 AND deleted_at IS NULL LIMIT 1"
+
+	return scanSegment(repo.db.QueryRow(qry, id, orgID))
+}
+
+// Writes only the given column values. Callers are expected to have validated them.
+func (repo *segmentRepository) PatchByID(id uint64, version uint64, changes map[string]interface{}) (*model.Segment, error) {
+	qry, args := generateUpdateQuery(segment_table_name, changes, id, version)
+
+	updated, err := scanSegment(repo.db.QueryRow(qry, args...))
+	if err == sql.ErrNoRows {
+		return nil, ErrVersionMismatch
+	}
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return updated, nil
+}
+
+func (repo *segmentRepository) DeleteByID(orgID uint64, id uint64, version uint64) error {
+	current, err := repo.FindByID(orgID, id)
+	if err != nil {
+		return err
+	}
+
+	if version > 0 && current.Version != version {
+		return ErrVersionMismatch
+	}
+
+	qry := "UPDATE " + segment_table_name + " SET deleted_at = $1 WHERE id = $2 AND version = $3 AND deleted_at IS NULL"
+	res, err := repo.db.Exec(qry, time.Now(), id, current.Version)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrVersionMismatch
+	}
+
+	return nil
+}
+
+func scanSegment(row rowScanner) (*model.Segment, error) {
+	var segment model.Segment
+
+	err := row.Scan(
+		&segment.ID,
+		&segment.OrganisationID,
+		&segment.Name,
+		&segment.Description,
+		&segment.Rules,
+		&segment.CreatedAt,
+		&segment.UpdatedAt,
+		&segment.DeletedAt,
+		&segment.Version,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &segment, nil
+}
@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+)
+
+// Attribute values stored as text which read as a number. Only these are cast for numeric
+// comparisons, so a stray value can never fail the whole query.
+const numericPattern = `'^-?[0-9]+(\.[0-9]+)?$'`
+
+var comparisonOperators = map[string]string{
+	"eq":  "=",
+	"neq": "IS DISTINCT FROM",
+	"gt":  ">",
+	"gte": ">=",
+	"lt":  "<",
+	"lte": "<=",
+}
+
+// Translates a validated segment rule into a boolean SQL expression over the contacts table.
+// Values are bound as placeholders appended to args, and relative ages are measured from now.
+func segmentRuleClause(rule *model.SegmentRule, args []interface{}, now time.Time) (string, []interface{}, error) {
+	switch {
+	case rule.All != nil:
+		return segmentRuleGroupClause(rule.All, " AND ", args, now)
+	case rule.Any != nil:
+		return segmentRuleGroupClause(rule.Any, " OR ", args, now)
+	case rule.Not != nil:
+		clause, args, err := segmentRuleClause(rule.Not, args, now)
+		if err != nil {
+			return "", nil, err
+		}
+		return "NOT (" + clause + ")", args, nil
+	}
+
+	switch {
+	case rule.Field == model.SegmentFieldTag:
+		args = append(args, rule.Operand)
+		if rule.Op == "not_has" {
+			return "NOT " + hasTagClause(len(args)), args, nil
+		}
+		return hasTagClause(len(args)), args, nil
+
+	case rule.Field == model.SegmentFieldOptInStatus:
+		values, _ := rule.OperandList()
+		args = append(args, pq.StringArray(values))
+		if rule.Op == "neq" || rule.Op == "not_in" {
+			return fmt.Sprintf("opt_in_status <> ALL($%d)", len(args)), args, nil
+		}
+		return fmt.Sprintf("opt_in_status = ANY($%d)", len(args)), args, nil
+
+	case rule.Field == model.SegmentFieldLastSeenAt:
+		if rule.Op == "never" {
+			return "last_seen_at IS NULL", args, nil
+		}
+		age, err := model.ParseRuleDuration(fmt.Sprint(rule.Operand))
+		if err != nil {
+			return "", nil, err
+		}
+		args = append(args, now.Add(-age))
+		if rule.Op == "older_than" {
+			return fmt.Sprintf("last_seen_at < $%d", len(args)), args, nil
+		}
+		return fmt.Sprintf("last_seen_at >= $%d", len(args)), args, nil
+
+	case strings.HasPrefix(rule.Field, model.SegmentFieldAttributePrefix):
+		return attributeClause(rule, args)
+	}
+
+	return "", nil, fmt.Errorf("Unknown segment rule field %q", rule.Field)
+}
+
+func segmentRuleGroupClause(rules []model.SegmentRule, join string, args []interface{}, now time.Time) (string, []interface{}, error) {
+	parts := make([]string, 0, len(rules))
+	for i := range rules {
+		clause, nextArgs, err := segmentRuleClause(&rules[i], args, now)
+		if err != nil {
+			return "", nil, err
+		}
+		args = nextArgs
+		parts = append(parts, clause)
+	}
+
+	if len(parts) == 0 {
+		return "", nil, fmt.Errorf("Segment rule groups must not be empty")
+	}
+
+	return "(" + strings.Join(parts, join) + ")", args, nil
+}
+
+// Compares one custom attribute. Attributes are read as text; numbers are compared numerically
+// against attribute values which look numeric and text is compared as text.
+func attributeClause(rule *model.SegmentRule, args []interface{}) (string, []interface{}, error) {
+	args = append(args, rule.AttributeKey())
+	attribute := fmt.Sprintf("(attributes->>$%d::text)", len(args))
+
+	switch rule.Op {
+	case "exists":
+		return fmt.Sprintf("attributes ? $%d::text", len(args)), args, nil
+	case "not_exists":
+		return fmt.Sprintf("NOT (attributes ? $%d::text)", len(args)), args, nil
+	case "contains":
+		args = append(args, "%"+escapeLike(fmt.Sprint(rule.Operand))+"%")
+		return fmt.Sprintf("%s ILIKE $%d", attribute, len(args)), args, nil
+	}
+
+	operator, ok := comparisonOperators[rule.Op]
+	if !ok {
+		return "", nil, fmt.Errorf("Unknown segment rule operator %q", rule.Op)
+	}
+
+	switch value := rule.Operand.(type) {
+	case float64:
+		args = append(args, value)
+		numeric := fmt.Sprintf("(CASE WHEN %s ~ %s THEN %s::numeric END)", attribute, numericPattern, attribute)
+		return fmt.Sprintf("%s %s $%d", numeric, operator, len(args)), args, nil
+	case bool:
+		args = append(args, strconv.FormatBool(value))
+	case string:
+		args = append(args, value)
+	default:
+		return "", nil, fmt.Errorf("Unsupported segment rule value %v", rule.Operand)
+	}
+
+	return fmt.Sprintf("%s %s $%d", attribute, operator, len(args)), args, nil
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
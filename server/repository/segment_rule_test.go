@@ -0,0 +1,168 @@
+package repository
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+)
+
+func TestSegmentRuleClause(t *testing.T) {
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	numeric := func(arg string) string {
+		return "(CASE WHEN (attributes->>" + arg + "::text) ~ " + numericPattern + " THEN (attributes->>" + arg + "::text)::numeric END)"
+	}
+
+	tests := []struct {
+		name     string
+		rule     string
+		wantSQL  string
+		wantArgs []interface{}
+	}{
+		{
+			name:     "tag",
+			rule:     `{"field": "tag", "op": "has", "value": "vip"}`,
+			wantSQL:  hasTagClause(2),
+			wantArgs: []interface{}{"vip"},
+		},
+		{
+			name:     "missing tag",
+			rule:     `{"field": "tag", "op": "not_has", "value": "vip"}`,
+			wantSQL:  "NOT " + hasTagClause(2),
+			wantArgs: []interface{}{"vip"},
+		},
+		{
+			name:     "opt in status list",
+			rule:     `{"field": "opt_in_status", "op": "not_in", "value": ["opted_out", "unknown"]}`,
+			wantSQL:  "opt_in_status <> ALL($2)",
+			wantArgs: []interface{}{pq.StringArray{"opted_out", "unknown"}},
+		},
+		{
+			name:     "last seen within",
+			rule:     `{"field": "last_seen_at", "op": "within", "value": "7d"}`,
+			wantSQL:  "last_seen_at >= $2",
+			wantArgs: []interface{}{now.Add(-7 * 24 * time.Hour)},
+		},
+		{
+			name:    "never seen",
+			rule:    `{"field": "last_seen_at", "op": "never"}`,
+			wantSQL: "last_seen_at IS NULL",
+		},
+		{
+			name:     "numeric attributes are cast only when they look numeric",
+			rule:     `{"field": "attributes.orders", "op": "gte", "value": 3}`,
+			wantSQL:  numeric("$2") + " >= $3",
+			wantArgs: []interface{}{"orders", float64(3)},
+		},
+		{
+			name:     "text attribute",
+			rule:     `{"field": "attributes.city", "op": "neq", "value": "Kochi"}`,
+			wantSQL:  "(attributes->>$2::text) IS DISTINCT FROM $3",
+			wantArgs: []interface{}{"city", "Kochi"},
+		},
+		{
+			name:     "boolean attribute is compared as text",
+			rule:     `{"field": "attributes.member", "op": "eq", "value": true}`,
+			wantSQL:  "(attributes->>$2::text) = $3",
+			wantArgs: []interface{}{"member", "true"},
+		},
+		{
+			name:     "contains escapes LIKE wildcards",
+			rule:     `{"field": "attributes.code", "op": "contains", "value": "50%_off\\"}`,
+			wantSQL:  "(attributes->>$2::text) ILIKE $3",
+			wantArgs: []interface{}{"code", `%50\%\_off\\%`},
+		},
+		{
+			name:     "attribute exists",
+			rule:     `{"field": "attributes.city", "op": "exists"}`,
+			wantSQL:  "attributes ? $2::text",
+			wantArgs: []interface{}{"city"},
+		},
+		{
+			name: "placeholders keep counting through nested groups",
+			rule: `{"all": [
+				{"field": "tag", "op": "has", "value": "vip"},
+				{"any": [
+					{"field": "attributes.orders", "op": "gt", "value": 10},
+					{"not": {"field": "opt_in_status", "op": "eq", "value": "opted_out"}}
+				]},
+				{"field": "attributes.city", "op": "eq", "value": "Kochi"}
+			]}`,
+			wantSQL: "(" + hasTagClause(2) + " AND (" + numeric("$3") + " > $4 OR NOT (opt_in_status = ANY($5)))" +
+				" AND (attributes->>$6::text) = $7)",
+			wantArgs: []interface{}{"vip", "orders", float64(10), pq.StringArray{"opted_out"}, "city", "Kochi"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rule model.SegmentRule
+			if err := json.Unmarshal([]byte(tt.rule), &rule); err != nil {
+				t.Fatalf("invalid rule: %v", err)
+			}
+
+			// The clause is appended to a query which already binds the organisation id as $1
+			gotSQL, gotArgs, err := segmentRuleClause(&rule, []interface{}{uint64(1)}, now)
+			if err != nil {
+				t.Fatalf("segmentRuleClause returned error: %v", err)
+			}
+
+			wantArgs := append([]interface{}{uint64(1)}, tt.wantArgs...)
+			if gotSQL != tt.wantSQL {
+				t.Errorf("SQL = %s\nwant  %s", gotSQL, tt.wantSQL)
+			}
+			if !reflect.DeepEqual(gotArgs, wantArgs) {
+				t.Errorf("args = %#v\nwant   %#v", gotArgs, wantArgs)
+			}
+		})
+	}
+}
+
+func TestSegmentRuleClauseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		rule string
+	}{
+		{"empty group", `{"all": []}`},
+		{"unknown field", `{"field": "email", "op": "eq", "value": "a"}`},
+		{"unknown attribute operator", `{"field": "attributes.city", "op": "starts_with", "value": "K"}`},
+		{"invalid duration", `{"field": "last_seen_at", "op": "within", "value": "soon"}`},
+		{"unsupported value", `{"field": "attributes.city", "op": "eq", "value": ["a"]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rule model.SegmentRule
+			if err := json.Unmarshal([]byte(tt.rule), &rule); err != nil {
+				t.Fatalf("invalid rule: %v", err)
+			}
+
+			_, _, err := segmentRuleClause(&rule, nil, time.Now())
+			if err == nil {
+				t.Error("segmentRuleClause returned no error")
+			}
+		})
+	}
+}
+
+func TestEscapeLike(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"plain", "plain"},
+		{"100%", `100\%`},
+		{"a_b", `a\_b`},
+		{`C:\path`, `C:\\path`},
+		{`\%_`, `\\\%\_`},
+	}
+
+	for _, tt := range tests {
+		if got := escapeLike(tt.in); got != tt.want {
+			t.Errorf("escapeLike(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/supermario64bit/whatsapp_connect/db"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+)
+
+const tag_table_name string = "tags"
+
+// Tags are read with the number of live contacts carrying them
+const tag_select string = "SELECT id, organisation_id, name, color, " +
+	"(SELECT COUNT(*) FROM contact_tags JOIN contacts ON contacts.id = contact_tags.contact_id " +
+	"WHERE contact_tags.tag_id = tags.id AND contacts.deleted_at IS NULL), " +
+	"created_at, updated_at FROM " + tag_table_name
+
+type tagRepository struct {
+	db *sql.DB
+}
+
+type TagRepository interface {
+	Create(tag *model.Tag) (*model.Tag, error)
+	Find(orgID uint64) ([]*model.Tag, error)
+	FindByID(orgID uint64, id uint64) (*model.Tag, error)
+	Assign(tagID uint64, orgID uint64, contactIDs []uint64) (int, error)
+	Unassign(tagID uint64, contactIDs []uint64) (int, error)
+	DeleteByID(orgID uint64, id uint64) error
+}
+
+func NewTagRepository() TagRepository {
+	return &tagRepository{
+		db: db.New(),
+	}
+}
+
+func (repo *tagRepository) Create(tag *model.Tag) (*model.Tag, error) {
+	if tag == nil {
+		return nil, fmt.Errorf("Cannot create tag for nil reference")
+	}
+
+	if tag.ID > 0 {
+		return nil, fmt.Errorf("ID field should be empty")
+	}
+
+	colNames := []string{"organisation_id", "name", "color"}
+	values := [][]interface{}{
+		{tag.OrganisationID, tag.Name, tag.Color},
+	}
+
+	qry, args := generateInsertStatement(tag_table_name, colNames, values)
+
+	var id uint64
+	err := repo.db.QueryRow(qry+" RETURNING id", args...).Scan(&id)
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return repo.FindByID(tag.OrganisationID, id)
+}
+
+func (repo *tagRepository) Find(orgID uint64) ([]*model.Tag, error) {
+	rows, err := repo.db.Query(tag_select+" WHERE organisation_id = $1 ORDER BY name", orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var tags []*model.Tag
+
+	for rows.Next() {
+		tag, err := scanTag(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		tags = append(tags, tag)
+	}
+
+	return tags, rows.Err()
+}
+
+func (repo *tagRepository) FindByID(orgID uint64, id uint64) (*model.Tag, error) {
+	return scanTag(repo.db.QueryRow(tag_select+" WHERE id = $1 AND organisation_id = $2 LIMIT 1", id, orgID))
+}
+
+// Tags the given contacts of the organisation. Contacts which already carry the tag, belong
+// elsewhere or are deleted are skipped. Returns the number of contacts newly tagged.
+func (repo *tagRepository) Assign(tagID uint64, orgID uint64, contactIDs []uint64) (int, error) {
+	qry := "INSERT INTO contact_tags (contact_id, tag_id) SELECT id, $1 FROM " + contact_table_name +
+		" WHERE id = ANY($2) AND organisation_id = $3 AND deleted_at IS NULL ON CONFLICT DO NOTHING RETURNING contact_id"
+
+	return repo.inTransaction(func(tx *sql.Tx) (int, error) {
+		return changeMembership(tx, qry, tagID, int64Array(contactIDs), orgID)
+	})
+}
+
+// Removes the tag from the given contacts. Returns the number of contacts which carried it.
+func (repo *tagRepository) Unassign(tagID uint64, contactIDs []uint64) (int, error) {
+	qry := "DELETE FROM contact_tags WHERE tag_id = $1 AND contact_id = ANY($2) RETURNING contact_id"
+
+	return repo.inTransaction(func(tx *sql.Tx) (int, error) {
+		return changeMembership(tx, qry, tagID, int64Array(contactIDs))
+	})
+}
+
+// Deletes the tag, taking it off every contact carrying it
+func (repo *tagRepository) DeleteByID(orgID uint64, id uint64) error {
+	_, err := repo.FindByID(orgID, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = repo.inTransaction(func(tx *sql.Tx) (int, error) {
+		changed, err := changeMembership(tx, "DELETE FROM contact_tags WHERE tag_id = $1 RETURNING contact_id", id)
+		if err != nil {
+			return 0, err
+		}
+
+		_, err = tx.Exec("DELETE FROM "+tag_table_name+" WHERE id = $1 AND organisation_id = $2", id, orgID)
+		return changed, err
+	})
+	return translateError(err)
+}
+
+func (repo *tagRepository) inTransaction(fn func(tx *sql.Tx) (int, error)) (int, error) {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	n, err := fn(tx)
+	if err != nil {
+		return 0, err
+	}
+
+	return n, tx.Commit()
+}
+
+// Runs a contact_tags write returning the affected contact ids and moves the version of each of
+// those contacts on, since their tags are part of them
+func changeMembership(tx *sql.Tx, qry string, args ...interface{}) (int, error) {
+	rows, err := tx.Query(qry, args...)
+	if err != nil {
+		return 0, translateError(err)
+	}
+
+	var changed pq.Int64Array
+	for rows.Next() {
+		var contactID int64
+		if err := rows.Scan(&contactID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		changed = append(changed, contactID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	if len(changed) > 0 {
+		_, err = tx.Exec("UPDATE "+contact_table_name+" SET updated_at = NOW() WHERE id = ANY($1)", changed)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(changed), nil
+}
+
+func int64Array(ids []uint64) pq.Int64Array {
+	arr := make(pq.Int64Array, 0, len(ids))
+	for _, id := range ids {
+		arr = append(arr, int64(id))
+	}
+	return arr
+}
+
+func scanTag(row rowScanner) (*model.Tag, error) {
+	var tag model.Tag
+
+	err := row.Scan(
+		&tag.ID,
+		&tag.OrganisationID,
+		&tag.Name,
+		&tag.Color,
+		&tag.ContactCount,
+		&tag.CreatedAt,
+		&tag.UpdatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &tag, nil
+}
@@ -10,18 +10,27 @@ import (
 	"github.com/supermario64bit/whatsapp_connect/server/model"
 )
 
-const user_table_name string = "user"
+const user_table_name string = "users"
+
+// Users see themselves and the members of the organisations they belong to. Expects
+// the viewer's id as $1.
+const visibleUserClause string = "(id = $1 OR id IN (SELECT m.user_id FROM " + organisation_member_table_name + " m" +
+	" JOIN " + organisation_member_table_name + " v ON v.organisation_id = m.organisation_id" +
+	" JOIN " + org_table_name + " o ON o.id = m.organisation_id AND o.deleted_at IS NULL" +
+	" WHERE v.user_id = $1))"
 
 type userRepository struct {
 	db *sql.DB
 }
 
 type UserRepository interface {
-	Create(user *model.User) (*model.User, error)
-	Find(filter *model.User) ([]*model.User, error)
+	Create(user *model.User, actorID uint64) (*model.User, error)
+	Find(filter *model.User, viewerID uint64) ([]*model.User, error)
 	FindByID(id uint64) (*model.User, error)
-	UpdateByID(updates *model.User, id uint64) (*model.User, error)
-	DeleteByID(id uint64) error
+	FindVisibleByID(id uint64, viewerID uint64) (*model.User, error)
+	UpdateByID(updates *model.User, id uint64, version uint64, actorID uint64) (*model.User, error)
+	PatchByID(id uint64, version uint64, changes map[string]interface{}, actorID uint64) (*model.User, error)
+	DeleteByID(id uint64, version uint64, actorID uint64) error
 }
 
 func NewUserRepository() UserRepository {
@@ -30,7 +39,8 @@ func NewUserRepository() UserRepository {
 	}
 }
 
-func (repo *userRepository) Create(user *model.User) (*model.User, error) {
+// Stores the user together with its user.created outbox event
+func (repo *userRepository) Create(user *model.User, actorID uint64) (*model.User, error) {
 	if user == nil {
 		return nil, fmt.Errorf("Cannot create user for nil reference")
 	}
@@ -44,29 +54,36 @@ func (repo *userRepository) Create(user *model.User) (*model.User, error) {
 
 	}
 
-	if len(user.Mobile) != 10 {
-		return nil, fmt.Errorf("Contact Number should be 10 digit")
-	}
-
 	colNames := []string{"name", "handle", "mobile_number", "email", "status"}
 	values := [][]interface{}{
 		{user.Name, user.Handle, user.Mobile, user.Email, user.Status},
 	}
 
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
 	qry, args := generateInsertQuery(user_table_name, colNames, values)
 
-	var createdUser model.User
-	err := repo.db.QueryRow(qry, args...).Scan(&createdUser.ID, &createdUser.Name, &createdUser.Handle, &createdUser.Mobile, &createdUser.Email, &createdUser.Status, &createdUser.CreatedAt, &createdUser.UpdatedAt, &createdUser.DeletedAt)
+	created, err := scanUser(tx.QueryRow(qry, args...))
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	_, err = insertOutboxEvent(tx, model.OutboxAggregateUser, created.ID, nil, actorID, model.OutboxEventUserCreated, nil, created)
 	if err != nil {
 		return nil, err
 	}
 
-	return &createdUser, nil
+	return created, tx.Commit()
 }
 
-func (repo *userRepository) Find(filter *model.User) ([]*model.User, error) {
-	args := []interface{}{}
-	whereParts := []string{}
+// Finds the users matching the filter which the viewer may see
+func (repo *userRepository) Find(filter *model.User, viewerID uint64) ([]*model.User, error) {
+	args := []interface{}{viewerID}
+	whereParts := []string{"deleted_at IS NULL", visibleUserClause}
 	if filter != nil {
 		if strings.TrimSpace(filter.Name) != "" {
 			args = append(args, "%"+strings.TrimSpace(filter.Name)+"%")
@@ -96,18 +113,7 @@ func (repo *userRepository) Find(filter *model.User) ([]*model.User, error) {
 		}
 	}
 
-	whereClause := ""
-	if len(whereClause) > 0 {
-		whereClause = "WHERE " + strings.Join(whereParts, " AND ")
-	}
-
-	if whereClause != "" {
-		whereClause = whereClause + " AND deleted_at IS NULL"
-	} else {
-		whereClause = whereClause + " WHERE deleted_at IS NULL"
-	}
-
-	qry := fmt.Sprintf("SELECT * FROM %s ", user_table_name) + whereClause
+	qry := fmt.Sprintf("SELECT * FROM %s WHERE ", user_table_name) + strings.Join(whereParts, " AND ")
 	rows, err := repo.db.Query(qry, args...)
 	if err != nil {
 		return nil, err
@@ -118,25 +124,12 @@ func (repo *userRepository) Find(filter *model.User) ([]*model.User, error) {
 	var users []*model.User
 
 	for rows.Next() {
-		var user model.User
-
-		err := rows.Scan(
-			&user.ID,
-			&user.Name,
-			&user.Handle,
-			&user.Mobile,
-			&user.Email,
-			&user.Status,
-			&user.CreatedAt,
-			&user.UpdatedAt,
-			&user.DeletedAt,
-		)
-
+		user, err := scanUser(rows)
 		if err != nil {
 			return nil, err
 		}
 
-		users = append(users, &user)
+		users = append(users, user)
 	}
 
 	return users, nil
@@ -145,34 +138,34 @@ func (repo *userRepository) Find(filter *model.User) ([]*model.User, error) {
 func (repo *userRepository) FindByID(id uint64) (*model.User, error) {
 	qry := "SELECT * FROM " + user_table_name + " WHERE id = $1 AND deleted_at IS NULL LIMIT 1"
 
-	var user model.User
+	return scanUser(repo.db.QueryRow(qry, id))
+}
 
-	err := repo.db.QueryRow(qry, id).Scan(
-		&user.ID,
-		&user.Name,
-		&user.Handle,
-		&user.Mobile,
-		&user.Email,
-		&user.Status,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-		&user.DeletedAt,
-	)
+// Finds the user when the viewer may see them, otherwise sql.ErrNoRows
+func (repo *userRepository) FindVisibleByID(id uint64, viewerID uint64) (*model.User, error) {
+	qry := "SELECT * FROM " + user_table_name + " WHERE id = $2 AND deleted_at IS NULL AND " + visibleUserClause + " LIMIT 1"
 
+	return scanUser(repo.db.QueryRow(qry, viewerID, id))
+}
+
+// Writes the non-empty fields of updates and stores the user.updated outbox event in the
+// same transaction
+func (repo *userRepository) UpdateByID(updates *model.User, id uint64, version uint64, actorID uint64) (*model.User, error) {
+	tx, err := repo.db.Begin()
 	if err != nil {
 		return nil, err
 	}
+	defer tx.Rollback()
 
-	return &user, nil
-}
-
-func (repo *userRepository) UpdateByID(updates *model.User, id uint64) (*model.User, error) {
-	_, err := repo.FindByID(id)
-
+	current, err := lockUser(tx, id)
 	if err != nil {
 		return nil, err
 	}
 
+	if version > 0 && current.Version != version {
+		return nil, ErrVersionMismatch
+	}
+
 	updatesParam := []string{}
 	args := []interface{}{}
 	argPos := 1
@@ -206,13 +199,109 @@ func (repo *userRepository) UpdateByID(updates *model.User, id uint64) (*model.U
 		argPos++
 	}
 
+	if len(updatesParam) == 0 {
+		return current, nil
+	}
+
+	// The row is locked, so the version read above is still current
 	qry := "UPDATE " + user_table_name + " SET " + strings.Join(updatesParam, ", ") +
-		fmt.Sprintf(" WHERE id = $%d AND deleted_at IS NULL RETURNING *", argPos)
+		fmt.Sprintf(" WHERE id = $%d RETURNING *", argPos)
 	args = append(args, id)
 
-	row := repo.db.QueryRow(qry, args...)
+	updated, err := scanUser(tx.QueryRow(qry, args...))
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	_, err = insertOutboxEvent(tx, model.OutboxAggregateUser, id, nil, actorID, model.OutboxEventUserUpdated, current, updated)
+	if err != nil {
+		return nil, err
+	}
+
+	return updated, tx.Commit()
+}
+
+// Writes only the given column values, and stores the user.updated outbox event in the
+// same transaction. Callers are expected to have validated the values.
+func (repo *userRepository) PatchByID(id uint64, version uint64, changes map[string]interface{}, actorID uint64) (*model.User, error) {
+	if len(changes) == 0 {
+		return repo.FindByID(id)
+	}
+
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	current, err := lockUser(tx, id)
+	if err == sql.ErrNoRows {
+		return nil, ErrVersionMismatch
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	qry, args := generateUpdateQuery(user_table_name, changes, id, version)
+
+	updated, err := scanUser(tx.QueryRow(qry, args...))
+	if err == sql.ErrNoRows {
+		return nil, ErrVersionMismatch
+	}
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	_, err = insertOutboxEvent(tx, model.OutboxAggregateUser, id, nil, actorID, model.OutboxEventUserUpdated, current, updated)
+	if err != nil {
+		return nil, err
+	}
+
+	return updated, tx.Commit()
+}
+
+// Soft deletes the row and stores the user.deleted outbox event in the same transaction
+func (repo *userRepository) DeleteByID(id uint64, version uint64, actorID uint64) error {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	current, err := lockUser(tx, id)
+	if err != nil {
+		return err
+	}
+
+	if version > 0 && current.Version != version {
+		return ErrVersionMismatch
+	}
+
+	qry := "UPDATE " + user_table_name + " SET deleted_at = $1 WHERE id = $2 RETURNING *"
+	deleted, err := scanUser(tx.QueryRow(qry, time.Now(), id))
+	if err != nil {
+		return err
+	}
+
+	_, err = insertOutboxEvent(tx, model.OutboxAggregateUser, id, nil, actorID, model.OutboxEventUserDeleted, current, deleted)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Reads the row for update, holding writers of the same row off until the transaction ends
+func lockUser(q rowQuerier, id uint64) (*model.User, error) {
+	qry := "SELECT * FROM " + user_table_name + " WHERE id = $1 AND deleted_at IS NULL FOR UPDATE"
+
+	return scanUser(q.QueryRow(qry, id))
+}
+
+func scanUser(row rowScanner) (*model.User, error) {
 	var user model.User
-	err = row.Scan(
+
+	err := row.Scan(
 		&user.ID,
 		&user.Name,
 		&user.Handle,
@@ -222,21 +311,12 @@ func (repo *userRepository) UpdateByID(updates *model.User, id uint64) (*model.U
 		&user.CreatedAt,
 		&user.UpdatedAt,
 		&user.DeletedAt,
+		&user.Version,
 	)
+
 	if err != nil {
 		return nil, err
 	}
 
 	return &user, nil
 }
-
-func (repo *userRepository) DeleteByID(id uint64) error {
-	_, err := repo.FindByID(id)
-	if err != nil {
-		return err
-	}
-
-	qry := "UPDATE " + user_table_name + " SET deleted_at = $1 WHERE id = $2 AND deleted_at IS NULL"
-	_, err = repo.db.Exec(qry, time.Now(), id)
-	return err
-}
@@ -0,0 +1,192 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/supermario64bit/whatsapp_connect/db"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+)
+
+const webhook_delivery_table_name string = "webhook_deliveries"
+
+type webhookDeliveryRepository struct {
+	db *sql.DB
+}
+
+type WebhookDeliveryRepository interface {
+	Enqueue(orgID uint64, eventType string, eventID string, payload json.RawMessage, maxAttempts int) (int64, error)
+	Find(orgID uint64, subscriptionID uint64, filter *model.WebhookDeliveryFilter) ([]*model.WebhookDelivery, int, error)
+	FindByID(orgID uint64, subscriptionID uint64, id uint64) (*model.WebhookDelivery, error)
+	Claim(lease time.Duration) (*model.WebhookDelivery, error)
+	Succeed(delivery *model.WebhookDelivery, attempt model.WebhookAttempt, at time.Time) error
+	Retry(delivery *model.WebhookDelivery, attempt model.WebhookAttempt, runAt time.Time) error
+	Bury(delivery *model.WebhookDelivery, attempt model.WebhookAttempt, at time.Time) error
+	Redeliver(delivery *model.WebhookDelivery, maxAttempts int) (*model.WebhookDelivery, error)
+}
+
+func NewWebhookDeliveryRepository() WebhookDeliveryRepository {
+	return &webhookDeliveryRepository{
+		db: db.New(),
+	}
+}
+
+// Queues the event for every enabled subscription of the organisation wanting its type.
+// Subscriptions which were queued the event already are skipped, so an event relayed twice is
+// delivered once. Returns the number of deliveries queued.
+func (repo *webhookDeliveryRepository) Enqueue(orgID uint64, eventType string, eventID string, payload json.RawMessage, maxAttempts int) (int64, error) {
+	qry := "INSERT INTO " + webhook_delivery_table_name + " (organisation_id, subscription_id, event_id, event_type, payload, max_attempts) " +
+		"SELECT organisation_id, id, $3, $2, $4, $5 FROM " + webhook_subscription_table_name +
+		" s WHERE organisation_id = $1 AND enabled AND deleted_at IS NULL AND $2 = ANY(event_types) " +
+		"AND NOT EXISTS (SELECT 1 FROM " + webhook_delivery_table_name + " d WHERE d.subscription_id = s.id AND d.event_id = $3)"
+
+	res, err := repo.db.Exec(qry, orgID, eventType, eventID, string(payload), maxAttempts)
+	if err != nil {
+		return 0, translateError(err)
+	}
+
+	return res.RowsAffected()
+}
+
+// Returns one page of the subscription's matching deliveries, newest first, along with the
+// total match count
+func (repo *webhookDeliveryRepository) Find(orgID uint64, subscriptionID uint64, filter *model.WebhookDeliveryFilter) ([]*model.WebhookDelivery, int, error) {
+	args := []interface{}{orgID, subscriptionID}
+	whereParts := []string{"organisation_id = $1", "subscription_id = $2"}
+
+	if strings.TrimSpace(filter.Status) != "" {
+		args = append(args, strings.TrimSpace(filter.Status))
+		whereParts = append(whereParts, fmt.Sprintf("status = $%d", len(args)))
+	}
+
+	if strings.TrimSpace(filter.EventType) != "" {
+		args = append(args, strings.TrimSpace(filter.EventType))
+		whereParts = append(whereParts, fmt.Sprintf("event_type = $%d", len(args)))
+	}
+
+	whereClause := " WHERE " + strings.Join(whereParts, " AND ")
+
+	var total int
+	err := repo.db.QueryRow("SELECT COUNT(*) FROM "+webhook_delivery_table_name+whereClause, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	args = append(args, filter.PageSize, filter.Offset())
+	qry := "SELECT * FROM " + webhook_delivery_table_name + whereClause +
+		fmt.Sprintf(" ORDER BY created_at DESC, id DESC LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	rows, err := repo.db.Query(qry, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	defer rows.Close()
+
+	var deliveries []*model.WebhookDelivery
+
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, total, rows.Err()
+}
+
+func (repo *webhookDeliveryRepository) FindByID(orgID uint64, subscriptionID uint64, id uint64) (*model.WebhookDelivery, error) {
+	qry := "SELECT * FROM " + webhook_delivery_table_name + " WHERE id = $1 AND organisation_id = $2 AND subscription_id = $3 LIMIT 1"
+
+	return scanWebhookDelivery(repo.db.QueryRow(qry, id, orgID, subscriptionID))
+}
+
+// Takes the next due delivery, leasing it to the caller and counting the attempt, the same
+// way message jobs are claimed. Returns sql.ErrNoRows when nothing is due.
+func (repo *webhookDeliveryRepository) Claim(lease time.Duration) (*model.WebhookDelivery, error) {
+	qry := "UPDATE " + webhook_delivery_table_name + " SET status = $1, attempts = attempts + 1, " +
+		"locked_until = NOW() + $2 * INTERVAL '1 second' WHERE id = (" +
+		"SELECT id FROM " + webhook_delivery_table_name + " WHERE run_at <= NOW() AND " +
+		"(status = $3 OR (status = $1 AND locked_until < NOW())) " +
+		"ORDER BY run_at, id LIMIT 1 FOR UPDATE SKIP LOCKED) RETURNING *"
+
+	return scanWebhookDelivery(repo.db.QueryRow(qry, model.WebhookDeliveryStatusRunning, lease.Seconds(), model.WebhookDeliveryStatusPending))
+}
+
+func (repo *webhookDeliveryRepository) Succeed(delivery *model.WebhookDelivery, attempt model.WebhookAttempt, at time.Time) error {
+	return repo.finish(delivery, attempt, model.WebhookDeliveryStatusSucceeded, at, &at)
+}
+
+// Puts the delivery back to be tried again at runAt
+func (repo *webhookDeliveryRepository) Retry(delivery *model.WebhookDelivery, attempt model.WebhookAttempt, runAt time.Time) error {
+	return repo.finish(delivery, attempt, model.WebhookDeliveryStatusPending, runAt, nil)
+}
+
+// Gives up on the delivery
+func (repo *webhookDeliveryRepository) Bury(delivery *model.WebhookDelivery, attempt model.WebhookAttempt, at time.Time) error {
+	return repo.finish(delivery, attempt, model.WebhookDeliveryStatusDead, at, &at)
+}
+
+// Records the outcome of an attempt and moves the delivery to the status
+func (repo *webhookDeliveryRepository) finish(delivery *model.WebhookDelivery, attempt model.WebhookAttempt, status string, runAt time.Time, completedAt *time.Time) error {
+	qry := "UPDATE " + webhook_delivery_table_name + " SET status = $2, run_at = $3, completed_at = $4, locked_until = NULL, " +
+		"response_status = $5, response_body = $6, duration_ms = $7, last_error = $8 WHERE id = $1"
+	_, err := repo.db.Exec(qry, delivery.ID, status, runAt, completedAt, attempt.ResponseStatus, attempt.ResponseBody, attempt.DurationMs, attempt.Error)
+	return err
+}
+
+// Queues the delivery's payload again as a new delivery with a fresh set of attempts. The
+// event id stays, so receivers can tell it apart from a new event.
+func (repo *webhookDeliveryRepository) Redeliver(delivery *model.WebhookDelivery, maxAttempts int) (*model.WebhookDelivery, error) {
+	colNames := []string{"organisation_id", "subscription_id", "event_id", "event_type", "payload", "max_attempts"}
+	values := [][]interface{}{
+		{delivery.OrganisationID, delivery.SubscriptionID, delivery.EventID, delivery.EventType, string(delivery.Payload), maxAttempts},
+	}
+
+	qry, args := generateInsertQuery(webhook_delivery_table_name, colNames, values)
+
+	created, err := scanWebhookDelivery(repo.db.QueryRow(qry, args...))
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return created, nil
+}
+
+func scanWebhookDelivery(row rowScanner) (*model.WebhookDelivery, error) {
+	var delivery model.WebhookDelivery
+	var payload []byte
+
+	err := row.Scan(
+		&delivery.ID,
+		&delivery.OrganisationID,
+		&delivery.SubscriptionID,
+		&delivery.EventID,
+		&delivery.EventType,
+		&payload,
+		&delivery.Status,
+		&delivery.Attempts,
+		&delivery.MaxAttempts,
+		&delivery.RunAt,
+		&delivery.LockedUntil,
+		&delivery.ResponseStatus,
+		&delivery.ResponseBody,
+		&delivery.DurationMs,
+		&delivery.LastError,
+		&delivery.CompletedAt,
+		&delivery.CreatedAt,
+		&delivery.UpdatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	delivery.Payload = json.RawMessage(payload)
+	return &delivery, nil
+}
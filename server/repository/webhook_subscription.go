@@ -0,0 +1,175 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/supermario64bit/whatsapp_connect/db"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+)
+
+const webhook_subscription_table_name string = "webhook_subscriptions"
+
+type webhookSubscriptionRepository struct {
+	db *sql.DB
+}
+
+type WebhookSubscriptionRepository interface {
+	Create(subscription *model.WebhookSubscription) (*model.WebhookSubscription, error)
+	Find(orgID uint64) ([]*model.WebhookSubscription, error)
+	FindByID(orgID uint64, id uint64) (*model.WebhookSubscription, error)
+	PatchByID(id uint64, version uint64, changes map[string]interface{}) (*model.WebhookSubscription, error)
+	DeleteByID(orgID uint64, id uint64, version uint64) error
+	RecordSuccess(id uint64) error
+	RecordFailure(id uint64, limit int, reason string) (*model.WebhookSubscription, error)
+}
+
+func NewWebhookSubscriptionRepository() WebhookSubscriptionRepository {
+	return &webhookSubscriptionRepository{
+		db: db.New(),
+	}
+}
+
+func (repo *webhookSubscriptionRepository) Create(subscription *model.WebhookSubscription) (*model.WebhookSubscription, error) {
+	if subscription == nil {
+		return nil, fmt.Errorf("Cannot create webhook subscription for nil reference")
+	}
+
+	if subscription.ID > 0 {
+		return nil, fmt.Errorf("ID field should be empty")
+	}
+
+	colNames := []string{"organisation_id", "url", "secret", "event_types", "enabled"}
+	values := [][]interface{}{
+		{subscription.OrganisationID, subscription.URL, subscription.Secret, subscription.EventTypes, subscription.Enabled},
+	}
+
+	qry, args := generateInsertQuery(webhook_subscription_table_name, colNames, values)
+
+	created, err := scanWebhookSubscription(repo.db.QueryRow(qry, args...))
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return created, nil
+}
+
+func (repo *webhookSubscriptionRepository) Find(orgID uint64) ([]*model.WebhookSubscription, error) {
+	qry := "SELECT * FROM " + webhook_subscription_table_name + " WHERE organisation_id = $1 AND deleted_at IS NULL ORDER BY id"
+
+	rows, err := repo.db.Query(qry, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var subscriptions []*model.WebhookSubscription
+
+	for rows.Next() {
+		subscription, err := scanWebhookSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		subscriptions = append(subscriptions, subscription)
+	}
+
+	return subscriptions, rows.Err()
+}
+
+func (repo *webhookSubscriptionRepository) FindByID(orgID uint64, id uint64) (*model.WebhookSubscription, error) {
+	qry := "SELECT * FROM " + webhook_subscription_table_name + " WHERE id = $1 AND organisation_id = $2 AND deleted_at IS NULL LIMIT 1"
+
+	return scanWebhookSubscription(repo.db.QueryRow(qry, id, orgID))
+}
+
+// Writes only the given column values. Callers are expected to have validated them.
+func (repo *webhookSubscriptionRepository) PatchByID(id uint64, version uint64, changes map[string]interface{}) (*model.WebhookSubscription, error) {
+	qry, args := generateUpdateQuery(webhook_subscription_table_name, changes, id, version)
+
+	updated, err := scanWebhookSubscription(repo.db.QueryRow(qry, args...))
+	if err == sql.ErrNoRows {
+		return nil, ErrVersionMismatch
+	}
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return updated, nil
+}
+
+func (repo *webhookSubscriptionRepository) DeleteByID(orgID uint64, id uint64, version uint64) error {
+	current, err := repo.FindByID(orgID, id)
+	if err != nil {
+		return err
+	}
+
+	if version > 0 && current.Version != version {
+		return ErrVersionMismatch
+	}
+
+	qry := "UPDATE " + webhook_subscription_table_name + " SET deleted_at = $1 WHERE id = $2 AND version = $3 AND deleted_at IS NULL"
+	res, err := repo.db.Exec(qry, time.Now(), id, current.Version)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrVersionMismatch
+	}
+
+	return nil
+}
+
+// Clears the failure count after a successful delivery. Subscriptions without failures are
+// left untouched, so their version stays.
+func (repo *webhookSubscriptionRepository) RecordSuccess(id uint64) error {
+	qry := "UPDATE " + webhook_subscription_table_name + " SET consecutive_failures = 0 WHERE id = $1 AND consecutive_failures > 0"
+	_, err := repo.db.Exec(qry, id)
+	return err
+}
+
+// Counts a failed attempt, disabling the subscription for the reason once it reaches the limit
+// of failures in a row. Returns sql.ErrNoRows when the subscription is disabled already.
+func (repo *webhookSubscriptionRepository) RecordFailure(id uint64, limit int, reason string) (*model.WebhookSubscription, error) {
+	qry := "UPDATE " + webhook_subscription_table_name + " SET consecutive_failures = consecutive_failures + 1, " +
+		"enabled = consecutive_failures + 1 < $2, " +
+		"disabled_reason = CASE WHEN consecutive_failures + 1 < $2 THEN disabled_reason ELSE $3 END, " +
+		"disabled_at = CASE WHEN consecutive_failures + 1 < $2 THEN disabled_at ELSE NOW() END " +
+		"WHERE id = $1 AND enabled AND deleted_at IS NULL RETURNING *"
+
+	return scanWebhookSubscription(repo.db.QueryRow(qry, id, limit, reason))
+}
+
+func scanWebhookSubscription(row rowScanner) (*model.WebhookSubscription, error) {
+	var subscription model.WebhookSubscription
+
+	err := row.Scan(
+		&subscription.ID,
+		&subscription.OrganisationID,
+		&subscription.URL,
+		&subscription.Secret,
+		&subscription.EventTypes,
+		&subscription.Enabled,
+		&subscription.ConsecutiveFailures,
+		&subscription.DisabledReason,
+		&subscription.DisabledAt,
+		&subscription.CreatedAt,
+		&subscription.UpdatedAt,
+		&subscription.DeletedAt,
+		&subscription.Version,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &subscription, nil
+}
@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/supermario64bit/whatsapp_connect/db"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+)
+
+const whatsapp_account_table_name string = "whatsapp_accounts"
+
+type whatsAppAccountRepository struct {
+	db *sql.DB
+}
+
+type WhatsAppAccountRepository interface {
+	Create(account *model.WhatsAppAccount) (*model.WhatsAppAccount, error)
+	Find(orgID uint64) ([]*model.WhatsAppAccount, error)
+	FindByID(orgID uint64, id uint64) (*model.WhatsAppAccount, error)
+	FindByPhoneNumberID(phoneNumberID string) (*model.WhatsAppAccount, error)
+	UpdateLimits(id uint64, version uint64, limits *model.WhatsAppAccountLimits) (*model.WhatsAppAccount, error)
+	DeleteByID(orgID uint64, id uint64, version uint64) error
+}
+
+func NewWhatsAppAccountRepository() WhatsAppAccountRepository {
+	return &whatsAppAccountRepository{
+		db: db.New(),
+	}
+}
+
+func (repo *whatsAppAccountRepository) Create(account *model.WhatsAppAccount) (*model.WhatsAppAccount, error) {
+	if account == nil {
+		return nil, fmt.Errorf("Cannot create whatsapp account for nil reference")
+	}
+
+	if account.ID > 0 {
+		return nil, fmt.Errorf("ID field should be empty")
+	}
+
+	colNames := []string{"organisation_id", "name", "business_account_id", "phone_number_id", "display_phone_number", "access_token", "status", "messages_per_second", "messaging_tier"}
+	values := [][]interface{}{
+		{account.OrganisationID, account.Name, account.BusinessAccountID, account.PhoneNumberID, account.DisplayPhoneNumber, account.AccessToken, account.Status, account.MessagesPerSecond, account.MessagingTier},
+	}
+
+	qry, args := generateInsertQuery(whatsapp_account_table_name, colNames, values)
+
+	created, err := scanWhatsAppAccount(repo.db.QueryRow(qry, args...))
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return created, nil
+}
+
+func (repo *whatsAppAccountRepository) Find(orgID uint64) ([]*model.WhatsAppAccount, error) {
+	qry := "SELECT * FROM " + whatsapp_account_table_name + " WHERE organisation_id = $1 AND deleted_at IS NULL ORDER BY id"
+	rows, err := repo.db.Query(qry, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var accounts []*model.WhatsAppAccount
+
+	for rows.Next() {
+		account, err := scanWhatsAppAccount(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		accounts = append(accounts, account)
+	}
+
+	return accounts, nil
+}
+
+func (repo *whatsAppAccountRepository) FindByID(orgID uint64, id uint64) (*model.WhatsAppAccount, error) {
+	qry := "SELECT * FROM " + whatsapp_account_table_name + " WHERE id = $1 AND organisation_id = $2 AND deleted_at IS NULL LIMIT 1"
+
+	return scanWhatsAppAccount(repo.db.QueryRow(qry, id, orgID))
+}
+
+// Resolves the account a webhook notification was sent for
+func (repo *whatsAppAccountRepository) FindByPhoneNumberID(phoneNumberID string) (*model.WhatsAppAccount, error) {
+	qry := "SELECT * FROM " + whatsapp_account_table_name + " WHERE phone_number_id = $1 AND deleted_at IS NULL LIMIT 1"
+
+	return scanWhatsAppAccount(repo.db.QueryRow(qry, phoneNumberID))
+}
+
+func (repo *whatsAppAccountRepository) UpdateLimits(id uint64, version uint64, limits *model.WhatsAppAccountLimits) (*model.WhatsAppAccount, error) {
+	changes := map[string]interface{}{
+		"messages_per_second": limits.MessagesPerSecond,
+		"messaging_tier":      limits.MessagingTier,
+	}
+
+	qry, args := generateUpdateQuery(whatsapp_account_table_name, changes, id, version)
+
+	updated, err := scanWhatsAppAccount(repo.db.QueryRow(qry, args...))
+	if err == sql.ErrNoRows {
+		return nil, ErrVersionMismatch
+	}
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	return updated, nil
+}
+
+func (repo *whatsAppAccountRepository) DeleteByID(orgID uint64, id uint64, version uint64) error {
+	current, err := repo.FindByID(orgID, id)
+	if err != nil {
+		return err
+	}
+
+	if version > 0 && current.Version != version {
+		return ErrVersionMismatch
+	}
+
+	qry := "UPDATE " + whatsapp_account_table_name + " SET deleted_at = $1 WHERE id = $2 AND version = $3 AND deleted_at IS NULL"
+	res, err := repo.db.Exec(qry, time.Now(), id, current.Version)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrVersionMismatch
+	}
+
+	return nil
+}
+
+func scanWhatsAppAccount(row rowScanner) (*model.WhatsAppAccount, error) {
+	var account model.WhatsAppAccount
+
+	err := row.Scan(
+		&account.ID,
+		&account.OrganisationID,
+		&account.Name,
+		&account.BusinessAccountID,
+		&account.PhoneNumberID,
+		&account.DisplayPhoneNumber,
+		&account.AccessToken,
+		&account.Status,
+		&account.CreatedAt,
+		&account.UpdatedAt,
+		&account.DeletedAt,
+		&account.Version,
+		&account.MessagesPerSecond,
+		&account.MessagingTier,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &account, nil
+}
@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/supermario64bit/whatsapp_connect/server/controller"
+)
+
+// Includes all the routes for audit log
+func mountAuditRoutes(r gin.IRouter) {
+	auditRouteGroup := r.Group("/audit")
+	{
+		ctrl := controller.NewAuditController()
+
+		auditRouteGroup.GET("", ctrl.Find)
+	}
+}
@@ -0,0 +1,21 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/supermario64bit/whatsapp_connect/server/controller"
+)
+
+// Includes all the routes for auto reply rules of an organisation
+func mountAutoReplyRuleRoutes(r gin.IRouter) {
+	ruleRouteGroup := r.Group("/organisation/:id/auto-reply-rules")
+	{
+		ctrl := controller.NewAutoReplyRuleController()
+
+		ruleRouteGroup.POST("", ctrl.Create)
+		ruleRouteGroup.GET("", ctrl.Find)
+		ruleRouteGroup.POST("/test", ctrl.Test)
+		ruleRouteGroup.GET("/:rule_id", ctrl.FindByID)
+		ruleRouteGroup.PATCH("/:rule_id", ctrl.PatchByID)
+		ruleRouteGroup.DELETE("/:rule_id", ctrl.DeleteByID)
+	}
+}
@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/supermario64bit/whatsapp_connect/server/controller"
+)
+
+// Includes all the routes for the business hours of an organisation
+func mountBusinessHoursRoutes(r gin.IRouter) {
+	hoursRouteGroup := r.Group("/organisation/:id/business-hours")
+	{
+		ctrl := controller.NewBusinessHoursController()
+
+		hoursRouteGroup.GET("", ctrl.Find)
+		hoursRouteGroup.PUT("", ctrl.Update)
+		hoursRouteGroup.GET("/status", ctrl.Status)
+	}
+}
@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/supermario64bit/whatsapp_connect/server/controller"
+)
+
+// Includes all the routes for broadcast campaigns of an organisation
+func mountCampaignRoutes(r gin.IRouter) {
+	campaignRouteGroup := r.Group("/organisation/:id/campaigns")
+	{
+		ctrl := controller.NewCampaignController()
+
+		campaignRouteGroup.POST("", ctrl.Create)
+		campaignRouteGroup.GET("", ctrl.Find)
+		campaignRouteGroup.GET("/:campaign_id", ctrl.FindByID)
+		campaignRouteGroup.POST("/:campaign_id/schedule", ctrl.Schedule)
+		campaignRouteGroup.POST("/:campaign_id/cancel", ctrl.Cancel)
+		campaignRouteGroup.GET("/:campaign_id/recipients", ctrl.FindRecipients)
+		campaignRouteGroup.GET("/:campaign_id/stats", ctrl.Stats)
+	}
+}
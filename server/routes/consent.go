@@ -0,0 +1,23 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/supermario64bit/whatsapp_connect/server/controller"
+)
+
+// Includes all the routes for contact consent and the keywords contacts opt in or out with
+func mountConsentRoutes(r gin.IRouter) {
+	ctrl := controller.NewConsentController()
+
+	r.POST("/organisation/:id/contacts/:contact_id/consents", ctrl.Record)
+	r.GET("/organisation/:id/contacts/:contact_id/consents", ctrl.Find)
+
+	keywordRouteGroup := r.Group("/organisation/:id/consent-keywords")
+	{
+		keywordCtrl := controller.NewConsentKeywordController()
+
+		keywordRouteGroup.POST("", keywordCtrl.Create)
+		keywordRouteGroup.GET("", keywordCtrl.Find)
+		keywordRouteGroup.DELETE("/:keyword_id", keywordCtrl.DeleteByID)
+	}
+}
@@ -0,0 +1,26 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/supermario64bit/whatsapp_connect/server/controller"
+)
+
+// Includes all the routes for contacts of an organisation
+func mountContactRoutes(r gin.IRouter) {
+	contactRouteGroup := r.Group("/organisation/:id/contacts")
+	{
+		ctrl := controller.NewContactController()
+
+		contactRouteGroup.POST("", ctrl.Create)
+		contactRouteGroup.GET("", ctrl.Find)
+		contactRouteGroup.GET("/:contact_id", ctrl.FindByID)
+		contactRouteGroup.PATCH("/:contact_id", ctrl.PatchByID)
+		contactRouteGroup.DELETE("/:contact_id", ctrl.DeleteByID)
+
+		importCtrl := controller.NewContactImportController()
+
+		contactRouteGroup.POST("/import", importCtrl.Import)
+		contactRouteGroup.GET("/import/:job_id", importCtrl.FindByID)
+		contactRouteGroup.GET("/export", importCtrl.Export)
+	}
+}
@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/supermario64bit/whatsapp_connect/server/controller"
+)
+
+// Includes all the routes for conversations between an organisation's accounts and contacts
+func mountConversationRoutes(r gin.IRouter) {
+	conversationRouteGroup := r.Group("/organisation/:id/conversations")
+	{
+		ctrl := controller.NewConversationController()
+		messageCtrl := controller.NewMessageController()
+
+		conversationRouteGroup.GET("", ctrl.Find)
+		conversationRouteGroup.GET("/:conversation_id", ctrl.FindByID)
+		conversationRouteGroup.GET("/:conversation_id/messages", messageCtrl.FindByConversation)
+	}
+}
@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/supermario64bit/whatsapp_connect/server/controller"
+)
+
+// Includes all the routes for chatbot flows of an organisation
+func mountFlowRoutes(r gin.IRouter) {
+	flowRouteGroup := r.Group("/organisation/:id/flows")
+	{
+		ctrl := controller.NewFlowController()
+
+		flowRouteGroup.POST("", ctrl.Create)
+		flowRouteGroup.GET("", ctrl.Find)
+		flowRouteGroup.GET("/:flow_id", ctrl.FindByID)
+		flowRouteGroup.PATCH("/:flow_id", ctrl.PatchByID)
+		flowRouteGroup.DELETE("/:flow_id", ctrl.DeleteByID)
+		flowRouteGroup.GET("/:flow_id/sessions", ctrl.FindSessions)
+		flowRouteGroup.POST("/:flow_id/simulate", ctrl.Simulate)
+	}
+}
@@ -0,0 +1,23 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/supermario64bit/whatsapp_connect/server/controller"
+)
+
+// Includes all the routes for the shared team inbox of an organisation
+func mountInboxRoutes(r gin.IRouter) {
+	inboxRouteGroup := r.Group("/organisation/:id/inbox")
+	{
+		ctrl := controller.NewInboxController()
+
+		inboxRouteGroup.GET("", ctrl.Find)
+		inboxRouteGroup.POST("/:conversation_id/assign", ctrl.Assign)
+		inboxRouteGroup.POST("/:conversation_id/unassign", ctrl.Unassign)
+		inboxRouteGroup.POST("/:conversation_id/status", ctrl.SetStatus)
+		inboxRouteGroup.POST("/:conversation_id/read", ctrl.MarkRead)
+		inboxRouteGroup.POST("/:conversation_id/notes", ctrl.AddNote)
+		inboxRouteGroup.GET("/:conversation_id/notes", ctrl.FindNotes)
+		inboxRouteGroup.POST("/:conversation_id/reply", ctrl.Reply)
+	}
+}
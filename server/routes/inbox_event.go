@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/supermario64bit/whatsapp_connect/server/controller"
+)
+
+// Includes the realtime event stream of an organisation's inbox. The stream is mounted on its
+// own router, as it also takes tokens from the query string.
+func mountInboxEventRoutes(r gin.IRouter, stream gin.IRouter) {
+	ctrl := controller.NewInboxEventController()
+
+	eventRouteGroup := r.Group("/organisation/:id/events")
+	{
+		eventRouteGroup.POST("/token", ctrl.IssueToken)
+	}
+
+	streamRouteGroup := stream.Group("/organisation/:id/events")
+	{
+		streamRouteGroup.GET("", ctrl.Stream)
+	}
+}
@@ -0,0 +1,26 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/supermario64bit/whatsapp_connect/pkg/storage"
+	"github.com/supermario64bit/whatsapp_connect/server/controller"
+)
+
+// Includes all the routes for media files of an organisation
+func mountMediaRoutes(r gin.IRouter) {
+	mediaRouteGroup := r.Group("/organisation/:id/media")
+	{
+		ctrl := controller.NewMediaController()
+
+		mediaRouteGroup.POST("", ctrl.Upload)
+		mediaRouteGroup.GET("/:media_id", ctrl.FindByID)
+	}
+}
+
+// Includes the signed links to locally stored files. The signature authorises the download, so
+// these are reachable without a bearer token, e.g. by the WhatsApp Cloud API fetching media.
+func mountLocalFileRoutes(r gin.IRouter) {
+	ctrl := controller.NewMediaController()
+
+	r.GET(storage.LocalFilesPath+"*key", ctrl.ServeLocal)
+}
@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/supermario64bit/whatsapp_connect/server/controller"
+)
+
+// Includes all the routes for whatsapp messages of an organisation
+func mountMessageRoutes(r gin.IRouter) {
+	messageRouteGroup := r.Group("/organisation/:id/messages")
+	{
+		ctrl := controller.NewMessageController()
+
+		messageRouteGroup.POST("", ctrl.Send)
+		messageRouteGroup.POST("/text", ctrl.SendText)
+		messageRouteGroup.POST("/media", ctrl.SendMedia)
+		messageRouteGroup.GET("", ctrl.Find)
+		messageRouteGroup.GET("/:message_id", ctrl.FindByID)
+
+		r.GET("/organisation/:id/contacts/:contact_id/messages", ctrl.FindByContact)
+	}
+}
@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/supermario64bit/whatsapp_connect/server/controller"
+)
+
+// Includes the routes to inspect an organisation's send queue and retry dead jobs
+func mountMessageJobRoutes(r gin.IRouter) {
+	messageJobRouteGroup := r.Group("/organisation/:id/message-jobs")
+	{
+		ctrl := controller.NewMessageJobController()
+
+		messageJobRouteGroup.GET("", ctrl.Find)
+		messageJobRouteGroup.GET("/:job_id", ctrl.FindByID)
+		messageJobRouteGroup.POST("/:job_id/retry", ctrl.Retry)
+	}
+}
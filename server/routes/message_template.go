@@ -0,0 +1,21 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/supermario64bit/whatsapp_connect/server/controller"
+)
+
+// Includes all the routes for message templates of a whatsapp account
+func mountMessageTemplateRoutes(r gin.IRouter) {
+	templateRouteGroup := r.Group("/organisation/:id/whatsapp-accounts/:account_id/templates")
+	{
+		ctrl := controller.NewMessageTemplateController()
+
+		templateRouteGroup.POST("", ctrl.Create)
+		templateRouteGroup.GET("", ctrl.Find)
+		templateRouteGroup.POST("/sync", ctrl.Sync)
+		templateRouteGroup.GET("/:template_id", ctrl.FindByID)
+		templateRouteGroup.PATCH("/:template_id", ctrl.PatchByID)
+		templateRouteGroup.DELETE("/:template_id", ctrl.DeleteByID)
+	}
+}
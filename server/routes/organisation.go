@@ -6,7 +6,7 @@ import (
 )
 
 // Includes all the routes for organisation
-func mountOrganisationRoutes(r *gin.Engine) {
+func mountOrganisationRoutes(r gin.IRouter) {
 	orgRouteGroup := r.Group("/organisation")
 	{
 		ctrl := controller.NewOrganisationController()
@@ -15,6 +15,7 @@ func mountOrganisationRoutes(r *gin.Engine) {
 		orgRouteGroup.GET("", ctrl.Find)
 		orgRouteGroup.GET("/:id", ctrl.FindByID)
 		orgRouteGroup.PUT("/:id", ctrl.UpdateByID)
+		orgRouteGroup.PATCH("/:id", ctrl.PatchByID)
 		orgRouteGroup.DELETE("/:id", ctrl.DeleteByID)
 	}
 }
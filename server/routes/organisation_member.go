@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/supermario64bit/whatsapp_connect/server/controller"
+)
+
+// Includes all the routes for the users who are members of an organisation
+func mountOrganisationMemberRoutes(r gin.IRouter) {
+	memberRouteGroup := r.Group("/organisation/:id/members")
+	{
+		ctrl := controller.NewOrganisationMemberController()
+
+		memberRouteGroup.POST("", ctrl.Create)
+		memberRouteGroup.GET("", ctrl.Find)
+		memberRouteGroup.DELETE("/:user_id", ctrl.DeleteByUserID)
+	}
+}
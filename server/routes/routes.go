@@ -1,9 +1,37 @@
 package routes
 
-import "github.com/gin-gonic/gin"
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/supermario64bit/whatsapp_connect/server/controller"
+)
 
-// Register all http routes
-func MountHTTPRoutes(r *gin.Engine) {
+// Register all http routes. Everything but the routes called by Meta and the signed file links
+// requires a bearer token, and the routes of an organisation require its membership too.
+func MountHTTPRoutes(engine *gin.Engine) {
+	mountWebhookRoutes(engine)
+	mountLocalFileRoutes(engine)
+
+	r := engine.Group("", controller.Authenticate(), controller.RequireMember())
+	stream := engine.Group("", controller.AuthenticateStream(), controller.RequireMember())
 	mountOrganisationRoutes(r)
 	mountUserRoutes(r)
+	mountOrganisationMemberRoutes(r)
+	mountAuditRoutes(r)
+	mountWhatsAppAccountRoutes(r)
+	mountMessageTemplateRoutes(r)
+	mountContactRoutes(r)
+	mountConsentRoutes(r)
+	mountTagRoutes(r)
+	mountSegmentRoutes(r)
+	mountMediaRoutes(r)
+	mountMessageRoutes(r)
+	mountMessageJobRoutes(r)
+	mountCampaignRoutes(r)
+	mountAutoReplyRuleRoutes(r)
+	mountFlowRoutes(r)
+	mountBusinessHoursRoutes(r)
+	mountWebhookSubscriptionRoutes(r)
+	mountConversationRoutes(r)
+	mountInboxRoutes(r)
+	mountInboxEventRoutes(r, stream)
 }
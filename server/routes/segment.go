@@ -0,0 +1,22 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/supermario64bit/whatsapp_connect/server/controller"
+)
+
+// Includes all the routes for contact segments of an organisation
+func mountSegmentRoutes(r gin.IRouter) {
+	segmentRouteGroup := r.Group("/organisation/:id/segments")
+	{
+		ctrl := controller.NewSegmentController()
+
+		segmentRouteGroup.POST("", ctrl.Create)
+		segmentRouteGroup.GET("", ctrl.Find)
+		segmentRouteGroup.POST("/preview", ctrl.Preview)
+		segmentRouteGroup.GET("/:segment_id", ctrl.FindByID)
+		segmentRouteGroup.PATCH("/:segment_id", ctrl.PatchByID)
+		segmentRouteGroup.DELETE("/:segment_id", ctrl.DeleteByID)
+		segmentRouteGroup.GET("/:segment_id/preview", ctrl.PreviewByID)
+	}
+}
@@ -0,0 +1,21 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/supermario64bit/whatsapp_connect/server/controller"
+)
+
+// Includes all the routes for contact tags of an organisation
+func mountTagRoutes(r gin.IRouter) {
+	tagRouteGroup := r.Group("/organisation/:id/tags")
+	{
+		ctrl := controller.NewTagController()
+
+		tagRouteGroup.POST("", ctrl.Create)
+		tagRouteGroup.GET("", ctrl.Find)
+		tagRouteGroup.GET("/:tag_id", ctrl.FindByID)
+		tagRouteGroup.DELETE("/:tag_id", ctrl.DeleteByID)
+		tagRouteGroup.POST("/:tag_id/assign", ctrl.Assign)
+		tagRouteGroup.POST("/:tag_id/unassign", ctrl.Unassign)
+	}
+}
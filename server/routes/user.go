@@ -6,7 +6,7 @@ import (
 )
 
 // Includes all the routes for organisation
-func mountUserRoutes(r *gin.Engine) {
+func mountUserRoutes(r gin.IRouter) {
 	userRouteGroup := r.Group("/user")
 	{
 		ctrl := controller.NewUserController()
@@ -15,6 +15,7 @@ func mountUserRoutes(r *gin.Engine) {
 		userRouteGroup.GET("", ctrl.Find)
 		userRouteGroup.GET("/:id", ctrl.FindByID)
 		userRouteGroup.PUT("/:id", ctrl.UpdateByID)
+		userRouteGroup.PATCH("/:id", ctrl.PatchByID)
 		userRouteGroup.DELETE("/:id", ctrl.DeleteByID)
 	}
 }
@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/supermario64bit/whatsapp_connect/server/controller"
+)
+
+// Includes the callback routes registered with the WhatsApp Cloud API
+func mountWebhookRoutes(r gin.IRouter) {
+	webhookRouteGroup := r.Group("/webhook")
+	{
+		ctrl := controller.NewWebhookController()
+
+		webhookRouteGroup.GET("/whatsapp", ctrl.Verify)
+		webhookRouteGroup.POST("/whatsapp", ctrl.Receive)
+	}
+}
@@ -0,0 +1,24 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/supermario64bit/whatsapp_connect/server/controller"
+)
+
+// Includes all the routes for outgoing webhooks of an organisation and their delivery logs
+func mountWebhookSubscriptionRoutes(r gin.IRouter) {
+	webhookRouteGroup := r.Group("/organisation/:id/webhooks")
+	{
+		ctrl := controller.NewWebhookSubscriptionController()
+		deliveryCtrl := controller.NewWebhookDeliveryController()
+
+		webhookRouteGroup.POST("", ctrl.Create)
+		webhookRouteGroup.GET("", ctrl.Find)
+		webhookRouteGroup.GET("/:webhook_id", ctrl.FindByID)
+		webhookRouteGroup.PATCH("/:webhook_id", ctrl.PatchByID)
+		webhookRouteGroup.DELETE("/:webhook_id", ctrl.DeleteByID)
+		webhookRouteGroup.GET("/:webhook_id/deliveries", deliveryCtrl.Find)
+		webhookRouteGroup.GET("/:webhook_id/deliveries/:delivery_id", deliveryCtrl.FindByID)
+		webhookRouteGroup.POST("/:webhook_id/deliveries/:delivery_id/redeliver", deliveryCtrl.Redeliver)
+	}
+}
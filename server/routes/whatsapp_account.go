@@ -0,0 +1,21 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/supermario64bit/whatsapp_connect/server/controller"
+)
+
+// Includes all the routes for whatsapp accounts of an organisation
+func mountWhatsAppAccountRoutes(r gin.IRouter) {
+	accountRouteGroup := r.Group("/organisation/:id/whatsapp-accounts")
+	{
+		ctrl := controller.NewWhatsAppAccountController()
+
+		accountRouteGroup.POST("", ctrl.Create)
+		accountRouteGroup.GET("", ctrl.Find)
+		accountRouteGroup.GET("/:account_id", ctrl.FindByID)
+		accountRouteGroup.PUT("/:account_id/limits", ctrl.UpdateLimits)
+		accountRouteGroup.GET("/:account_id/usage", ctrl.Usage)
+		accountRouteGroup.DELETE("/:account_id", ctrl.DeleteByID)
+	}
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"reflect"
+
+	"github.com/supermario64bit/whatsapp_connect/pkg/logger"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+	"github.com/supermario64bit/whatsapp_connect/server/repository"
+	"github.com/supermario64bit/whatsapp_connect/types"
+)
+
+// Fields which change on every write and would only add noise to a diff
+var ignoredAuditFields = map[string]bool{
+	"updated_at": true,
+	"version":    true,
+}
+
+type auditService struct {
+	repo repository.AuditLogRepository
+}
+
+type AuditService interface {
+	Record(actorID uint64, orgID *uint64, entityType string, entityID uint64, action string, before interface{}, after interface{})
+	RecordEvent(eventID uint64, actorID uint64, orgID *uint64, entityType string, entityID uint64, action string, before interface{}, after interface{}) error
+	Find(filter *model.AuditLogFilter) ([]*model.AuditLog, *model.Pagination, *types.ApplicationError)
+}
+
+func NewAuditService() AuditService {
+	return &auditService{
+		repo: repository.NewAuditLogRepository(),
+	}
+}
+
+// Records a mutation. Pass nil for before on create and nil for after on delete.
+// Failures are logged rather than returned so that auditing never blocks the mutation itself.
+func (svc *auditService) Record(actorID uint64, orgID *uint64, entityType string, entityID uint64, action string, before interface{}, after interface{}) {
+	err := svc.write(nil, actorID, orgID, entityType, entityID, action, before, after)
+	if err != nil {
+		logger.Warning("Unable to record audit log for " + entityType + ". Error: " + err.Error())
+	}
+}
+
+// Records the mutation an outbox event reports, returning failures so the relay retries.
+// An event already audited is not recorded again.
+func (svc *auditService) RecordEvent(eventID uint64, actorID uint64, orgID *uint64, entityType string, entityID uint64, action string, before interface{}, after interface{}) error {
+	err := svc.write(&eventID, actorID, orgID, entityType, entityID, action, before, after)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	return err
+}
+
+func (svc *auditService) write(eventID *uint64, actorID uint64, orgID *uint64, entityType string, entityID uint64, action string, before interface{}, after interface{}) error {
+	diff, err := diffEntities(before, after)
+	if err != nil {
+		return err
+	}
+
+	entry := &model.AuditLog{
+		OrganisationID: orgID,
+		EntityType:     entityType,
+		EntityID:       entityID,
+		Action:         action,
+		Diff:           diff,
+		OutboxEventID:  eventID,
+	}
+	if actorID > 0 {
+		entry.ActorID = &actorID
+	}
+
+	_, err = svc.repo.Create(entry)
+	return err
+}
+
+func (svc *auditService) Find(filter *model.AuditLogFilter) ([]*model.AuditLog, *model.Pagination, *types.ApplicationError) {
+	filter.Pagination.Normalise()
+
+	entries, total, err := svc.repo.Find(filter)
+	if err != nil {
+		return nil, nil, databaseError("Unable to find audit logs", err)
+	}
+
+	page := filter.Pagination
+	page.Total = total
+	return entries, &page, nil
+}
+
+// Builds a {"field": {"before": x, "after": y}} object holding only the fields that differ
+func diffEntities(before interface{}, after interface{}) (json.RawMessage, error) {
+	beforeFields, err := toFieldMap(before)
+	if err != nil {
+		return nil, err
+	}
+
+	afterFields, err := toFieldMap(after)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := map[string]map[string]interface{}{}
+	for field, value := range beforeFields {
+		if ignoredAuditFields[field] {
+			continue
+		}
+		if afterValue, ok := afterFields[field]; ok && reflect.DeepEqual(value, afterValue) {
+			continue
+		}
+		diff[field] = map[string]interface{}{"before": value, "after": afterFields[field]}
+	}
+
+	for field, value := range afterFields {
+		if ignoredAuditFields[field] {
+			continue
+		}
+		if _, ok := beforeFields[field]; ok {
+			continue
+		}
+		diff[field] = map[string]interface{}{"before": nil, "after": value}
+	}
+
+	return json.Marshal(diff)
+}
+
+func toFieldMap(entity interface{}) (map[string]interface{}, error) {
+	fields := map[string]interface{}{}
+	if entity == nil {
+		return fields, nil
+	}
+	if v := reflect.ValueOf(entity); v.Kind() == reflect.Ptr && v.IsNil() {
+		return fields, nil
+	}
+
+	raw, err := json.Marshal(entity)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(raw, &fields)
+	return fields, err
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/supermario64bit/whatsapp_connect/pkg/authtoken"
+	"github.com/supermario64bit/whatsapp_connect/pkg/logger"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+	"github.com/supermario64bit/whatsapp_connect/types"
+)
+
+const (
+	// Shortest AUTH_TOKEN_SECRET accepted. A shorter secret could be guessed offline from a single
+	// token.
+	minAuthTokenSecretLength = 32
+	// Scope of the tokens browsers open an organisation's event stream with
+	authScopeEvents = "events"
+	// Stream tokens travel in the URL, where proxies and access logs may keep them, so they are
+	// only good for opening the stream shortly after being issued
+	streamTokenTTL = 5 * time.Minute
+)
+
+var errAuthNotConfigured = errors.New("AUTH_TOKEN_SECRET is not set")
+
+type authService struct {
+	secret []byte
+}
+
+type AuthService interface {
+	Authenticate(token string) (uint64, *types.ApplicationError)
+	IssueStreamToken(orgID uint64, userID uint64) (*model.StreamToken, *types.ApplicationError)
+	AuthenticateStream(token string, orgID uint64) (uint64, *types.ApplicationError)
+}
+
+// Verifies bearer tokens signed with AUTH_TOKEN_SECRET. Without a usable secret every token is
+// refused, rather than letting callers through unidentified.
+func NewAuthService() AuthService {
+	secret := []byte(os.Getenv("AUTH_TOKEN_SECRET"))
+	if len(secret) < minAuthTokenSecretLength {
+		logger.Warning("AUTH_TOKEN_SECRET is not set or shorter than 32 bytes. All API requests will be refused.")
+		secret = nil
+	}
+
+	return &authService{secret: secret}
+}
+
+// Returns the user an API token identifies
+func (svc *authService) Authenticate(token string) (uint64, *types.ApplicationError) {
+	claims, appErr := svc.verify(token)
+	if appErr != nil {
+		return 0, appErr
+	}
+
+	if claims.Scope != "" {
+		return 0, types.NewUnauthorizedError("Invalid Token", fmt.Errorf("A token scoped to %s cannot be used as an API token", claims.Scope))
+	}
+
+	return claims.UserID, nil
+}
+
+// Issues a token opening the organisation's event stream as the user, for clients which cannot
+// send headers, like browsers using EventSource
+func (svc *authService) IssueStreamToken(orgID uint64, userID uint64) (*model.StreamToken, *types.ApplicationError) {
+	if svc.secret == nil {
+		return nil, types.NewUnauthorizedError("Authentication Unavailable", errAuthNotConfigured)
+	}
+
+	expiresAt := time.Now().Add(streamTokenTTL).Truncate(time.Second)
+	token, err := authtoken.Sign(svc.secret, authtoken.Claims{
+		UserID:         userID,
+		ExpiresAt:      expiresAt.Unix(),
+		Scope:          authScopeEvents,
+		OrganisationID: orgID,
+	})
+	if err != nil {
+		return nil, types.NewInternalError("Unable to issue stream token", err)
+	}
+
+	return &model.StreamToken{Token: token, ExpiresAt: expiresAt}, nil
+}
+
+// Returns the user a stream token for the organisation identifies
+func (svc *authService) AuthenticateStream(token string, orgID uint64) (uint64, *types.ApplicationError) {
+	claims, appErr := svc.verify(token)
+	if appErr != nil {
+		return 0, appErr
+	}
+
+	if claims.Scope != authScopeEvents || claims.OrganisationID != orgID {
+		return 0, types.NewUnauthorizedError("Invalid Token", errors.New("The token does not open this organisation's event stream"))
+	}
+
+	return claims.UserID, nil
+}
+
+func (svc *authService) verify(token string) (*authtoken.Claims, *types.ApplicationError) {
+	if svc.secret == nil {
+		return nil, types.NewUnauthorizedError("Authentication Unavailable", errAuthNotConfigured)
+	}
+	if token == "" {
+		return nil, types.NewUnauthorizedError("Authentication Required", errors.New("No token was sent"))
+	}
+
+	claims, err := authtoken.Verify(svc.secret, token, time.Now())
+	if err != nil {
+		return nil, types.NewUnauthorizedError("Invalid Token", err)
+	}
+
+	return claims, nil
+}
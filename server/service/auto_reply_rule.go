@@ -0,0 +1,284 @@
+package service
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+	"github.com/supermario64bit/whatsapp_connect/server/repository"
+	"github.com/supermario64bit/whatsapp_connect/types"
+)
+
+type autoReplyRuleService struct {
+	repo         repository.AutoReplyRuleRepository
+	templateRepo repository.MessageTemplateRepository
+	contactRepo  repository.ContactRepository
+	accounts     WhatsAppAccountService
+	messages     MessageService
+	audit        AuditService
+}
+
+type AutoReplyRuleService interface {
+	Create(orgID uint64, rule *model.AutoReplyRule, actorID uint64) (*model.AutoReplyRule, *types.ApplicationError)
+	Find(orgID uint64) ([]*model.AutoReplyRule, *types.ApplicationError)
+	FindByID(orgID uint64, id uint64) (*model.AutoReplyRule, *types.ApplicationError)
+	PatchByID(orgID uint64, patch []byte, id uint64, version uint64, actorID uint64) (*model.AutoReplyRule, *types.ApplicationError)
+	DeleteByID(orgID uint64, id uint64, version uint64, actorID uint64) *types.ApplicationError
+	Test(orgID uint64, test *model.AutoReplyTest) (*model.AutoReplyTestResult, *types.ApplicationError)
+	HandleInbound(account *model.WhatsAppAccount, contact *model.Contact, message *model.Message) *types.ApplicationError
+}
+
+func NewAutoReplyRuleService() AutoReplyRuleService {
+	return &autoReplyRuleService{
+		repo:         repository.NewAutoReplyRuleRepository(),
+		templateRepo: repository.NewMessageTemplateRepository(),
+		contactRepo:  repository.NewContactRepository(),
+		accounts:     NewWhatsAppAccountService(),
+		messages:     NewMessageService(),
+		audit:        NewAuditService(),
+	}
+}
+
+func (svc *autoReplyRuleService) Create(orgID uint64, rule *model.AutoReplyRule, actorID uint64) (*model.AutoReplyRule, *types.ApplicationError) {
+	rule.OrganisationID = orgID
+	rule.Normalise()
+	details := rule.ValidateFields()
+	if len(details) > 0 {
+		return nil, types.NewFieldValidationError(details...)
+	}
+
+	appErr := svc.checkReferences(rule)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	new, err := svc.repo.Create(rule)
+	if err != nil {
+		return nil, databaseError("Unable to create auto reply rule", err)
+	}
+
+	svc.audit.Record(actorID, &orgID, model.AuditEntityAutoReplyRule, new.ID, model.AuditActionCreate, nil, new)
+
+	return new, nil
+}
+
+func (svc *autoReplyRuleService) Find(orgID uint64) ([]*model.AutoReplyRule, *types.ApplicationError) {
+	ruleSet, err := svc.repo.Find(orgID)
+	if err != nil {
+		return nil, databaseError("Unable to find auto reply rules", err)
+	}
+	return ruleSet, nil
+}
+
+func (svc *autoReplyRuleService) FindByID(orgID uint64, id uint64) (*model.AutoReplyRule, *types.ApplicationError) {
+	rule, err := svc.repo.FindByID(orgID, id)
+	if err != nil {
+		return nil, databaseError("Unable to find auto reply rule by id", err)
+	}
+	return rule, nil
+}
+
+// Applies an RFC 7396 merge patch. Only the fields present in the patch are validated and
+// written, and a patch which changes nothing returns the current rule untouched.
+func (svc *autoReplyRuleService) PatchByID(orgID uint64, patch []byte, id uint64, version uint64, actorID uint64) (*model.AutoReplyRule, *types.ApplicationError) {
+	current, err := svc.repo.FindByID(orgID, id)
+	if err != nil {
+		return nil, databaseError("Unable to update auto reply rule", err)
+	}
+
+	if version > 0 && current.Version != version {
+		return nil, databaseError("Unable to update auto reply rule", repository.ErrVersionMismatch)
+	}
+
+	var patched model.AutoReplyRule
+	fields, appErr := applyMergePatch(current, patch, &patched)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	patched.Normalise()
+	details := patched.ValidatePartial(fields)
+	if len(details) > 0 {
+		return nil, types.NewFieldValidationError(details...)
+	}
+
+	changes := changedColumns(current, &patched, fields)
+	if len(changes) == 0 {
+		return current, nil
+	}
+
+	appErr = svc.checkReferences(&patched)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	updatedRule, err := svc.repo.PatchByID(id, current.Version, changes)
+	if err != nil {
+		return nil, databaseError("Unable to update auto reply rule", err)
+	}
+
+	svc.audit.Record(actorID, &orgID, model.AuditEntityAutoReplyRule, updatedRule.ID, model.AuditActionUpdate, current, updatedRule)
+
+	return updatedRule, nil
+}
+
+func (svc *autoReplyRuleService) DeleteByID(orgID uint64, id uint64, version uint64, actorID uint64) *types.ApplicationError {
+	before, err := svc.repo.FindByID(orgID, id)
+	if err != nil {
+		return databaseError("Unable to delete auto reply rule", err)
+	}
+
+	err = svc.repo.DeleteByID(orgID, id, version)
+	if err != nil {
+		return databaseError("Unable to delete auto reply rule", err)
+	}
+
+	svc.audit.Record(actorID, &orgID, model.AuditEntityAutoReplyRule, id, model.AuditActionDelete, before, nil)
+
+	return nil
+}
+
+// Runs a message through the organisation's rules the way an inbound message is, returning the
+// reply it would get without sending it
+func (svc *autoReplyRuleService) Test(orgID uint64, test *model.AutoReplyTest) (*model.AutoReplyTestResult, *types.ApplicationError) {
+	test.Normalise()
+	validationErrors := test.ValidateFields()
+	if len(validationErrors) > 0 {
+		return nil, types.NewValidationError(validationErrors)
+	}
+
+	at := time.Now()
+	if test.At != nil {
+		at = *test.At
+	}
+
+	contact := &model.Contact{}
+	if test.ContactID > 0 {
+		found, err := svc.contactRepo.FindByID(orgID, test.ContactID)
+		if err == sql.ErrNoRows {
+			return nil, types.NewFieldValidationError(types.FieldError{Field: "contact_id", Rule: "exists", Message: "must refer to a contact of the organisation"})
+		}
+		if err != nil {
+			return nil, databaseError("Unable to find contact by id", err)
+		}
+		contact = found
+	}
+
+	var rules []*model.AutoReplyRule
+	var err error
+	if test.WhatsAppAccountID > 0 {
+		rules, err = svc.repo.FindEnabled(orgID, test.WhatsAppAccountID)
+	} else {
+		rules, err = svc.repo.Find(orgID)
+	}
+	if err != nil {
+		return nil, databaseError("Unable to find auto reply rules", err)
+	}
+
+	result := &model.AutoReplyTestResult{}
+	rule := selectAutoReplyRule(rules, test.Text, at)
+	if rule == nil {
+		return result, nil
+	}
+
+	result.Matched = true
+	result.Rule = rule
+	switch rule.Response.Type {
+	case model.AutoReplyResponseText:
+		result.Text = rule.Response.Text
+	case model.AutoReplyResponseTemplate:
+		req := rule.Response.Template.Resolve(contact)
+		result.Template = &req
+	}
+
+	return result, nil
+}
+
+// Replies to an inbound text or button reply with the first active rule it matches. Contacts
+// who opted out get no reply.
+func (svc *autoReplyRuleService) HandleInbound(account *model.WhatsAppAccount, contact *model.Contact, message *model.Message) *types.ApplicationError {
+	if message.Type != model.MessageTypeText && message.Type != model.MessageTypeButton {
+		return nil
+	}
+	if contact.OptInStatus == model.OptInStatusOptedOut {
+		return nil
+	}
+
+	rules, err := svc.repo.FindEnabled(account.OrganisationID, account.ID)
+	if err != nil {
+		return databaseError("Unable to find auto reply rules", err)
+	}
+
+	rule := selectAutoReplyRule(rules, message.Body, message.CreatedAt)
+	if rule == nil {
+		return nil
+	}
+
+	var appErr *types.ApplicationError
+	switch rule.Response.Type {
+	case model.AutoReplyResponseText:
+		_, appErr = svc.messages.SendText(account.OrganisationID, &model.SendTextRequest{
+			WhatsAppAccountID: account.ID,
+			To:                contact.PhoneNumber,
+			Text:              model.TextMessageRequest{Body: rule.Response.Text},
+		})
+	case model.AutoReplyResponseTemplate:
+		_, appErr = svc.messages.SendTemplate(account.OrganisationID, &model.SendTemplateRequest{
+			WhatsAppAccountID: account.ID,
+			To:                contact.PhoneNumber,
+			Template:          rule.Response.Template.Resolve(contact),
+		}, 0)
+	}
+
+	return appErr
+}
+
+// Checks the account the rule is limited to, and the template it replies with, belong to the
+// organisation
+func (svc *autoReplyRuleService) checkReferences(rule *model.AutoReplyRule) *types.ApplicationError {
+	if rule.WhatsAppAccountID == nil {
+		return nil
+	}
+
+	account, appErr := svc.accounts.FindByID(rule.OrganisationID, *rule.WhatsAppAccountID)
+	if appErr != nil {
+		if appErr.Code == types.CodeNotFound {
+			return types.NewFieldValidationError(types.FieldError{Field: "whatsapp_account_id", Rule: "exists", Message: "must refer to an account of the organisation"})
+		}
+		return appErr
+	}
+
+	if rule.Response.Type != model.AutoReplyResponseTemplate {
+		return nil
+	}
+
+	tpl, err := svc.templateRepo.FindByName(account.ID, rule.Response.Template.Name, rule.Response.Template.Language)
+	if err == sql.ErrNoRows {
+		return types.NewFieldValidationError(types.FieldError{Field: "response.template.name", Rule: "exists", Message: "must name a template of the account in the given language"})
+	}
+	if err != nil {
+		return databaseError("Unable to find message template", err)
+	}
+
+	// Every contact gets the same number of parameters, so checking a stand-in is enough
+	_, _, details := buildTemplatePayload(tpl, sampleTemplateRequest(rule.Response.Template))
+	if len(details) > 0 {
+		for i := range details {
+			details[i].Field = "response." + details[i].Field
+		}
+		return types.NewFieldValidationError(details...)
+	}
+
+	return nil
+}
+
+// Returns the first rule active at the given time whose pattern the text matches. Rules are
+// expected in priority order.
+func selectAutoReplyRule(rules []*model.AutoReplyRule, text string, at time.Time) *model.AutoReplyRule {
+	for _, rule := range rules {
+		if rule.ActiveAt(at) && rule.Matches(text) {
+			return rule
+		}
+	}
+	return nil
+}
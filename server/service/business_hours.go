@@ -0,0 +1,130 @@
+package service
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/supermario64bit/whatsapp_connect/pkg/logger"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+	"github.com/supermario64bit/whatsapp_connect/server/repository"
+	"github.com/supermario64bit/whatsapp_connect/types"
+)
+
+type businessHoursService struct {
+	repo     repository.BusinessHoursRepository
+	messages MessageService
+	audit    AuditService
+}
+
+type BusinessHoursService interface {
+	Find(orgID uint64) (*model.BusinessHours, *types.ApplicationError)
+	Update(orgID uint64, hours *model.BusinessHours, version uint64, actorID uint64) (*model.BusinessHours, *types.ApplicationError)
+	Status(orgID uint64, at time.Time) (*model.BusinessHoursStatus, *types.ApplicationError)
+	HandleInbound(account *model.WhatsAppAccount, contact *model.Contact, message *model.Message) *types.ApplicationError
+}
+
+func NewBusinessHoursService() BusinessHoursService {
+	return &businessHoursService{
+		repo:     repository.NewBusinessHoursRepository(),
+		messages: NewMessageService(),
+		audit:    NewAuditService(),
+	}
+}
+
+// Returns the organisation's hours, or the defaults when it has not set any. Defaults have
+// version 0.
+func (svc *businessHoursService) Find(orgID uint64) (*model.BusinessHours, *types.ApplicationError) {
+	hours, err := svc.repo.FindByOrganisation(orgID)
+	if err == sql.ErrNoRows {
+		return model.DefaultBusinessHours(orgID), nil
+	}
+	if err != nil {
+		return nil, databaseError("Unable to find business hours", err)
+	}
+	return hours, nil
+}
+
+// Replaces the organisation's hours as a whole, creating them the first time
+func (svc *businessHoursService) Update(orgID uint64, hours *model.BusinessHours, version uint64, actorID uint64) (*model.BusinessHours, *types.ApplicationError) {
+	hours.OrganisationID = orgID
+	hours.Normalise()
+	details := hours.ValidateFields()
+	if len(details) > 0 {
+		return nil, types.NewFieldValidationError(details...)
+	}
+
+	before, err := svc.repo.FindByOrganisation(orgID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, databaseError("Unable to update business hours", err)
+	}
+
+	saved, err := svc.repo.Save(hours, version)
+	if err != nil {
+		return nil, databaseError("Unable to update business hours", err)
+	}
+
+	if before == nil {
+		svc.audit.Record(actorID, &orgID, model.AuditEntityBusinessHours, orgID, model.AuditActionCreate, nil, saved)
+	} else {
+		svc.audit.Record(actorID, &orgID, model.AuditEntityBusinessHours, orgID, model.AuditActionUpdate, before, saved)
+	}
+
+	return saved, nil
+}
+
+func (svc *businessHoursService) Status(orgID uint64, at time.Time) (*model.BusinessHoursStatus, *types.ApplicationError) {
+	hours, appErr := svc.Find(orgID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	status := hours.StatusAt(at)
+	return &status, nil
+}
+
+// Sends the away message to contacts writing outside the hours, and the greeting to contacts
+// writing during them, unless the contact got the same message within its interval
+func (svc *businessHoursService) HandleInbound(account *model.WhatsAppAccount, contact *model.Contact, message *model.Message) *types.ApplicationError {
+	if contact.OptInStatus == model.OptInStatusOptedOut {
+		return nil
+	}
+
+	hours, err := svc.repo.FindByOrganisation(account.OrganisationID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return databaseError("Unable to find business hours", err)
+	}
+
+	kind, autoMessage := hours.MessageAt(message.CreatedAt)
+	if autoMessage == nil {
+		return nil
+	}
+
+	// Postgres keeps microseconds, the claim is released by the time it stored
+	now := time.Now().Truncate(time.Microsecond)
+	claimed, err := svc.repo.ClaimDelivery(contact.ID, kind, now, autoMessage.Interval())
+	if err != nil {
+		return databaseError("Unable to record "+kind+" message", err)
+	}
+	if !claimed {
+		return nil
+	}
+
+	_, appErr := svc.messages.SendText(account.OrganisationID, &model.SendTextRequest{
+		WhatsAppAccountID: account.ID,
+		To:                contact.PhoneNumber,
+		Text:              model.TextMessageRequest{Body: autoMessage.Text},
+	})
+	if appErr != nil {
+		err = svc.repo.ReleaseDelivery(contact.ID, kind, now)
+		if err != nil {
+			logger.Warning(fmt.Sprintf("Unable to forget unsent %s message to contact %d, it is held back for its interval. Error: %s", kind, contact.ID, err.Error()))
+		}
+		return appErr
+	}
+
+	return nil
+}
@@ -0,0 +1,366 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/supermario64bit/whatsapp_connect/pkg/logger"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+	"github.com/supermario64bit/whatsapp_connect/server/repository"
+	"github.com/supermario64bit/whatsapp_connect/types"
+)
+
+const (
+	// How often the worker looks for campaigns which are due
+	campaignPollInterval = 15 * time.Second
+	// How long a worker holds a campaign without checking in before another may take it over
+	campaignLease = 5 * time.Minute
+	// Recipients sent to between checks for cancellation
+	campaignBatchSize = 100
+)
+
+type campaignService struct {
+	repo         repository.CampaignRepository
+	recipients   repository.CampaignRecipientRepository
+	segmentRepo  repository.SegmentRepository
+	templateRepo repository.MessageTemplateRepository
+	contactRepo  repository.ContactRepository
+	accounts     WhatsAppAccountService
+	messages     MessageService
+	audit        AuditService
+}
+
+type CampaignService interface {
+	Create(orgID uint64, campaign *model.Campaign, actorID uint64) (*model.Campaign, *types.ApplicationError)
+	Find(orgID uint64, page *model.Pagination) ([]*model.Campaign, *model.Pagination, *types.ApplicationError)
+	FindByID(orgID uint64, id uint64) (*model.Campaign, *types.ApplicationError)
+	Schedule(orgID uint64, id uint64, schedule *model.CampaignSchedule, actorID uint64) (*model.Campaign, *types.ApplicationError)
+	Cancel(orgID uint64, id uint64, actorID uint64) (*model.Campaign, *types.ApplicationError)
+	FindRecipients(orgID uint64, id uint64, filter *model.CampaignRecipientFilter) ([]*model.CampaignRecipient, *model.Pagination, *types.ApplicationError)
+	Stats(orgID uint64, id uint64) (*model.CampaignStats, *types.ApplicationError)
+	Run()
+}
+
+func NewCampaignService() CampaignService {
+	return &campaignService{
+		repo:         repository.NewCampaignRepository(),
+		recipients:   repository.NewCampaignRecipientRepository(),
+		segmentRepo:  repository.NewSegmentRepository(),
+		templateRepo: repository.NewMessageTemplateRepository(),
+		contactRepo:  repository.NewContactRepository(),
+		accounts:     NewWhatsAppAccountService(),
+		messages:     NewMessageService(),
+		audit:        NewAuditService(),
+	}
+}
+
+// Creates a campaign after checking its account, segment and template. A campaign given a send
+// time is scheduled right away, otherwise it is kept as a draft.
+func (svc *campaignService) Create(orgID uint64, campaign *model.Campaign, actorID uint64) (*model.Campaign, *types.ApplicationError) {
+	campaign.OrganisationID = orgID
+	campaign.Normalise()
+	details := campaign.ValidateFields()
+	if len(details) > 0 {
+		return nil, types.NewFieldValidationError(details...)
+	}
+
+	account, appErr := svc.accounts.FindByID(orgID, campaign.WhatsAppAccountID)
+	if appErr != nil {
+		if appErr.Code == types.CodeNotFound {
+			return nil, types.NewFieldValidationError(types.FieldError{Field: "whatsapp_account_id", Rule: "exists", Message: "must refer to an account of the organisation"})
+		}
+		return nil, appErr
+	}
+	if account.Status != model.WhatsAppAccountStatusActive {
+		return nil, types.NewFieldValidationError(types.FieldError{Field: "whatsapp_account_id", Rule: "active", Message: "must refer to an active account"})
+	}
+
+	_, err := svc.segmentRepo.FindByID(orgID, campaign.SegmentID)
+	if err == sql.ErrNoRows {
+		return nil, types.NewFieldValidationError(types.FieldError{Field: "segment_id", Rule: "exists", Message: "must refer to a segment of the organisation"})
+	}
+	if err != nil {
+		return nil, databaseError("Unable to find segment", err)
+	}
+
+	tpl, err := svc.templateRepo.FindByName(account.ID, campaign.Template.Name, campaign.Template.Language)
+	if err == sql.ErrNoRows {
+		return nil, types.NewFieldValidationError(types.FieldError{Field: "template.name", Rule: "exists", Message: "must name a template of the sending account in the given language"})
+	}
+	if err != nil {
+		return nil, databaseError("Unable to find message template", err)
+	}
+
+	if tpl.Status != model.TemplateStatusApproved {
+		return nil, types.NewConflictError("Unable to create campaign", fmt.Errorf("Template %s is %s. Only approved templates can be sent", tpl.Name, tpl.Status))
+	}
+
+	// Every recipient gets the same number of parameters, so checking a stand-in is enough
+	_, _, details = buildTemplatePayload(tpl, sampleTemplateRequest(&campaign.Template))
+	if len(details) > 0 {
+		return nil, types.NewFieldValidationError(details...)
+	}
+
+	campaign.TemplateID = tpl.ID
+	campaign.Status = model.CampaignStatusDraft
+	if campaign.SendAt != "" {
+		at, err := model.CampaignSchedule{SendAt: campaign.SendAt, Timezone: campaign.Timezone}.Time(time.Now())
+		if err != nil {
+			return nil, types.NewFieldValidationError(types.FieldError{Field: "send_at", Rule: "datetime", Message: "must be a local time such as 2025-01-31T09:30"})
+		}
+		campaign.Status = model.CampaignStatusScheduled
+		campaign.ScheduledAt = &at
+	}
+
+	new, err := svc.repo.Create(campaign)
+	if err != nil {
+		return nil, databaseError("Unable to create campaign", err)
+	}
+
+	svc.audit.Record(actorID, &orgID, model.AuditEntityCampaign, new.ID, model.AuditActionCreate, nil, new)
+
+	return new, nil
+}
+
+func (svc *campaignService) Find(orgID uint64, page *model.Pagination) ([]*model.Campaign, *model.Pagination, *types.ApplicationError) {
+	page.Normalise()
+
+	campaignSet, total, err := svc.repo.Find(orgID, page)
+	if err != nil {
+		return nil, nil, databaseError("Unable to find campaigns", err)
+	}
+
+	result := *page
+	result.Total = total
+	return campaignSet, &result, nil
+}
+
+func (svc *campaignService) FindByID(orgID uint64, id uint64) (*model.Campaign, *types.ApplicationError) {
+	campaign, err := svc.repo.FindByID(orgID, id)
+	if err != nil {
+		return nil, databaseError("Unable to find campaign by id", err)
+	}
+	return campaign, nil
+}
+
+// Sets when a draft or scheduled campaign is sent. A send time in the past sends it right away.
+func (svc *campaignService) Schedule(orgID uint64, id uint64, schedule *model.CampaignSchedule, actorID uint64) (*model.Campaign, *types.ApplicationError) {
+	validationErrors := schedule.ValidateFields()
+	if len(validationErrors) > 0 {
+		return nil, types.NewValidationError(validationErrors)
+	}
+
+	at, err := schedule.Time(time.Now())
+	if err != nil {
+		return nil, types.NewFieldValidationError(types.FieldError{Field: "send_at", Rule: "datetime", Message: "must be a local time such as 2025-01-31T09:30"})
+	}
+
+	current, appErr := svc.FindByID(orgID, id)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	timezone := schedule.Timezone
+	if timezone == "" {
+		timezone = current.Timezone
+	}
+
+	updated, err := svc.repo.Schedule(orgID, id, at, timezone)
+	if err == sql.ErrNoRows {
+		return nil, types.NewConflictError("Unable to schedule campaign", fmt.Errorf("Campaign is %s. Only draft or scheduled campaigns can be scheduled", current.Status))
+	}
+	if err != nil {
+		return nil, databaseError("Unable to schedule campaign", err)
+	}
+
+	svc.audit.Record(actorID, &orgID, model.AuditEntityCampaign, id, model.AuditActionUpdate, current, updated)
+
+	return updated, nil
+}
+
+// Stops a campaign. Messages already sent are not recalled.
+func (svc *campaignService) Cancel(orgID uint64, id uint64, actorID uint64) (*model.Campaign, *types.ApplicationError) {
+	current, appErr := svc.FindByID(orgID, id)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	updated, err := svc.repo.Cancel(orgID, id)
+	if err == sql.ErrNoRows {
+		return nil, types.NewConflictError("Unable to cancel campaign", fmt.Errorf("Campaign is %s already", current.Status))
+	}
+	if err != nil {
+		return nil, databaseError("Unable to cancel campaign", err)
+	}
+
+	svc.audit.Record(actorID, &orgID, model.AuditEntityCampaign, id, model.AuditActionUpdate, current, updated)
+
+	return updated, nil
+}
+
+func (svc *campaignService) FindRecipients(orgID uint64, id uint64, filter *model.CampaignRecipientFilter) ([]*model.CampaignRecipient, *model.Pagination, *types.ApplicationError) {
+	_, appErr := svc.FindByID(orgID, id)
+	if appErr != nil {
+		return nil, nil, appErr
+	}
+
+	filter.Pagination.Normalise()
+
+	recipientSet, total, err := svc.recipients.Find(id, filter)
+	if err != nil {
+		return nil, nil, databaseError("Unable to find campaign recipients", err)
+	}
+
+	page := filter.Pagination
+	page.Total = total
+	return recipientSet, &page, nil
+}
+
+func (svc *campaignService) Stats(orgID uint64, id uint64) (*model.CampaignStats, *types.ApplicationError) {
+	_, appErr := svc.FindByID(orgID, id)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	stats, err := svc.recipients.Stats(id)
+	if err != nil {
+		return nil, databaseError("Unable to count campaign recipients", err)
+	}
+	return stats, nil
+}
+
+// Sends due campaigns until the process exits. Every instance may run it, each campaign is
+// sent by one worker at a time.
+func (svc *campaignService) Run() {
+	for {
+		svc.sendDue()
+		time.Sleep(campaignPollInterval)
+	}
+}
+
+func (svc *campaignService) sendDue() {
+	for {
+		campaign, err := svc.repo.ClaimDue(campaignLease)
+		if err == sql.ErrNoRows {
+			return
+		}
+		if err != nil {
+			logger.Warning("Unable to look for due campaigns. Error: " + err.Error())
+			return
+		}
+
+		svc.send(campaign)
+	}
+}
+
+// Sends the campaign to its recipients batch by batch. Recipients are added from the segment
+// when sending starts, so the campaign reaches the contacts matching at that time.
+func (svc *campaignService) send(campaign *model.Campaign) {
+	name := strconv.FormatUint(campaign.ID, 10)
+
+	segment, err := svc.segmentRepo.FindByID(campaign.OrganisationID, campaign.SegmentID)
+	if err == sql.ErrNoRows {
+		// Without its segment there is nobody to send to, which must not pass for a completed campaign
+		err = svc.repo.Fail(campaign.ID, "Segment was deleted")
+		if err != nil {
+			logger.Warning("Unable to fail campaign " + name + ". Error: " + err.Error())
+			return
+		}
+		logger.Warning("Campaign " + name + " failed as its segment was deleted")
+		return
+	}
+	if err != nil {
+		logger.Warning("Unable to find segment of campaign " + name + ". Error: " + err.Error())
+		return
+	}
+
+	_, err = svc.recipients.AddMatching(campaign, &segment.Rules)
+	if err != nil {
+		logger.Warning("Unable to add recipients of campaign " + name + ". Error: " + err.Error())
+		return
+	}
+
+	for {
+		running, err := svc.repo.ExtendLease(campaign.ID, campaignLease)
+		if err != nil {
+			logger.Warning("Unable to keep campaign " + name + ". Error: " + err.Error())
+			return
+		}
+		if !running {
+			logger.Info("Campaign " + name + " was stopped")
+			return
+		}
+
+		pending, err := svc.recipients.FindPending(campaign.ID, campaignBatchSize)
+		if err != nil {
+			logger.Warning("Unable to find recipients of campaign " + name + ". Error: " + err.Error())
+			return
+		}
+
+		if len(pending) == 0 {
+			err = svc.repo.Complete(campaign.ID)
+			if err != nil {
+				logger.Warning("Unable to complete campaign " + name + ". Error: " + err.Error())
+				return
+			}
+			logger.Success("Campaign " + name + " completed")
+			return
+		}
+
+		for _, recipient := range pending {
+			err = svc.sendTo(campaign, recipient)
+			if err != nil {
+				// The recipient is still pending, so stop until the lease runs out rather than
+				// picking it up again straight away
+				logger.Warning(fmt.Sprintf("Unable to record campaign %d recipient %d. Error: %s", campaign.ID, recipient.ID, err.Error()))
+				return
+			}
+		}
+	}
+}
+
+// Sends the template to the recipient, or marks them failed. Errors are those marking them
+// failed, which leave the recipient pending.
+func (svc *campaignService) sendTo(campaign *model.Campaign, recipient *model.CampaignRecipient) error {
+	contact, findErr := svc.contactRepo.FindByID(campaign.OrganisationID, recipient.ContactID)
+	switch {
+	case findErr == sql.ErrNoRows:
+		return svc.recipients.MarkFailed(recipient.ID, "Contact was deleted")
+	case findErr != nil:
+		return svc.recipients.MarkFailed(recipient.ID, findErr.Error())
+	default:
+		req := &model.SendTemplateRequest{
+			WhatsAppAccountID:   campaign.WhatsAppAccountID,
+			To:                  contact.PhoneNumber,
+			Template:            campaign.Template.Resolve(contact),
+			CampaignRecipientID: recipient.ID,
+		}
+
+		// The recipient is marked sent along with queueing the message
+		_, appErr := svc.messages.SendTemplate(campaign.OrganisationID, req, 0)
+		if appErr != nil && !errors.Is(appErr, repository.ErrRecipientNotPending) {
+			return svc.recipients.MarkFailed(recipient.ID, appErr.Error())
+		}
+	}
+
+	return nil
+}
+
+// Stands in a value for each parameter, so the parameter counts can be checked against the
+// template
+func sampleTemplateRequest(tpl *model.CampaignTemplate) *model.TemplateMessageRequest {
+	req := &model.TemplateMessageRequest{
+		Name:        tpl.Name,
+		Language:    tpl.Language,
+		HeaderMedia: tpl.HeaderMedia,
+		Header:      make([]string, len(tpl.Header)),
+		Body:        make([]string, len(tpl.Body)),
+	}
+	for _, button := range tpl.Buttons {
+		req.Buttons = append(req.Buttons, model.TemplateButtonRequest{Index: button.Index, Value: "-"})
+	}
+	return req
+}
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+	"github.com/supermario64bit/whatsapp_connect/server/repository"
+	"github.com/supermario64bit/whatsapp_connect/types"
+)
+
+type consentService struct {
+	repo        repository.ConsentRepository
+	contactRepo repository.ContactRepository
+	keywords    ConsentKeywordService
+	audit       AuditService
+}
+
+type ConsentService interface {
+	Record(orgID uint64, contactID uint64, consent *model.Consent, actorID uint64) (*model.Consent, *types.ApplicationError)
+	Find(orgID uint64, contactID uint64, page *model.Pagination) ([]*model.Consent, *model.Pagination, *types.ApplicationError)
+	Require(contact *model.Contact, template bool) *types.ApplicationError
+	RequireForMessage(messageID uint64, template bool) *types.ApplicationError
+	RequireOptInAllowed(contact *model.Contact, consent *model.Consent) *types.ApplicationError
+	HandleInbound(contact *model.Contact, message *model.Message) *types.ApplicationError
+}
+
+func NewConsentService() ConsentService {
+	return &consentService{
+		repo:        repository.NewConsentRepository(),
+		contactRepo: repository.NewContactRepository(),
+		keywords:    NewConsentKeywordService(),
+		audit:       NewAuditService(),
+	}
+}
+
+// Records consent collected outside the inbox, such as on a website form, and moves the
+// contact's opt-in status along with it. Opt-ins need their evidence, and only consent collected
+// with it may opt a contact back in after they opted out by keyword.
+func (svc *consentService) Record(orgID uint64, contactID uint64, consent *model.Consent, actorID uint64) (*model.Consent, *types.ApplicationError) {
+	consent.OrganisationID = orgID
+	consent.ContactID = contactID
+	consent.MessageID = nil
+	consent.Normalise()
+	validationErrors := consent.ValidateFields()
+	if len(validationErrors) > 0 {
+		return nil, types.NewValidationError(validationErrors)
+	}
+
+	now := time.Now()
+	if consent.RecordedAt.IsZero() {
+		consent.RecordedAt = now
+	}
+	if consent.RecordedAt.After(now) {
+		return nil, types.NewFieldValidationError(types.FieldError{Field: "recorded_at", Rule: "past", Message: "must not be in the future"})
+	}
+
+	if consent.Source == model.ConsentSourceKeyword {
+		return nil, types.NewFieldValidationError(types.FieldError{Field: "source", Rule: "keyword", Message: "is only recorded from inbound messages"})
+	}
+	if consent.Status == model.OptInStatusOptedIn && consent.Evidence == "" {
+		return nil, types.NewFieldValidationError(types.FieldError{Field: "evidence", Rule: "required", Message: "is required to opt a contact in"})
+	}
+
+	if actorID > 0 {
+		consent.ActorID = &actorID
+	}
+
+	new, err := svc.repo.Record(consent)
+	if err == repository.ErrKeywordOptOut {
+		return nil, keywordOptOutError("status")
+	}
+	if err != nil {
+		return nil, databaseError("Unable to record consent", err)
+	}
+
+	svc.audit.Record(actorID, &orgID, model.AuditEntityConsent, new.ID, model.AuditActionCreate, nil, new)
+
+	return new, nil
+}
+
+// Returns one page of the contact's consent history, latest first
+func (svc *consentService) Find(orgID uint64, contactID uint64, page *model.Pagination) ([]*model.Consent, *model.Pagination, *types.ApplicationError) {
+	page.Normalise()
+
+	_, err := svc.contactRepo.FindByID(orgID, contactID)
+	if err != nil {
+		return nil, nil, databaseError("Unable to find contact by id", err)
+	}
+
+	consentSet, total, err := svc.repo.Find(orgID, contactID, page)
+	if err != nil {
+		return nil, nil, databaseError("Unable to find consent history", err)
+	}
+
+	result := *page
+	result.Total = total
+	return consentSet, &result, nil
+}
+
+// Refuses messages the contact did not consent to. Contacts who opted out get nothing, and
+// templates need the contact to have opted in.
+func (svc *consentService) Require(contact *model.Contact, template bool) *types.ApplicationError {
+	return consentError(contact.PhoneNumber, contact.OptInStatus, template)
+}
+
+// Checks consent of the contact a queued message is addressed to, which may have changed
+// since the message was queued
+func (svc *consentService) RequireForMessage(messageID uint64, template bool) *types.ApplicationError {
+	status, err := svc.repo.FindStatusByMessage(messageID)
+	if err != nil {
+		return databaseError("Unable to find consent of message recipient", err)
+	}
+	return consentError("The contact", status, template)
+}
+
+// Refuses consent which would opt the contact back in after they opted out by keyword, as
+// Record does, without recording it
+func (svc *consentService) RequireOptInAllowed(contact *model.Contact, consent *model.Consent) *types.ApplicationError {
+	if contact.OptInStatus != model.OptInStatusOptedOut || consent.Status != model.OptInStatusOptedIn || consent.OverridesKeywordOptOut() {
+		return nil
+	}
+
+	optedOut, err := svc.repo.OptedOutByKeyword(contact.OrganisationID, contact.ID)
+	if err != nil {
+		return databaseError("Unable to find consent history", err)
+	}
+	if optedOut {
+		return keywordOptOutError("opt_in_status")
+	}
+	return nil
+}
+
+// Opts the contact in or out when an inbound text or button reply is one of the organisation's
+// consent keywords. Keywords matching the contact's current status are not recorded again, so
+// notifications Meta delivers twice leave a single record.
+func (svc *consentService) HandleInbound(contact *model.Contact, message *model.Message) *types.ApplicationError {
+	if message.Type != model.MessageTypeText && message.Type != model.MessageTypeButton {
+		return nil
+	}
+
+	status, ok, appErr := svc.keywords.Match(contact.OrganisationID, message.Body)
+	if appErr != nil || !ok || status == contact.OptInStatus {
+		return appErr
+	}
+
+	consent, err := svc.repo.Record(&model.Consent{
+		OrganisationID: contact.OrganisationID,
+		ContactID:      contact.ID,
+		Status:         status,
+		Source:         model.ConsentSourceKeyword,
+		Evidence:       message.Body,
+		MessageID:      &message.ID,
+		RecordedAt:     message.CreatedAt,
+	})
+	if err != nil {
+		return databaseError("Unable to record consent", err)
+	}
+
+	contact.OptInStatus = status
+	svc.audit.Record(0, &contact.OrganisationID, model.AuditEntityConsent, consent.ID, model.AuditActionCreate, nil, consent)
+
+	return nil
+}
+
+// Error for consent which would override a keyword opt-out, reported on the given field
+func keywordOptOutError(field string) *types.ApplicationError {
+	return types.NewConflictError("Unable to record consent",
+		fmt.Errorf("The contact opted out by keyword. Only the contact, or consent collected with its own evidence, can opt them back in"),
+		types.FieldError{Field: field, Rule: "consent", Message: "must not override an opt-out the contact sent by keyword"})
+}
+
+func consentError(recipient string, optInStatus string, template bool) *types.ApplicationError {
+	if model.ConsentAllows(optInStatus, template) {
+		return nil
+	}
+
+	if optInStatus == model.OptInStatusOptedOut {
+		return types.NewConflictError("Unable to send message", fmt.Errorf("%s opted out of messages", recipient),
+			types.FieldError{Field: "to", Rule: "consent", Message: "must not have opted out of messages"})
+	}
+
+	return types.NewConflictError("Unable to send message",
+		fmt.Errorf("%s has not opted in to messages. Record their consent before sending templates", recipient),
+		types.FieldError{Field: "to", Rule: "consent", Message: "must have opted in to messages"})
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+	"github.com/supermario64bit/whatsapp_connect/server/repository"
+	"github.com/supermario64bit/whatsapp_connect/types"
+)
+
+type consentKeywordService struct {
+	repo  repository.ConsentKeywordRepository
+	audit AuditService
+}
+
+type ConsentKeywordService interface {
+	Create(orgID uint64, keyword *model.ConsentKeyword, actorID uint64) (*model.ConsentKeyword, *types.ApplicationError)
+	Find(orgID uint64) ([]*model.ConsentKeyword, *types.ApplicationError)
+	DeleteByID(orgID uint64, id uint64, actorID uint64) *types.ApplicationError
+	Match(orgID uint64, text string) (string, bool, *types.ApplicationError)
+}
+
+func NewConsentKeywordService() ConsentKeywordService {
+	return &consentKeywordService{
+		repo:  repository.NewConsentKeywordRepository(),
+		audit: NewAuditService(),
+	}
+}
+
+func (svc *consentKeywordService) Create(orgID uint64, keyword *model.ConsentKeyword, actorID uint64) (*model.ConsentKeyword, *types.ApplicationError) {
+	keyword.OrganisationID = orgID
+	keyword.Normalise()
+	validationErrors := keyword.ValidateFields()
+	if len(validationErrors) > 0 {
+		return nil, types.NewValidationError(validationErrors)
+	}
+
+	new, err := svc.repo.Create(keyword)
+	if err != nil {
+		return nil, databaseError("Unable to create consent keyword", err)
+	}
+
+	svc.audit.Record(actorID, &orgID, model.AuditEntityConsentKeyword, new.ID, model.AuditActionCreate, nil, new)
+
+	return new, nil
+}
+
+func (svc *consentKeywordService) Find(orgID uint64) ([]*model.ConsentKeyword, *types.ApplicationError) {
+	keywordSet, err := svc.repo.Find(orgID)
+	if err != nil {
+		return nil, databaseError("Unable to find consent keywords", err)
+	}
+	return keywordSet, nil
+}
+
+func (svc *consentKeywordService) DeleteByID(orgID uint64, id uint64, actorID uint64) *types.ApplicationError {
+	before, err := svc.repo.FindByID(orgID, id)
+	if err != nil {
+		return databaseError("Unable to delete consent keyword", err)
+	}
+
+	err = svc.repo.DeleteByID(orgID, id)
+	if err != nil {
+		return databaseError("Unable to delete consent keyword", err)
+	}
+
+	svc.audit.Record(actorID, &orgID, model.AuditEntityConsentKeyword, id, model.AuditActionDelete, before, nil)
+
+	return nil
+}
+
+// Returns the opt-in status the message text asks for when it is one of the organisation's
+// keywords, or one of the default keywords when the organisation configured none. Only a
+// message made up of the keyword alone matches.
+func (svc *consentKeywordService) Match(orgID uint64, text string) (string, bool, *types.ApplicationError) {
+	text = model.NormaliseKeyword(text)
+	if text == "" {
+		return "", false, nil
+	}
+
+	keywords, appErr := svc.Find(orgID)
+	if appErr != nil {
+		return "", false, appErr
+	}
+
+	if len(keywords) == 0 {
+		for _, keyword := range model.DefaultOptOutKeywords {
+			keywords = append(keywords, &model.ConsentKeyword{Keyword: keyword, Status: model.OptInStatusOptedOut})
+		}
+		for _, keyword := range model.DefaultOptInKeywords {
+			keywords = append(keywords, &model.ConsentKeyword{Keyword: keyword, Status: model.OptInStatusOptedIn})
+		}
+	}
+
+	for _, keyword := range keywords {
+		if keyword.Keyword == text {
+			return keyword.Status, true, nil
+		}
+	}
+	return "", false, nil
+}
@@ -0,0 +1,234 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/supermario64bit/whatsapp_connect/pkg/logger"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+	"github.com/supermario64bit/whatsapp_connect/server/repository"
+	"github.com/supermario64bit/whatsapp_connect/types"
+)
+
+type contactService struct {
+	repo     repository.ContactRepository
+	consents ConsentService
+	audit    AuditService
+}
+
+type ContactService interface {
+	Create(orgID uint64, contact *model.Contact, actorID uint64) (*model.Contact, *types.ApplicationError)
+	Find(orgID uint64, filter *model.ContactFilter) ([]*model.Contact, *model.Pagination, *types.ApplicationError)
+	FindByID(orgID uint64, id uint64) (*model.Contact, *types.ApplicationError)
+	FindByPhoneNumber(orgID uint64, phoneNumber string) (*model.Contact, *types.ApplicationError)
+	PatchByID(orgID uint64, patch []byte, id uint64, version uint64, actorID uint64) (*model.Contact, *types.ApplicationError)
+	DeleteByID(orgID uint64, id uint64, version uint64, actorID uint64) *types.ApplicationError
+	RecordInbound(orgID uint64, phoneNumber string, profileName string, seenAt time.Time) (*model.Contact, *types.ApplicationError)
+	FindOrCreate(orgID uint64, phoneNumber string, actorID uint64) (*model.Contact, *types.ApplicationError)
+}
+
+func NewContactService() ContactService {
+	return &contactService{
+		repo:     repository.NewContactRepository(),
+		consents: NewConsentService(),
+		audit:    NewAuditService(),
+	}
+}
+
+// Creates the contact. Contacts can be created opted out, but opting them in takes consent
+// recorded with its evidence.
+func (svc *contactService) Create(orgID uint64, contact *model.Contact, actorID uint64) (*model.Contact, *types.ApplicationError) {
+	contact.OrganisationID = orgID
+	contact.Normalise()
+	validationErrors := contact.ValidateFields()
+	if len(validationErrors) > 0 {
+		return nil, types.NewValidationError(validationErrors)
+	}
+
+	if contact.OptInStatus == model.OptInStatusOptedIn {
+		return nil, optInStatusError()
+	}
+
+	new, err := svc.repo.Create(contact)
+	if err != nil {
+		return nil, databaseError("Unable to create contact", err)
+	}
+
+	svc.audit.Record(actorID, &orgID, model.AuditEntityContact, new.ID, model.AuditActionCreate, nil, new)
+	outboxWake.notify()
+
+	if new.OptInStatus == model.OptInStatusOptedOut {
+		svc.recordOptOut(new, actorID)
+	}
+
+	return new, nil
+}
+
+func (svc *contactService) Find(orgID uint64, filter *model.ContactFilter) ([]*model.Contact, *model.Pagination, *types.ApplicationError) {
+	filter.Pagination.Normalise()
+
+	contactSet, total, err := svc.repo.Find(orgID, filter)
+	if err != nil {
+		return nil, nil, databaseError("Unable to find contacts", err)
+	}
+
+	page := filter.Pagination
+	page.Total = total
+	return contactSet, &page, nil
+}
+
+func (svc *contactService) FindByID(orgID uint64, id uint64) (*model.Contact, *types.ApplicationError) {
+	contact, err := svc.repo.FindByID(orgID, id)
+	if err != nil {
+		return nil, databaseError("Unable to find contact by id", err)
+	}
+	return contact, nil
+}
+
+// Applies an RFC 7396 merge patch. Only the fields present in the patch are validated and
+// written, and a patch which changes nothing returns the current contact untouched. The opt-in
+// status can only be moved to opted out, opting in takes consent recorded with its evidence.
+func (svc *contactService) PatchByID(orgID uint64, patch []byte, id uint64, version uint64, actorID uint64) (*model.Contact, *types.ApplicationError) {
+	current, err := svc.repo.FindByID(orgID, id)
+	if err != nil {
+		return nil, databaseError("Unable to update contact", err)
+	}
+
+	if version > 0 && current.Version != version {
+		return nil, databaseError("Unable to update contact", repository.ErrVersionMismatch)
+	}
+
+	var patched model.Contact
+	fields, appErr := applyMergePatch(current, patch, &patched)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	patched.Normalise()
+	validationErrors := patched.ValidatePartial(fields)
+	if len(validationErrors) > 0 {
+		return nil, types.NewValidationError(validationErrors)
+	}
+
+	changes := changedColumns(current, &patched, fields)
+	if len(changes) == 0 {
+		return current, nil
+	}
+
+	if _, ok := changes["opt_in_status"]; ok && patched.OptInStatus != model.OptInStatusOptedOut {
+		return nil, optInStatusError()
+	}
+
+	updatedContact, err := svc.repo.PatchByID(id, current.Version, changes)
+	if err != nil {
+		return nil, databaseError("Unable to update contact", err)
+	}
+
+	svc.audit.Record(actorID, &orgID, model.AuditEntityContact, updatedContact.ID, model.AuditActionUpdate, current, updatedContact)
+
+	if _, ok := changes["opt_in_status"]; ok {
+		svc.recordOptOut(updatedContact, actorID)
+	}
+
+	return updatedContact, nil
+}
+
+func (svc *contactService) DeleteByID(orgID uint64, id uint64, version uint64, actorID uint64) *types.ApplicationError {
+	before, err := svc.repo.FindByID(orgID, id)
+	if err != nil {
+		return databaseError("Unable to delete contact", err)
+	}
+
+	err = svc.repo.DeleteByID(orgID, id, version)
+	if err != nil {
+		return databaseError("Unable to delete contact", err)
+	}
+
+	svc.audit.Record(actorID, &orgID, model.AuditEntityContact, id, model.AuditActionDelete, before, nil)
+
+	return nil
+}
+
+// Finds the contact an inbound message came from, creating it on first contact, and moves its
+// last seen time forward
+func (svc *contactService) RecordInbound(orgID uint64, phoneNumber string, profileName string, seenAt time.Time) (*model.Contact, *types.ApplicationError) {
+	contact, created, appErr := svc.findOrCreate(orgID, &model.Contact{
+		PhoneNumber: phoneNumber,
+		Name:        profileName,
+		LastSeenAt:  &seenAt,
+	}, 0)
+	if appErr != nil || created {
+		return contact, appErr
+	}
+
+	err := svc.repo.TouchLastSeen(contact.ID, seenAt)
+	if err != nil {
+		logger.Warning("Unable to update last seen time of contact. Error: " + err.Error())
+	}
+	return contact, nil
+}
+
+// Finds the contact with the given phone number, creating it when the organisation has none
+func (svc *contactService) FindOrCreate(orgID uint64, phoneNumber string, actorID uint64) (*model.Contact, *types.ApplicationError) {
+	contact, _, appErr := svc.findOrCreate(orgID, &model.Contact{PhoneNumber: phoneNumber}, actorID)
+	return contact, appErr
+}
+
+// Returns the existing contact with the phone number of new, or creates new. Reports whether
+// the contact was created. Callers validate the phone number, which may come from Meta.
+func (svc *contactService) findOrCreate(orgID uint64, new *model.Contact, actorID uint64) (*model.Contact, bool, *types.ApplicationError) {
+	contact, err := svc.repo.FindByPhoneNumber(orgID, new.PhoneNumber)
+	if err == nil {
+		return contact, false, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, false, databaseError("Unable to find contact by phone number", err)
+	}
+
+	new.OrganisationID = orgID
+	new.Normalise()
+
+	created, err := svc.repo.Create(new)
+	var constraintErr *repository.ConstraintError
+	if errors.As(err, &constraintErr) && constraintErr.Kind == repository.ConstraintUnique {
+		// Another request for the same number created it in the meantime
+		contact, appErr := svc.FindByPhoneNumber(orgID, new.PhoneNumber)
+		return contact, false, appErr
+	}
+	if err != nil {
+		return nil, false, databaseError("Unable to create contact", err)
+	}
+
+	svc.audit.Record(actorID, &orgID, model.AuditEntityContact, created.ID, model.AuditActionCreate, nil, created)
+	outboxWake.notify()
+
+	return created, true, nil
+}
+
+func (svc *contactService) FindByPhoneNumber(orgID uint64, phoneNumber string) (*model.Contact, *types.ApplicationError) {
+	contact, err := svc.repo.FindByPhoneNumber(orgID, phoneNumber)
+	if err != nil {
+		return nil, databaseError("Unable to find contact by phone number", err)
+	}
+	return contact, nil
+}
+
+// Adds the opt-out set on the contact to their consent history. Failures are logged, the status
+// itself is already stored with the contact.
+func (svc *contactService) recordOptOut(contact *model.Contact, actorID uint64) {
+	_, appErr := svc.consents.Record(contact.OrganisationID, contact.ID, &model.Consent{
+		Status:   model.OptInStatusOptedOut,
+		Source:   model.ConsentSourceAPI,
+		Evidence: "Opted out on the contact",
+	}, actorID)
+	if appErr != nil {
+		logger.Warning(fmt.Sprintf("Unable to record consent of contact %d. Error: %s", contact.ID, appErr.Error()))
+	}
+}
+
+func optInStatusError() *types.ApplicationError {
+	return types.NewFieldValidationError(types.FieldError{Field: "opt_in_status", Rule: "consent",
+		Message: "can only be set to opted_out. Record consent with its evidence to opt the contact in"})
+}
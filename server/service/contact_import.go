@@ -0,0 +1,518 @@
+package service
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/supermario64bit/whatsapp_connect/pkg/logger"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+	"github.com/supermario64bit/whatsapp_connect/server/repository"
+	"github.com/supermario64bit/whatsapp_connect/types"
+)
+
+const (
+	// Only the first errors are kept so a badly mapped file cannot bloat the job row
+	maxImportErrors = 1000
+	// Number of rows processed between progress writes
+	importProgressInterval = 100
+	// Jobs whose progress has not been saved for this long are failed. Imports run in the process
+	// that received the file, so a job stops for good when that process does.
+	importStaleAfter = 10 * time.Minute
+	// How often jobs are checked for going stale
+	importStaleCheckInterval = time.Minute
+
+	attributeTargetPrefix = "attributes."
+)
+
+// Contact fields a CSV column can be mapped onto, besides attributes.<key>
+var importTargets = map[string]bool{
+	"phone_number":  true,
+	"name":          true,
+	"opt_in_status": true,
+	"tags":          true,
+	"attributes":    true,
+}
+
+var exportHeader = []string{"id", "phone_number", "name", "opt_in_status", "tags", "attributes", "last_seen_at", "created_at"}
+
+type contactImportService struct {
+	repo        repository.ContactImportJobRepository
+	contactRepo repository.ContactRepository
+	contacts    ContactService
+	consents    ConsentService
+}
+
+type ContactImportService interface {
+	Start(orgID uint64, fileName string, file io.Reader, mapping map[string]string, dryRun bool, actorID uint64) (*model.ContactImportJob, *types.ApplicationError)
+	FindByID(orgID uint64, id uint64) (*model.ContactImportJob, *types.ApplicationError)
+	Export(orgID uint64, filter *model.ContactFilter, w io.Writer) *types.ApplicationError
+	Run()
+}
+
+func NewContactImportService() ContactImportService {
+	return &contactImportService{
+		repo:        repository.NewContactImportJobRepository(),
+		contactRepo: repository.NewContactRepository(),
+		contacts:    NewContactService(),
+		consents:    NewConsentService(),
+	}
+}
+
+// Reads and checks the file, then imports its rows in the background. The mapping goes from
+// contact field (phone_number, name, opt_in_status, tags, attributes or attributes.<key>) to CSV
+// column header. Without a mapping, columns named after contact fields are imported.
+// Rows are deduplicated by phone number and existing contacts are updated rather than recreated.
+// Opt-in statuses are recorded as consent citing the row, and never override an opt-out the
+// contact sent by keyword.
+func (svc *contactImportService) Start(orgID uint64, fileName string, file io.Reader, mapping map[string]string, dryRun bool, actorID uint64) (*model.ContactImportJob, *types.ApplicationError) {
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, types.NewBadRequestError("Invalid CSV File", err)
+	}
+
+	if len(records) < 2 {
+		return nil, types.NewBadRequestError("Invalid CSV File", fmt.Errorf("File must contain a header row and at least one contact"))
+	}
+
+	columns, appErr := resolveImportColumns(records[0], mapping)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	storedMapping := model.JSONMap{}
+	for target, index := range columns {
+		storedMapping[target] = strings.TrimSpace(records[0][index])
+	}
+
+	job := &model.ContactImportJob{
+		OrganisationID: orgID,
+		Status:         model.ImportStatusPending,
+		DryRun:         dryRun,
+		FileName:       fileName,
+		Mapping:        storedMapping,
+		TotalRows:      len(records) - 1,
+		Errors:         model.ImportRowErrors{},
+	}
+	if actorID > 0 {
+		job.CreatedBy = &actorID
+	}
+
+	created, err := svc.repo.Create(job)
+	if err != nil {
+		return nil, databaseError("Unable to create contact import job", err)
+	}
+
+	progress := *created
+	go svc.run(&progress, columns, records[1:], actorID)
+
+	return created, nil
+}
+
+func (svc *contactImportService) FindByID(orgID uint64, id uint64) (*model.ContactImportJob, *types.ApplicationError) {
+	job, err := svc.repo.FindByID(orgID, id)
+	if err != nil {
+		return nil, databaseError("Unable to find contact import job by id", err)
+	}
+	return job, nil
+}
+
+// Writes every contact matching the filter as CSV, in the layout accepted by imports
+func (svc *contactImportService) Export(orgID uint64, filter *model.ContactFilter, w io.Writer) *types.ApplicationError {
+	writer := csv.NewWriter(w)
+
+	err := writer.Write(exportHeader)
+	if err != nil {
+		return types.NewInternalError("Unable to export contacts", err)
+	}
+
+	rowsWritten := 0
+	err = svc.contactRepo.Each(orgID, filter, func(contact *model.Contact) error {
+		attributes, err := json.Marshal(contact.Attributes)
+		if err != nil {
+			return err
+		}
+
+		lastSeenAt := ""
+		if contact.LastSeenAt != nil {
+			lastSeenAt = contact.LastSeenAt.Format(time.RFC3339)
+		}
+
+		err = writer.Write([]string{
+			strconv.FormatUint(contact.ID, 10),
+			contact.PhoneNumber,
+			contact.Name,
+			contact.OptInStatus,
+			strings.Join(contact.Tags, ";"),
+			string(attributes),
+			lastSeenAt,
+			contact.CreatedAt.Format(time.RFC3339),
+		})
+		if err != nil {
+			return err
+		}
+
+		rowsWritten++
+		if rowsWritten%importProgressInterval == 0 {
+			writer.Flush()
+		}
+		return writer.Error()
+	})
+	if err != nil {
+		return databaseError("Unable to export contacts", err)
+	}
+
+	writer.Flush()
+	if writer.Error() != nil {
+		return types.NewInternalError("Unable to export contacts", writer.Error())
+	}
+
+	return nil
+}
+
+// Fails jobs left pending or running by a stopped process, so they do not look busy forever.
+// Blocks forever.
+func (svc *contactImportService) Run() {
+	for {
+		failed, err := svc.repo.FailStale(importStaleAfter)
+		if err != nil {
+			logger.Warning("Unable to fail stale contact import jobs. Error: " + err.Error())
+		} else if failed > 0 {
+			logger.Warning(fmt.Sprintf("Failed %d contact import job(s) which stopped making progress", failed))
+		}
+
+		time.Sleep(importStaleCheckInterval)
+	}
+}
+
+func (svc *contactImportService) run(job *model.ContactImportJob, columns map[string]int, rows [][]string, actorID uint64) {
+	now := time.Now()
+	job.Status = model.ImportStatusRunning
+	job.StartedAt = &now
+	svc.saveProgress(job)
+
+	defer func() {
+		if r := recover(); r != nil {
+			logger.HighlightedDanger(fmt.Sprintf("Contact import job %d crashed. Error: %v", job.ID, r))
+			job.Status = model.ImportStatusFailed
+			svc.finish(job)
+		}
+	}()
+
+	seen := map[string]int{}
+	for i, record := range rows {
+		svc.importRow(job, i+1, columns, record, seen, actorID)
+
+		job.ProcessedRows++
+		if job.ProcessedRows%importProgressInterval == 0 && !svc.saveProgress(job) {
+			return
+		}
+	}
+
+	job.Status = model.ImportStatusCompleted
+	svc.finish(job)
+}
+
+func (svc *contactImportService) importRow(job *model.ContactImportJob, row int, columns map[string]int, record []string, seen map[string]int, actorID uint64) {
+	contact, rowErrors := contactFromRecord(row, columns, record)
+	if len(rowErrors) > 0 {
+		job.FailedCount++
+		addImportErrors(job, rowErrors...)
+		return
+	}
+
+	contact.Normalise()
+	validationErrors := contact.ValidateFields()
+	if len(validationErrors) > 0 {
+		job.FailedCount++
+		for _, detail := range types.NewValidationError(validationErrors).Details {
+			addImportErrors(job, model.ImportRowError{Row: row, Field: detail.Field, Message: detail.Message})
+		}
+		return
+	}
+
+	if firstRow, ok := seen[contact.PhoneNumber]; ok {
+		job.SkippedCount++
+		addImportErrors(job, model.ImportRowError{Row: row, Field: "phone_number", Message: fmt.Sprintf("duplicates row %d", firstRow)})
+		return
+	}
+	seen[contact.PhoneNumber] = row
+
+	existing, appErr := svc.contacts.FindByPhoneNumber(job.OrganisationID, contact.PhoneNumber)
+	if appErr != nil && appErr.Code != types.CodeNotFound {
+		job.FailedCount++
+		addImportErrors(job, model.ImportRowError{Row: row, Message: appErr.Error()})
+		return
+	}
+
+	// The opt-in status is recorded as consent rather than written with the contact
+	consent := importedConsent(job, row, contact)
+	contact.OptInStatus = model.OptInStatusUnknown
+
+	if existing == nil {
+		if !job.DryRun {
+			created, appErr := svc.contacts.Create(job.OrganisationID, contact, actorID)
+			if appErr == nil && consent != nil {
+				_, appErr = svc.consents.Record(job.OrganisationID, created.ID, consent, actorID)
+			}
+			if appErr != nil {
+				job.FailedCount++
+				addImportErrors(job, applicationRowErrors(row, appErr)...)
+				return
+			}
+		}
+		job.CreatedCount++
+		return
+	}
+
+	if consent != nil && consent.Status == existing.OptInStatus {
+		consent = nil
+	}
+	if consent != nil {
+		appErr = svc.consents.RequireOptInAllowed(existing, consent)
+		if appErr != nil {
+			job.FailedCount++
+			addImportErrors(job, applicationRowErrors(row, appErr)...)
+			return
+		}
+	}
+
+	patch, err := contactMergePatch(existing, contact)
+	if err != nil {
+		job.FailedCount++
+		addImportErrors(job, model.ImportRowError{Row: row, Message: err.Error()})
+		return
+	}
+
+	if job.DryRun {
+		changed, appErr := contactPatchChanges(existing, patch)
+		if appErr != nil {
+			job.FailedCount++
+			addImportErrors(job, applicationRowErrors(row, appErr)...)
+			return
+		}
+		if !changed && consent == nil {
+			job.SkippedCount++
+			return
+		}
+		job.UpdatedCount++
+		return
+	}
+
+	updated, appErr := svc.contacts.PatchByID(job.OrganisationID, patch, existing.ID, 0, actorID)
+	if appErr == nil && consent != nil {
+		_, appErr = svc.consents.Record(job.OrganisationID, existing.ID, consent, actorID)
+	}
+	if appErr != nil {
+		job.FailedCount++
+		addImportErrors(job, applicationRowErrors(row, appErr)...)
+		return
+	}
+
+	if updated.Version == existing.Version && consent == nil {
+		job.SkippedCount++
+		return
+	}
+	job.UpdatedCount++
+}
+
+// Consent for the opt-in status of an imported row, citing the row as its evidence. Nil when
+// the row has no status.
+func importedConsent(job *model.ContactImportJob, row int, contact *model.Contact) *model.Consent {
+	if contact.OptInStatus == model.OptInStatusUnknown {
+		return nil
+	}
+	return &model.Consent{
+		Status:   contact.OptInStatus,
+		Source:   model.ConsentSourceImport,
+		Evidence: fmt.Sprintf("Row %d of %s, imported by contact import job %d", row, job.FileName, job.ID),
+	}
+}
+
+// Reports false once the job has been failed for going stale, so the import stops there
+func (svc *contactImportService) saveProgress(job *model.ContactImportJob) bool {
+	err := svc.repo.UpdateProgress(job)
+	if err == sql.ErrNoRows {
+		logger.Warning(fmt.Sprintf("Contact import job %d was failed for going stale. Stopping the import.", job.ID))
+		return false
+	}
+	if err != nil {
+		logger.Warning(fmt.Sprintf("Unable to save progress of contact import job %d. Error: %s", job.ID, err.Error()))
+	}
+	return true
+}
+
+func (svc *contactImportService) finish(job *model.ContactImportJob) {
+	now := time.Now()
+	job.FinishedAt = &now
+	svc.saveProgress(job)
+}
+
+// Maps each import target to the index of its column in the header
+func resolveImportColumns(header []string, mapping map[string]string) (map[string]int, *types.ApplicationError) {
+	headerIndex := map[string]int{}
+	for i, name := range header {
+		// Spreadsheet exports often start with a byte order mark
+		name = strings.TrimSpace(strings.TrimPrefix(name, "\uFEFF"))
+		headerIndex[name] = i
+	}
+
+	if len(mapping) == 0 {
+		mapping = map[string]string{}
+		for name := range headerIndex {
+			if importTargets[name] {
+				mapping[name] = name
+			}
+		}
+	}
+
+	columns := map[string]int{}
+	details := []types.FieldError{}
+	for target, column := range mapping {
+		if !importTargets[target] && !(strings.HasPrefix(target, attributeTargetPrefix) && len(target) > len(attributeTargetPrefix)) {
+			details = append(details, types.FieldError{Field: target, Rule: "target", Message: "is not a contact field which can be imported"})
+			continue
+		}
+
+		index, ok := headerIndex[strings.TrimSpace(column)]
+		if !ok {
+			details = append(details, types.FieldError{Field: target, Rule: "column", Message: "is mapped to column " + column + " which is not in the file"})
+			continue
+		}
+		columns[target] = index
+	}
+
+	if _, ok := columns["phone_number"]; !ok && len(details) == 0 {
+		details = append(details, types.FieldError{Field: "phone_number", Rule: "required", Message: "must be mapped to a column"})
+	}
+
+	if len(details) > 0 {
+		return nil, types.NewFieldValidationError(details...)
+	}
+
+	return columns, nil
+}
+
+func contactFromRecord(row int, columns map[string]int, record []string) (*model.Contact, []model.ImportRowError) {
+	value := func(target string) string {
+		index, ok := columns[target]
+		if !ok || index >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[index])
+	}
+
+	contact := &model.Contact{
+		PhoneNumber: value("phone_number"),
+		Name:        value("name"),
+		OptInStatus: value("opt_in_status"),
+		Attributes:  model.JSONMap{},
+	}
+
+	rowErrors := []model.ImportRowError{}
+	if raw := value("attributes"); raw != "" {
+		err := json.Unmarshal([]byte(raw), &contact.Attributes)
+		if err != nil {
+			rowErrors = append(rowErrors, model.ImportRowError{Row: row, Field: "attributes", Message: "must be a JSON object"})
+		}
+	}
+
+	for target := range columns {
+		if key, ok := strings.CutPrefix(target, attributeTargetPrefix); ok {
+			if raw := value(target); raw != "" {
+				contact.Attributes[key] = raw
+			}
+		}
+	}
+
+	contact.Tags = model.StringList{}
+	for _, tag := range strings.FieldsFunc(value("tags"), func(r rune) bool { return r == ';' || r == ',' }) {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			contact.Tags = append(contact.Tags, tag)
+		}
+	}
+
+	return contact, rowErrors
+}
+
+// Builds the merge patch bringing an existing contact in line with an imported row. Empty cells
+// never clear existing values, attributes are merged key by key and tags are added to. The
+// opt-in status is left to the row's consent.
+func contactMergePatch(existing *model.Contact, imported *model.Contact) ([]byte, error) {
+	patch := map[string]interface{}{}
+	if imported.Name != "" {
+		patch["name"] = imported.Name
+	}
+
+	if len(imported.Attributes) > 0 {
+		patch["attributes"] = imported.Attributes
+	}
+
+	if len(imported.Tags) > 0 {
+		tags := append(model.StringList{}, existing.Tags...)
+		for _, tag := range imported.Tags {
+			if !containsString(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+		patch["tags"] = tags
+	}
+
+	return json.Marshal(patch)
+}
+
+// Reports whether PatchByID would change the contact, checking the patch the same way
+func contactPatchChanges(existing *model.Contact, patch []byte) (bool, *types.ApplicationError) {
+	var patched model.Contact
+	fields, appErr := applyMergePatch(existing, patch, &patched)
+	if appErr != nil {
+		return false, appErr
+	}
+
+	patched.Normalise()
+	validationErrors := patched.ValidatePartial(fields)
+	if len(validationErrors) > 0 {
+		return false, types.NewValidationError(validationErrors)
+	}
+
+	return len(changedColumns(existing, &patched, fields)) > 0, nil
+}
+
+func applicationRowErrors(row int, appErr *types.ApplicationError) []model.ImportRowError {
+	if len(appErr.Details) == 0 {
+		return []model.ImportRowError{{Row: row, Message: appErr.Error()}}
+	}
+
+	rowErrors := []model.ImportRowError{}
+	for _, detail := range appErr.Details {
+		rowErrors = append(rowErrors, model.ImportRowError{Row: row, Field: detail.Field, Message: detail.Message})
+	}
+	return rowErrors
+}
+
+func addImportErrors(job *model.ContactImportJob, rowErrors ...model.ImportRowError) {
+	for _, rowError := range rowErrors {
+		if len(job.Errors) >= maxImportErrors {
+			return
+		}
+		job.Errors = append(job.Errors, rowError)
+	}
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"strings"
+	"time"
+
+	"github.com/supermario64bit/whatsapp_connect/pkg/whatsapp"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+	"github.com/supermario64bit/whatsapp_connect/server/repository"
+	"github.com/supermario64bit/whatsapp_connect/types"
+)
+
+type conversationService struct {
+	repo repository.ConversationRepository
+}
+
+type ConversationService interface {
+	Find(orgID uint64, filter *model.ConversationFilter) ([]*model.Conversation, *model.Pagination, *types.ApplicationError)
+	FindByID(orgID uint64, id uint64) (*model.Conversation, *types.ApplicationError)
+	FindByContact(accountID uint64, contactID uint64) (*model.Conversation, *types.ApplicationError)
+	Record(orgID uint64, accountID uint64, contactID uint64, at time.Time, inbound bool) (*model.Conversation, *types.ApplicationError)
+	ApplyPricing(id uint64, status *whatsapp.MessageStatus) (*model.Conversation, *types.ApplicationError)
+}
+
+func NewConversationService() ConversationService {
+	return &conversationService{
+		repo: repository.NewConversationRepository(),
+	}
+}
+
+func (svc *conversationService) Find(orgID uint64, filter *model.ConversationFilter) ([]*model.Conversation, *model.Pagination, *types.ApplicationError) {
+	filter.Pagination.Normalise()
+
+	conversationSet, total, err := svc.repo.Find(orgID, filter)
+	if err != nil {
+		return nil, nil, databaseError("Unable to find conversations", err)
+	}
+
+	page := filter.Pagination
+	page.Total = total
+	return conversationSet, &page, nil
+}
+
+func (svc *conversationService) FindByID(orgID uint64, id uint64) (*model.Conversation, *types.ApplicationError) {
+	conversation, err := svc.repo.FindByID(orgID, id)
+	if err != nil {
+		return nil, databaseError("Unable to find conversation by id", err)
+	}
+	return conversation, nil
+}
+
+func (svc *conversationService) FindByContact(accountID uint64, contactID uint64) (*model.Conversation, *types.ApplicationError) {
+	conversation, err := svc.repo.FindByContact(accountID, contactID)
+	if err != nil {
+		return nil, databaseError("Unable to find conversation by contact", err)
+	}
+	return conversation, nil
+}
+
+// Adds a message at the given time to the conversation of the contact with the account. Inbound
+// messages open the customer service window for another 24 hours.
+func (svc *conversationService) Record(orgID uint64, accountID uint64, contactID uint64, at time.Time, inbound bool) (*model.Conversation, *types.ApplicationError) {
+	conversation, err := svc.repo.Record(orgID, accountID, contactID, at, inbound)
+	if err != nil {
+		return nil, databaseError("Unable to record conversation", err)
+	}
+	return conversation, nil
+}
+
+// Keeps the billing conversation and pricing Meta reported in a status callback. Statuses
+// without them, such as read statuses, change nothing.
+func (svc *conversationService) ApplyPricing(id uint64, status *whatsapp.MessageStatus) (*model.Conversation, *types.ApplicationError) {
+	if status.Conversation == nil && status.Pricing == nil {
+		return nil, nil
+	}
+
+	var externalID, category, pricingModel string
+	var billable *bool
+	var expiresAt *time.Time
+	if status.Conversation != nil {
+		externalID = status.Conversation.ID
+		category = strings.ToLower(status.Conversation.Origin.Type)
+		if status.Conversation.ExpirationTimestamp != "" {
+			at := whatsapp.ParseTimestamp(status.Conversation.ExpirationTimestamp)
+			expiresAt = &at
+		}
+	}
+	if status.Pricing != nil {
+		pricingModel = status.Pricing.PricingModel
+		billable = &status.Pricing.Billable
+		if status.Pricing.Category != "" {
+			category = strings.ToLower(status.Pricing.Category)
+		}
+	}
+
+	updated, err := svc.repo.UpdatePricing(id, externalID, category, pricingModel, billable, expiresAt)
+	if err != nil {
+		return nil, databaseError("Unable to update conversation pricing", err)
+	}
+	return updated, nil
+}
@@ -0,0 +1,820 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/supermario64bit/whatsapp_connect/pkg/logger"
+	"github.com/supermario64bit/whatsapp_connect/pkg/safehttp"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+	"github.com/supermario64bit/whatsapp_connect/server/repository"
+	"github.com/supermario64bit/whatsapp_connect/types"
+)
+
+const (
+	// Nodes run for one message at most, so a flow looping back on itself without asking
+	// anything stops
+	maxFlowSteps = 100
+	// Wait for webhooks whose node does not set its own timeout
+	defaultFlowWebhookTimeout = 10 * time.Second
+	// Webhook response bytes kept, the rest is ignored
+	maxFlowWebhookResponse = 1 << 20
+	// Attempts at writing a contact attribute while the contact keeps changing under it
+	flowAttributeAttempts = 3
+)
+
+type flowService struct {
+	repo             repository.FlowRepository
+	sessionRepo      repository.FlowSessionRepository
+	contactRepo      repository.ContactRepository
+	conversationRepo repository.ConversationRepository
+	accounts         WhatsAppAccountService
+	members          OrganisationMemberService
+	messages         MessageService
+	audit            AuditService
+	httpClient       *http.Client
+}
+
+type FlowService interface {
+	Create(orgID uint64, flow *model.Flow, actorID uint64) (*model.Flow, *types.ApplicationError)
+	Find(orgID uint64) ([]*model.Flow, *types.ApplicationError)
+	FindByID(orgID uint64, id uint64) (*model.Flow, *types.ApplicationError)
+	PatchByID(orgID uint64, patch []byte, id uint64, version uint64, actorID uint64) (*model.Flow, *types.ApplicationError)
+	DeleteByID(orgID uint64, id uint64, version uint64, actorID uint64) *types.ApplicationError
+	FindSessions(orgID uint64, id uint64, filter *model.FlowSessionFilter) ([]*model.FlowSession, *model.Pagination, *types.ApplicationError)
+	Simulate(orgID uint64, id uint64, simulation *model.FlowSimulation) (*model.FlowSimulationResult, *types.ApplicationError)
+	HandleInbound(account *model.WhatsAppAccount, contact *model.Contact, message *model.Message) (bool, *types.ApplicationError)
+}
+
+func NewFlowService() FlowService {
+	return &flowService{
+		repo:             repository.NewFlowRepository(),
+		sessionRepo:      repository.NewFlowSessionRepository(),
+		contactRepo:      repository.NewContactRepository(),
+		conversationRepo: repository.NewConversationRepository(),
+		accounts:         NewWhatsAppAccountService(),
+		members:          NewOrganisationMemberService(),
+		messages:         NewMessageService(),
+		audit:            NewAuditService(),
+		httpClient:       safehttp.NewClient(0, false),
+	}
+}
+
+func (svc *flowService) Create(orgID uint64, flow *model.Flow, actorID uint64) (*model.Flow, *types.ApplicationError) {
+	flow.OrganisationID = orgID
+	flow.Normalise()
+	details := flow.ValidateFields()
+	if len(details) > 0 {
+		return nil, types.NewFieldValidationError(details...)
+	}
+
+	appErr := svc.checkReferences(flow)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	new, err := svc.repo.Create(flow)
+	if err != nil {
+		return nil, databaseError("Unable to create flow", err)
+	}
+
+	svc.audit.Record(actorID, &orgID, model.AuditEntityFlow, new.ID, model.AuditActionCreate, nil, new)
+
+	return new, nil
+}
+
+func (svc *flowService) Find(orgID uint64) ([]*model.Flow, *types.ApplicationError) {
+	flowSet, err := svc.repo.Find(orgID)
+	if err != nil {
+		return nil, databaseError("Unable to find flows", err)
+	}
+	return flowSet, nil
+}
+
+func (svc *flowService) FindByID(orgID uint64, id uint64) (*model.Flow, *types.ApplicationError) {
+	flow, err := svc.repo.FindByID(orgID, id)
+	if err != nil {
+		return nil, databaseError("Unable to find flow by id", err)
+	}
+	return flow, nil
+}
+
+// Applies an RFC 7396 merge patch. Only the fields present in the patch are validated and
+// written, and a patch which changes nothing returns the current flow untouched. Sessions
+// already running go on with the new definition from the node they are at.
+func (svc *flowService) PatchByID(orgID uint64, patch []byte, id uint64, version uint64, actorID uint64) (*model.Flow, *types.ApplicationError) {
+	current, err := svc.repo.FindByID(orgID, id)
+	if err != nil {
+		return nil, databaseError("Unable to update flow", err)
+	}
+
+	if version > 0 && current.Version != version {
+		return nil, databaseError("Unable to update flow", repository.ErrVersionMismatch)
+	}
+
+	var patched model.Flow
+	fields, appErr := applyMergePatch(current, patch, &patched)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	patched.Normalise()
+	details := patched.ValidatePartial(fields)
+	if len(details) > 0 {
+		return nil, types.NewFieldValidationError(details...)
+	}
+
+	changes := changedColumns(current, &patched, fields)
+	if len(changes) == 0 {
+		return current, nil
+	}
+
+	appErr = svc.checkReferences(&patched)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	updatedFlow, err := svc.repo.PatchByID(id, current.Version, changes)
+	if err != nil {
+		return nil, databaseError("Unable to update flow", err)
+	}
+
+	svc.audit.Record(actorID, &orgID, model.AuditEntityFlow, updatedFlow.ID, model.AuditActionUpdate, current, updatedFlow)
+
+	return updatedFlow, nil
+}
+
+func (svc *flowService) DeleteByID(orgID uint64, id uint64, version uint64, actorID uint64) *types.ApplicationError {
+	before, err := svc.repo.FindByID(orgID, id)
+	if err != nil {
+		return databaseError("Unable to delete flow", err)
+	}
+
+	err = svc.repo.DeleteByID(orgID, id, version)
+	if err != nil {
+		return databaseError("Unable to delete flow", err)
+	}
+
+	svc.audit.Record(actorID, &orgID, model.AuditEntityFlow, id, model.AuditActionDelete, before, nil)
+
+	return nil
+}
+
+func (svc *flowService) FindSessions(orgID uint64, id uint64, filter *model.FlowSessionFilter) ([]*model.FlowSession, *model.Pagination, *types.ApplicationError) {
+	_, appErr := svc.FindByID(orgID, id)
+	if appErr != nil {
+		return nil, nil, appErr
+	}
+
+	filter.Pagination.Normalise()
+
+	sessionSet, total, err := svc.sessionRepo.Find(orgID, id, filter)
+	if err != nil {
+		return nil, nil, databaseError("Unable to find flow sessions", err)
+	}
+
+	page := filter.Pagination
+	page.Total = total
+	return sessionSet, &page, nil
+}
+
+// Plays the messages through the flow the way a conversation would, returning every step it
+// took. Nothing is sent, no contact is changed and no webhook is called.
+func (svc *flowService) Simulate(orgID uint64, id uint64, simulation *model.FlowSimulation) (*model.FlowSimulationResult, *types.ApplicationError) {
+	validationErrors := simulation.ValidateFields()
+	if len(validationErrors) > 0 {
+		return nil, types.NewValidationError(validationErrors)
+	}
+
+	flow, appErr := svc.FindByID(orgID, id)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	definition := flow.Definition
+	if simulation.Definition != nil {
+		definition = *simulation.Definition
+		definition.Normalise()
+		details := definition.Validate("definition")
+		if len(details) > 0 {
+			return nil, types.NewFieldValidationError(details...)
+		}
+	}
+
+	contact := &model.Contact{}
+	if simulation.ContactID > 0 {
+		found, err := svc.contactRepo.FindByID(orgID, simulation.ContactID)
+		if err == sql.ErrNoRows {
+			return nil, types.NewFieldValidationError(types.FieldError{Field: "contact_id", Rule: "exists", Message: "must refer to a contact of the organisation"})
+		}
+		if err != nil {
+			return nil, databaseError("Unable to find contact by id", err)
+		}
+		contact = found
+	}
+
+	attributes := model.JSONMap{}
+	for key, value := range contact.Attributes {
+		attributes[key] = value
+	}
+	for key, value := range simulation.Attributes {
+		attributes[key] = value
+	}
+	contact.Attributes = attributes
+
+	run := &flowRun{
+		definition: &definition,
+		session: &model.FlowSession{
+			OrganisationID: orgID,
+			FlowID:         flow.ID,
+			ContactID:      contact.ID,
+			NodeID:         definition.Start,
+			Status:         model.FlowSessionStatusActive,
+			Variables:      model.JSONMap{},
+		},
+		contact: contact,
+		effects: &simulatedFlowEffects{responses: simulation.WebhookResponses},
+	}
+
+	for i, text := range simulation.Messages {
+		if run.session.Status != model.FlowSessionStatusActive {
+			break
+		}
+
+		run.step(model.FlowStep{Action: model.FlowStepReceive, Text: text})
+		if i == 0 {
+			run.start()
+		} else {
+			run.answer(text)
+		}
+	}
+
+	return &model.FlowSimulationResult{
+		Triggered:  flow.Enabled && flow.Matches(simulation.Messages[0]),
+		Status:     run.session.Status,
+		NodeID:     run.session.NodeID,
+		Variables:  run.session.Variables,
+		Attributes: contact.Attributes,
+		Steps:      run.steps,
+	}, nil
+}
+
+// Passes an inbound message to the flow its conversation is in, or starts the first enabled
+// flow whose trigger the message matches. Flows disabled meanwhile still finish the sessions
+// they started. Reports whether a flow took the message, which then gets no other reply.
+func (svc *flowService) HandleInbound(account *model.WhatsAppAccount, contact *model.Contact, message *model.Message) (bool, *types.ApplicationError) {
+	if message.ConversationID == nil || contact.OptInStatus == model.OptInStatusOptedOut {
+		return false, nil
+	}
+
+	session, appErr := svc.activeSession(account.OrganisationID, *message.ConversationID)
+	if appErr != nil {
+		return false, appErr
+	}
+
+	var run *flowRun
+	if session != nil {
+		flow, err := svc.repo.FindByID(account.OrganisationID, session.FlowID)
+		if err != nil && err != sql.ErrNoRows {
+			return false, databaseError("Unable to find flow by id", err)
+		}
+
+		if err == nil {
+			run = svc.newRun(account, contact, &flow.Definition, session)
+			run.step(model.FlowStep{Action: model.FlowStepReceive, Text: message.Body})
+			run.answer(message.Body)
+		} else {
+			// The flow was deleted, so the message may start another one
+			svc.end(session, model.FlowSessionStatusFailed, "Flow was deleted")
+		}
+	}
+
+	if run == nil {
+		if message.Type != model.MessageTypeText && message.Type != model.MessageTypeButton {
+			return false, nil
+		}
+
+		flows, err := svc.repo.FindEnabled(account.OrganisationID, account.ID)
+		if err != nil {
+			return false, databaseError("Unable to find flows", err)
+		}
+
+		var flow *model.Flow
+		for _, candidate := range flows {
+			if candidate.Matches(message.Body) {
+				flow = candidate
+				break
+			}
+		}
+		if flow == nil {
+			return false, nil
+		}
+
+		session, err = svc.sessionRepo.Start(&model.FlowSession{
+			OrganisationID:    account.OrganisationID,
+			FlowID:            flow.ID,
+			WhatsAppAccountID: account.ID,
+			ConversationID:    *message.ConversationID,
+			ContactID:         contact.ID,
+			NodeID:            flow.Definition.Start,
+			Variables:         model.JSONMap{},
+		})
+		if err == sql.ErrNoRows {
+			// Another message of the conversation started a flow at the same time
+			return true, nil
+		}
+		if err != nil {
+			return false, databaseError("Unable to start flow", err)
+		}
+
+		run = svc.newRun(account, contact, &flow.Definition, session)
+		run.step(model.FlowStep{Action: model.FlowStepReceive, Text: message.Body})
+		run.start()
+	}
+
+	_, err := svc.sessionRepo.Save(run.session)
+	if err != nil && err != sql.ErrNoRows {
+		return true, databaseError("Unable to save flow session", err)
+	}
+
+	if run.session.Status == model.FlowSessionStatusFailed {
+		logger.Warning(fmt.Sprintf("Flow %d failed in conversation %d. Error: %s", run.session.FlowID, run.session.ConversationID, run.session.LastError))
+	}
+
+	return true, nil
+}
+
+// Returns the session the conversation is in, ending it instead when it was left unanswered
+// for longer than the customer service window
+func (svc *flowService) activeSession(orgID uint64, conversationID uint64) (*model.FlowSession, *types.ApplicationError) {
+	session, err := svc.sessionRepo.FindActive(conversationID)
+	if err == sql.ErrNoRows || (err == nil && session.OrganisationID != orgID) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, databaseError("Unable to find flow session", err)
+	}
+
+	if session.IsStale(time.Now(), model.CustomerServiceWindow) {
+		svc.end(session, model.FlowSessionStatusExpired, "")
+		return nil, nil
+	}
+	return session, nil
+}
+
+func (svc *flowService) end(session *model.FlowSession, status string, lastError string) {
+	now := time.Now()
+	session.Status = status
+	session.LastError = lastError
+	session.EndedAt = &now
+
+	_, err := svc.sessionRepo.Save(session)
+	if err != nil && err != sql.ErrNoRows {
+		logger.Warning(fmt.Sprintf("Unable to end flow session %d. Error: %s", session.ID, err.Error()))
+	}
+}
+
+func (svc *flowService) newRun(account *model.WhatsAppAccount, contact *model.Contact, definition *model.FlowDefinition, session *model.FlowSession) *flowRun {
+	if session.Variables == nil {
+		session.Variables = model.JSONMap{}
+	}
+	if contact.Attributes == nil {
+		contact.Attributes = model.JSONMap{}
+	}
+
+	return &flowRun{
+		definition: definition,
+		session:    session,
+		contact:    contact,
+		effects: &liveFlowEffects{
+			svc:            svc,
+			account:        account,
+			contact:        contact,
+			conversationID: session.ConversationID,
+		},
+	}
+}
+
+// Checks the account the flow is limited to, and the members its handovers assign to, belong
+// to the organisation
+func (svc *flowService) checkReferences(flow *model.Flow) *types.ApplicationError {
+	if flow.WhatsAppAccountID != nil {
+		_, appErr := svc.accounts.FindByID(flow.OrganisationID, *flow.WhatsAppAccountID)
+		if appErr != nil {
+			if appErr.Code == types.CodeNotFound {
+				return types.NewFieldValidationError(types.FieldError{Field: "whatsapp_account_id", Rule: "exists", Message: "must refer to an account of the organisation"})
+			}
+			return appErr
+		}
+	}
+
+	for i, node := range flow.Definition.Nodes {
+		if node.Type != model.FlowNodeHandover || node.AssigneeID == nil {
+			continue
+		}
+
+		appErr := svc.members.Require(flow.OrganisationID, *node.AssigneeID)
+		if appErr != nil {
+			if appErr.Code == types.CodeForbidden {
+				return types.NewFieldValidationError(types.FieldError{Field: fmt.Sprintf("definition.nodes[%d].assignee_id", i), Rule: "member", Message: "must refer to a member of the organisation"})
+			}
+			return appErr
+		}
+	}
+
+	return nil
+}
+
+// Side effects of the nodes. Inbound messages take them for real, the simulator only
+// pretends to.
+type flowEffects interface {
+	send(text string) error
+	setAttribute(key string, value interface{}) error
+	callWebhook(node *model.FlowNode, body []byte) (int, []byte, error)
+	handover(node *model.FlowNode) error
+}
+
+// Walks a session through the nodes of a flow until it waits for an answer or ends
+type flowRun struct {
+	definition *model.FlowDefinition
+	session    *model.FlowSession
+	contact    *model.Contact
+	effects    flowEffects
+	steps      []model.FlowStep
+}
+
+func (run *flowRun) start() {
+	run.walk(run.definition.Start)
+}
+
+// Takes the message as the answer to the question the session waits at. Invalid answers get
+// the question again until the node's retries run out.
+func (run *flowRun) answer(text string) {
+	node := run.definition.Node(run.session.NodeID)
+	if node == nil || node.Type != model.FlowNodeAskQuestion {
+		run.fail(run.session.NodeID, "Flow no longer has the question "+run.session.NodeID)
+		return
+	}
+
+	value, ok := node.Validation.Check(text)
+	if !ok {
+		run.session.Retries++
+		if run.session.Retries > node.MaxRetries {
+			run.step(model.FlowStep{NodeID: node.ID, Action: model.FlowStepInvalidAnswer, Text: text, Next: node.OnInvalid})
+			if node.OnInvalid == "" {
+				run.fail(node.ID, fmt.Sprintf("No valid answer after %d attempt(s)", run.session.Retries))
+				return
+			}
+
+			run.session.Retries = 0
+			run.walk(node.OnInvalid)
+			return
+		}
+
+		run.step(model.FlowStep{NodeID: node.ID, Action: model.FlowStepInvalidAnswer, Text: text})
+		retry := node.RetryText
+		if retry == "" {
+			retry = node.Text
+		}
+		run.send(node, retry, model.FlowStepAsk)
+		return
+	}
+
+	run.session.Retries = 0
+	run.session.Variables[model.FlowFieldAnswer] = value
+	run.session.Variables[node.ID] = value
+	run.step(model.FlowStep{NodeID: node.ID, Action: model.FlowStepAnswer, Value: value, Next: node.Next})
+
+	if node.Attribute != "" && !run.setAttribute(node, value) {
+		return
+	}
+	run.walk(node.Next)
+}
+
+func (run *flowRun) walk(nodeID string) {
+	for steps := 0; ; steps++ {
+		if nodeID == "" {
+			run.step(model.FlowStep{NodeID: run.session.NodeID, Action: model.FlowStepComplete})
+			run.end(model.FlowSessionStatusCompleted, "")
+			return
+		}
+
+		if steps == maxFlowSteps {
+			run.fail(nodeID, fmt.Sprintf("Flow ran %d nodes without waiting for an answer, it may loop", maxFlowSteps))
+			return
+		}
+
+		node := run.definition.Node(nodeID)
+		if node == nil {
+			run.fail(nodeID, "Flow has no node "+nodeID)
+			return
+		}
+		run.session.NodeID = node.ID
+
+		switch node.Type {
+		case model.FlowNodeSendMessage:
+			if !run.send(node, node.Text, model.FlowStepSend) {
+				return
+			}
+			nodeID = node.Next
+
+		case model.FlowNodeAskQuestion:
+			run.session.Retries = 0
+			run.send(node, node.Text, model.FlowStepAsk)
+			return
+
+		case model.FlowNodeBranch:
+			nodeID = run.branch(node)
+
+		case model.FlowNodeSetAttribute:
+			if !run.setAttribute(node, run.session.Render(node.Value, run.contact)) {
+				return
+			}
+			nodeID = node.Next
+
+		case model.FlowNodeCallWebhook:
+			next, ok := run.callWebhook(node)
+			if !ok {
+				return
+			}
+			nodeID = next
+
+		case model.FlowNodeHandover:
+			if node.Text != "" && !run.send(node, node.Text, model.FlowStepSend) {
+				return
+			}
+
+			err := run.effects.handover(node)
+			if err != nil {
+				run.fail(node.ID, "Unable to hand the conversation over. Error: "+err.Error())
+				return
+			}
+
+			var assignee interface{}
+			if node.AssigneeID != nil {
+				assignee = *node.AssigneeID
+			}
+			run.step(model.FlowStep{NodeID: node.ID, Action: model.FlowStepHandover, Value: assignee})
+			run.end(model.FlowSessionStatusHandedOver, "")
+			return
+
+		default:
+			run.fail(node.ID, "Flow has a node of unknown type "+node.Type)
+			return
+		}
+	}
+}
+
+// Returns the node of the first condition holding, or the default
+func (run *flowRun) branch(node *model.FlowNode) string {
+	for _, condition := range node.Conditions {
+		value, found := run.session.Lookup(condition.Field, run.contact)
+		if condition.Holds(value, found) {
+			run.step(model.FlowStep{NodeID: node.ID, Action: model.FlowStepBranch, Value: value, Next: condition.Next})
+			return condition.Next
+		}
+	}
+
+	run.step(model.FlowStep{NodeID: node.ID, Action: model.FlowStepBranch, Next: node.Default})
+	return node.Default
+}
+
+func (run *flowRun) send(node *model.FlowNode, text string, action string) bool {
+	rendered := run.session.Render(text, run.contact)
+
+	err := run.effects.send(rendered)
+	if err != nil {
+		run.fail(node.ID, "Unable to send message. Error: "+err.Error())
+		return false
+	}
+
+	run.step(model.FlowStep{NodeID: node.ID, Action: action, Text: rendered})
+	return true
+}
+
+func (run *flowRun) setAttribute(node *model.FlowNode, value interface{}) bool {
+	err := run.effects.setAttribute(node.Attribute, value)
+	if err != nil {
+		run.fail(node.ID, "Unable to set contact attribute "+node.Attribute+". Error: "+err.Error())
+		return false
+	}
+
+	run.contact.Attributes[node.Attribute] = value
+	run.step(model.FlowStep{NodeID: node.ID, Action: model.FlowStepSetAttribute, Attribute: node.Attribute, Value: value})
+	return true
+}
+
+// Calls the node's webhook, returning the node to go on with. Failed calls go on with the
+// node's error node, or fail the flow without one.
+func (run *flowRun) callWebhook(node *model.FlowNode) (string, bool) {
+	var body []byte
+	if node.Webhook.Method == http.MethodPost {
+		if node.Webhook.Body != "" {
+			body = []byte(run.session.Render(node.Webhook.Body, run.contact))
+		} else {
+			body, _ = json.Marshal(map[string]interface{}{
+				"flow_id":    run.session.FlowID,
+				"session_id": run.session.ID,
+				"contact": map[string]interface{}{
+					"id":           run.contact.ID,
+					"name":         run.contact.Name,
+					"phone_number": run.contact.PhoneNumber,
+					"attributes":   run.contact.Attributes,
+				},
+				"variables": run.session.Variables,
+			})
+		}
+	}
+
+	status, response, err := run.effects.callWebhook(node, body)
+	if err == nil && (status < 200 || status > 299) {
+		err = fmt.Errorf("Webhook answered with status %d", status)
+	}
+	if err != nil {
+		run.step(model.FlowStep{NodeID: node.ID, Action: model.FlowStepCallWebhook, Status: status, Error: err.Error(), Next: node.OnError})
+		if node.OnError == "" {
+			run.fail(node.ID, "Webhook call failed. Error: "+err.Error())
+			return "", false
+		}
+		return node.OnError, true
+	}
+
+	if node.Webhook.SaveAs != "" {
+		var saved interface{}
+		if json.Unmarshal(response, &saved) != nil {
+			saved = string(response)
+		}
+		run.session.Variables[node.Webhook.SaveAs] = saved
+	}
+
+	run.step(model.FlowStep{NodeID: node.ID, Action: model.FlowStepCallWebhook, Status: status, Next: node.Next})
+	return node.Next, true
+}
+
+func (run *flowRun) fail(nodeID string, reason string) {
+	run.step(model.FlowStep{NodeID: nodeID, Action: model.FlowStepFail, Error: reason})
+	run.end(model.FlowSessionStatusFailed, reason)
+}
+
+func (run *flowRun) end(status string, lastError string) {
+	now := time.Now()
+	run.session.Status = status
+	run.session.LastError = lastError
+	run.session.EndedAt = &now
+}
+
+func (run *flowRun) step(step model.FlowStep) {
+	run.steps = append(run.steps, step)
+}
+
+// Takes the effects of a flow running in a real conversation
+type liveFlowEffects struct {
+	svc            *flowService
+	account        *model.WhatsAppAccount
+	contact        *model.Contact
+	conversationID uint64
+}
+
+func (effects *liveFlowEffects) send(text string) error {
+	_, appErr := effects.svc.messages.SendText(effects.account.OrganisationID, &model.SendTextRequest{
+		WhatsAppAccountID: effects.account.ID,
+		To:                effects.contact.PhoneNumber,
+		Text:              model.TextMessageRequest{Body: text},
+	})
+	if appErr != nil {
+		return appErr
+	}
+	return nil
+}
+
+// Writes the attribute onto the latest version of the contact, trying again when the contact
+// changes in between
+func (effects *liveFlowEffects) setAttribute(key string, value interface{}) error {
+	svc := effects.svc
+	orgID := effects.account.OrganisationID
+
+	var err error
+	for attempt := 0; attempt < flowAttributeAttempts; attempt++ {
+		var current *model.Contact
+		current, err = svc.contactRepo.FindByID(orgID, effects.contact.ID)
+		if err != nil {
+			return err
+		}
+
+		attributes := model.JSONMap{}
+		for k, v := range current.Attributes {
+			attributes[k] = v
+		}
+		attributes[key] = value
+
+		var updated *model.Contact
+		updated, err = svc.contactRepo.PatchByID(current.ID, current.Version, map[string]interface{}{"attributes": attributes})
+		if errors.Is(err, repository.ErrVersionMismatch) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		svc.audit.Record(0, &orgID, model.AuditEntityContact, current.ID, model.AuditActionUpdate, current, updated)
+		return nil
+	}
+	return err
+}
+
+func (effects *liveFlowEffects) callWebhook(node *model.FlowNode, body []byte) (int, []byte, error) {
+	timeout := defaultFlowWebhookTimeout
+	if node.Webhook.TimeoutSeconds > 0 {
+		timeout = time.Duration(node.Webhook.TimeoutSeconds) * time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, node.Webhook.Method, node.Webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for name, value := range node.Webhook.Headers {
+		req.Header.Set(name, value)
+	}
+
+	res, err := effects.svc.httpClient.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer res.Body.Close()
+
+	response, err := io.ReadAll(io.LimitReader(res.Body, maxFlowWebhookResponse))
+	if err != nil {
+		return res.StatusCode, nil, err
+	}
+	return res.StatusCode, response, nil
+}
+
+// Opens the conversation for the team inbox, assigned to the node's member when it names one
+func (effects *liveFlowEffects) handover(node *model.FlowNode) error {
+	svc := effects.svc
+	orgID := effects.account.OrganisationID
+
+	before, err := svc.conversationRepo.FindByID(orgID, effects.conversationID)
+	if err != nil {
+		return err
+	}
+
+	updated := before
+	if before.Status != model.ConversationStatusOpen {
+		updated, err = svc.conversationRepo.SetStatus(orgID, before.ID, model.ConversationStatusOpen)
+		if err != nil {
+			return err
+		}
+	}
+
+	if node.AssigneeID != nil {
+		updated, err = svc.conversationRepo.Assign(orgID, before.ID, node.AssigneeID)
+		if err != nil {
+			return err
+		}
+		outboxWake.notify()
+	}
+
+	if updated != before {
+		svc.audit.Record(0, &orgID, model.AuditEntityConversation, before.ID, model.AuditActionUpdate, before, updated)
+	}
+	return nil
+}
+
+// Pretends to take the effects of a flow, for the simulator
+type simulatedFlowEffects struct {
+	responses map[string]model.FlowWebhookResponse
+}
+
+func (effects *simulatedFlowEffects) send(text string) error {
+	return nil
+}
+
+func (effects *simulatedFlowEffects) setAttribute(key string, value interface{}) error {
+	return nil
+}
+
+func (effects *simulatedFlowEffects) callWebhook(node *model.FlowNode, body []byte) (int, []byte, error) {
+	response, ok := effects.responses[node.ID]
+	if !ok || response.Status == 0 {
+		response.Status = http.StatusOK
+	}
+	return response.Status, response.Body, nil
+}
+
+func (effects *simulatedFlowEffects) handover(node *model.FlowNode) error {
+	return nil
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+
+	"github.com/lib/pq"
+	"github.com/supermario64bit/whatsapp_connect/pkg/mergepatch"
+	"github.com/supermario64bit/whatsapp_connect/pkg/whatsapp"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+	"github.com/supermario64bit/whatsapp_connect/server/repository"
+	"github.com/supermario64bit/whatsapp_connect/types"
+)
+
+// Fields which are maintained by the database or the system and can never be written by clients
+var readOnlyFields = map[string]bool{
+	"id":              true,
+	"organisation_id": true,
+	"last_seen_at":    true,
+	"created_at":      true,
+	"updated_at":      true,
+	"deleted_at":      true,
+	"version":         true,
+}
+
+// Applies a merge patch onto current and decodes the outcome into patched. Returns the json
+// names of the fields present in the patch, whether set to a value or to null.
+func applyMergePatch(current interface{}, patch []byte, patched interface{}) ([]string, *types.ApplicationError) {
+	fields, err := mergepatch.Fields(patch)
+	if err != nil {
+		return nil, types.NewBadRequestError("Invalid Merge Patch", err)
+	}
+
+	for _, field := range fields {
+		if readOnlyFields[field] {
+			return nil, types.NewBadRequestError("Invalid Merge Patch", fmt.Errorf("Field %s is read only", field))
+		}
+	}
+
+	original, err := json.Marshal(current)
+	if err != nil {
+		return nil, types.NewInternalError("Unable to apply merge patch", err)
+	}
+
+	merged, err := mergepatch.Apply(original, patch)
+	if err == nil {
+		err = json.Unmarshal(merged, patched)
+	}
+	if err != nil {
+		return nil, types.NewBadRequestError("Invalid Merge Patch", err)
+	}
+
+	return fields, nil
+}
+
+// Maps each of the given json fields whose value differs between current and patched to its db
+// column and new value. Fields without a db column are skipped.
+func changedColumns(current interface{}, patched interface{}, fields []string) map[string]interface{} {
+	wanted := map[string]bool{}
+	for _, field := range fields {
+		wanted[field] = true
+	}
+
+	changes := map[string]interface{}{}
+	before := reflect.Indirect(reflect.ValueOf(current))
+	after := reflect.Indirect(reflect.ValueOf(patched))
+	typ := before.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		column := typ.Field(i).Tag.Get("db")
+		if column == "" || !wanted[model.JSONName(typ.Field(i))] {
+			continue
+		}
+
+		if !reflect.DeepEqual(before.Field(i).Interface(), after.Field(i).Interface()) {
+			changes[column] = after.Field(i).Interface()
+		}
+	}
+
+	return changes
+}
+
+// Maps an error returned by a repository onto the application error clients should see
+func databaseError(message string, err error) *types.ApplicationError {
+	if errors.Is(err, sql.ErrNoRows) {
+		return types.NewNotFoundError(message, errors.New("No record available for the given id"))
+	}
+
+	if errors.Is(err, repository.ErrVersionMismatch) {
+		return types.NewPreconditionFailedError(message, err)
+	}
+
+	var constraintErr *repository.ConstraintError
+	if errors.As(err, &constraintErr) {
+		return constraintError(message, constraintErr)
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code.Name() {
+		case "unique_violation", "exclusion_violation", "foreign_key_violation":
+			return types.NewConflictError(message, errors.New(pqErr.Message))
+		case "check_violation", "not_null_violation", "string_data_right_truncation":
+			return types.NewBadRequestError(message, errors.New(pqErr.Message))
+		}
+	}
+
+	return types.NewInternalError(message, err)
+}
+
+func constraintError(message string, err *repository.ConstraintError) *types.ApplicationError {
+	detail := types.FieldError{Field: err.Field, Rule: err.Kind}
+
+	switch err.Kind {
+	case repository.ConstraintUnique:
+		detail.Message = "is already in use"
+		return types.NewConflictError(message, fmt.Errorf("%s is already in use", err.Field), detail)
+	case repository.ConstraintForeignKey:
+		detail.Message = "refers to a record which does not exist or is still referenced"
+		return types.NewConflictError(message, err, detail)
+	case repository.ConstraintCheck:
+		detail.Message = "has a value which is not allowed"
+		return types.NewFieldValidationError(detail)
+	case repository.ConstraintNotNull:
+		detail.Message = "is required"
+		return types.NewFieldValidationError(detail)
+	}
+
+	return types.NewInternalError(message, err)
+}
+
+// Maps an error returned by the Graph API client onto the application error clients should see.
+// Requests Meta rejected as invalid are the client's to fix, anything else is an upstream failure.
+func graphError(message string, err error) *types.ApplicationError {
+	var apiErr *whatsapp.APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusBadRequest {
+		return types.NewBadRequestError(message, apiErr)
+	}
+
+	return types.NewUpstreamError(message, err)
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/supermario64bit/whatsapp_connect/pkg/logger"
+	"github.com/supermario64bit/whatsapp_connect/pkg/whatsapp"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+	"github.com/supermario64bit/whatsapp_connect/server/repository"
+	"github.com/supermario64bit/whatsapp_connect/types"
+)
+
+const (
+	// Retries wait twice as long each time, from the base delay up to the max delay
+	inboundMediaJobBaseDelay = 5 * time.Second
+	inboundMediaJobMaxDelay  = 15 * time.Minute
+	// Workers started when INBOUND_MEDIA_WORKERS is not set
+	defaultInboundMediaWorkers = 2
+)
+
+var inboundMediaWake = newQueueWake()
+
+type inboundMediaJobService struct {
+	repo     repository.InboundMediaJobRepository
+	accounts WhatsAppAccountService
+	messages MessageService
+	media    MediaService
+}
+
+type InboundMediaJobService interface {
+	Run()
+}
+
+func NewInboundMediaJobService() InboundMediaJobService {
+	return &inboundMediaJobService{
+		repo:     repository.NewInboundMediaJobRepository(),
+		accounts: NewWhatsAppAccountService(),
+		messages: NewMessageService(),
+		media:    NewMediaService(),
+	}
+}
+
+// Runs the workers downloading the media of inbound messages until the process exits. Every
+// instance may run them, each job is handled by one worker at a time.
+func (svc *inboundMediaJobService) Run() {
+	queue := &leaseQueue[*model.InboundMediaJob]{
+		name:           "inbound media job",
+		workersEnv:     "INBOUND_MEDIA_WORKERS",
+		defaultWorkers: defaultInboundMediaWorkers,
+		wake:           inboundMediaWake,
+		claim:          svc.repo.Claim,
+		process:        svc.process,
+	}
+	queue.run()
+}
+
+// Downloads the media into our storage and links it to its message. Media Meta refuses or which
+// exceeds the size limits is given up right away, other failures are retried with backoff.
+func (svc *inboundMediaJobService) process(job *model.InboundMediaJob) {
+	var inbound whatsapp.InboundMedia
+	err := json.Unmarshal(job.Payload, &inbound)
+	if err != nil {
+		svc.bury(job, "Invalid media payload. Error: "+err.Error())
+		return
+	}
+
+	message, appErr := svc.messages.FindByID(job.OrganisationID, job.MessageID)
+	if appErr != nil {
+		svc.fail(job, appErr)
+		return
+	}
+
+	if message.MediaID == nil {
+		account, appErr := svc.accounts.FindByID(job.OrganisationID, job.WhatsAppAccountID)
+		if appErr != nil {
+			svc.fail(job, appErr)
+			return
+		}
+
+		media, appErr := svc.media.StoreInbound(account, message.Type, &inbound)
+		if appErr != nil {
+			svc.fail(job, appErr)
+			return
+		}
+
+		_, appErr = svc.messages.AttachMedia(message.ID, media)
+		if appErr != nil {
+			svc.fail(job, appErr)
+			return
+		}
+	}
+
+	err = svc.repo.Succeed(job, time.Now())
+	if err != nil {
+		warnLeaseRetry(fmt.Sprintf("complete inbound media job %d", job.ID), err)
+	}
+}
+
+// Gives the job up when the failure is for good, otherwise tries it again later
+func (svc *inboundMediaJobService) fail(job *model.InboundMediaJob, appErr *types.ApplicationError) {
+	if appErr.Code == types.CodeNotFound || appErr.Code == types.CodeBadRequest {
+		svc.bury(job, appErr.Error())
+		return
+	}
+
+	if job.Attempts >= job.MaxAttempts {
+		svc.bury(job, appErr.Error())
+		return
+	}
+
+	err := svc.repo.Retry(job, time.Now().Add(retryBackoff(job.Attempts, inboundMediaJobBaseDelay, inboundMediaJobMaxDelay)), appErr.Error())
+	if err != nil {
+		warnLeaseRetry(fmt.Sprintf("reschedule inbound media job %d", job.ID), err)
+	}
+}
+
+func (svc *inboundMediaJobService) bury(job *model.InboundMediaJob, lastError string) {
+	err := svc.repo.Bury(job, time.Now(), lastError)
+	if err != nil {
+		warnLeaseRetry(fmt.Sprintf("give up inbound media job %d", job.ID), err)
+		return
+	}
+
+	logger.Danger(fmt.Sprintf("Gave up storing media of inbound message %d after %d attempt(s). Error: %s", job.MessageID, job.Attempts, lastError))
+}
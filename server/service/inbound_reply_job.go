@@ -0,0 +1,150 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/supermario64bit/whatsapp_connect/pkg/logger"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+	"github.com/supermario64bit/whatsapp_connect/server/repository"
+	"github.com/supermario64bit/whatsapp_connect/types"
+)
+
+const (
+	// Retries wait twice as long each time, from the base delay up to the max delay
+	inboundReplyJobBaseDelay = 5 * time.Second
+	inboundReplyJobMaxDelay  = 5 * time.Minute
+	// Workers started when INBOUND_REPLY_WORKERS is not set
+	defaultInboundReplyWorkers = 4
+)
+
+var inboundReplyWake = newQueueWake()
+
+type inboundReplyJobService struct {
+	repo          repository.InboundReplyJobRepository
+	messages      MessageService
+	accounts      WhatsAppAccountService
+	contacts      ContactService
+	consents      ConsentService
+	flows         FlowService
+	businessHours BusinessHoursService
+	autoReplies   AutoReplyRuleService
+}
+
+type InboundReplyJobService interface {
+	Run()
+}
+
+func NewInboundReplyJobService() InboundReplyJobService {
+	return &inboundReplyJobService{
+		repo:          repository.NewInboundReplyJobRepository(),
+		messages:      NewMessageService(),
+		accounts:      NewWhatsAppAccountService(),
+		contacts:      NewContactService(),
+		consents:      NewConsentService(),
+		flows:         NewFlowService(),
+		businessHours: NewBusinessHoursService(),
+		autoReplies:   NewAutoReplyRuleService(),
+	}
+}
+
+// Runs the workers answering inbound messages until the process exits. Every instance may run
+// them, each conversation is answered by one worker at a time.
+func (svc *inboundReplyJobService) Run() {
+	queue := &leaseQueue[*model.InboundReplyJob]{
+		name:           "inbound reply job",
+		workersEnv:     "INBOUND_REPLY_WORKERS",
+		defaultWorkers: defaultInboundReplyWorkers,
+		wake:           inboundReplyWake,
+		claim:          svc.repo.Claim,
+		process:        svc.process,
+	}
+	queue.run()
+}
+
+// Applies the consent keyword the message may hold, then answers it. Messages a flow takes get
+// no greeting, away message or auto reply. A failed attempt is retried as a whole, so a reply
+// sent before the failure may be sent again.
+func (svc *inboundReplyJobService) process(job *model.InboundReplyJob) {
+	message, appErr := svc.messages.FindByID(job.OrganisationID, job.MessageID)
+	if appErr != nil {
+		svc.fail(job, appErr)
+		return
+	}
+
+	account, appErr := svc.accounts.FindByID(job.OrganisationID, message.WhatsAppAccountID)
+	if appErr != nil {
+		svc.fail(job, appErr)
+		return
+	}
+
+	contact, appErr := svc.contacts.FindByID(job.OrganisationID, message.ContactID)
+	if appErr != nil {
+		svc.fail(job, appErr)
+		return
+	}
+
+	// Replies must see an opt-out the message itself sends
+	appErr = svc.consents.HandleInbound(contact, message)
+	if appErr != nil {
+		svc.retry(job, fmt.Errorf("Unable to apply consent keyword. Error: %s", appErr.Error()))
+		return
+	}
+
+	handled, appErr := svc.flows.HandleInbound(account, contact, message)
+	if appErr != nil {
+		svc.retry(job, fmt.Errorf("Unable to run flow. Error: %s", appErr.Error()))
+		return
+	}
+
+	if !handled {
+		appErr = svc.businessHours.HandleInbound(account, contact, message)
+		if appErr != nil {
+			svc.retry(job, fmt.Errorf("Unable to send away or greeting message. Error: %s", appErr.Error()))
+			return
+		}
+
+		appErr = svc.autoReplies.HandleInbound(account, contact, message)
+		if appErr != nil {
+			svc.retry(job, fmt.Errorf("Unable to auto reply. Error: %s", appErr.Error()))
+			return
+		}
+	}
+
+	err := svc.repo.Succeed(job, time.Now())
+	if err != nil {
+		warnLeaseRetry(fmt.Sprintf("complete inbound reply job %d", job.ID), err)
+	}
+}
+
+// Gives the job up when what it answers was deleted, otherwise tries it again later
+func (svc *inboundReplyJobService) fail(job *model.InboundReplyJob, appErr *types.ApplicationError) {
+	if appErr.Code == types.CodeNotFound {
+		svc.bury(job, appErr.Error())
+		return
+	}
+	svc.retry(job, appErr)
+}
+
+// Tries the job again later, or gives it up once it ran out of attempts
+func (svc *inboundReplyJobService) retry(job *model.InboundReplyJob, cause error) {
+	if job.Attempts >= job.MaxAttempts {
+		svc.bury(job, cause.Error())
+		return
+	}
+
+	err := svc.repo.Retry(job, time.Now().Add(retryBackoff(job.Attempts, inboundReplyJobBaseDelay, inboundReplyJobMaxDelay)), cause.Error())
+	if err != nil {
+		warnLeaseRetry(fmt.Sprintf("reschedule inbound reply job %d", job.ID), err)
+	}
+}
+
+func (svc *inboundReplyJobService) bury(job *model.InboundReplyJob, lastError string) {
+	err := svc.repo.Bury(job, time.Now(), lastError)
+	if err != nil {
+		warnLeaseRetry(fmt.Sprintf("give up inbound reply job %d", job.ID), err)
+		return
+	}
+
+	logger.Warning(fmt.Sprintf("Gave up answering inbound message %d after %d attempt(s). Error: %s", job.MessageID, job.Attempts, lastError))
+}
@@ -0,0 +1,235 @@
+package service
+
+import (
+	"strings"
+	"time"
+
+	"github.com/supermario64bit/whatsapp_connect/pkg/logger"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+	"github.com/supermario64bit/whatsapp_connect/server/repository"
+	"github.com/supermario64bit/whatsapp_connect/types"
+)
+
+// Shared team inbox. Every action is taken by a member of the organisation on one of its
+// conversations.
+type inboxService struct {
+	conversationRepo repository.ConversationRepository
+	noteRepo         repository.ConversationNoteRepository
+	conversations    ConversationService
+	messages         MessageService
+	contacts         ContactService
+	members          OrganisationMemberService
+	audit            AuditService
+}
+
+type InboxService interface {
+	Find(orgID uint64, filter *model.ConversationFilter, actorID uint64) ([]*model.Conversation, *model.Pagination, *types.ApplicationError)
+	Assign(orgID uint64, id uint64, assignment *model.ConversationAssignment, actorID uint64) (*model.Conversation, *types.ApplicationError)
+	Unassign(orgID uint64, id uint64, actorID uint64) (*model.Conversation, *types.ApplicationError)
+	SetStatus(orgID uint64, id uint64, change *model.ConversationStatusChange, actorID uint64) (*model.Conversation, *types.ApplicationError)
+	MarkRead(orgID uint64, id uint64, actorID uint64) (*model.Conversation, *types.ApplicationError)
+	AddNote(orgID uint64, id uint64, note *model.ConversationNote, actorID uint64) (*model.ConversationNote, *types.ApplicationError)
+	FindNotes(orgID uint64, id uint64, actorID uint64) ([]*model.ConversationNote, *types.ApplicationError)
+	Reply(orgID uint64, id uint64, reply *model.TextMessageRequest, actorID uint64) (*model.Message, *types.ApplicationError)
+}
+
+func NewInboxService() InboxService {
+	return &inboxService{
+		conversationRepo: repository.NewConversationRepository(),
+		noteRepo:         repository.NewConversationNoteRepository(),
+		conversations:    NewConversationService(),
+		messages:         NewMessageService(),
+		contacts:         NewContactService(),
+		members:          NewOrganisationMemberService(),
+		audit:            NewAuditService(),
+	}
+}
+
+// Lists the organisation's conversations, the open ones unless another status is asked for
+func (svc *inboxService) Find(orgID uint64, filter *model.ConversationFilter, actorID uint64) ([]*model.Conversation, *model.Pagination, *types.ApplicationError) {
+	appErr := svc.members.Require(orgID, actorID)
+	if appErr != nil {
+		return nil, nil, appErr
+	}
+
+	if filter.Status == "" {
+		filter.Status = model.ConversationStatusOpen
+	}
+	return svc.conversations.Find(orgID, filter)
+}
+
+// Assigns the conversation to a member of the organisation
+func (svc *inboxService) Assign(orgID uint64, id uint64, assignment *model.ConversationAssignment, actorID uint64) (*model.Conversation, *types.ApplicationError) {
+	validationErrors := assignment.ValidateFields()
+	if len(validationErrors) > 0 {
+		return nil, types.NewValidationError(validationErrors)
+	}
+
+	before, appErr := svc.find(orgID, id, actorID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	appErr = svc.members.Require(orgID, assignment.UserID)
+	if appErr != nil {
+		if appErr.Code == types.CodeForbidden {
+			return nil, types.NewFieldValidationError(types.FieldError{Field: "user_id", Rule: "member", Message: "must refer to a member of the organisation"})
+		}
+		return nil, appErr
+	}
+
+	updated, err := svc.conversationRepo.Assign(orgID, id, &assignment.UserID)
+	if err != nil {
+		return nil, databaseError("Unable to assign conversation", err)
+	}
+
+	svc.audit.Record(actorID, &orgID, model.AuditEntityConversation, id, model.AuditActionUpdate, before, updated)
+	outboxWake.notify()
+
+	return updated, nil
+}
+
+func (svc *inboxService) Unassign(orgID uint64, id uint64, actorID uint64) (*model.Conversation, *types.ApplicationError) {
+	before, appErr := svc.find(orgID, id, actorID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	if before.AssigneeID == nil {
+		return before, nil
+	}
+
+	updated, err := svc.conversationRepo.Assign(orgID, id, nil)
+	if err != nil {
+		return nil, databaseError("Unable to unassign conversation", err)
+	}
+
+	svc.audit.Record(actorID, &orgID, model.AuditEntityConversation, id, model.AuditActionUpdate, before, updated)
+	outboxWake.notify()
+
+	return updated, nil
+}
+
+func (svc *inboxService) SetStatus(orgID uint64, id uint64, change *model.ConversationStatusChange, actorID uint64) (*model.Conversation, *types.ApplicationError) {
+	validationErrors := change.ValidateFields()
+	if len(validationErrors) > 0 {
+		return nil, types.NewValidationError(validationErrors)
+	}
+
+	before, appErr := svc.find(orgID, id, actorID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	if before.Status == change.Status {
+		return before, nil
+	}
+
+	updated, err := svc.conversationRepo.SetStatus(orgID, id, change.Status)
+	if err != nil {
+		return nil, databaseError("Unable to change conversation status", err)
+	}
+
+	svc.audit.Record(actorID, &orgID, model.AuditEntityConversation, id, model.AuditActionUpdate, before, updated)
+
+	return updated, nil
+}
+
+// Clears the unread count once an agent has read the conversation
+func (svc *inboxService) MarkRead(orgID uint64, id uint64, actorID uint64) (*model.Conversation, *types.ApplicationError) {
+	_, appErr := svc.find(orgID, id, actorID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	updated, err := svc.conversationRepo.MarkRead(orgID, id, time.Now())
+	if err != nil {
+		return nil, databaseError("Unable to mark conversation as read", err)
+	}
+	return updated, nil
+}
+
+// Leaves an internal note on the conversation. Notes are never sent to the contact.
+func (svc *inboxService) AddNote(orgID uint64, id uint64, note *model.ConversationNote, actorID uint64) (*model.ConversationNote, *types.ApplicationError) {
+	_, appErr := svc.find(orgID, id, actorID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	note.OrganisationID = orgID
+	note.ConversationID = id
+	note.AuthorID = actorID
+	validationErrors := note.ValidateFields()
+	if len(validationErrors) > 0 {
+		return nil, types.NewValidationError(validationErrors)
+	}
+
+	new, err := svc.noteRepo.Create(note)
+	if err != nil {
+		return nil, databaseError("Unable to add conversation note", err)
+	}
+
+	svc.audit.Record(actorID, &orgID, model.AuditEntityNote, new.ID, model.AuditActionCreate, nil, new)
+
+	return new, nil
+}
+
+func (svc *inboxService) FindNotes(orgID uint64, id uint64, actorID uint64) ([]*model.ConversationNote, *types.ApplicationError) {
+	_, appErr := svc.find(orgID, id, actorID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	noteSet, err := svc.noteRepo.Find(id)
+	if err != nil {
+		return nil, databaseError("Unable to find conversation notes", err)
+	}
+	return noteSet, nil
+}
+
+// Sends a free-form reply to the contact from the conversation's account. Replying also marks
+// the conversation read.
+func (svc *inboxService) Reply(orgID uint64, id uint64, reply *model.TextMessageRequest, actorID uint64) (*model.Message, *types.ApplicationError) {
+	reply.Body = strings.TrimSpace(reply.Body)
+	validationErrors := reply.ValidateFields()
+	if len(validationErrors) > 0 {
+		return nil, types.NewValidationError(validationErrors)
+	}
+
+	conversation, appErr := svc.find(orgID, id, actorID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	contact, appErr := svc.contacts.FindByID(orgID, conversation.ContactID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	message, appErr := svc.messages.SendText(orgID, &model.SendTextRequest{
+		WhatsAppAccountID: conversation.WhatsAppAccountID,
+		To:                contact.PhoneNumber,
+		Text:              *reply,
+	})
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	_, err := svc.conversationRepo.MarkRead(orgID, id, time.Now())
+	if err != nil {
+		// The reply is on its way, so it must not look like it failed
+		logger.Warning("Unable to mark conversation as read after reply. Error: " + err.Error())
+	}
+
+	return message, nil
+}
+
+// Returns the conversation after checking the actor may work the organisation's inbox
+func (svc *inboxService) find(orgID uint64, id uint64, actorID uint64) (*model.Conversation, *types.ApplicationError) {
+	appErr := svc.members.Require(orgID, actorID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	return svc.conversations.FindByID(orgID, id)
+}
@@ -0,0 +1,217 @@
+package service
+
+import (
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/supermario64bit/whatsapp_connect/db"
+	"github.com/supermario64bit/whatsapp_connect/pkg/logger"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+	"github.com/supermario64bit/whatsapp_connect/server/repository"
+	"github.com/supermario64bit/whatsapp_connect/types"
+)
+
+const (
+	// Events kept for reconnecting clients to resume from
+	inboxEventRetention = 24 * time.Hour
+	// Most events replayed to a resuming client
+	inboxEventReplayLimit = 1000
+	// Events buffered per connection. A connection falling this far behind is closed, and its
+	// client resumes from the last event it received.
+	inboxEventBuffer = 64
+)
+
+// Live connection to an organisation's inbox events
+type InboxEventSubscription struct {
+	orgID  uint64
+	filter model.InboxEventFilter
+	events chan *model.InboxEvent
+}
+
+// Delivers the events of the subscription. Closed when the subscription falls behind.
+func (sub *InboxEventSubscription) Events() <-chan *model.InboxEvent {
+	return sub.events
+}
+
+// Fans events out to the subscriptions of this instance. Events reach every instance through
+// Postgres notifications, so agents connected anywhere see changes made anywhere.
+type inboxEventHub struct {
+	mu            sync.Mutex
+	subscriptions map[*InboxEventSubscription]bool
+	start         sync.Once
+	listening     bool
+}
+
+var eventHub = &inboxEventHub{subscriptions: map[*InboxEventSubscription]bool{}}
+
+type inboxEventService struct {
+	repo    repository.InboxEventRepository
+	members OrganisationMemberService
+	hub     *inboxEventHub
+}
+
+type InboxEventService interface {
+	Publish(orgID uint64, eventType string, conversationID *uint64, payload interface{}) error
+	Subscribe(orgID uint64, filter *model.InboxEventFilter, lastEventID uint64, actorID uint64) (*InboxEventSubscription, []*model.InboxEvent, *types.ApplicationError)
+	Unsubscribe(sub *InboxEventSubscription)
+}
+
+func NewInboxEventService() InboxEventService {
+	return &inboxEventService{
+		repo:    repository.NewInboxEventRepository(),
+		members: NewOrganisationMemberService(),
+		hub:     eventHub,
+	}
+}
+
+// Records the event for the organisation's connected agents. Called by the outbox relay, which
+// retries on failure.
+func (svc *inboxEventService) Publish(orgID uint64, eventType string, conversationID *uint64, payload interface{}) error {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	event, err := svc.repo.Create(&model.InboxEvent{
+		OrganisationID: orgID,
+		Type:           eventType,
+		ConversationID: conversationID,
+		Payload:        raw,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Without a listener the notification never comes back, so deliver here directly
+	if !svc.hub.isListening() {
+		svc.hub.deliver(event)
+	}
+	return nil
+}
+
+// Opens a subscription for a member of the organisation. Returns the events following
+// lastEventID which the client missed, oldest first. Events delivered live may repeat the tail
+// of those, so clients skip ids they have already seen.
+func (svc *inboxEventService) Subscribe(orgID uint64, filter *model.InboxEventFilter, lastEventID uint64, actorID uint64) (*InboxEventSubscription, []*model.InboxEvent, *types.ApplicationError) {
+	appErr := svc.members.Require(orgID, actorID)
+	if appErr != nil {
+		return nil, nil, appErr
+	}
+
+	svc.hub.start.Do(svc.listen)
+
+	sub := &InboxEventSubscription{
+		orgID:  orgID,
+		filter: *filter,
+		events: make(chan *model.InboxEvent, inboxEventBuffer),
+	}
+	// Subscribe before reading the backlog, so nothing published in between is lost
+	svc.hub.add(sub)
+
+	if lastEventID == 0 {
+		return sub, nil, nil
+	}
+
+	eventSet, err := svc.repo.FindAfter(orgID, lastEventID, inboxEventReplayLimit)
+	if err != nil {
+		svc.hub.remove(sub)
+		return nil, nil, databaseError("Unable to find inbox events", err)
+	}
+
+	var backlog []*model.InboxEvent
+	for _, event := range eventSet {
+		if filter.Matches(event) {
+			backlog = append(backlog, event)
+		}
+	}
+
+	return sub, backlog, nil
+}
+
+func (svc *inboxEventService) Unsubscribe(sub *InboxEventSubscription) {
+	svc.hub.remove(sub)
+}
+
+// Starts relaying notified events to the subscriptions and pruning expired events
+func (svc *inboxEventService) listen() {
+	listener, err := db.NewListener(repository.InboxEventChannel)
+	if err != nil {
+		logger.Warning("Unable to listen for inbox events, only events of this instance are streamed. Error: " + err.Error())
+	} else {
+		svc.hub.mu.Lock()
+		svc.hub.listening = true
+		svc.hub.mu.Unlock()
+
+		go func() {
+			for notification := range listener.Notify {
+				// A nil notification reports a reconnect, after which clients resume by event id
+				if notification == nil {
+					continue
+				}
+
+				id, err := strconv.ParseUint(notification.Extra, 10, 64)
+				if err != nil {
+					continue
+				}
+
+				event, err := svc.repo.FindByID(id)
+				if err != nil {
+					logger.Warning("Unable to load inbox event " + notification.Extra + ". Error: " + err.Error())
+					continue
+				}
+				svc.hub.deliver(event)
+			}
+		}()
+	}
+
+	go func() {
+		for range time.Tick(time.Hour) {
+			_, err := svc.repo.DeleteBefore(time.Now().Add(-inboxEventRetention))
+			if err != nil {
+				logger.Warning("Unable to prune inbox events. Error: " + err.Error())
+			}
+		}
+	}()
+}
+
+func (hub *inboxEventHub) isListening() bool {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	return hub.listening
+}
+
+func (hub *inboxEventHub) add(sub *InboxEventSubscription) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	hub.subscriptions[sub] = true
+}
+
+func (hub *inboxEventHub) remove(sub *InboxEventSubscription) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	if hub.subscriptions[sub] {
+		delete(hub.subscriptions, sub)
+		close(sub.events)
+	}
+}
+
+// Hands the event to every matching subscription, closing those too far behind to take it
+func (hub *inboxEventHub) deliver(event *model.InboxEvent) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	for sub := range hub.subscriptions {
+		if sub.orgID != event.OrganisationID || !sub.filter.Matches(event) {
+			continue
+		}
+
+		select {
+		case sub.events <- event:
+		default:
+			delete(hub.subscriptions, sub)
+			close(sub.events)
+		}
+	}
+}
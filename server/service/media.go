@@ -0,0 +1,261 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/supermario64bit/whatsapp_connect/pkg/logger"
+	"github.com/supermario64bit/whatsapp_connect/pkg/storage"
+	"github.com/supermario64bit/whatsapp_connect/pkg/whatsapp"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+	"github.com/supermario64bit/whatsapp_connect/server/repository"
+	"github.com/supermario64bit/whatsapp_connect/types"
+)
+
+// How long the download links handed to the inbox stay valid
+const mediaURLExpiry = 15 * time.Minute
+
+var extensionPattern = regexp.MustCompile(`^\.[a-z0-9]{1,10}$`)
+
+type mediaService struct {
+	repo     repository.MediaRepository
+	accounts WhatsAppAccountService
+	audit    AuditService
+	storage  storage.Storage
+	graph    *whatsapp.Client
+}
+
+type MediaService interface {
+	Upload(orgID uint64, accountID uint64, filename string, mimeType string, size int64, body io.Reader, actorID uint64) (*model.Media, *types.ApplicationError)
+	FindByID(orgID uint64, id uint64) (*model.Media, *types.ApplicationError)
+	OpenLocal(key string, expires string, signature string) (*model.Media, io.ReadCloser, *types.ApplicationError)
+	StoreInbound(account *model.WhatsAppAccount, mediaType string, inbound *whatsapp.InboundMedia) (*model.Media, *types.ApplicationError)
+}
+
+func NewMediaService() MediaService {
+	return &mediaService{
+		repo:     repository.NewMediaRepository(),
+		accounts: NewWhatsAppAccountService(),
+		audit:    NewAuditService(),
+		storage:  storage.New(),
+		graph:    whatsapp.NewClient(),
+	}
+}
+
+// Keeps the file in our storage and uploads it to the Graph API, so it can be sent from the
+// account. The stored file is removed again when Meta refuses it.
+func (svc *mediaService) Upload(orgID uint64, accountID uint64, filename string, mimeType string, size int64, body io.Reader, actorID uint64) (*model.Media, *types.ApplicationError) {
+	filename = path.Base(strings.TrimSpace(filename))
+	if mimeType == "" || model.NormaliseMimeType(mimeType) == "application/octet-stream" {
+		mimeType = mime.TypeByExtension(path.Ext(filename))
+	}
+	mimeType = model.NormaliseMimeType(mimeType)
+
+	limit, appErr := checkMediaLimit("file", mimeType, size)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	account, appErr := svc.accounts.FindByID(orgID, accountID)
+	if appErr != nil {
+		if appErr.Code == types.CodeNotFound {
+			return nil, types.NewFieldValidationError(types.FieldError{Field: "whatsapp_account_id", Rule: "exists", Message: "must refer to an account of the organisation"})
+		}
+		return nil, appErr
+	}
+
+	media := &model.Media{
+		OrganisationID:    orgID,
+		WhatsAppAccountID: account.ID,
+		Direction:         model.MessageDirectionOutbound,
+		Type:              limit.Type,
+		Filename:          filename,
+		MimeType:          mimeType,
+		Size:              size,
+		StorageKey:        mediaStorageKey(orgID, filename, mimeType),
+	}
+
+	hasher := sha256.New()
+	err := svc.storage.Put(media.StorageKey, io.TeeReader(body, hasher), size, mimeType)
+	if err != nil {
+		return nil, types.NewInternalError("Unable to store media", err)
+	}
+	media.SHA256 = hex.EncodeToString(hasher.Sum(nil))
+
+	file, err := svc.storage.Get(media.StorageKey)
+	if err != nil {
+		svc.discard(media.StorageKey)
+		return nil, types.NewInternalError("Unable to store media", err)
+	}
+	media.ExternalID, err = svc.graph.UploadMedia(account.AccessToken, account.PhoneNumberID, filename, mimeType, file)
+	file.Close()
+	if err != nil {
+		svc.discard(media.StorageKey)
+		return nil, graphError("Unable to upload media", err)
+	}
+
+	new, err := svc.repo.Create(media)
+	if err != nil {
+		svc.discard(media.StorageKey)
+		return nil, databaseError("Unable to store media", err)
+	}
+
+	svc.audit.Record(actorID, &orgID, model.AuditEntityMedia, new.ID, model.AuditActionCreate, nil, new)
+
+	return svc.withURL(new), nil
+}
+
+// Returns the media with a short lived link to download the file
+func (svc *mediaService) FindByID(orgID uint64, id uint64) (*model.Media, *types.ApplicationError) {
+	media, err := svc.repo.FindByID(orgID, id)
+	if err != nil {
+		return nil, databaseError("Unable to find media by id", err)
+	}
+	return svc.withURL(media), nil
+}
+
+// Opens a locally stored file for a signed link handed out by FindByID. The caller closes the
+// reader.
+func (svc *mediaService) OpenLocal(key string, expires string, signature string) (*model.Media, io.ReadCloser, *types.ApplicationError) {
+	local, ok := svc.storage.(*storage.LocalStorage)
+	if !ok {
+		return nil, nil, types.NewNotFoundError("Unable to find media file", fmt.Errorf("Files are not served from local storage"))
+	}
+
+	if !local.Verify(key, expires, signature) {
+		return nil, nil, types.NewForbiddenError("Unable to open media file", fmt.Errorf("The link is invalid or has expired"))
+	}
+
+	media, err := svc.repo.FindByStorageKey(key)
+	if err != nil {
+		return nil, nil, databaseError("Unable to find media file", err)
+	}
+
+	file, err := local.Get(key)
+	if err == storage.ErrNotFound {
+		return nil, nil, types.NewNotFoundError("Unable to find media file", err)
+	}
+	if err != nil {
+		return nil, nil, types.NewInternalError("Unable to open media file", err)
+	}
+
+	return media, file, nil
+}
+
+// Downloads media a customer sent into our storage. Media already stored is returned as is, as
+// Meta retries notifications it considers undelivered.
+func (svc *mediaService) StoreInbound(account *model.WhatsAppAccount, mediaType string, inbound *whatsapp.InboundMedia) (*model.Media, *types.ApplicationError) {
+	existing, err := svc.repo.FindByExternalID(account.ID, inbound.ID)
+	if err == nil {
+		return existing, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, databaseError("Unable to store inbound media", err)
+	}
+
+	info, err := svc.graph.GetMedia(account.AccessToken, inbound.ID)
+	if err != nil {
+		return nil, graphError("Unable to look up inbound media", err)
+	}
+
+	mimeType := model.NormaliseMimeType(info.MimeType)
+	if limit, ok := model.MediaLimitFor(mimeType); ok && info.FileSize > limit.MaxSize {
+		return nil, types.NewBadRequestError("Unable to store inbound media", fmt.Errorf("Media %s of %d bytes exceeds the %d byte limit", inbound.ID, info.FileSize, limit.MaxSize))
+	}
+
+	media := &model.Media{
+		OrganisationID:    account.OrganisationID,
+		WhatsAppAccountID: account.ID,
+		ExternalID:        inbound.ID,
+		Direction:         model.MessageDirectionInbound,
+		Type:              mediaType,
+		Filename:          path.Base(strings.TrimSpace(inbound.Filename)),
+		MimeType:          mimeType,
+		Size:              info.FileSize,
+	}
+	if media.Filename == "." {
+		media.Filename = ""
+	}
+	media.StorageKey = mediaStorageKey(account.OrganisationID, media.Filename, mimeType)
+
+	file, err := svc.graph.DownloadMedia(account.AccessToken, info.URL)
+	if err != nil {
+		return nil, graphError("Unable to download inbound media", err)
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	err = svc.storage.Put(media.StorageKey, io.TeeReader(file, hasher), media.Size, mimeType)
+	if err != nil {
+		return nil, types.NewInternalError("Unable to store inbound media", err)
+	}
+
+	media.SHA256 = hex.EncodeToString(hasher.Sum(nil))
+	if info.SHA256 != "" && !strings.EqualFold(info.SHA256, media.SHA256) {
+		svc.discard(media.StorageKey)
+		return nil, types.NewUpstreamError("Unable to store inbound media", fmt.Errorf("Checksum of media %s does not match", inbound.ID))
+	}
+
+	new, err := svc.repo.Create(media)
+	if err != nil {
+		svc.discard(media.StorageKey)
+		return nil, databaseError("Unable to store inbound media", err)
+	}
+
+	return new, nil
+}
+
+func (svc *mediaService) withURL(media *model.Media) *model.Media {
+	url, err := svc.storage.SignedURL(media.StorageKey, mediaURLExpiry)
+	if err != nil {
+		logger.Warning(fmt.Sprintf("Unable to sign url of media %d. Error: %s", media.ID, err.Error()))
+		return media
+	}
+	media.URL = url
+	return media
+}
+
+func (svc *mediaService) discard(key string) {
+	err := svc.storage.Delete(key)
+	if err != nil {
+		logger.Warning("Unable to delete stored media " + key + ". Error: " + err.Error())
+	}
+}
+
+// Checks WhatsApp accepts files of the MIME type and size, returning the message type they are
+// sent as
+func checkMediaLimit(field string, mimeType string, size int64) (model.MediaLimit, *types.ApplicationError) {
+	limit, ok := model.MediaLimitFor(mimeType)
+	if !ok {
+		return limit, types.NewFieldValidationError(types.FieldError{Field: field, Rule: "mime_type", Message: "must be of a type WhatsApp supports, " + mimeType + " is not"})
+	}
+	if size <= 0 || size > limit.MaxSize {
+		return limit, types.NewFieldValidationError(types.FieldError{Field: field, Rule: "size", Message: fmt.Sprintf("must hold between 1 and %d bytes for %s", limit.MaxSize, mimeType)})
+	}
+	return limit, nil
+}
+
+// Files are kept per organisation and month under a random name with the original extension
+func mediaStorageKey(orgID uint64, filename string, mimeType string) string {
+	ext := strings.ToLower(path.Ext(filename))
+	if !extensionPattern.MatchString(ext) {
+		ext = ""
+		if extensions, _ := mime.ExtensionsByType(mimeType); len(extensions) > 0 {
+			ext = extensions[0]
+		}
+	}
+
+	name := make([]byte, 16)
+	rand.Read(name)
+
+	return fmt.Sprintf("%d/%s/%s%s", orgID, time.Now().UTC().Format("2006/01"), hex.EncodeToString(name), ext)
+}
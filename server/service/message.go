@@ -0,0 +1,582 @@
+package service
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/supermario64bit/whatsapp_connect/pkg/logger"
+	"github.com/supermario64bit/whatsapp_connect/pkg/whatsapp"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+	"github.com/supermario64bit/whatsapp_connect/server/repository"
+	"github.com/supermario64bit/whatsapp_connect/types"
+)
+
+type messageService struct {
+	repo          repository.MessageRepository
+	templateRepo  repository.MessageTemplateRepository
+	mediaRepo     repository.MediaRepository
+	accounts      WhatsAppAccountService
+	contacts      ContactService
+	consents      ConsentService
+	conversations ConversationService
+	jobRepo       repository.MessageJobRepository
+}
+
+type MessageService interface {
+	SendTemplate(orgID uint64, req *model.SendTemplateRequest, actorID uint64) (*model.Message, *types.ApplicationError)
+	SendText(orgID uint64, req *model.SendTextRequest) (*model.Message, *types.ApplicationError)
+	SendMedia(orgID uint64, req *model.SendMediaRequest) (*model.Message, *types.ApplicationError)
+	Find(orgID uint64, filter *model.MessageFilter) ([]*model.Message, *model.Pagination, *types.ApplicationError)
+	FindByContact(orgID uint64, contactID uint64, filter *model.MessageFilter) ([]*model.Message, *model.Pagination, *types.ApplicationError)
+	FindByConversation(orgID uint64, conversationID uint64, filter *model.MessageFilter) ([]*model.Message, *model.Pagination, *types.ApplicationError)
+	FindByID(orgID uint64, id uint64) (*model.Message, *types.ApplicationError)
+	RecordInbound(account *model.WhatsAppAccount, contactID uint64, inbound *whatsapp.InboundMessage) (*model.Message, *types.ApplicationError)
+	UpdateStatus(status *whatsapp.MessageStatus) (*model.Message, *types.ApplicationError)
+	AttachMedia(id uint64, media *model.Media) (*model.Message, *types.ApplicationError)
+}
+
+func NewMessageService() MessageService {
+	return &messageService{
+		repo:          repository.NewMessageRepository(),
+		templateRepo:  repository.NewMessageTemplateRepository(),
+		mediaRepo:     repository.NewMediaRepository(),
+		accounts:      NewWhatsAppAccountService(),
+		contacts:      NewContactService(),
+		consents:      NewConsentService(),
+		conversations: NewConversationService(),
+		jobRepo:       repository.NewMessageJobRepository(),
+	}
+}
+
+// Sends an approved template with its placeholders filled from the request. Templates may be
+// sent whether or not the customer service window is open, to contacts who opted in.
+func (svc *messageService) SendTemplate(orgID uint64, req *model.SendTemplateRequest, actorID uint64) (*model.Message, *types.ApplicationError) {
+	req.Normalise()
+	validationErrors := req.ValidateFields()
+	if len(validationErrors) > 0 {
+		return nil, types.NewValidationError(validationErrors)
+	}
+
+	account, appErr := svc.resolveSender(orgID, req.WhatsAppAccountID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	tpl, err := svc.templateRepo.FindByName(account.ID, req.Template.Name, req.Template.Language)
+	if err == sql.ErrNoRows {
+		return nil, types.NewFieldValidationError(types.FieldError{Field: "template.name", Rule: "exists", Message: "must name a template of the sending account in the given language"})
+	}
+	if err != nil {
+		return nil, databaseError("Unable to find message template", err)
+	}
+
+	if tpl.Status != model.TemplateStatusApproved {
+		return nil, types.NewConflictError("Unable to send message", fmt.Errorf("Template %s is %s. Only approved templates can be sent", tpl.Name, tpl.Status))
+	}
+
+	payload, body, details := buildTemplatePayload(tpl, &req.Template)
+	if len(details) > 0 {
+		return nil, types.NewFieldValidationError(details...)
+	}
+
+	contact, appErr := svc.contacts.FindOrCreate(orgID, req.To, actorID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	appErr = svc.consents.Require(contact, true)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	message := &model.Message{
+		OrganisationID:    orgID,
+		WhatsAppAccountID: account.ID,
+		ContactID:         contact.ID,
+		Direction:         model.MessageDirectionOutbound,
+		Type:              model.MessageTypeTemplate,
+		TemplateID:        &tpl.ID,
+		Body:              body,
+		Payload:           payloadOf(payload),
+	}
+
+	return svc.deliver(account, message, whatsapp.NewTemplateMessage(req.To, payload), req.CampaignRecipientID)
+}
+
+// Sends a free-form text message. WhatsApp only delivers these within 24 hours of the
+// customer's last message, so they are refused once the customer service window has closed.
+// Contacts who opted out are not sent anything.
+func (svc *messageService) SendText(orgID uint64, req *model.SendTextRequest) (*model.Message, *types.ApplicationError) {
+	req.Normalise()
+	validationErrors := req.ValidateFields()
+	if len(validationErrors) > 0 {
+		return nil, types.NewValidationError(validationErrors)
+	}
+
+	account, appErr := svc.resolveSender(orgID, req.WhatsAppAccountID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	contact, appErr := svc.requireOpenWindow(orgID, account, req.To)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	appErr = svc.consents.Require(contact, false)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	text := &whatsapp.TextPayload{Body: req.Text.Body, PreviewURL: req.Text.PreviewURL}
+	message := &model.Message{
+		OrganisationID:    orgID,
+		WhatsAppAccountID: account.ID,
+		ContactID:         contact.ID,
+		Direction:         model.MessageDirectionOutbound,
+		Type:              model.MessageTypeText,
+		Body:              text.Body,
+		Payload:           payloadOf(text),
+	}
+
+	return svc.deliver(account, message, whatsapp.NewTextMessage(req.To, text), 0)
+}
+
+// Sends a file uploaded through the media endpoint. Like text, media can only be sent within
+// the customer service window.
+func (svc *messageService) SendMedia(orgID uint64, req *model.SendMediaRequest) (*model.Message, *types.ApplicationError) {
+	req.Normalise()
+	validationErrors := req.ValidateFields()
+	if len(validationErrors) > 0 {
+		return nil, types.NewValidationError(validationErrors)
+	}
+
+	account, appErr := svc.resolveSender(orgID, req.WhatsAppAccountID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	media, err := svc.mediaRepo.FindByID(orgID, req.MediaID)
+	if err == sql.ErrNoRows {
+		return nil, types.NewFieldValidationError(types.FieldError{Field: "media_id", Rule: "exists", Message: "must refer to media of the organisation"})
+	}
+	if err != nil {
+		return nil, databaseError("Unable to find media", err)
+	}
+
+	if media.Direction != model.MessageDirectionOutbound || media.WhatsAppAccountID != account.ID {
+		return nil, types.NewFieldValidationError(types.FieldError{Field: "media_id", Rule: "account", Message: "must refer to media uploaded for the sending account"})
+	}
+
+	if req.Caption != "" && (media.Type == model.MediaTypeAudio || media.Type == model.MediaTypeSticker) {
+		return nil, types.NewFieldValidationError(types.FieldError{Field: "caption", Rule: "absent", Message: "must be left out, " + media.Type + " messages have no caption"})
+	}
+
+	contact, appErr := svc.requireOpenWindow(orgID, account, req.To)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	appErr = svc.consents.Require(contact, false)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	object := &whatsapp.MediaObject{ID: media.ExternalID, Caption: req.Caption}
+	if media.Type == model.MediaTypeDocument {
+		object.Filename = media.Filename
+	}
+
+	message := &model.Message{
+		OrganisationID:    orgID,
+		WhatsAppAccountID: account.ID,
+		ContactID:         contact.ID,
+		Direction:         model.MessageDirectionOutbound,
+		Type:              media.Type,
+		MediaID:           &media.ID,
+		Body:              req.Caption,
+		Payload:           payloadOf(object),
+	}
+
+	return svc.deliver(account, message, whatsapp.NewMediaMessage(req.To, media.Type, object), 0)
+}
+
+// Returns the contact with the phone number when the customer service window with them is
+// open on the account
+func (svc *messageService) requireOpenWindow(orgID uint64, account *model.WhatsAppAccount, to string) (*model.Contact, *types.ApplicationError) {
+	windowClosed := types.NewConflictError("Unable to send message",
+		fmt.Errorf("The customer service window with %s is closed. Free-form messages can only be sent within 24 hours of the customer's last message, send an approved template instead", to),
+		types.FieldError{Field: "to", Rule: "window", Message: "must have written to the account within the last 24 hours"})
+
+	contact, appErr := svc.contacts.FindByPhoneNumber(orgID, to)
+	if appErr != nil {
+		if appErr.Code == types.CodeNotFound {
+			return nil, windowClosed
+		}
+		return nil, appErr
+	}
+
+	conversation, appErr := svc.conversations.FindByContact(account.ID, contact.ID)
+	if appErr != nil {
+		if appErr.Code == types.CodeNotFound {
+			return nil, windowClosed
+		}
+		return nil, appErr
+	}
+
+	if !conversation.IsWindowOpen(time.Now()) {
+		return nil, windowClosed
+	}
+
+	return contact, nil
+}
+
+// Stores the message in the contact's conversation and queues it for sending. The send queue
+// moves the message on to queued once Meta accepts it, or to failed when Meta refuses it.
+func (svc *messageService) deliver(account *model.WhatsAppAccount, message *model.Message, outgoing *whatsapp.OutgoingMessage, campaignRecipientID uint64) (*model.Message, *types.ApplicationError) {
+	conversation, appErr := svc.conversations.Record(message.OrganisationID, account.ID, message.ContactID, time.Now(), false)
+	if appErr != nil {
+		return nil, appErr
+	}
+	message.ConversationID = &conversation.ID
+	message.Status = model.MessageStatusPending
+
+	payload, err := json.Marshal(outgoing)
+	if err != nil {
+		return nil, types.NewInternalError("Unable to queue message", err)
+	}
+
+	new, _, err := svc.jobRepo.Enqueue(message, payload, messageJobMaxAttempts, campaignRecipientID)
+	if err != nil {
+		return nil, databaseError("Unable to queue message", err)
+	}
+
+	messageQueueWake.notify()
+
+	return new, nil
+}
+
+func (svc *messageService) Find(orgID uint64, filter *model.MessageFilter) ([]*model.Message, *model.Pagination, *types.ApplicationError) {
+	filter.Pagination.Normalise()
+
+	messageSet, total, err := svc.repo.Find(orgID, filter)
+	if err != nil {
+		return nil, nil, databaseError("Unable to find messages", err)
+	}
+
+	page := filter.Pagination
+	page.Total = total
+	return messageSet, &page, nil
+}
+
+// Returns the message history of one contact, which must belong to the organisation
+func (svc *messageService) FindByContact(orgID uint64, contactID uint64, filter *model.MessageFilter) ([]*model.Message, *model.Pagination, *types.ApplicationError) {
+	_, appErr := svc.contacts.FindByID(orgID, contactID)
+	if appErr != nil {
+		return nil, nil, appErr
+	}
+
+	filter.ContactID = contactID
+	return svc.Find(orgID, filter)
+}
+
+// Returns the messages of one conversation, which must belong to the organisation
+func (svc *messageService) FindByConversation(orgID uint64, conversationID uint64, filter *model.MessageFilter) ([]*model.Message, *model.Pagination, *types.ApplicationError) {
+	_, appErr := svc.conversations.FindByID(orgID, conversationID)
+	if appErr != nil {
+		return nil, nil, appErr
+	}
+
+	filter.ConversationID = conversationID
+	return svc.Find(orgID, filter)
+}
+
+func (svc *messageService) FindByID(orgID uint64, id uint64) (*model.Message, *types.ApplicationError) {
+	message, err := svc.repo.FindByID(orgID, id)
+	if err != nil {
+		return nil, databaseError("Unable to find message by id", err)
+	}
+	return message, nil
+}
+
+// Stores a message received through the webhook. Meta retries notifications it considers
+// undelivered, so a message already stored is returned as is, and is not answered again.
+func (svc *messageService) RecordInbound(account *model.WhatsAppAccount, contactID uint64, inbound *whatsapp.InboundMessage) (*model.Message, *types.ApplicationError) {
+	existing, err := svc.repo.FindByWAMID(inbound.ID)
+	if err == nil {
+		return existing, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, databaseError("Unable to record inbound message", err)
+	}
+
+	payload := model.JSONMap{}
+	err = json.Unmarshal(inbound.Raw, &payload)
+	if err != nil {
+		logger.Warning("Unable to keep payload of inbound message " + inbound.ID + ". Error: " + err.Error())
+	}
+
+	conversation, appErr := svc.conversations.Record(account.OrganisationID, account.ID, contactID, whatsapp.ParseTimestamp(inbound.Timestamp), true)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	message := &model.Message{
+		OrganisationID:    account.OrganisationID,
+		WhatsAppAccountID: account.ID,
+		ContactID:         contactID,
+		ConversationID:    &conversation.ID,
+		Direction:         model.MessageDirectionInbound,
+		WAMID:             &inbound.ID,
+		Type:              inbound.Type,
+		Payload:           payload,
+		Status:            model.MessageStatusReceived,
+	}
+	if inbound.Text != nil {
+		message.Body = inbound.Text.Body
+	}
+	if inbound.Button != nil {
+		message.Body = inbound.Button.Text
+	}
+	// Downloads can take a while, and Meta expects the notification to be answered quickly, so
+	// media is fetched by a job stored with the message
+	var mediaPayload json.RawMessage
+	if media := inbound.Media(); media != nil {
+		message.Body = media.Caption
+		mediaPayload, err = json.Marshal(media)
+		if err != nil {
+			return nil, types.NewInternalError("Unable to record inbound message", err)
+		}
+	}
+
+	new, err := svc.repo.Create(message, mediaPayload)
+	if err != nil {
+		return nil, databaseError("Unable to record inbound message", err)
+	}
+
+	outboxWake.notify()
+	inboundReplyWake.notify()
+	if mediaPayload != nil {
+		inboundMediaWake.notify()
+	}
+
+	return new, nil
+}
+
+// Links an inbound message to its media once the file was downloaded
+func (svc *messageService) AttachMedia(id uint64, media *model.Media) (*model.Message, *types.ApplicationError) {
+	updated, err := svc.repo.SetMedia(id, media.ID)
+	if err != nil {
+		return nil, databaseError("Unable to attach media to message", err)
+	}
+	return updated, nil
+}
+
+// Applies a delivery status reported through the webhook to the outbound message it is about
+func (svc *messageService) UpdateStatus(status *whatsapp.MessageStatus) (*model.Message, *types.ApplicationError) {
+	state := strings.ToLower(status.Status)
+	if model.MessageStatusRank(state) == 0 {
+		return nil, types.NewBadRequestError("Unable to update message status", fmt.Errorf("Unknown message status %s", status.Status))
+	}
+
+	var errorCode, errorMessage string
+	if len(status.Errors) > 0 {
+		errorCode = strconv.Itoa(status.Errors[0].Code)
+		errorMessage = status.Errors[0].Description()
+	}
+
+	updated, err := svc.repo.UpdateStatus(status.ID, state, whatsapp.ParseTimestamp(status.Timestamp), errorCode, errorMessage)
+	if err != nil {
+		return nil, databaseError("Unable to update message status", err)
+	}
+
+	if updated.ConversationID != nil {
+		_, appErr := svc.conversations.ApplyPricing(*updated.ConversationID, status)
+		if appErr != nil {
+			logger.Warning("Unable to apply pricing of message " + status.ID + " to its conversation. Error: " + appErr.Error())
+		}
+	}
+
+	outboxWake.notify()
+
+	return updated, nil
+}
+
+// Returns the requested sending account, or the organisation's only active account when none
+// was requested
+func (svc *messageService) resolveSender(orgID uint64, accountID uint64) (*model.WhatsAppAccount, *types.ApplicationError) {
+	field := types.FieldError{Field: "whatsapp_account_id", Rule: "required"}
+
+	if accountID > 0 {
+		account, appErr := svc.accounts.FindByID(orgID, accountID)
+		if appErr != nil {
+			return nil, appErr
+		}
+		if account.Status != model.WhatsAppAccountStatusActive {
+			field.Rule, field.Message = "active", "must refer to an active account"
+			return nil, types.NewFieldValidationError(field)
+		}
+		return account, nil
+	}
+
+	accountSet, appErr := svc.accounts.Find(orgID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	var active []*model.WhatsAppAccount
+	for _, account := range accountSet {
+		if account.Status == model.WhatsAppAccountStatusActive {
+			active = append(active, account)
+		}
+	}
+
+	switch len(active) {
+	case 1:
+		return active[0], nil
+	case 0:
+		field.Message = "cannot be resolved, the organisation has no active account"
+	default:
+		field.Message = "is required when the organisation has several active accounts"
+	}
+	return nil, types.NewFieldValidationError(field)
+}
+
+// Matches the request parameters against the placeholders of the template. Returns the template
+// payload to send and the message text with every placeholder filled in.
+func buildTemplatePayload(tpl *model.MessageTemplate, req *model.TemplateMessageRequest) (*whatsapp.TemplatePayload, string, []types.FieldError) {
+	payload := &whatsapp.TemplatePayload{
+		Name:     tpl.Name,
+		Language: whatsapp.TemplateLanguage{Code: tpl.Language},
+	}
+	var texts []string
+	var details []types.FieldError
+
+	header := tpl.Component(whatsapp.ComponentHeader)
+	switch {
+	case header == nil:
+		if len(req.Header) > 0 || req.HeaderMedia != nil {
+			details = append(details, types.FieldError{Field: "template.header", Rule: "absent", Message: "must be left out, the template has no header"})
+		}
+	case header.Format == "TEXT":
+		count, _ := whatsapp.CountPlaceholders(header.Text)
+		if len(req.Header) != count {
+			details = append(details, parameterCountError("template.header", count, len(req.Header)))
+		} else if count > 0 {
+			payload.Components = append(payload.Components, whatsapp.ComponentPayload{Type: "header", Parameters: whatsapp.TextParameters(req.Header)})
+		}
+		texts = append(texts, whatsapp.RenderPlaceholders(header.Text, req.Header))
+	case header.Format == "IMAGE" || header.Format == "VIDEO" || header.Format == "DOCUMENT":
+		if req.HeaderMedia == nil {
+			details = append(details, types.FieldError{Field: "template.header_media", Rule: "required", Message: "is required, the template has a " + strings.ToLower(header.Format) + " header"})
+			break
+		}
+		media := &whatsapp.MediaObject{Link: req.HeaderMedia.Link}
+		param := whatsapp.Parameter{Type: strings.ToLower(header.Format)}
+		switch header.Format {
+		case "IMAGE":
+			param.Image = media
+		case "VIDEO":
+			param.Video = media
+		case "DOCUMENT":
+			media.Filename = req.HeaderMedia.Filename
+			param.Document = media
+		}
+		payload.Components = append(payload.Components, whatsapp.ComponentPayload{Type: "header", Parameters: []whatsapp.Parameter{param}})
+	default:
+		details = append(details, types.FieldError{Field: "template.header", Rule: "supported", Message: "cannot be filled, " + strings.ToLower(header.Format) + " headers are not supported"})
+	}
+
+	if body := tpl.Component(whatsapp.ComponentBody); body != nil {
+		count, _ := whatsapp.CountPlaceholders(body.Text)
+		if len(req.Body) != count {
+			details = append(details, parameterCountError("template.body", count, len(req.Body)))
+		} else if count > 0 {
+			payload.Components = append(payload.Components, whatsapp.ComponentPayload{Type: "body", Parameters: whatsapp.TextParameters(req.Body)})
+		}
+		texts = append(texts, whatsapp.RenderPlaceholders(body.Text, req.Body))
+	}
+
+	if footer := tpl.Component(whatsapp.ComponentFooter); footer != nil {
+		texts = append(texts, footer.Text)
+	}
+
+	buttons, buttonDetails := buildButtonPayloads(tpl.Component(whatsapp.ComponentButtons), req.Buttons)
+	payload.Components = append(payload.Components, buttons...)
+	details = append(details, buttonDetails...)
+
+	return payload, strings.Join(texts, "\n\n"), details
+}
+
+// URL buttons with a placeholder need their suffix, quick reply buttons may be given a payload
+func buildButtonPayloads(component *whatsapp.TemplateComponent, req []model.TemplateButtonRequest) ([]whatsapp.ComponentPayload, []types.FieldError) {
+	var templateButtons []whatsapp.TemplateButton
+	if component != nil {
+		templateButtons = component.Buttons
+	}
+
+	var payloads []whatsapp.ComponentPayload
+	var details []types.FieldError
+	given := map[int]bool{}
+
+	for i, button := range req {
+		field := fmt.Sprintf("template.buttons[%d].index", i)
+		if button.Index >= len(templateButtons) {
+			details = append(details, types.FieldError{Field: field, Rule: "exists", Message: fmt.Sprintf("must refer to one of the %d button(s) of the template", len(templateButtons))})
+			continue
+		}
+		if given[button.Index] {
+			details = append(details, types.FieldError{Field: field, Rule: "unique", Message: "must not repeat a button"})
+			continue
+		}
+		given[button.Index] = true
+
+		payload := whatsapp.ComponentPayload{Type: "button", Index: strconv.Itoa(button.Index)}
+		switch strings.ToUpper(templateButtons[button.Index].Type) {
+		case "URL":
+			payload.SubType = "url"
+			payload.Parameters = whatsapp.TextParameters([]string{button.Value})
+		case "QUICK_REPLY":
+			payload.SubType = "quick_reply"
+			payload.Parameters = []whatsapp.Parameter{{Type: "payload", Payload: button.Value}}
+		default:
+			details = append(details, types.FieldError{Field: field, Rule: "dynamic", Message: "must refer to a url or quick reply button"})
+			continue
+		}
+		payloads = append(payloads, payload)
+	}
+
+	for index, button := range templateButtons {
+		count, _ := whatsapp.CountPlaceholders(button.URL)
+		if strings.ToUpper(button.Type) == "URL" && count > 0 && !given[index] {
+			details = append(details, types.FieldError{Field: "template.buttons", Rule: "required", Message: fmt.Sprintf("must give the url suffix of button %d", index)})
+		}
+	}
+
+	return payloads, details
+}
+
+func parameterCountError(field string, want int, got int) types.FieldError {
+	return types.FieldError{
+		Field:   field,
+		Rule:    "placeholders",
+		Message: fmt.Sprintf("must give %d value(s), one for each placeholder of the template, but gives %d", want, got),
+	}
+}
+
+// Keeps the payload as sent for the message history
+func payloadOf(payload interface{}) model.JSONMap {
+	kept := model.JSONMap{}
+	raw, err := json.Marshal(payload)
+	if err == nil {
+		err = json.Unmarshal(raw, &kept)
+	}
+	if err != nil {
+		logger.Warning("Unable to keep message payload. Error: " + err.Error())
+	}
+	return kept
+}
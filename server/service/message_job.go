@@ -0,0 +1,247 @@
+package service
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/supermario64bit/whatsapp_connect/pkg/logger"
+	"github.com/supermario64bit/whatsapp_connect/pkg/whatsapp"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+	"github.com/supermario64bit/whatsapp_connect/server/repository"
+	"github.com/supermario64bit/whatsapp_connect/types"
+)
+
+const (
+	// Attempts a message gets before its job is given up
+	messageJobMaxAttempts = 8
+	// Retries wait twice as long each time, from the base delay up to the max delay
+	messageJobBaseDelay = 2 * time.Second
+	messageJobMaxDelay  = 15 * time.Minute
+	// Workers started when MESSAGE_QUEUE_WORKERS is not set
+	defaultMessageQueueWorkers = 4
+)
+
+var messageQueueWake = newQueueWake()
+
+type messageJobService struct {
+	repo     repository.MessageJobRepository
+	accounts WhatsAppAccountService
+	limits   RateLimitService
+	consents ConsentService
+	audit    AuditService
+	graph    *whatsapp.Client
+}
+
+type MessageJobService interface {
+	Find(orgID uint64, filter *model.MessageJobFilter) ([]*model.MessageJob, *model.Pagination, *types.ApplicationError)
+	FindByID(orgID uint64, id uint64) (*model.MessageJob, *types.ApplicationError)
+	Retry(orgID uint64, id uint64, actorID uint64) (*model.MessageJob, *types.ApplicationError)
+	Run()
+}
+
+func NewMessageJobService() MessageJobService {
+	return &messageJobService{
+		repo:     repository.NewMessageJobRepository(),
+		accounts: NewWhatsAppAccountService(),
+		limits:   NewRateLimitService(),
+		consents: NewConsentService(),
+		audit:    NewAuditService(),
+		graph:    whatsapp.NewClient(),
+	}
+}
+
+func (svc *messageJobService) Find(orgID uint64, filter *model.MessageJobFilter) ([]*model.MessageJob, *model.Pagination, *types.ApplicationError) {
+	filter.Pagination.Normalise()
+
+	jobSet, total, err := svc.repo.Find(orgID, filter)
+	if err != nil {
+		return nil, nil, databaseError("Unable to find message jobs", err)
+	}
+
+	page := filter.Pagination
+	page.Total = total
+	return jobSet, &page, nil
+}
+
+func (svc *messageJobService) FindByID(orgID uint64, id uint64) (*model.MessageJob, *types.ApplicationError) {
+	job, err := svc.repo.FindByID(orgID, id)
+	if err != nil {
+		return nil, databaseError("Unable to find message job by id", err)
+	}
+	return job, nil
+}
+
+// Queues a dead job again with a fresh set of attempts
+func (svc *messageJobService) Retry(orgID uint64, id uint64, actorID uint64) (*model.MessageJob, *types.ApplicationError) {
+	current, appErr := svc.FindByID(orgID, id)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	job, err := svc.repo.Requeue(orgID, id)
+	if err == sql.ErrNoRows {
+		return nil, types.NewConflictError("Unable to retry message job", fmt.Errorf("Job is %s. Only dead jobs can be retried", current.Status))
+	}
+	if err != nil {
+		return nil, databaseError("Unable to retry message job", err)
+	}
+
+	svc.audit.Record(actorID, &orgID, model.AuditEntityMessageJob, id, model.AuditActionUpdate, current, job)
+	messageQueueWake.notify()
+
+	return job, nil
+}
+
+// Runs the workers sending queued messages until the process exits. Every instance may run
+// them, each job is sent by one worker at a time.
+func (svc *messageJobService) Run() {
+	queue := &leaseQueue[*model.MessageJob]{
+		name:           "message job",
+		workersEnv:     "MESSAGE_QUEUE_WORKERS",
+		defaultWorkers: defaultMessageQueueWorkers,
+		wake:           messageQueueWake,
+		claim:          svc.repo.Claim,
+		process:        svc.process,
+	}
+	queue.run()
+}
+
+// Sends the message of the job. Messages the contact no longer consents to are failed, and
+// messages over the account's rate limits wait until it has room again. Failures Meta may
+// recover from are retried with backoff, any other failure fails the message right away.
+func (svc *messageJobService) process(job *model.MessageJob) {
+	account, appErr := svc.accounts.FindByID(job.OrganisationID, job.WhatsAppAccountID)
+	if appErr != nil {
+		if appErr.Code == types.CodeNotFound {
+			svc.bury(job, "", "Sending account was deleted")
+			return
+		}
+		svc.retry(job, appErr)
+		return
+	}
+
+	if account.Status != model.WhatsAppAccountStatusActive {
+		svc.bury(job, "", "Sending account is "+account.Status)
+		return
+	}
+
+	var outgoing whatsapp.OutgoingMessage
+	err := json.Unmarshal(job.Payload, &outgoing)
+	if err != nil {
+		svc.bury(job, "", "Invalid message payload. Error: "+err.Error())
+		return
+	}
+
+	template := outgoing.Type == model.MessageTypeTemplate
+
+	appErr = svc.consents.RequireForMessage(job.MessageID, template)
+	if appErr != nil {
+		if appErr.Code == types.CodeConflict {
+			svc.bury(job, "", appErr.Err.Error())
+			return
+		}
+		svc.retry(job, appErr)
+		return
+	}
+
+	resumeAt, err := svc.limits.Reserve(account, job.MessageID, template)
+	if err != nil {
+		svc.retry(job, err)
+		return
+	}
+	if !resumeAt.IsZero() {
+		err = svc.repo.Postpone(job, resumeAt)
+		if err != nil {
+			warnLeaseRetry(fmt.Sprintf("hold back rate limited message job %d", job.ID), err)
+		}
+		return
+	}
+
+	res, err := svc.graph.SendMessage(account.AccessToken, account.PhoneNumberID, &outgoing)
+	if err == nil && len(res.Messages) == 0 {
+		err = errors.New("Graph API accepted the message without returning its id")
+	}
+	if err != nil {
+		if whatsapp.IsRetryable(err) {
+			svc.retry(job, err)
+			return
+		}
+
+		var errorCode string
+		var apiErr *whatsapp.APIError
+		if errors.As(err, &apiErr) {
+			errorCode = strconv.Itoa(apiErr.Code)
+		}
+		svc.bury(job, errorCode, err.Error())
+		return
+	}
+
+	_, err = svc.repo.Succeed(job, res.Messages[0].ID, time.Now())
+	if err != nil {
+		// The job runs again once its lease runs out, sending the message a second time
+		logger.Danger(fmt.Sprintf("Unable to record message %d as sent with id %s, it may be sent again. Error: %s", job.MessageID, res.Messages[0].ID, err.Error()))
+		return
+	}
+
+	outboxWake.notify()
+}
+
+// Tries the job again later, or gives it up once it ran out of attempts
+func (svc *messageJobService) retry(job *model.MessageJob, cause error) {
+	var errorCode string
+	var apiErr *whatsapp.APIError
+	if errors.As(cause, &apiErr) {
+		errorCode = strconv.Itoa(apiErr.Code)
+	}
+
+	if job.Attempts >= job.MaxAttempts {
+		svc.bury(job, errorCode, cause.Error())
+		return
+	}
+
+	err := svc.repo.Retry(job, time.Now().Add(messageJobBackoff(job.Attempts)), cause.Error())
+	if err != nil {
+		warnLeaseRetry(fmt.Sprintf("reschedule message job %d", job.ID), err)
+	}
+}
+
+func (svc *messageJobService) bury(job *model.MessageJob, errorCode string, errorMessage string) {
+	_, err := svc.repo.Bury(job, time.Now(), errorCode, errorMessage)
+	if err != nil {
+		warnLeaseRetry(fmt.Sprintf("give up message job %d", job.ID), err)
+		return
+	}
+
+	logger.Warning(fmt.Sprintf("Gave up sending message %d after %d attempt(s). Error: %s", job.MessageID, job.Attempts, errorMessage))
+	outboxWake.notify()
+}
+
+// Delay before the next attempt of a message job
+func messageJobBackoff(attempts int) time.Duration {
+	return retryBackoff(attempts, messageJobBaseDelay, messageJobMaxDelay)
+}
+
+// Delay before the next attempt, doubling with each attempt made from the base delay up to the
+// max delay. The delay is picked at random from its upper half, so work which failed together
+// does not all come back together.
+func retryBackoff(attempts int, baseDelay time.Duration, maxDelay time.Duration) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	delay := maxDelay
+	if attempts < 20 {
+		delay = baseDelay << (attempts - 1)
+		if delay <= 0 || delay > maxDelay {
+			delay = maxDelay
+		}
+	}
+
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryBackoff(t *testing.T) {
+	tests := []struct {
+		name      string
+		attempts  int
+		baseDelay time.Duration
+		maxDelay  time.Duration
+		wantDelay time.Duration
+	}{
+		{name: "no attempts yet", attempts: 0, baseDelay: 2 * time.Second, maxDelay: 15 * time.Minute, wantDelay: 2 * time.Second},
+		{name: "first attempt", attempts: 1, baseDelay: 2 * time.Second, maxDelay: 15 * time.Minute, wantDelay: 2 * time.Second},
+		{name: "second attempt", attempts: 2, baseDelay: 2 * time.Second, maxDelay: 15 * time.Minute, wantDelay: 4 * time.Second},
+		{name: "eighth attempt", attempts: 8, baseDelay: 2 * time.Second, maxDelay: 15 * time.Minute, wantDelay: 256 * time.Second},
+		{name: "capped", attempts: 10, baseDelay: 2 * time.Second, maxDelay: 15 * time.Minute, wantDelay: 15 * time.Minute},
+		{name: "past shift limit", attempts: 30, baseDelay: 2 * time.Second, maxDelay: 15 * time.Minute, wantDelay: 15 * time.Minute},
+		{name: "shift overflow", attempts: 19, baseDelay: time.Duration(1) << 60, maxDelay: time.Hour, wantDelay: time.Hour},
+		{name: "webhook delivery", attempts: 3, baseDelay: 30 * time.Second, maxDelay: 30 * time.Minute, wantDelay: 2 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 100; i++ {
+				got := retryBackoff(tt.attempts, tt.baseDelay, tt.maxDelay)
+				if got < tt.wantDelay/2 || got > tt.wantDelay {
+					t.Fatalf("retryBackoff(%d, %s, %s) = %s, want between %s and %s", tt.attempts, tt.baseDelay, tt.maxDelay, got, tt.wantDelay/2, tt.wantDelay)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,327 @@
+package service
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/supermario64bit/whatsapp_connect/pkg/logger"
+	"github.com/supermario64bit/whatsapp_connect/pkg/whatsapp"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+	"github.com/supermario64bit/whatsapp_connect/server/repository"
+	"github.com/supermario64bit/whatsapp_connect/types"
+)
+
+// Template fields which can still be changed once Meta has the template. Name and language
+// identify a template and are fixed.
+var editableTemplateFields = map[string]bool{
+	"category":   true,
+	"components": true,
+}
+
+// Fields a sync copies from Meta onto the local template
+var syncedTemplateFields = []string{"external_id", "category", "components", "status", "rejection_reason"}
+
+type messageTemplateService struct {
+	repo     repository.MessageTemplateRepository
+	accounts WhatsAppAccountService
+	graph    *whatsapp.Client
+	audit    AuditService
+}
+
+type MessageTemplateService interface {
+	Create(orgID uint64, accountID uint64, tpl *model.MessageTemplate, actorID uint64) (*model.MessageTemplate, *types.ApplicationError)
+	Find(orgID uint64, accountID uint64) ([]*model.MessageTemplate, *types.ApplicationError)
+	FindByID(orgID uint64, accountID uint64, id uint64) (*model.MessageTemplate, *types.ApplicationError)
+	PatchByID(orgID uint64, accountID uint64, patch []byte, id uint64, version uint64, actorID uint64) (*model.MessageTemplate, *types.ApplicationError)
+	DeleteByID(orgID uint64, accountID uint64, id uint64, version uint64, actorID uint64) *types.ApplicationError
+	Sync(orgID uint64, accountID uint64, actorID uint64) ([]*model.MessageTemplate, *types.ApplicationError)
+	UpdateStatus(externalID string, event string, reason string) *types.ApplicationError
+}
+
+func NewMessageTemplateService() MessageTemplateService {
+	return &messageTemplateService{
+		repo:     repository.NewMessageTemplateRepository(),
+		accounts: NewWhatsAppAccountService(),
+		graph:    whatsapp.NewClient(),
+		audit:    NewAuditService(),
+	}
+}
+
+// Validates the template locally, submits it to Meta for review and stores it with the id and
+// status Meta assigned
+func (svc *messageTemplateService) Create(orgID uint64, accountID uint64, tpl *model.MessageTemplate, actorID uint64) (*model.MessageTemplate, *types.ApplicationError) {
+	account, appErr := svc.accounts.FindByID(orgID, accountID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	tpl.OrganisationID = orgID
+	tpl.WhatsAppAccountID = accountID
+	tpl.Normalise()
+	details := tpl.ValidateFields()
+	if len(details) > 0 {
+		return nil, types.NewFieldValidationError(details...)
+	}
+
+	// Catch duplicates before Meta does, so a failed insert never leaves a template behind there
+	_, err := svc.repo.FindByName(accountID, tpl.Name, tpl.Language)
+	if err == nil {
+		return nil, types.NewConflictError("Unable to create message template", fmt.Errorf("A %s template named %s already exists", tpl.Language, tpl.Name),
+			types.FieldError{Field: "name", Rule: "unique", Message: "is already in use for this language"})
+	}
+	if err != sql.ErrNoRows {
+		return nil, databaseError("Unable to create message template", err)
+	}
+
+	created, err := svc.graph.CreateTemplate(account.AccessToken, account.BusinessAccountID, tpl.ToGraph())
+	if err != nil {
+		return nil, graphError("Unable to create message template", err)
+	}
+
+	tpl.ExternalID = created.ID
+	tpl.Status = model.TemplateStatusFromGraph(created.Status)
+	if tpl.Status == "" {
+		tpl.Status = model.TemplateStatusPending
+	}
+
+	new, err := svc.repo.Create(tpl)
+	if err != nil {
+		return nil, databaseError("Unable to create message template", err)
+	}
+
+	svc.audit.Record(actorID, &orgID, model.AuditEntityMessageTemplate, new.ID, model.AuditActionCreate, nil, new)
+
+	return new, nil
+}
+
+func (svc *messageTemplateService) Find(orgID uint64, accountID uint64) ([]*model.MessageTemplate, *types.ApplicationError) {
+	_, appErr := svc.accounts.FindByID(orgID, accountID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	templateSet, err := svc.repo.Find(accountID)
+	if err != nil {
+		return nil, databaseError("Unable to find message templates", err)
+	}
+	return templateSet, nil
+}
+
+func (svc *messageTemplateService) FindByID(orgID uint64, accountID uint64, id uint64) (*model.MessageTemplate, *types.ApplicationError) {
+	_, appErr := svc.accounts.FindByID(orgID, accountID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	tpl, err := svc.repo.FindByID(accountID, id)
+	if err != nil {
+		return nil, databaseError("Unable to find message template by id", err)
+	}
+	return tpl, nil
+}
+
+// Applies an RFC 7396 merge patch to the category and components, resubmitting the template to
+// Meta, which sends it back to review
+func (svc *messageTemplateService) PatchByID(orgID uint64, accountID uint64, patch []byte, id uint64, version uint64, actorID uint64) (*model.MessageTemplate, *types.ApplicationError) {
+	account, appErr := svc.accounts.FindByID(orgID, accountID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	current, err := svc.repo.FindByID(accountID, id)
+	if err != nil {
+		return nil, databaseError("Unable to update message template", err)
+	}
+
+	if version > 0 && current.Version != version {
+		return nil, databaseError("Unable to update message template", repository.ErrVersionMismatch)
+	}
+
+	var patched model.MessageTemplate
+	fields, appErr := applyMergePatch(current, patch, &patched)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	for _, field := range fields {
+		if !editableTemplateFields[field] {
+			return nil, types.NewBadRequestError("Invalid Merge Patch", fmt.Errorf("Field %s cannot be changed once the template is created", field))
+		}
+	}
+
+	patched.Normalise()
+	details := patched.ValidatePartial(fields)
+	if len(details) > 0 {
+		return nil, types.NewFieldValidationError(details...)
+	}
+
+	changes := changedColumns(current, &patched, fields)
+	if len(changes) == 0 {
+		return current, nil
+	}
+
+	err = svc.graph.EditTemplate(account.AccessToken, current.ExternalID, strings.ToUpper(patched.Category), patched.Components)
+	if err != nil {
+		return nil, graphError("Unable to update message template", err)
+	}
+
+	changes["status"] = model.TemplateStatusPending
+	changes["rejection_reason"] = ""
+
+	updated, err := svc.repo.PatchByID(id, current.Version, changes)
+	if err != nil {
+		return nil, databaseError("Unable to update message template", err)
+	}
+
+	svc.audit.Record(actorID, &orgID, model.AuditEntityMessageTemplate, updated.ID, model.AuditActionUpdate, current, updated)
+
+	return updated, nil
+}
+
+// Deletes the template from Meta, then locally
+func (svc *messageTemplateService) DeleteByID(orgID uint64, accountID uint64, id uint64, version uint64, actorID uint64) *types.ApplicationError {
+	account, appErr := svc.accounts.FindByID(orgID, accountID)
+	if appErr != nil {
+		return appErr
+	}
+
+	before, err := svc.repo.FindByID(accountID, id)
+	if err != nil {
+		return databaseError("Unable to delete message template", err)
+	}
+
+	if version > 0 && before.Version != version {
+		return databaseError("Unable to delete message template", repository.ErrVersionMismatch)
+	}
+
+	if before.Status != model.TemplateStatusDeleted {
+		err = svc.graph.DeleteTemplate(account.AccessToken, account.BusinessAccountID, before.ExternalID, before.Name)
+		if err != nil {
+			return graphError("Unable to delete message template", err)
+		}
+	}
+
+	err = svc.repo.DeleteByID(accountID, id, version)
+	if err != nil {
+		return databaseError("Unable to delete message template", err)
+	}
+
+	svc.audit.Record(actorID, &orgID, model.AuditEntityMessageTemplate, id, model.AuditActionDelete, before, nil)
+
+	return nil
+}
+
+// Pulls every template of the account from Meta. Templates created elsewhere, such as in
+// WhatsApp Manager, are added, known ones take Meta's status and content, and local templates
+// Meta no longer has are marked deleted.
+func (svc *messageTemplateService) Sync(orgID uint64, accountID uint64, actorID uint64) ([]*model.MessageTemplate, *types.ApplicationError) {
+	account, appErr := svc.accounts.FindByID(orgID, accountID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	remoteSet, err := svc.graph.ListTemplates(account.AccessToken, account.BusinessAccountID)
+	if err != nil {
+		return nil, graphError("Unable to sync message templates", err)
+	}
+
+	localSet, err := svc.repo.Find(accountID)
+	if err != nil {
+		return nil, databaseError("Unable to sync message templates", err)
+	}
+
+	locals := map[string]*model.MessageTemplate{}
+	for _, local := range localSet {
+		locals[local.Name+"/"+local.Language] = local
+	}
+
+	for _, remote := range remoteSet {
+		synced := model.MessageTemplate{
+			OrganisationID:    orgID,
+			WhatsAppAccountID: accountID,
+			ExternalID:        remote.ID,
+			Name:              remote.Name,
+			Language:          remote.Language,
+			Category:          strings.ToLower(remote.Category),
+			Components:        remote.Components,
+			Status:            model.TemplateStatusFromGraph(remote.Status),
+			RejectionReason:   templateRejectionReason(remote.RejectedReason),
+		}
+
+		key := remote.Name + "/" + remote.Language
+		local, ok := locals[key]
+		delete(locals, key)
+
+		if !ok {
+			new, err := svc.repo.Create(&synced)
+			if err != nil {
+				return nil, databaseError("Unable to sync message templates", err)
+			}
+			svc.audit.Record(actorID, &orgID, model.AuditEntityMessageTemplate, new.ID, model.AuditActionCreate, nil, new)
+			continue
+		}
+
+		appErr := svc.applySync(local, &synced, actorID)
+		if appErr != nil {
+			return nil, appErr
+		}
+	}
+
+	// Whatever is left is gone from Meta
+	for _, local := range locals {
+		gone := *local
+		gone.Status = model.TemplateStatusDeleted
+		appErr := svc.applySync(local, &gone, actorID)
+		if appErr != nil {
+			return nil, appErr
+		}
+	}
+
+	return svc.Find(orgID, accountID)
+}
+
+func (svc *messageTemplateService) applySync(current *model.MessageTemplate, synced *model.MessageTemplate, actorID uint64) *types.ApplicationError {
+	changes := changedColumns(current, synced, syncedTemplateFields)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	updated, err := svc.repo.PatchByID(current.ID, 0, changes)
+	if err != nil {
+		return databaseError("Unable to sync message templates", err)
+	}
+
+	svc.audit.Record(actorID, &current.OrganisationID, model.AuditEntityMessageTemplate, updated.ID, model.AuditActionUpdate, current, updated)
+
+	return nil
+}
+
+// Applies a status change Meta reported through the webhook
+func (svc *messageTemplateService) UpdateStatus(externalID string, event string, reason string) *types.ApplicationError {
+	current, err := svc.repo.FindByExternalID(externalID)
+	if err != nil {
+		return databaseError("Unable to find message template by external id", err)
+	}
+
+	updated := *current
+	updated.Status = model.TemplateStatusFromGraph(event)
+	updated.RejectionReason = templateRejectionReason(reason)
+
+	appErr := svc.applySync(current, &updated, 0)
+	if appErr != nil {
+		return appErr
+	}
+
+	logger.Info("Message template " + current.Name + " (" + current.Language + ") is now " + updated.Status)
+
+	return nil
+}
+
+// Meta reports NONE when there is no rejection reason
+func templateRejectionReason(reason string) string {
+	if strings.EqualFold(reason, "NONE") {
+		return ""
+	}
+	return reason
+}
@@ -1,10 +1,6 @@
 package service
 
 import (
-	"database/sql"
-	"fmt"
-	"net/http"
-
 	"github.com/supermario64bit/whatsapp_connect/server/model"
 	"github.com/supermario64bit/whatsapp_connect/server/repository"
 	"github.com/supermario64bit/whatsapp_connect/types"
@@ -15,11 +11,12 @@ type organisationService struct {
 }
 
 type OrganisationService interface {
-	Create(org *model.Organisation) (*model.Organisation, *types.ApplicationError)
-	Find(filter *model.Organisation) ([]*model.Organisation, *types.ApplicationError)
+	Create(org *model.Organisation, actorID uint64) (*model.Organisation, *types.ApplicationError)
+	Find(filter *model.Organisation, actorID uint64) ([]*model.Organisation, *types.ApplicationError)
 	FindByID(id uint64) (*model.Organisation, *types.ApplicationError)
-	UpdateByID(updates *model.Organisation, id uint64) (*model.Organisation, *types.ApplicationError)
-	DeleteByID(id uint64) *types.ApplicationError
+	UpdateByID(updates *model.Organisation, id uint64, version uint64, actorID uint64) (*model.Organisation, *types.ApplicationError)
+	PatchByID(patch []byte, id uint64, version uint64, actorID uint64) (*model.Organisation, *types.ApplicationError)
+	DeleteByID(id uint64, version uint64, actorID uint64) *types.ApplicationError
 }
 
 func NewOrganisationService() OrganisationService {
@@ -28,36 +25,29 @@ func NewOrganisationService() OrganisationService {
 	}
 }
 
-func (svc *organisationService) Create(org *model.Organisation) (*model.Organisation, *types.ApplicationError) {
+func (svc *organisationService) Create(org *model.Organisation, actorID uint64) (*model.Organisation, *types.ApplicationError) {
+	org.Normalise()
 	validationErrors := org.ValidateFields()
 	if len(validationErrors) > 0 {
-		return nil, &types.ApplicationError{
-			HttpStatus: http.StatusBadRequest,
-			Message:    "Validation Failed",
-			Err:        fmt.Errorf("", validationErrors),
-		}
+		return nil, types.NewValidationError(validationErrors)
 	}
 
-	new, err := svc.repo.Create(org)
+	new, err := svc.repo.Create(org, actorID)
 	if err != nil {
-		return nil, &types.ApplicationError{
-			HttpStatus: http.StatusInternalServerError,
-			Message:    "Unable to create organisation",
-			Err:        fmt.Errorf("Unable to create organisation. Error: " + err.Error()),
-		}
+		return nil, databaseError("Unable to create organisation", err)
 	}
 
+	// Audited by the outbox relay
+	outboxWake.notify()
+
 	return new, nil
 }
 
-func (svc *organisationService) Find(filter *model.Organisation) ([]*model.Organisation, *types.ApplicationError) {
-	orgSet, err := svc.repo.Find(filter)
+// Finds the organisations the user is a member of
+func (svc *organisationService) Find(filter *model.Organisation, actorID uint64) ([]*model.Organisation, *types.ApplicationError) {
+	orgSet, err := svc.repo.Find(filter, actorID)
 	if err != nil {
-		return nil, &types.ApplicationError{
-			HttpStatus: http.StatusInternalServerError,
-			Message:    "Unable to find organisations",
-			Err:        err,
-		}
+		return nil, databaseError("Unable to find organisations", err)
 	}
 	return orgSet, nil
 }
@@ -65,52 +55,77 @@ func (svc *organisationService) Find(filter *model.Organisation) ([]*model.Organ
 func (svc *organisationService) FindByID(id uint64) (*model.Organisation, *types.ApplicationError) {
 	org, err := svc.repo.FindByID(id)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil
-		}
-		return nil, &types.ApplicationError{
-			HttpStatus: http.StatusInternalServerError,
-			Message:    "Unable to find organisation by id",
-			Err:        err,
-		}
+		return nil, databaseError("Unable to find organisation by id", err)
 	}
 	return org, nil
 }
 
-func (svc *organisationService) UpdateByID(updates *model.Organisation, id uint64) (*model.Organisation, *types.ApplicationError) {
-	updatedOrg, err := svc.repo.UpdateByID(updates, id)
+func (svc *organisationService) UpdateByID(updates *model.Organisation, id uint64, version uint64, actorID uint64) (*model.Organisation, *types.ApplicationError) {
+	updates.Normalise()
+	validationErrors := updates.ValidateSupplied()
+	if len(validationErrors) > 0 {
+		return nil, types.NewValidationError(validationErrors)
+	}
+
+	updatedOrg, err := svc.repo.UpdateByID(updates, id, version, actorID)
+	if err != nil {
+		return nil, databaseError("Unable to update organisation", err)
+	}
+
+	// Audited by the outbox relay
+	outboxWake.notify()
+
+	return updatedOrg, nil
+}
+
+// Applies an RFC 7396 merge patch. Only the fields present in the patch are validated and
+// written, and a patch which changes nothing returns the current organisation untouched.
+func (svc *organisationService) PatchByID(patch []byte, id uint64, version uint64, actorID uint64) (*model.Organisation, *types.ApplicationError) {
+	current, err := svc.repo.FindByID(id)
+	if err != nil {
+		return nil, databaseError("Unable to update organisation", err)
+	}
+
+	if version > 0 && current.Version != version {
+		return nil, databaseError("Unable to update organisation", repository.ErrVersionMismatch)
+	}
+
+	var patched model.Organisation
+	fields, appErr := applyMergePatch(current, patch, &patched)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	patched.Normalise()
+	validationErrors := patched.ValidatePartial(fields)
+	if len(validationErrors) > 0 {
+		return nil, types.NewValidationError(validationErrors)
+	}
+
+	changes := changedColumns(current, &patched, fields)
+	if len(changes) == 0 {
+		return current, nil
+	}
+
+	updatedOrg, err := svc.repo.PatchByID(id, current.Version, changes, actorID)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, &types.ApplicationError{
-				HttpStatus: http.StatusNoContent,
-				Message:    "Unable to update organisation",
-				Err:        fmt.Errorf("No organistion available for the given id"),
-			}
-		}
-		return nil, &types.ApplicationError{
-			HttpStatus: http.StatusInternalServerError,
-			Message:    "Unable to update organisation",
-			Err:        err,
-		}
+		return nil, databaseError("Unable to update organisation", err)
 	}
+
+	// Audited by the outbox relay
+	outboxWake.notify()
+
 	return updatedOrg, nil
 }
 
-func (svc *organisationService) DeleteByID(id uint64) *types.ApplicationError {
-	err := svc.repo.DeleteByID(id)
+func (svc *organisationService) DeleteByID(id uint64, version uint64, actorID uint64) *types.ApplicationError {
+	err := svc.repo.DeleteByID(id, version, actorID)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return &types.ApplicationError{
-				HttpStatus: http.StatusNoContent,
-				Message:    "Unable to delete organisation",
-				Err:        fmt.Errorf("No organistion available for the given id"),
-			}
-		}
-		return &types.ApplicationError{
-			HttpStatus: http.StatusInternalServerError,
-			Message:    "Unable to delete organisation",
-			Err:        err,
-		}
+		return databaseError("Unable to delete organisation", err)
 	}
+
+	// Audited by the outbox relay
+	outboxWake.notify()
+
 	return nil
 }
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+	"github.com/supermario64bit/whatsapp_connect/server/repository"
+	"github.com/supermario64bit/whatsapp_connect/types"
+)
+
+type organisationMemberService struct {
+	repo  repository.OrganisationMemberRepository
+	users repository.UserRepository
+	audit AuditService
+}
+
+type OrganisationMemberService interface {
+	Create(orgID uint64, member *model.OrganisationMember, actorID uint64) (*model.OrganisationMember, *types.ApplicationError)
+	Find(orgID uint64) ([]*model.OrganisationMember, *types.ApplicationError)
+	DeleteByUserID(orgID uint64, userID uint64, actorID uint64) *types.ApplicationError
+	Require(orgID uint64, userID uint64) *types.ApplicationError
+}
+
+func NewOrganisationMemberService() OrganisationMemberService {
+	return &organisationMemberService{
+		repo:  repository.NewOrganisationMemberRepository(),
+		users: repository.NewUserRepository(),
+		audit: NewAuditService(),
+	}
+}
+
+func (svc *organisationMemberService) Create(orgID uint64, member *model.OrganisationMember, actorID uint64) (*model.OrganisationMember, *types.ApplicationError) {
+	member.OrganisationID = orgID
+	validationErrors := member.ValidateFields()
+	if len(validationErrors) > 0 {
+		return nil, types.NewValidationError(validationErrors)
+	}
+
+	_, err := svc.users.FindByID(member.UserID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, types.NewFieldValidationError(types.FieldError{Field: "user_id", Rule: "exists", Message: "must refer to an existing user"})
+		}
+		return nil, databaseError("Unable to find user by id", err)
+	}
+
+	new, err := svc.repo.Create(member)
+	if err != nil {
+		return nil, databaseError("Unable to add organisation member", err)
+	}
+
+	svc.audit.Record(actorID, &orgID, model.AuditEntityMember, new.UserID, model.AuditActionCreate, nil, new)
+
+	return new, nil
+}
+
+func (svc *organisationMemberService) Find(orgID uint64) ([]*model.OrganisationMember, *types.ApplicationError) {
+	memberSet, err := svc.repo.Find(orgID)
+	if err != nil {
+		return nil, databaseError("Unable to find organisation members", err)
+	}
+	return memberSet, nil
+}
+
+// Removes the user from the organisation. Conversations assigned to them become unassigned.
+func (svc *organisationMemberService) DeleteByUserID(orgID uint64, userID uint64, actorID uint64) *types.ApplicationError {
+	before, err := svc.repo.FindByUserID(orgID, userID)
+	if err != nil {
+		return databaseError("Unable to remove organisation member", err)
+	}
+
+	err = svc.repo.DeleteByUserID(orgID, userID)
+	if err != nil {
+		return databaseError("Unable to remove organisation member", err)
+	}
+
+	svc.audit.Record(actorID, &orgID, model.AuditEntityMember, userID, model.AuditActionDelete, before, nil)
+
+	return nil
+}
+
+// Fails unless the user is a member of the organisation
+func (svc *organisationMemberService) Require(orgID uint64, userID uint64) *types.ApplicationError {
+	if userID == 0 {
+		return types.NewForbiddenError("Organisation members only", errors.New("The request does not identify a user"))
+	}
+
+	_, err := svc.repo.FindByUserID(orgID, userID)
+	if err == sql.ErrNoRows {
+		return types.NewForbiddenError("Organisation members only", errors.New("The user is not a member of the organisation"))
+	}
+	if err != nil {
+		return databaseError("Unable to find organisation member", err)
+	}
+
+	return nil
+}
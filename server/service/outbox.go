@@ -0,0 +1,291 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/supermario64bit/whatsapp_connect/pkg/logger"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+	"github.com/supermario64bit/whatsapp_connect/server/repository"
+)
+
+const (
+	// Failed events wait twice as long each time, from the base delay up to the max delay. They
+	// are retried until every subscriber and broker handled them.
+	outboxBaseDelay = time.Second
+	outboxMaxDelay  = 15 * time.Minute
+	// Published events kept for inspection
+	outboxRetention = 7 * 24 * time.Hour
+	// Workers started when OUTBOX_RELAY_WORKERS is not set. A single worker publishes events in
+	// the order they were stored, retries aside.
+	defaultOutboxRelayWorkers = 1
+	// Names the built-in subscribers' deliveries are recorded under
+	outboxAuditSubscriber    = "audit"
+	outboxWebhookSubscriber  = "webhooks"
+	outboxRealtimeSubscriber = "realtime"
+)
+
+// Outbox events queued for webhook subscriptions, by the webhook event type they are sent as
+var outboxWebhookEvents = map[string]string{
+	model.OutboxEventContactCreated:  model.WebhookEventContactCreated,
+	model.OutboxEventMessageReceived: model.WebhookEventMessageReceived,
+	model.OutboxEventMessageStatus:   model.WebhookEventMessageStatus,
+}
+
+// Outbox events streamed to connected agents, by the inbox event type they are sent as
+var outboxInboxEvents = map[string]string{
+	model.OutboxEventMessageReceived:      model.InboxEventMessageReceived,
+	model.OutboxEventMessageStatus:        model.InboxEventMessageStatus,
+	model.OutboxEventConversationAssigned: model.InboxEventConversationAssigned,
+}
+
+var outboxWake = newQueueWake()
+
+// Handles an outbox event in process. Events may come more than once, so subscribers have to
+// be idempotent. An error has the event handed to the subscriber again later.
+type OutboxSubscriber func(event *model.OutboxEvent) error
+
+// Carries outbox events to another system, such as a message broker. Brokers get every event,
+// at least once.
+type OutboxBroker interface {
+	// Name the broker's deliveries are recorded under. It has to stay the same across restarts.
+	Name() string
+	Publish(event *model.OutboxEvent) error
+}
+
+type outboxHandler struct {
+	name string
+	// Event types handled. All types when empty.
+	eventTypes map[string]bool
+	handle     func(event *model.OutboxEvent) error
+}
+
+// Subscribers and brokers registered at startup, before the relay runs
+var outboxHandlers = struct {
+	mu       sync.Mutex
+	handlers []outboxHandler
+}{}
+
+// Registers an in-process subscriber for the given event types, or for every event when none
+// are given. Names identify subscribers across restarts and have to be unique.
+func SubscribeOutbox(name string, subscriber OutboxSubscriber, eventTypes ...string) {
+	wanted := map[string]bool{}
+	for _, eventType := range eventTypes {
+		wanted[eventType] = true
+	}
+	registerOutboxHandler(outboxHandler{name: name, eventTypes: wanted, handle: subscriber})
+}
+
+// Registers a broker every outbox event is published to
+func RegisterOutboxBroker(broker OutboxBroker) {
+	registerOutboxHandler(outboxHandler{name: broker.Name(), eventTypes: map[string]bool{}, handle: broker.Publish})
+}
+
+func registerOutboxHandler(handler outboxHandler) {
+	outboxHandlers.mu.Lock()
+	defer outboxHandlers.mu.Unlock()
+
+	if handler.name == "" || handler.name == outboxAuditSubscriber || handler.name == outboxWebhookSubscriber || handler.name == outboxRealtimeSubscriber {
+		panic(fmt.Sprintf("Invalid outbox subscriber name %q", handler.name))
+	}
+	for _, registered := range outboxHandlers.handlers {
+		if registered.name == handler.name {
+			panic(fmt.Sprintf("Outbox subscriber %q is registered already", handler.name))
+		}
+	}
+	outboxHandlers.handlers = append(outboxHandlers.handlers, handler)
+}
+
+type outboxService struct {
+	repo     repository.OutboxEventRepository
+	audit    AuditService
+	webhooks WebhookDeliveryService
+	events   InboxEventService
+}
+
+type OutboxService interface {
+	Run()
+}
+
+func NewOutboxService() OutboxService {
+	return &outboxService{
+		repo:     repository.NewOutboxEventRepository(),
+		audit:    NewAuditService(),
+		webhooks: NewWebhookDeliveryService(),
+		events:   NewInboxEventService(),
+	}
+}
+
+// Runs the relay publishing stored events until the process exits. Every instance may run it,
+// each event is published by one worker at a time. Setting OUTBOX_NOTIFY_CHANNEL publishes
+// every event as a Postgres notification on that channel too.
+func (svc *outboxService) Run() {
+	if channel := strings.TrimSpace(os.Getenv("OUTBOX_NOTIFY_CHANNEL")); channel != "" {
+		RegisterOutboxBroker(&notifyOutboxBroker{repo: svc.repo, channel: channel})
+	}
+
+	go func() {
+		for range time.Tick(time.Hour) {
+			_, err := svc.repo.DeletePublishedBefore(time.Now().Add(-outboxRetention))
+			if err != nil {
+				logger.Warning("Unable to prune outbox events. Error: " + err.Error())
+			}
+		}
+	}()
+
+	queue := &leaseQueue[*model.OutboxEvent]{
+		name:           "outbox event",
+		workersEnv:     "OUTBOX_RELAY_WORKERS",
+		defaultWorkers: defaultOutboxRelayWorkers,
+		wake:           outboxWake,
+		claim:          svc.repo.Claim,
+		process:        svc.publish,
+	}
+	queue.run()
+}
+
+// Hands the event to every subscriber and broker which has not handled it yet. Each one is
+// recorded as it succeeds, so a retry only reaches the ones which failed.
+func (svc *outboxService) publish(event *model.OutboxEvent) {
+	var failures []string
+	for _, handler := range svc.handlers() {
+		if event.DeliveredBy(handler.name) || (len(handler.eventTypes) > 0 && !handler.eventTypes[event.EventType]) {
+			continue
+		}
+
+		err := handler.handle(event)
+		if err != nil {
+			failures = append(failures, handler.name+": "+err.Error())
+			continue
+		}
+
+		err = svc.repo.MarkDelivered(event, handler.name)
+		if err != nil {
+			logger.Warning(fmt.Sprintf("Unable to record delivery of outbox event %d to %s, it may get it again. Error: %s", event.ID, handler.name, err.Error()))
+		}
+	}
+
+	if len(failures) > 0 {
+		lastError := strings.Join(failures, "; ")
+		logger.Warning(fmt.Sprintf("Unable to publish outbox event %d on attempt %d. Error: %s", event.ID, event.Attempts, lastError))
+
+		err := svc.repo.Retry(event, time.Now().Add(retryBackoff(event.Attempts, outboxBaseDelay, outboxMaxDelay)), lastError)
+		if err != nil {
+			warnLeaseRetry(fmt.Sprintf("reschedule outbox event %d", event.ID), err)
+		}
+		return
+	}
+
+	err := svc.repo.Publish(event, time.Now())
+	if err != nil {
+		logger.Warning(fmt.Sprintf("Unable to complete outbox event %d, it is published again once its lease runs out. Error: %s", event.ID, err.Error()))
+	}
+}
+
+// Built-in subscribers followed by the registered ones
+func (svc *outboxService) handlers() []outboxHandler {
+	outboxHandlers.mu.Lock()
+	defer outboxHandlers.mu.Unlock()
+
+	handlers := []outboxHandler{
+		{name: outboxAuditSubscriber, eventTypes: map[string]bool{}, handle: svc.recordAudit},
+		{name: outboxWebhookSubscriber, eventTypes: eventTypeSet(outboxWebhookEvents), handle: svc.publishWebhook},
+		{name: outboxRealtimeSubscriber, eventTypes: eventTypeSet(outboxInboxEvents), handle: svc.publishInboxEvent},
+	}
+	return append(handlers, outboxHandlers.handlers...)
+}
+
+// Records the change an event reports in the audit log. Events of other aggregates are audited
+// by the services making the change.
+func (svc *outboxService) recordAudit(event *model.OutboxEvent) error {
+	var actorID uint64
+	if event.ActorID != nil {
+		actorID = *event.ActorID
+	}
+
+	switch event.EventType {
+	case model.OutboxEventUserCreated:
+		return svc.audit.RecordEvent(event.ID, actorID, event.OrganisationID, model.AuditEntityUser, event.AggregateID, model.AuditActionCreate, nil, event.Payload)
+	case model.OutboxEventUserUpdated:
+		return svc.audit.RecordEvent(event.ID, actorID, event.OrganisationID, model.AuditEntityUser, event.AggregateID, model.AuditActionUpdate, event.Previous, event.Payload)
+	case model.OutboxEventUserDeleted:
+		return svc.audit.RecordEvent(event.ID, actorID, event.OrganisationID, model.AuditEntityUser, event.AggregateID, model.AuditActionDelete, event.Previous, nil)
+	case model.OutboxEventOrganisationCreated:
+		return svc.audit.RecordEvent(event.ID, actorID, event.OrganisationID, model.AuditEntityOrganisation, event.AggregateID, model.AuditActionCreate, nil, event.Payload)
+	case model.OutboxEventOrganisationUpdated:
+		return svc.audit.RecordEvent(event.ID, actorID, event.OrganisationID, model.AuditEntityOrganisation, event.AggregateID, model.AuditActionUpdate, event.Previous, event.Payload)
+	case model.OutboxEventOrganisationDeleted:
+		return svc.audit.RecordEvent(event.ID, actorID, event.OrganisationID, model.AuditEntityOrganisation, event.AggregateID, model.AuditActionDelete, event.Previous, nil)
+	}
+	return nil
+}
+
+// Queues the event for the organisation's webhook subscriptions. The webhook event id comes
+// from the outbox event, so an event relayed twice is delivered once.
+func (svc *outboxService) publishWebhook(event *model.OutboxEvent) error {
+	if event.OrganisationID == nil {
+		return nil
+	}
+	return svc.webhooks.Publish(*event.OrganisationID, outboxWebhookEvents[event.EventType], fmt.Sprintf("%032x", event.ID), event.CreatedAt, event.Payload)
+}
+
+// Streams the event to the organisation's connected agents. Agents may get an event relayed
+// twice, as they may on reconnecting.
+func (svc *outboxService) publishInboxEvent(event *model.OutboxEvent) error {
+	if event.OrganisationID == nil {
+		return nil
+	}
+
+	conversationID := &event.AggregateID
+	if event.AggregateType == model.OutboxAggregateMessage {
+		var message struct {
+			ConversationID *uint64 `json:"conversation_id"`
+		}
+		err := json.Unmarshal(event.Payload, &message)
+		if err != nil {
+			return err
+		}
+		conversationID = message.ConversationID
+	}
+
+	return svc.events.Publish(*event.OrganisationID, outboxInboxEvents[event.EventType], conversationID, event.Payload)
+}
+
+func eventTypeSet(eventTypes map[string]string) map[string]bool {
+	set := map[string]bool{}
+	for eventType := range eventTypes {
+		set[eventType] = true
+	}
+	return set
+}
+
+// Publishes events as Postgres notifications, for processes listening on the channel. The
+// notification carries the event without its payload, which may exceed the notification size
+// limit.
+type notifyOutboxBroker struct {
+	repo    repository.OutboxEventRepository
+	channel string
+}
+
+func (broker *notifyOutboxBroker) Name() string {
+	return "notify:" + broker.channel
+}
+
+func (broker *notifyOutboxBroker) Publish(event *model.OutboxEvent) error {
+	notification, err := json.Marshal(map[string]interface{}{
+		"id":              event.ID,
+		"aggregate_type":  event.AggregateType,
+		"aggregate_id":    event.AggregateID,
+		"organisation_id": event.OrganisationID,
+		"event_type":      event.EventType,
+		"created_at":      event.CreatedAt,
+	})
+	if err != nil {
+		return err
+	}
+	return broker.repo.Notify(broker.channel, string(notification))
+}
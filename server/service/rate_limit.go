@@ -0,0 +1,109 @@
+package service
+
+import (
+	"time"
+
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+	"github.com/supermario64bit/whatsapp_connect/server/repository"
+	"github.com/supermario64bit/whatsapp_connect/types"
+)
+
+// Window Meta counts the unique customers of a messaging tier over
+const messagingTierWindow = 24 * time.Hour
+
+type rateLimitService struct {
+	repo     repository.RateLimitRepository
+	accounts WhatsAppAccountService
+}
+
+type RateLimitService interface {
+	Reserve(account *model.WhatsAppAccount, messageID uint64, template bool) (time.Time, error)
+	Usage(orgID uint64, accountID uint64) (*model.WhatsAppAccountUsage, *types.ApplicationError)
+}
+
+func NewRateLimitService() RateLimitService {
+	return &rateLimitService{
+		repo:     repository.NewRateLimitRepository(),
+		accounts: NewWhatsAppAccountService(),
+	}
+}
+
+// Returns when the message may be sent from the account, the zero time meaning right away.
+// Every message takes from the account's throughput. Templates to customers not sent one in the
+// last 24 hours also count against its tier. Workers sending for the same account at once may
+// each let a message past a full tier before the count catches up.
+func (svc *rateLimitService) Reserve(account *model.WhatsAppAccount, messageID uint64, template bool) (time.Time, error) {
+	now := time.Now()
+
+	if limit, ok := account.DailyLimit(); ok && template {
+		since := now.Add(-messagingTierWindow)
+
+		known, err := svc.repo.HasTemplateRecipient(account.ID, messageID, since)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		if !known {
+			count, earliest, err := svc.repo.TemplateRecipients(account.ID, since)
+			if err != nil {
+				return time.Time{}, err
+			}
+
+			// A customer drops out of the count once their template is a day old
+			if count >= limit && earliest != nil {
+				return earliest.Add(messagingTierWindow), nil
+			}
+		}
+	}
+
+	taken, err := svc.repo.TakeToken(account)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !taken {
+		return now.Add(time.Second / time.Duration(account.MessagesPerSecond)), nil
+	}
+
+	return time.Time{}, nil
+}
+
+func (svc *rateLimitService) Usage(orgID uint64, accountID uint64) (*model.WhatsAppAccountUsage, *types.ApplicationError) {
+	account, appErr := svc.accounts.FindByID(orgID, accountID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	now := time.Now()
+	usage := &model.WhatsAppAccountUsage{
+		WhatsAppAccountID: account.ID,
+		MessagesPerSecond: account.MessagesPerSecond,
+		MessagingTier:     account.MessagingTier,
+	}
+
+	var err error
+	usage.SentLastMinute, err = svc.repo.SentSince(account.ID, now.Add(-time.Minute))
+	if err != nil {
+		return nil, databaseError("Unable to find whatsapp account usage", err)
+	}
+
+	usage.UniqueRecipients24h, _, err = svc.repo.TemplateRecipients(account.ID, now.Add(-messagingTierWindow))
+	if err != nil {
+		return nil, databaseError("Unable to find whatsapp account usage", err)
+	}
+
+	if limit, ok := account.DailyLimit(); ok {
+		remaining := limit - usage.UniqueRecipients24h
+		if remaining < 0 {
+			remaining = 0
+		}
+		usage.DailyLimit = &limit
+		usage.DailyRemaining = &remaining
+	}
+
+	usage.PendingJobs, err = svc.repo.PendingJobs(account.ID)
+	if err != nil {
+		return nil, databaseError("Unable to find whatsapp account usage", err)
+	}
+
+	return usage, nil
+}
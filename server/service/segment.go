@@ -0,0 +1,151 @@
+package service
+
+import (
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+	"github.com/supermario64bit/whatsapp_connect/server/repository"
+	"github.com/supermario64bit/whatsapp_connect/types"
+)
+
+// Number of matching contacts a preview returns alongside the count
+const segmentPreviewSampleSize = 10
+
+type segmentService struct {
+	repo        repository.SegmentRepository
+	contactRepo repository.ContactRepository
+	audit       AuditService
+}
+
+type SegmentService interface {
+	Create(orgID uint64, segment *model.Segment, actorID uint64) (*model.Segment, *types.ApplicationError)
+	Find(orgID uint64) ([]*model.Segment, *types.ApplicationError)
+	FindByID(orgID uint64, id uint64) (*model.Segment, *types.ApplicationError)
+	PatchByID(orgID uint64, patch []byte, id uint64, version uint64, actorID uint64) (*model.Segment, *types.ApplicationError)
+	DeleteByID(orgID uint64, id uint64, version uint64, actorID uint64) *types.ApplicationError
+	Preview(orgID uint64, rule *model.SegmentRule) (*model.SegmentPreview, *types.ApplicationError)
+	PreviewByID(orgID uint64, id uint64) (*model.SegmentPreview, *types.ApplicationError)
+}
+
+func NewSegmentService() SegmentService {
+	return &segmentService{
+		repo:        repository.NewSegmentRepository(),
+		contactRepo: repository.NewContactRepository(),
+		audit:       NewAuditService(),
+	}
+}
+
+func (svc *segmentService) Create(orgID uint64, segment *model.Segment, actorID uint64) (*model.Segment, *types.ApplicationError) {
+	segment.OrganisationID = orgID
+	segment.Normalise()
+	details := segment.ValidateFields()
+	if len(details) > 0 {
+		return nil, types.NewFieldValidationError(details...)
+	}
+
+	new, err := svc.repo.Create(segment)
+	if err != nil {
+		return nil, databaseError("Unable to create segment", err)
+	}
+
+	svc.audit.Record(actorID, &orgID, model.AuditEntitySegment, new.ID, model.AuditActionCreate, nil, new)
+
+	return new, nil
+}
+
+func (svc *segmentService) Find(orgID uint64) ([]*model.Segment, *types.ApplicationError) {
+	segmentSet, err := svc.repo.Find(orgID)
+	if err != nil {
+		return nil, databaseError("Unable to find segments", err)
+	}
+	return segmentSet, nil
+}
+
+func (svc *segmentService) FindByID(orgID uint64, id uint64) (*model.Segment, *types.ApplicationError) {
+	segment, err := svc.repo.FindByID(orgID, id)
+	if err != nil {
+		return nil, databaseError("Unable to find segment by id", err)
+	}
+	return segment, nil
+}
+
+// Applies an RFC 7396 merge patch. Only the fields present in the patch are validated and
+// written, and a patch which changes nothing returns the current segment untouched.
+func (svc *segmentService) PatchByID(orgID uint64, patch []byte, id uint64, version uint64, actorID uint64) (*model.Segment, *types.ApplicationError) {
+	current, err := svc.repo.FindByID(orgID, id)
+	if err != nil {
+		return nil, databaseError("Unable to update segment", err)
+	}
+
+	if version > 0 && current.Version != version {
+		return nil, databaseError("Unable to update segment", repository.ErrVersionMismatch)
+	}
+
+	var patched model.Segment
+	fields, appErr := applyMergePatch(current, patch, &patched)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	patched.Normalise()
+	details := patched.ValidatePartial(fields)
+	if len(details) > 0 {
+		return nil, types.NewFieldValidationError(details...)
+	}
+
+	changes := changedColumns(current, &patched, fields)
+	if len(changes) == 0 {
+		return current, nil
+	}
+
+	updatedSegment, err := svc.repo.PatchByID(id, current.Version, changes)
+	if err != nil {
+		return nil, databaseError("Unable to update segment", err)
+	}
+
+	svc.audit.Record(actorID, &orgID, model.AuditEntitySegment, updatedSegment.ID, model.AuditActionUpdate, current, updatedSegment)
+
+	return updatedSegment, nil
+}
+
+func (svc *segmentService) DeleteByID(orgID uint64, id uint64, version uint64, actorID uint64) *types.ApplicationError {
+	before, err := svc.repo.FindByID(orgID, id)
+	if err != nil {
+		return databaseError("Unable to delete segment", err)
+	}
+
+	err = svc.repo.DeleteByID(orgID, id, version)
+	if err != nil {
+		return databaseError("Unable to delete segment", err)
+	}
+
+	svc.audit.Record(actorID, &orgID, model.AuditEntitySegment, id, model.AuditActionDelete, before, nil)
+
+	return nil
+}
+
+// Evaluates a rule, saved or not, and returns how many contacts it matches with a sample of them
+func (svc *segmentService) Preview(orgID uint64, rule *model.SegmentRule) (*model.SegmentPreview, *types.ApplicationError) {
+	details := rule.Validate("rules")
+	if len(details) > 0 {
+		return nil, types.NewFieldValidationError(details...)
+	}
+
+	sample, count, err := svc.contactRepo.FindMatching(orgID, rule, segmentPreviewSampleSize)
+	if err != nil {
+		return nil, databaseError("Unable to preview segment", err)
+	}
+
+	if sample == nil {
+		sample = []*model.Contact{}
+	}
+
+	return &model.SegmentPreview{Count: count, Sample: sample}, nil
+}
+
+func (svc *segmentService) PreviewByID(orgID uint64, id uint64) (*model.SegmentPreview, *types.ApplicationError) {
+	segment, appErr := svc.FindByID(orgID, id)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	return svc.Preview(orgID, &segment.Rules)
+}
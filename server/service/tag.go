@@ -0,0 +1,118 @@
+package service
+
+import (
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+	"github.com/supermario64bit/whatsapp_connect/server/repository"
+	"github.com/supermario64bit/whatsapp_connect/types"
+)
+
+type tagService struct {
+	repo  repository.TagRepository
+	audit AuditService
+}
+
+type TagService interface {
+	Create(orgID uint64, tag *model.Tag, actorID uint64) (*model.Tag, *types.ApplicationError)
+	Find(orgID uint64) ([]*model.Tag, *types.ApplicationError)
+	FindByID(orgID uint64, id uint64) (*model.Tag, *types.ApplicationError)
+	Assign(orgID uint64, id uint64, assignment *model.TagAssignment) (*model.Tag, *types.ApplicationError)
+	Unassign(orgID uint64, id uint64, assignment *model.TagAssignment) (*model.Tag, *types.ApplicationError)
+	DeleteByID(orgID uint64, id uint64, actorID uint64) *types.ApplicationError
+}
+
+func NewTagService() TagService {
+	return &tagService{
+		repo:  repository.NewTagRepository(),
+		audit: NewAuditService(),
+	}
+}
+
+func (svc *tagService) Create(orgID uint64, tag *model.Tag, actorID uint64) (*model.Tag, *types.ApplicationError) {
+	tag.OrganisationID = orgID
+	tag.Normalise()
+	validationErrors := tag.ValidateFields()
+	if len(validationErrors) > 0 {
+		return nil, types.NewValidationError(validationErrors)
+	}
+
+	new, err := svc.repo.Create(tag)
+	if err != nil {
+		return nil, databaseError("Unable to create tag", err)
+	}
+
+	svc.audit.Record(actorID, &orgID, model.AuditEntityTag, new.ID, model.AuditActionCreate, nil, new)
+
+	return new, nil
+}
+
+func (svc *tagService) Find(orgID uint64) ([]*model.Tag, *types.ApplicationError) {
+	tagSet, err := svc.repo.Find(orgID)
+	if err != nil {
+		return nil, databaseError("Unable to find tags", err)
+	}
+	return tagSet, nil
+}
+
+func (svc *tagService) FindByID(orgID uint64, id uint64) (*model.Tag, *types.ApplicationError) {
+	tag, err := svc.repo.FindByID(orgID, id)
+	if err != nil {
+		return nil, databaseError("Unable to find tag by id", err)
+	}
+	return tag, nil
+}
+
+// Tags the given contacts and returns the tag with its new contact count
+func (svc *tagService) Assign(orgID uint64, id uint64, assignment *model.TagAssignment) (*model.Tag, *types.ApplicationError) {
+	validationErrors := assignment.ValidateFields()
+	if len(validationErrors) > 0 {
+		return nil, types.NewValidationError(validationErrors)
+	}
+
+	_, appErr := svc.FindByID(orgID, id)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	_, err := svc.repo.Assign(id, orgID, assignment.ContactIDs)
+	if err != nil {
+		return nil, databaseError("Unable to assign tag", err)
+	}
+
+	return svc.FindByID(orgID, id)
+}
+
+// Takes the tag off the given contacts and returns the tag with its new contact count
+func (svc *tagService) Unassign(orgID uint64, id uint64, assignment *model.TagAssignment) (*model.Tag, *types.ApplicationError) {
+	validationErrors := assignment.ValidateFields()
+	if len(validationErrors) > 0 {
+		return nil, types.NewValidationError(validationErrors)
+	}
+
+	_, appErr := svc.FindByID(orgID, id)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	_, err := svc.repo.Unassign(id, assignment.ContactIDs)
+	if err != nil {
+		return nil, databaseError("Unable to unassign tag", err)
+	}
+
+	return svc.FindByID(orgID, id)
+}
+
+func (svc *tagService) DeleteByID(orgID uint64, id uint64, actorID uint64) *types.ApplicationError {
+	before, err := svc.repo.FindByID(orgID, id)
+	if err != nil {
+		return databaseError("Unable to delete tag", err)
+	}
+
+	err = svc.repo.DeleteByID(orgID, id)
+	if err != nil {
+		return databaseError("Unable to delete tag", err)
+	}
+
+	svc.audit.Record(actorID, &orgID, model.AuditEntityTag, id, model.AuditActionDelete, before, nil)
+
+	return nil
+}
@@ -1,9 +1,7 @@
 package service
 
 import (
-	"database/sql"
-	"fmt"
-	"net/http"
+	"errors"
 
 	"github.com/supermario64bit/whatsapp_connect/server/model"
 	"github.com/supermario64bit/whatsapp_connect/server/repository"
@@ -15,11 +13,12 @@ type userservice struct {
 }
 
 type UserService interface {
-	Create(user *model.User) (*model.User, *types.ApplicationError)
-	Find(filter *model.User) ([]*model.User, *types.ApplicationError)
-	FindByID(id uint64) (*model.User, *types.ApplicationError)
-	UpdateByID(updates *model.User, id uint64) (*model.User, *types.ApplicationError)
-	DeleteByID(id uint64) *types.ApplicationError
+	Create(user *model.User, actorID uint64) (*model.User, *types.ApplicationError)
+	Find(filter *model.User, actorID uint64) ([]*model.User, *types.ApplicationError)
+	FindByID(id uint64, actorID uint64) (*model.User, *types.ApplicationError)
+	UpdateByID(updates *model.User, id uint64, version uint64, actorID uint64) (*model.User, *types.ApplicationError)
+	PatchByID(patch []byte, id uint64, version uint64, actorID uint64) (*model.User, *types.ApplicationError)
+	DeleteByID(id uint64, version uint64, actorID uint64) *types.ApplicationError
 }
 
 func NewUserService() UserService {
@@ -28,89 +27,132 @@ func NewUserService() UserService {
 	}
 }
 
-func (svc *userservice) Create(user *model.User) (*model.User, *types.ApplicationError) {
+func (svc *userservice) Create(user *model.User, actorID uint64) (*model.User, *types.ApplicationError) {
+	user.Normalise()
 	validationErrors := user.ValidateFields()
 	if len(validationErrors) > 0 {
-		return nil, &types.ApplicationError{
-			HttpStatus: http.StatusBadRequest,
-			Message:    "Validation Failed",
-			Err:        fmt.Errorf("", validationErrors),
-		}
+		return nil, types.NewValidationError(validationErrors)
 	}
 
-	new, err := svc.repo.Create(user)
+	new, err := svc.repo.Create(user, actorID)
 	if err != nil {
-		return nil, &types.ApplicationError{
-			HttpStatus: http.StatusInternalServerError,
-			Message:    "Unable to create user",
-			Err:        fmt.Errorf("Unable to create user. Error: " + err.Error()),
-		}
+		return nil, databaseError("Unable to create user", err)
 	}
 
+	// Audited by the outbox relay
+	outboxWake.notify()
+
 	return new, nil
 }
 
-func (svc *userservice) Find(filter *model.User) ([]*model.User, *types.ApplicationError) {
-	userSet, err := svc.repo.Find(filter)
+// Finds the matching users among the actor and those sharing an organisation with them
+func (svc *userservice) Find(filter *model.User, actorID uint64) ([]*model.User, *types.ApplicationError) {
+	userSet, err := svc.repo.Find(filter, actorID)
 	if err != nil {
-		return nil, &types.ApplicationError{
-			HttpStatus: http.StatusInternalServerError,
-			Message:    "Unable to find users",
-			Err:        err,
-		}
+		return nil, databaseError("Unable to find users", err)
 	}
 	return userSet, nil
 }
 
-func (svc *userservice) FindByID(id uint64) (*model.User, *types.ApplicationError) {
-	user, err := svc.repo.FindByID(id)
+// Finds the user when it is the actor or shares an organisation with them. Other users
+// are reported as not found.
+func (svc *userservice) FindByID(id uint64, actorID uint64) (*model.User, *types.ApplicationError) {
+	user, err := svc.repo.FindVisibleByID(id, actorID)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil
-		}
-		return nil, &types.ApplicationError{
-			HttpStatus: http.StatusInternalServerError,
-			Message:    "Unable to find user by id",
-			Err:        err,
-		}
+		return nil, databaseError("Unable to find user by id", err)
 	}
 	return user, nil
 }
 
-func (svc *userservice) UpdateByID(updates *model.User, id uint64) (*model.User, *types.ApplicationError) {
-	updatedUser, err := svc.repo.UpdateByID(updates, id)
+func (svc *userservice) UpdateByID(updates *model.User, id uint64, version uint64, actorID uint64) (*model.User, *types.ApplicationError) {
+	appErr := requireSelf(id, actorID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	updates.Normalise()
+	validationErrors := updates.ValidateSupplied()
+	if len(validationErrors) > 0 {
+		return nil, types.NewValidationError(validationErrors)
+	}
+
+	updatedUser, err := svc.repo.UpdateByID(updates, id, version, actorID)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, &types.ApplicationError{
-				HttpStatus: http.StatusNoContent,
-				Message:    "Unable to update user",
-				Err:        fmt.Errorf("No user available for the given id"),
-			}
-		}
-		return nil, &types.ApplicationError{
-			HttpStatus: http.StatusInternalServerError,
-			Message:    "Unable to update user",
-			Err:        err,
-		}
+		return nil, databaseError("Unable to update user", err)
 	}
+
+	// Audited by the outbox relay
+	outboxWake.notify()
+
 	return updatedUser, nil
 }
 
-func (svc *userservice) DeleteByID(id uint64) *types.ApplicationError {
-	err := svc.repo.DeleteByID(id)
+// Applies an RFC 7396 merge patch. Only the fields present in the patch are validated and
+// written, and a patch which changes nothing returns the current user untouched.
+func (svc *userservice) PatchByID(patch []byte, id uint64, version uint64, actorID uint64) (*model.User, *types.ApplicationError) {
+	appErr := requireSelf(id, actorID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	current, err := svc.repo.FindByID(id)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return &types.ApplicationError{
-				HttpStatus: http.StatusNoContent,
-				Message:    "Unable to delete user",
-				Err:        fmt.Errorf("No user available for the given id"),
-			}
-		}
-		return &types.ApplicationError{
-			HttpStatus: http.StatusInternalServerError,
-			Message:    "Unable to delete user",
-			Err:        err,
-		}
+		return nil, databaseError("Unable to update user", err)
+	}
+
+	if version > 0 && current.Version != version {
+		return nil, databaseError("Unable to update user", repository.ErrVersionMismatch)
+	}
+
+	var patched model.User
+	fields, appErr := applyMergePatch(current, patch, &patched)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	patched.Normalise()
+	validationErrors := patched.ValidatePartial(fields)
+	if len(validationErrors) > 0 {
+		return nil, types.NewValidationError(validationErrors)
+	}
+
+	changes := changedColumns(current, &patched, fields)
+	if len(changes) == 0 {
+		return current, nil
+	}
+
+	updatedUser, err := svc.repo.PatchByID(id, current.Version, changes, actorID)
+	if err != nil {
+		return nil, databaseError("Unable to update user", err)
+	}
+
+	// Audited by the outbox relay
+	outboxWake.notify()
+
+	return updatedUser, nil
+}
+
+func (svc *userservice) DeleteByID(id uint64, version uint64, actorID uint64) *types.ApplicationError {
+	appErr := requireSelf(id, actorID)
+	if appErr != nil {
+		return appErr
+	}
+
+	err := svc.repo.DeleteByID(id, version, actorID)
+	if err != nil {
+		return databaseError("Unable to delete user", err)
+	}
+
+	// Audited by the outbox relay
+	outboxWake.notify()
+
+	return nil
+}
+
+// Users may only change their own account
+func requireSelf(id uint64, actorID uint64) *types.ApplicationError {
+	if id != actorID {
+		return types.NewForbiddenError("Users may only change their own account", errors.New("The user is not the one signed in"))
 	}
 	return nil
 }
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"strconv"
+
+	"github.com/supermario64bit/whatsapp_connect/pkg/logger"
+	"github.com/supermario64bit/whatsapp_connect/pkg/whatsapp"
+)
+
+type webhookService struct {
+	accounts  WhatsAppAccountService
+	contacts  ContactService
+	templates MessageTemplateService
+	messages  MessageService
+}
+
+type WebhookService interface {
+	Handle(payload *whatsapp.WebhookPayload)
+}
+
+func NewWebhookService() WebhookService {
+	return &webhookService{
+		accounts:  NewWhatsAppAccountService(),
+		contacts:  NewContactService(),
+		templates: NewMessageTemplateService(),
+		messages:  NewMessageService(),
+	}
+}
+
+// Processes a webhook notification. Failures are logged rather than returned since Meta only
+// needs to know the notification was received. Inbound messages are stored before it returns,
+// together with the jobs answering them and downloading their media.
+func (svc *webhookService) Handle(payload *whatsapp.WebhookPayload) {
+	for _, entry := range payload.Entry {
+		for _, change := range entry.Changes {
+			switch change.Field {
+			case whatsapp.WebhookFieldMessages:
+				svc.handleMessages(change.Value)
+			case whatsapp.WebhookFieldTemplateStatusUpdate:
+				svc.handleTemplateStatus(change.Value)
+			}
+		}
+	}
+}
+
+func (svc *webhookService) handleMessages(value whatsapp.WebhookValue) {
+	account, appErr := svc.accounts.FindByPhoneNumberID(value.Metadata.PhoneNumberID)
+	if appErr != nil {
+		logger.Warning("Ignoring webhook for unknown phone number id " + value.Metadata.PhoneNumberID + ". Error: " + appErr.Error())
+		return
+	}
+
+	profileNames := map[string]string{}
+	for _, contact := range value.Contacts {
+		profileNames[contact.WaID] = contact.Profile.Name
+	}
+
+	for i := range value.Messages {
+		message := &value.Messages[i]
+		phoneNumber := whatsapp.PhoneNumberFromWaID(message.From)
+		contact, appErr := svc.contacts.RecordInbound(account.OrganisationID, phoneNumber, profileNames[message.From], whatsapp.ParseTimestamp(message.Timestamp))
+		if appErr != nil {
+			logger.Danger("Unable to record contact for inbound message " + message.ID + ". Error: " + appErr.Error())
+			continue
+		}
+
+		_, appErr = svc.messages.RecordInbound(account, contact.ID, message)
+		if appErr != nil {
+			logger.Danger("Unable to record inbound message " + message.ID + ". Error: " + appErr.Error())
+		}
+	}
+
+	for i := range value.Statuses {
+		status := &value.Statuses[i]
+		_, appErr := svc.messages.UpdateStatus(status)
+		if appErr != nil {
+			logger.Warning("Unable to apply status " + status.Status + " to message " + status.ID + ". Error: " + appErr.Error())
+		}
+	}
+}
+
+func (svc *webhookService) handleTemplateStatus(value whatsapp.WebhookValue) {
+	externalID := strconv.FormatInt(value.MessageTemplateID, 10)
+	appErr := svc.templates.UpdateStatus(externalID, value.Event, value.Reason)
+	if appErr != nil {
+		logger.Warning("Unable to apply status " + value.Event + " to message template " + externalID + ". Error: " + appErr.Error())
+	}
+}
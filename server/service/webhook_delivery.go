@@ -0,0 +1,283 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/supermario64bit/whatsapp_connect/pkg/logger"
+	"github.com/supermario64bit/whatsapp_connect/pkg/safehttp"
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+	"github.com/supermario64bit/whatsapp_connect/server/repository"
+	"github.com/supermario64bit/whatsapp_connect/types"
+)
+
+const (
+	// Attempts a delivery gets before it is given up
+	webhookDeliveryMaxAttempts = 8
+	webhookDeliveryTimeout     = 10 * time.Second
+	// Retries wait twice as long each time, so a delivery is given up about an hour after its
+	// first attempt at the latest
+	webhookDeliveryBaseDelay = 30 * time.Second
+	webhookDeliveryMaxDelay  = 30 * time.Minute
+	// Failed attempts in a row, over all of its deliveries, after which a subscription is disabled
+	webhookMaxConsecutiveFailures = 25
+	// Bytes of the response body kept in the delivery log
+	maxWebhookResponseBody = 2048
+	// Workers started when WEBHOOK_DELIVERY_WORKERS is not set
+	defaultWebhookDeliveryWorkers = 4
+)
+
+var webhookDeliveryWake = newQueueWake()
+
+type webhookDeliveryService struct {
+	repo             repository.WebhookDeliveryRepository
+	subscriptionRepo repository.WebhookSubscriptionRepository
+	audit            AuditService
+	httpClient       *http.Client
+}
+
+type WebhookDeliveryService interface {
+	Publish(orgID uint64, eventType string, eventID string, createdAt time.Time, data interface{}) error
+	Find(orgID uint64, subscriptionID uint64, filter *model.WebhookDeliveryFilter) ([]*model.WebhookDelivery, *model.Pagination, *types.ApplicationError)
+	FindByID(orgID uint64, subscriptionID uint64, id uint64) (*model.WebhookDelivery, *types.ApplicationError)
+	Redeliver(orgID uint64, subscriptionID uint64, id uint64, actorID uint64) (*model.WebhookDelivery, *types.ApplicationError)
+	Run()
+}
+
+func NewWebhookDeliveryService() WebhookDeliveryService {
+	return &webhookDeliveryService{
+		repo:             repository.NewWebhookDeliveryRepository(),
+		subscriptionRepo: repository.NewWebhookSubscriptionRepository(),
+		audit:            NewAuditService(),
+		httpClient:       safehttp.NewClient(webhookDeliveryTimeout, true),
+	}
+}
+
+// Queues the event for every subscription of the organisation wanting it, once per event id.
+// Called by the outbox relay, which retries on failure.
+func (svc *webhookDeliveryService) Publish(orgID uint64, eventType string, eventID string, createdAt time.Time, data interface{}) error {
+	payload, err := json.Marshal(model.WebhookPayload{
+		ID:             eventID,
+		Type:           eventType,
+		OrganisationID: orgID,
+		CreatedAt:      createdAt.UTC(),
+		Data:           data,
+	})
+	if err != nil {
+		return err
+	}
+
+	queued, err := svc.repo.Enqueue(orgID, eventType, eventID, payload, webhookDeliveryMaxAttempts)
+	if err != nil {
+		return err
+	}
+
+	if queued > 0 {
+		webhookDeliveryWake.notify()
+	}
+	return nil
+}
+
+func (svc *webhookDeliveryService) Find(orgID uint64, subscriptionID uint64, filter *model.WebhookDeliveryFilter) ([]*model.WebhookDelivery, *model.Pagination, *types.ApplicationError) {
+	_, err := svc.subscriptionRepo.FindByID(orgID, subscriptionID)
+	if err != nil {
+		return nil, nil, databaseError("Unable to find webhook subscription by id", err)
+	}
+
+	filter.Pagination.Normalise()
+
+	deliverySet, total, err := svc.repo.Find(orgID, subscriptionID, filter)
+	if err != nil {
+		return nil, nil, databaseError("Unable to find webhook deliveries", err)
+	}
+
+	page := filter.Pagination
+	page.Total = total
+	return deliverySet, &page, nil
+}
+
+func (svc *webhookDeliveryService) FindByID(orgID uint64, subscriptionID uint64, id uint64) (*model.WebhookDelivery, *types.ApplicationError) {
+	delivery, err := svc.repo.FindByID(orgID, subscriptionID, id)
+	if err != nil {
+		return nil, databaseError("Unable to find webhook delivery by id", err)
+	}
+	return delivery, nil
+}
+
+// Posts the delivery's payload again as a new delivery with a fresh set of attempts
+func (svc *webhookDeliveryService) Redeliver(orgID uint64, subscriptionID uint64, id uint64, actorID uint64) (*model.WebhookDelivery, *types.ApplicationError) {
+	subscription, err := svc.subscriptionRepo.FindByID(orgID, subscriptionID)
+	if err != nil {
+		return nil, databaseError("Unable to find webhook subscription by id", err)
+	}
+
+	if !subscription.Enabled {
+		return nil, types.NewConflictError("Unable to redeliver webhook", fmt.Errorf("Subscription is disabled. Enable it before redelivering"))
+	}
+
+	original, appErr := svc.FindByID(orgID, subscriptionID, id)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	delivery, err := svc.repo.Redeliver(original, webhookDeliveryMaxAttempts)
+	if err != nil {
+		return nil, databaseError("Unable to redeliver webhook", err)
+	}
+
+	svc.audit.Record(actorID, &orgID, model.AuditEntityWebhookDelivery, delivery.ID, model.AuditActionCreate, nil, delivery)
+	webhookDeliveryWake.notify()
+
+	return delivery, nil
+}
+
+// Runs the workers posting queued deliveries until the process exits. Every instance may run
+// them, each delivery is posted by one worker at a time.
+func (svc *webhookDeliveryService) Run() {
+	queue := &leaseQueue[*model.WebhookDelivery]{
+		name:           "webhook delivery",
+		workersEnv:     "WEBHOOK_DELIVERY_WORKERS",
+		defaultWorkers: defaultWebhookDeliveryWorkers,
+		wake:           webhookDeliveryWake,
+		claim:          svc.repo.Claim,
+		process:        svc.deliver,
+	}
+	queue.run()
+}
+
+func (svc *webhookDeliveryService) deliver(delivery *model.WebhookDelivery) {
+	subscription, err := svc.subscriptionRepo.FindByID(delivery.OrganisationID, delivery.SubscriptionID)
+	if err == sql.ErrNoRows {
+		svc.bury(delivery, model.WebhookAttempt{Error: "Subscription was deleted"})
+		return
+	}
+	if err != nil {
+		warnLeaseRetry(fmt.Sprintf("find subscription of webhook delivery %d", delivery.ID), err)
+		return
+	}
+
+	if !subscription.Enabled {
+		svc.bury(delivery, model.WebhookAttempt{Error: "Subscription is disabled"})
+		return
+	}
+
+	// Subscriptions saved before URLs were checked may still point into the network
+	err = safehttp.CheckURL(subscription.URL, true)
+	if err != nil {
+		attempt := model.WebhookAttempt{Error: "Subscription URL is not allowed. Error: " + err.Error()}
+		svc.recordFailure(subscription, attempt)
+		svc.bury(delivery, attempt)
+		return
+	}
+
+	attempt := svc.post(subscription, delivery)
+	if attempt.Error == "" {
+		err = svc.repo.Succeed(delivery, attempt, time.Now())
+		if err != nil {
+			logger.Warning(fmt.Sprintf("Unable to complete webhook delivery %d, it is posted again once its lease runs out. Error: %s", delivery.ID, err.Error()))
+		}
+
+		err = svc.subscriptionRepo.RecordSuccess(subscription.ID)
+		if err != nil {
+			logger.Warning(fmt.Sprintf("Unable to reset failures of webhook subscription %d. Error: %s", subscription.ID, err.Error()))
+		}
+		return
+	}
+
+	svc.recordFailure(subscription, attempt)
+
+	if delivery.Attempts >= delivery.MaxAttempts {
+		svc.bury(delivery, attempt)
+		return
+	}
+
+	err = svc.repo.Retry(delivery, attempt, time.Now().Add(retryBackoff(delivery.Attempts, webhookDeliveryBaseDelay, webhookDeliveryMaxDelay)))
+	if err != nil {
+		warnLeaseRetry(fmt.Sprintf("reschedule webhook delivery %d", delivery.ID), err)
+	}
+}
+
+// Posts the payload signed with the subscription's secret. Any 2xx response counts as success.
+// Connections to local or private addresses are refused, redirects included, so nothing is
+// read from them.
+func (svc *webhookDeliveryService) post(subscription *model.WebhookSubscription, delivery *model.WebhookDelivery) model.WebhookAttempt {
+	var attempt model.WebhookAttempt
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, subscription.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(model.WebhookHeaderEventID, delivery.EventID)
+	req.Header.Set(model.WebhookHeaderEventType, delivery.EventType)
+	req.Header.Set(model.WebhookHeaderDelivery, strconv.FormatUint(delivery.ID, 10))
+	req.Header.Set(model.WebhookHeaderTimestamp, timestamp)
+	req.Header.Set(model.WebhookHeaderSignature, signWebhookPayload(subscription.Secret, timestamp, delivery.Payload))
+
+	started := time.Now()
+	res, err := svc.httpClient.Do(req)
+	attempt.DurationMs = int(time.Since(started).Milliseconds())
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	defer res.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(res.Body, maxWebhookResponseBody))
+	attempt.ResponseStatus = &res.StatusCode
+	attempt.ResponseBody = strings.ReplaceAll(strings.ToValidUTF8(string(body), ""), "\x00", "")
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		attempt.Error = fmt.Sprintf("Endpoint responded with status %d", res.StatusCode)
+	}
+	return attempt
+}
+
+func (svc *webhookDeliveryService) recordFailure(subscription *model.WebhookSubscription, attempt model.WebhookAttempt) {
+	reason := fmt.Sprintf("Disabled after %d failed attempts in a row. Last error: %s", webhookMaxConsecutiveFailures, attempt.Error)
+	updated, err := svc.subscriptionRepo.RecordFailure(subscription.ID, webhookMaxConsecutiveFailures, reason)
+	if err == sql.ErrNoRows {
+		return
+	}
+	if err != nil {
+		logger.Warning(fmt.Sprintf("Unable to count failure of webhook subscription %d. Error: %s", subscription.ID, err.Error()))
+		return
+	}
+
+	if !updated.Enabled {
+		logger.Warning(fmt.Sprintf("Disabled webhook subscription %d. %s", subscription.ID, reason))
+		svc.audit.Record(0, &subscription.OrganisationID, model.AuditEntityWebhookSubscription, subscription.ID, model.AuditActionUpdate, subscription.Redacted(), updated.Redacted())
+	}
+}
+
+func (svc *webhookDeliveryService) bury(delivery *model.WebhookDelivery, attempt model.WebhookAttempt) {
+	err := svc.repo.Bury(delivery, attempt, time.Now())
+	if err != nil {
+		warnLeaseRetry(fmt.Sprintf("give up webhook delivery %d", delivery.ID), err)
+		return
+	}
+
+	logger.Warning(fmt.Sprintf("Gave up webhook delivery %d after %d attempt(s). Error: %s", delivery.ID, delivery.Attempts, attempt.Error))
+}
+
+// Signature of the payload as sent in the signature header
+func signWebhookPayload(secret string, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
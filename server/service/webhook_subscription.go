@@ -0,0 +1,162 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+	"github.com/supermario64bit/whatsapp_connect/server/repository"
+	"github.com/supermario64bit/whatsapp_connect/types"
+)
+
+// Fields kept by the delivery workers, which clients cannot patch
+var webhookSubscriptionManagedFields = map[string]bool{
+	"consecutive_failures": true,
+	"disabled_reason":      true,
+	"disabled_at":          true,
+}
+
+type webhookSubscriptionService struct {
+	repo  repository.WebhookSubscriptionRepository
+	audit AuditService
+}
+
+// Subscriptions are returned without their secret, except when it was just set
+type WebhookSubscriptionService interface {
+	Create(orgID uint64, subscription *model.WebhookSubscription, actorID uint64) (*model.WebhookSubscription, *types.ApplicationError)
+	Find(orgID uint64) ([]*model.WebhookSubscription, *types.ApplicationError)
+	FindByID(orgID uint64, id uint64) (*model.WebhookSubscription, *types.ApplicationError)
+	PatchByID(orgID uint64, patch []byte, id uint64, version uint64, actorID uint64) (*model.WebhookSubscription, *types.ApplicationError)
+	DeleteByID(orgID uint64, id uint64, version uint64, actorID uint64) *types.ApplicationError
+}
+
+func NewWebhookSubscriptionService() WebhookSubscriptionService {
+	return &webhookSubscriptionService{
+		repo:  repository.NewWebhookSubscriptionRepository(),
+		audit: NewAuditService(),
+	}
+}
+
+// Creates the subscription, generating its secret when none is given
+func (svc *webhookSubscriptionService) Create(orgID uint64, subscription *model.WebhookSubscription, actorID uint64) (*model.WebhookSubscription, *types.ApplicationError) {
+	subscription.OrganisationID = orgID
+	subscription.Normalise()
+	if subscription.Secret == "" {
+		subscription.Secret = newWebhookSecret()
+	}
+
+	details := subscription.ValidateFields()
+	if len(details) > 0 {
+		return nil, types.NewFieldValidationError(details...)
+	}
+
+	new, err := svc.repo.Create(subscription)
+	if err != nil {
+		return nil, databaseError("Unable to create webhook subscription", err)
+	}
+
+	svc.audit.Record(actorID, &orgID, model.AuditEntityWebhookSubscription, new.ID, model.AuditActionCreate, nil, new.Redacted())
+
+	return new, nil
+}
+
+func (svc *webhookSubscriptionService) Find(orgID uint64) ([]*model.WebhookSubscription, *types.ApplicationError) {
+	subscriptionSet, err := svc.repo.Find(orgID)
+	if err != nil {
+		return nil, databaseError("Unable to find webhook subscriptions", err)
+	}
+
+	for i, subscription := range subscriptionSet {
+		subscriptionSet[i] = subscription.Redacted()
+	}
+	return subscriptionSet, nil
+}
+
+func (svc *webhookSubscriptionService) FindByID(orgID uint64, id uint64) (*model.WebhookSubscription, *types.ApplicationError) {
+	subscription, err := svc.repo.FindByID(orgID, id)
+	if err != nil {
+		return nil, databaseError("Unable to find webhook subscription by id", err)
+	}
+	return subscription.Redacted(), nil
+}
+
+// Applies an RFC 7396 merge patch. Setting the secret to null rotates it to a generated one.
+// Enabling a subscription the workers disabled clears its failures.
+func (svc *webhookSubscriptionService) PatchByID(orgID uint64, patch []byte, id uint64, version uint64, actorID uint64) (*model.WebhookSubscription, *types.ApplicationError) {
+	current, err := svc.repo.FindByID(orgID, id)
+	if err != nil {
+		return nil, databaseError("Unable to update webhook subscription", err)
+	}
+
+	if version > 0 && current.Version != version {
+		return nil, databaseError("Unable to update webhook subscription", repository.ErrVersionMismatch)
+	}
+
+	var patched model.WebhookSubscription
+	fields, appErr := applyMergePatch(current, patch, &patched)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	for _, field := range fields {
+		if webhookSubscriptionManagedFields[field] {
+			return nil, types.NewBadRequestError("Invalid Merge Patch", fmt.Errorf("Field %s is read only", field))
+		}
+	}
+
+	patched.Normalise()
+	if patched.Secret == "" {
+		patched.Secret = newWebhookSecret()
+	}
+
+	details := patched.ValidatePartial(fields)
+	if len(details) > 0 {
+		return nil, types.NewFieldValidationError(details...)
+	}
+
+	changes := changedColumns(current, &patched, fields)
+	if len(changes) == 0 {
+		return current.Redacted(), nil
+	}
+
+	if patched.Enabled && !current.Enabled {
+		changes["consecutive_failures"] = 0
+		changes["disabled_reason"] = ""
+		changes["disabled_at"] = nil
+	}
+
+	updatedSubscription, err := svc.repo.PatchByID(id, current.Version, changes)
+	if err != nil {
+		return nil, databaseError("Unable to update webhook subscription", err)
+	}
+
+	svc.audit.Record(actorID, &orgID, model.AuditEntityWebhookSubscription, updatedSubscription.ID, model.AuditActionUpdate, current.Redacted(), updatedSubscription.Redacted())
+
+	if _, rotated := changes["secret"]; rotated {
+		return updatedSubscription, nil
+	}
+	return updatedSubscription.Redacted(), nil
+}
+
+func (svc *webhookSubscriptionService) DeleteByID(orgID uint64, id uint64, version uint64, actorID uint64) *types.ApplicationError {
+	before, err := svc.repo.FindByID(orgID, id)
+	if err != nil {
+		return databaseError("Unable to delete webhook subscription", err)
+	}
+
+	err = svc.repo.DeleteByID(orgID, id, version)
+	if err != nil {
+		return databaseError("Unable to delete webhook subscription", err)
+	}
+
+	svc.audit.Record(actorID, &orgID, model.AuditEntityWebhookSubscription, id, model.AuditActionDelete, before.Redacted(), nil)
+
+	return nil
+}
+
+func newWebhookSecret() string {
+	secret := make([]byte, 32)
+	rand.Read(secret)
+	return hex.EncodeToString(secret)
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"github.com/supermario64bit/whatsapp_connect/server/model"
+	"github.com/supermario64bit/whatsapp_connect/server/repository"
+	"github.com/supermario64bit/whatsapp_connect/types"
+)
+
+type whatsAppAccountService struct {
+	repo  repository.WhatsAppAccountRepository
+	audit AuditService
+}
+
+type WhatsAppAccountService interface {
+	Create(orgID uint64, account *model.WhatsAppAccount, actorID uint64) (*model.WhatsAppAccount, *types.ApplicationError)
+	Find(orgID uint64) ([]*model.WhatsAppAccount, *types.ApplicationError)
+	FindByID(orgID uint64, id uint64) (*model.WhatsAppAccount, *types.ApplicationError)
+	FindByPhoneNumberID(phoneNumberID string) (*model.WhatsAppAccount, *types.ApplicationError)
+	UpdateLimits(orgID uint64, id uint64, version uint64, limits *model.WhatsAppAccountLimits, actorID uint64) (*model.WhatsAppAccount, *types.ApplicationError)
+	DeleteByID(orgID uint64, id uint64, version uint64, actorID uint64) *types.ApplicationError
+}
+
+func NewWhatsAppAccountService() WhatsAppAccountService {
+	return &whatsAppAccountService{
+		repo:  repository.NewWhatsAppAccountRepository(),
+		audit: NewAuditService(),
+	}
+}
+
+func (svc *whatsAppAccountService) Create(orgID uint64, account *model.WhatsAppAccount, actorID uint64) (*model.WhatsAppAccount, *types.ApplicationError) {
+	account.OrganisationID = orgID
+	account.Normalise()
+	validationErrors := account.ValidateFields()
+	if len(validationErrors) > 0 {
+		return nil, types.NewValidationError(validationErrors)
+	}
+
+	new, err := svc.repo.Create(account)
+	if err != nil {
+		return nil, databaseError("Unable to create whatsapp account", err)
+	}
+
+	svc.audit.Record(actorID, &orgID, model.AuditEntityWhatsAppAccount, new.ID, model.AuditActionCreate, nil, new.Redacted())
+
+	return new, nil
+}
+
+func (svc *whatsAppAccountService) Find(orgID uint64) ([]*model.WhatsAppAccount, *types.ApplicationError) {
+	accountSet, err := svc.repo.Find(orgID)
+	if err != nil {
+		return nil, databaseError("Unable to find whatsapp accounts", err)
+	}
+	return accountSet, nil
+}
+
+func (svc *whatsAppAccountService) FindByID(orgID uint64, id uint64) (*model.WhatsAppAccount, *types.ApplicationError) {
+	account, err := svc.repo.FindByID(orgID, id)
+	if err != nil {
+		return nil, databaseError("Unable to find whatsapp account by id", err)
+	}
+	return account, nil
+}
+
+func (svc *whatsAppAccountService) FindByPhoneNumberID(phoneNumberID string) (*model.WhatsAppAccount, *types.ApplicationError) {
+	account, err := svc.repo.FindByPhoneNumberID(phoneNumberID)
+	if err != nil {
+		return nil, databaseError("Unable to find whatsapp account by phone number id", err)
+	}
+	return account, nil
+}
+
+// Sets the sending limits the send queue holds the account to
+func (svc *whatsAppAccountService) UpdateLimits(orgID uint64, id uint64, version uint64, limits *model.WhatsAppAccountLimits, actorID uint64) (*model.WhatsAppAccount, *types.ApplicationError) {
+	limits.Normalise()
+	validationErrors := limits.ValidateFields()
+	if len(validationErrors) > 0 {
+		return nil, types.NewValidationError(validationErrors)
+	}
+
+	before, err := svc.repo.FindByID(orgID, id)
+	if err != nil {
+		return nil, databaseError("Unable to update whatsapp account limits", err)
+	}
+
+	updated, err := svc.repo.UpdateLimits(id, version, limits)
+	if err != nil {
+		return nil, databaseError("Unable to update whatsapp account limits", err)
+	}
+
+	svc.audit.Record(actorID, &orgID, model.AuditEntityWhatsAppAccount, id, model.AuditActionUpdate, before.Redacted(), updated.Redacted())
+
+	return updated, nil
+}
+
+func (svc *whatsAppAccountService) DeleteByID(orgID uint64, id uint64, version uint64, actorID uint64) *types.ApplicationError {
+	before, err := svc.repo.FindByID(orgID, id)
+	if err != nil {
+		return databaseError("Unable to delete whatsapp account", err)
+	}
+
+	err = svc.repo.DeleteByID(orgID, id, version)
+	if err != nil {
+		return databaseError("Unable to delete whatsapp account", err)
+	}
+
+	svc.audit.Record(actorID, &orgID, model.AuditEntityWhatsAppAccount, id, model.AuditActionDelete, before.Redacted(), nil)
+
+	return nil
+}
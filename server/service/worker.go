@@ -0,0 +1,91 @@
+package service
+
+import (
+	"database/sql"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/supermario64bit/whatsapp_connect/pkg/logger"
+)
+
+const (
+	// How long a worker holds a claimed item before another may take it over
+	queueLease = time.Minute
+	// How often idle workers look for due items
+	queuePollInterval = time.Second
+)
+
+// Wakes an idle worker of this instance when work is stored, so it is taken up without waiting
+// for the next poll
+type queueWake chan struct{}
+
+func newQueueWake() queueWake {
+	return make(queueWake, 1)
+}
+
+func (wake queueWake) notify() {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+// Work stored in a table and claimed by a pool of workers, such as message jobs. Every instance
+// may run the pool. A claimed item is leased to one worker, and taken again by any worker once
+// the lease runs out, as when the worker holding it stopped.
+type leaseQueue[T any] struct {
+	// Names an item in log lines, e.g. "message job"
+	name string
+	// Env var setting the number of workers
+	workersEnv     string
+	defaultWorkers int
+	wake           queueWake
+	// Returns the next due item leased for the given time, or sql.ErrNoRows when none is due
+	claim   func(lease time.Duration) (T, error)
+	process func(item T)
+}
+
+// Runs the workers until the process exits
+func (queue *leaseQueue[T]) run() {
+	workers := queue.defaultWorkers
+	if n, err := strconv.Atoi(os.Getenv(queue.workersEnv)); err == nil && n > 0 {
+		workers = n
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			queue.work()
+		}()
+	}
+	wg.Wait()
+}
+
+func (queue *leaseQueue[T]) work() {
+	for {
+		item, err := queue.claim(queueLease)
+		if err != nil {
+			if err != sql.ErrNoRows {
+				logger.Warning("Unable to take " + queue.name + ". Error: " + err.Error())
+			}
+
+			select {
+			case <-queue.wake:
+			case <-time.After(queuePollInterval):
+			}
+			continue
+		}
+
+		queue.process(item)
+	}
+}
+
+// Logs a failure to record what became of a claimed item. The item is left leased, so it is
+// processed again once the lease runs out.
+func warnLeaseRetry(action string, err error) {
+	logger.Warning("Unable to " + action + ", it is retried once its lease runs out. Error: " + err.Error())
+}
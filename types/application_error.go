@@ -1,36 +1,265 @@
 package types
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/supermario64bit/whatsapp_connect/pkg/logger"
 )
 
+const ProblemContentType = "application/problem+json"
+
+// Stable, machine readable error codes. Clients may branch on these, so never rename one.
+type ErrorCode string
+
+const (
+	CodeValidationFailed     ErrorCode = "VALIDATION_FAILED"
+	CodeBadRequest           ErrorCode = "BAD_REQUEST"
+	CodeNotFound             ErrorCode = "NOT_FOUND"
+	CodeUnauthorized         ErrorCode = "UNAUTHORIZED"
+	CodeForbidden            ErrorCode = "FORBIDDEN"
+	CodeConflict             ErrorCode = "CONFLICT"
+	CodePreconditionFailed   ErrorCode = "PRECONDITION_FAILED"
+	CodePreconditionRequired ErrorCode = "PRECONDITION_REQUIRED"
+	CodeUnsupportedMediaType ErrorCode = "UNSUPPORTED_MEDIA_TYPE"
+	CodeUpstreamFailed       ErrorCode = "UPSTREAM_FAILED"
+	CodeInternal             ErrorCode = "INTERNAL_ERROR"
+)
+
+// Describes a problem with a single request field
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
 type ApplicationError struct {
 	HttpStatus int
+	Code       ErrorCode
 	Message    string
 	Err        error
+	Details    []FieldError
+}
+
+// RFC 7807 problem details body, extended with the error code and field errors
+type problem struct {
+	Type   string       `json:"type"`
+	Title  string       `json:"title"`
+	Status int          `json:"status"`
+	Detail string       `json:"detail,omitempty"`
+	Code   ErrorCode    `json:"code"`
+	Errors []FieldError `json:"errors,omitempty"`
+}
+
+func (err *ApplicationError) Error() string {
+	if err.Err == nil {
+		return err.Message
+	}
+	return err.Message + ". Error: " + err.Err.Error()
+}
+
+func (err *ApplicationError) Unwrap() error {
+	return err.Err
 }
 
 func (err *ApplicationError) WriteHttpResponse(c *gin.Context) {
-	if err.HttpStatus == 0 || err.Message == "" {
-		c.JSON(http.StatusNoContent, gin.H{"message": "No content"})
-		return
+	status := err.HttpStatus
+	if status == 0 {
+		status = http.StatusInternalServerError
 	}
 
-	var resp gin.H
-	if err == nil {
-		resp = gin.H{
-			"status":  "failed",
-			"message": err.Message,
-		}
-	} else {
-		resp = gin.H{
-			"status":  "failed",
-			"message": err.Message,
-			"result":  gin.H{"error": err.Err.Error()},
+	code := err.Code
+	if code == "" {
+		code = CodeInternal
+	}
+
+	body := problem{
+		Type:   "/problems/" + strings.ReplaceAll(strings.ToLower(string(code)), "_", "-"),
+		Title:  err.Message,
+		Status: status,
+		Code:   code,
+		Errors: err.Details,
+	}
+	// Server side causes may carry SQL, upstream responses or other internals, so clients only
+	// get them for their own mistakes and the rest goes to the log
+	if status >= http.StatusInternalServerError {
+		logger.Danger(c.Request.Method + " " + c.Request.URL.Path + " failed with " + strconv.Itoa(status) + ". Error: " + err.Error())
+	} else if err.Err != nil {
+		body.Detail = err.Err.Error()
+	}
+
+	c.Header("Content-Type", ProblemContentType)
+	c.AbortWithStatusJSON(status, body)
+}
+
+func NewValidationError(errs []error) *ApplicationError {
+	details := make([]FieldError, 0, len(errs))
+	for _, err := range errs {
+		var fieldErr validator.FieldError
+		if errors.As(err, &fieldErr) {
+			details = append(details, FieldError{
+				Field:   fieldPath(fieldErr),
+				Rule:    fieldErr.Tag(),
+				Message: validationMessage(fieldErr),
+			})
+			continue
 		}
+		details = append(details, FieldError{Message: err.Error()})
+	}
+
+	return NewFieldValidationError(details...)
+}
+
+// Error for a well formed request whose fields do not pass validation. Answered with 422, so
+// clients can tell it from a body which could not be read at all.
+func NewFieldValidationError(details ...FieldError) *ApplicationError {
+	return &ApplicationError{
+		HttpStatus: http.StatusUnprocessableEntity,
+		Code:       CodeValidationFailed,
+		Message:    "Validation Failed",
+		Err:        fmt.Errorf("%d field(s) failed validation", len(details)),
+		Details:    details,
+	}
+}
+
+func NewBadRequestError(message string, err error) *ApplicationError {
+	return &ApplicationError{
+		HttpStatus: http.StatusBadRequest,
+		Code:       CodeBadRequest,
+		Message:    message,
+		Err:        err,
+	}
+}
+
+func NewNotFoundError(message string, err error) *ApplicationError {
+	return &ApplicationError{
+		HttpStatus: http.StatusNotFound,
+		Code:       CodeNotFound,
+		Message:    message,
+		Err:        err,
+	}
+}
+
+func NewUnauthorizedError(message string, err error) *ApplicationError {
+	return &ApplicationError{
+		HttpStatus: http.StatusUnauthorized,
+		Code:       CodeUnauthorized,
+		Message:    message,
+		Err:        err,
+	}
+}
+
+func NewForbiddenError(message string, err error) *ApplicationError {
+	return &ApplicationError{
+		HttpStatus: http.StatusForbidden,
+		Code:       CodeForbidden,
+		Message:    message,
+		Err:        err,
+	}
+}
+
+func NewConflictError(message string, err error, details ...FieldError) *ApplicationError {
+	return &ApplicationError{
+		HttpStatus: http.StatusConflict,
+		Code:       CodeConflict,
+		Message:    message,
+		Err:        err,
+		Details:    details,
+	}
+}
+
+func NewPreconditionFailedError(message string, err error) *ApplicationError {
+	return &ApplicationError{
+		HttpStatus: http.StatusPreconditionFailed,
+		Code:       CodePreconditionFailed,
+		Message:    message,
+		Err:        err,
+	}
+}
+
+func NewPreconditionRequiredError(message string, err error) *ApplicationError {
+	return &ApplicationError{
+		HttpStatus: http.StatusPreconditionRequired,
+		Code:       CodePreconditionRequired,
+		Message:    message,
+		Err:        err,
+	}
+}
+
+func NewUnsupportedMediaTypeError(message string, err error) *ApplicationError {
+	return &ApplicationError{
+		HttpStatus: http.StatusUnsupportedMediaType,
+		Code:       CodeUnsupportedMediaType,
+		Message:    message,
+		Err:        err,
 	}
+}
+
+// Reports a failure of a service this one depends on, such as the WhatsApp Cloud API
+func NewUpstreamError(message string, err error) *ApplicationError {
+	return &ApplicationError{
+		HttpStatus: http.StatusBadGateway,
+		Code:       CodeUpstreamFailed,
+		Message:    message,
+		Err:        err,
+	}
+}
 
-	c.JSON(err.HttpStatus, resp)
+func NewInternalError(message string, err error) *ApplicationError {
+	return &ApplicationError{
+		HttpStatus: http.StatusInternalServerError,
+		Code:       CodeInternal,
+		Message:    message,
+		Err:        err,
+	}
+}
+
+// Names the field by its path below the validated struct, e.g. template.name, so fields of
+// nested structs stay distinguishable
+func fieldPath(err validator.FieldError) string {
+	_, path, ok := strings.Cut(err.Namespace(), ".")
+	if !ok {
+		return err.Field()
+	}
+	return path
+}
+
+func validationMessage(err validator.FieldError) string {
+	switch err.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "numeric":
+		return "must be numeric"
+	case "oneof":
+		return "must be one of: " + strings.ReplaceAll(err.Param(), " ", ", ")
+	case "phone":
+		return "must be a valid phone number in E.164 format, e.g. +919876543210"
+	case "url":
+		return "must be a valid URL"
+	case "gte":
+		return "must be at least " + err.Param()
+	case "lte":
+		return "must be at most " + err.Param()
+	case "public_https_url":
+		return "must be an https URL which does not point to a local or private address"
+	case "template_name":
+		return "must contain only lowercase letters, digits and underscores"
+	case "hexcolor":
+		return "must be a hex colour, e.g. #25d366"
+	case "len":
+		return "must be exactly " + err.Param() + " characters long"
+	case "min":
+		return "must be at least " + err.Param() + " characters long"
+	case "max":
+		return "must be at most " + err.Param() + " characters long"
+	default:
+		return "failed the '" + err.Tag() + "' rule"
+	}
 }